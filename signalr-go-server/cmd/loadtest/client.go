@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// client is a load test connection talking the json hub protocol
+type client struct {
+	conn    net.Conn
+	reader  *bufio.Reader
+	writeMx sync.Mutex
+	stats   *stats
+	payload string
+}
+
+func newClient(conn net.Conn, stats *stats, payload string) (*client, error) {
+	c := &client{conn: conn, reader: bufio.NewReader(conn), stats: stats, payload: payload}
+	if err := c.write(map[string]interface{}{"protocol": "json", "version": 1}); err != nil {
+		return nil, err
+	}
+	response, err := c.reader.ReadBytes(30)
+	if err != nil {
+		return nil, err
+	}
+	if strings.Contains(string(response), `"error"`) {
+		return nil, fmt.Errorf("handshake failed: %s", strings.TrimSuffix(string(response), "\u001e"))
+	}
+	return c, nil
+}
+
+func (c *client) write(message interface{}) error {
+	data, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+	c.writeMx.Lock()
+	defer c.writeMx.Unlock()
+	_, err = c.conn.Write(append(data, 30))
+	return err
+}
+
+// receive reads the messages of the server until the connection ends and records the latencies they carry
+func (c *client) receive() {
+	for {
+		data, err := c.reader.ReadBytes(30)
+		if err != nil {
+			return
+		}
+		var message struct {
+			Type      int               `json:"type"`
+			Target    string            `json:"target"`
+			Arguments []json.RawMessage `json:"arguments"`
+			Result    json.RawMessage   `json:"result"`
+			Error     string            `json:"error"`
+		}
+		if err = json.Unmarshal(data[:len(data)-1], &message); err != nil {
+			c.stats.fail(err)
+			continue
+		}
+		switch {
+		case message.Type == 3 && message.Error != "":
+			c.stats.fail(fmt.Errorf("invocation failed: %v", message.Error))
+		case message.Type == 3 && len(message.Result) > 0:
+			c.stats.record(opInvoke, message.Result)
+		case message.Type == 1 && message.Target == "broadcast" && len(message.Arguments) > 0:
+			c.stats.record(opBroadcast, message.Arguments[0])
+		case message.Type == 7:
+			c.stats.fail(fmt.Errorf("closed by the server: %v", message.Error))
+			return
+		}
+	}
+}
+
+// drive sends rate operations per second until done is closed. broadcast is the share of broadcasts
+func (c *client) drive(rate float64, broadcast float64, done <-chan struct{}) {
+	interval := time.Duration(float64(time.Second) / rate)
+	// Spread the clients over the interval, so they do not send in lockstep
+	select {
+	case <-time.After(time.Duration(rand.Int63n(int64(interval)))):
+	case <-done:
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	invocationID := 0
+	for {
+		select {
+		case <-ticker.C:
+		case <-done:
+			return
+		}
+		sentAt := strconv.FormatInt(time.Now().UnixNano(), 10)
+		var err error
+		if rand.Float64() < broadcast {
+			err = c.write(map[string]interface{}{"type": 1, "target": "broadcast", "arguments": []interface{}{sentAt, c.payload}})
+			c.stats.sent(opBroadcast)
+		} else {
+			invocationID++
+			err = c.write(map[string]interface{}{"type": 1, "invocationId": strconv.Itoa(invocationID), "target": "echo",
+				"arguments": []interface{}{sentAt, c.payload}})
+			c.stats.sent(opInvoke)
+		}
+		if err != nil {
+			c.stats.fail(err)
+			return
+		}
+	}
+}
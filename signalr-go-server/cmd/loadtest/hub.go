@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+
+	"../../pkg/signalr"
+)
+
+// loadHub is the hub driven by the load test. The arguments carry the time the client sent the invocation,
+// so the clients can measure the latency of the results and broadcasts
+type loadHub struct {
+	signalr.Hub
+}
+
+// Echo returns sentAt, the client measures the round trip of the invocation with it
+func (l *loadHub) Echo(sentAt string, payload string) string {
+	return sentAt
+}
+
+// Broadcast sends sentAt and payload to all clients
+func (l *loadHub) Broadcast(sentAt string, payload string) {
+	l.Clients().All().Send("broadcast", sentAt, payload)
+}
+
+// serve serves the load hub on address at /load, for load tests of a remote server
+func serve(address string) error {
+	mux := http.NewServeMux()
+	signalr.MapHub(mux, "/load", &loadHub{})
+	fmt.Printf("Serving the load hub on ws://%v/load\n", address)
+	return http.ListenAndServe(address, mux)
+}
+
+// pipeConnection is an in-process connection of the server to a load test client
+type pipeConnection struct {
+	net.Conn
+	connectionID string
+}
+
+func (p *pipeConnection) ConnectionID() string {
+	return p.connectionID
+}
+
+// inProcess returns a dialer which connects clients to an in-process server of the load hub
+func inProcess() func(id int) (net.Conn, error) {
+	server := signalr.NewServer(&loadHub{})
+	return func(id int) (net.Conn, error) {
+		serverSide, clientSide := net.Pipe()
+		go func() {
+			server.Run(&pipeConnection{Conn: serverSide, connectionID: fmt.Sprintf("load-%v", id)})
+			_ = serverSide.Close()
+		}()
+		return clientSide, nil
+	}
+}
@@ -0,0 +1,89 @@
+// Command loadtest drives a mix of invocations and broadcasts through many connections of a SignalR server and
+// reports their latency percentiles and the memory used, to plan capacity and to measure regressions.
+//
+// By default, it runs the server in-process. To test a remote server, run
+//
+//	loadtest -listen :8090
+//
+// on the server machine, and
+//
+//	loadtest -url ws://server:8090/load
+//
+// on the load generating machines. The invocations and broadcasts of each client are sent at a fixed rate,
+// the latency of a broadcast is measured at each client receiving it
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+func main() {
+	clients := flag.Int("clients", 100, "number of client connections")
+	duration := flag.Duration("duration", 30*time.Second, "duration of the load")
+	rate := flag.Float64("rate", 10, "operations per second of each client")
+	broadcast := flag.Float64("broadcast", 0.1, "share of the operations which are broadcasts to all clients, the others are invocations with result")
+	payload := flag.Int("payload", 64, "size of the payload of each operation in bytes")
+	url := flag.String("url", "", "WebSocket URL of the load hub of a remote server, the server runs in-process if empty")
+	origin := flag.String("origin", "http://localhost/", "origin of the WebSocket connections to a remote server")
+	listen := flag.String("listen", "", "serve the load hub on this address instead of running a load test")
+	flag.Parse()
+
+	if *listen != "" {
+		log.Fatal(serve(*listen))
+	}
+	if *clients <= 0 || *rate <= 0 {
+		log.Fatal("clients and rate must be positive")
+	}
+
+	dial := inProcess()
+	if *url != "" {
+		dial = func(int) (net.Conn, error) {
+			return websocket.Dial(*url, "", *origin)
+		}
+	}
+	s := &stats{}
+	connections := make([]*client, 0, *clients)
+	for i := 0; i < *clients; i++ {
+		conn, err := dial(i)
+		if err != nil {
+			log.Fatalf("connecting client %v: %v", i, err)
+		}
+		c, err := newClient(conn, s, strings.Repeat("x", *payload))
+		if err != nil {
+			log.Fatalf("connecting client %v: %v", i, err)
+		}
+		connections = append(connections, c)
+		go c.receive()
+	}
+	fmt.Printf("%v clients, %v, %v operations/s per client, %.0f%% broadcasts\n", *clients, *duration, *rate, *broadcast*100)
+
+	done := make(chan struct{})
+	go s.sampleMemory(done)
+	var wg sync.WaitGroup
+	for _, c := range connections {
+		wg.Add(1)
+		go func(c *client) {
+			defer wg.Done()
+			c.drive(*rate, *broadcast, done)
+		}(c)
+	}
+	time.Sleep(*duration)
+	close(done)
+	wg.Wait()
+	// Give the last results and broadcasts time to arrive
+	time.Sleep(time.Second)
+	s.sampleOnce()
+	for _, c := range connections {
+		_ = c.conn.Close()
+	}
+	s.report(os.Stdout, *duration)
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"runtime"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// The operations of the load test
+const (
+	opInvoke = iota
+	opBroadcast
+)
+
+var opNames = []string{"invoke", "broadcast"}
+
+// maxErrors is the number of errors kept for the report
+const maxErrors = 5
+
+// stats collects the results of a load test
+type stats struct {
+	mx        sync.Mutex
+	sends     [2]int
+	latencies [2][]time.Duration
+	errors    int
+	firstErrs []string
+	// heapPeak and goroutinePeak are the maximum memory and goroutines sampled during the test
+	heapPeak      uint64
+	goroutinePeak int
+}
+
+func (s *stats) sent(op int) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.sends[op]++
+}
+
+// record records the latency of an operation from the send time in the message
+func (s *stats) record(op int, sentAt json.RawMessage) {
+	var value string
+	if err := json.Unmarshal(sentAt, &value); err != nil {
+		s.fail(err)
+		return
+	}
+	nanos, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		s.fail(err)
+		return
+	}
+	latency := time.Since(time.Unix(0, nanos))
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.latencies[op] = append(s.latencies[op], latency)
+}
+
+func (s *stats) fail(err error) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.errors++
+	if len(s.firstErrs) < maxErrors {
+		s.firstErrs = append(s.firstErrs, err.Error())
+	}
+}
+
+// sampleMemory samples the memory and goroutines of the process until done is closed
+func (s *stats) sampleMemory(done <-chan struct{}) {
+	ticker := time.NewTicker(500 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		s.sampleOnce()
+		select {
+		case <-ticker.C:
+		case <-done:
+			return
+		}
+	}
+}
+
+func (s *stats) sampleOnce() {
+	var memStats runtime.MemStats
+	runtime.ReadMemStats(&memStats)
+	goroutines := runtime.NumGoroutine()
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	if memStats.HeapInuse > s.heapPeak {
+		s.heapPeak = memStats.HeapInuse
+	}
+	if goroutines > s.goroutinePeak {
+		s.goroutinePeak = goroutines
+	}
+}
+
+// report writes the operations with their latency percentiles, the errors and the memory of the test to w
+func (s *stats) report(w io.Writer, duration time.Duration) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	received := []string{"completed", "delivered"}
+	for op, name := range opNames {
+		latencies := s.latencies[op]
+		sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+		fmt.Fprintf(w, "%-10v sent %v (%.0f/s), %v %v", name, s.sends[op], float64(s.sends[op])/duration.Seconds(), received[op], len(latencies))
+		if len(latencies) > 0 {
+			fmt.Fprintf(w, ", latency p50 %v p90 %v p99 %v max %v", percentile(latencies, 50), percentile(latencies, 90),
+				percentile(latencies, 99), latencies[len(latencies)-1].Round(time.Microsecond))
+		}
+		fmt.Fprintln(w)
+	}
+	fmt.Fprintf(w, "%-10v %v\n", "errors", s.errors)
+	for _, err := range s.firstErrs {
+		fmt.Fprintf(w, "  %v\n", err)
+	}
+	fmt.Fprintf(w, "%-10v heap in use peak %.1fMB, goroutines peak %v\n", "memory", float64(s.heapPeak)/(1<<20), s.goroutinePeak)
+}
+
+// percentile returns the p-th percentile of the sorted latencies
+func percentile(latencies []time.Duration, p int) time.Duration {
+	return latencies[(len(latencies)-1)*p/100].Round(time.Microsecond)
+}
@@ -8,6 +8,7 @@ import (
 	"strings"
 	"time"
 
+	"../pkg/rooms"
 	"../pkg/signalr"
 )
 
@@ -118,7 +119,13 @@ func runHTTP(address string, hub signalr.HubInterface) {
 	router := http.NewServeMux()
 	router.Handle("/", http.FileServer(http.Dir("../public")))
 
-	signalr.MapHub(router, "/chat", hub)
+	server := signalr.MapHub(router, "/chat", hub)
+	router.Handle("/healthz", server.HealthHandler())
+	router.Handle("/readyz", server.ReadinessHandler())
+	// Only answered for requests from the local host
+	router.Handle("/debug/signalr", server.DebugHandler(nil))
+	// The chat rooms of rooms.html
+	signalr.MapHub(router, "/rooms", rooms.NewHub(rooms.Options{}))
 
 	fmt.Printf("Listening for websocket connections on %s\n", address)
 
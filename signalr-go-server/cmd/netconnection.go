@@ -4,6 +4,7 @@ import (
 	"crypto/rand"
 	"encoding/base64"
 	"net"
+	"time"
 )
 
 type netConnection struct {
@@ -27,6 +28,14 @@ func (w *netConnection) Read(p []byte) (n int, err error) {
 	return w.conn.Read(p)
 }
 
+func (w *netConnection) SetWriteDeadline(t time.Time) error {
+	return w.conn.SetWriteDeadline(t)
+}
+
+func (w *netConnection) Close() error {
+	return w.conn.Close()
+}
+
 func getConnectionID() string {
 	bytes := make([]byte, 16)
 	rand.Read(bytes)
@@ -0,0 +1,65 @@
+package rooms
+
+import (
+	"sync"
+
+	"../signalr"
+)
+
+// Hub is a chat hub with rooms. Clients call Join, Leave and Send, and receive the messages and the presence
+// of the rooms they have joined, see Options. A connection is named by the user ID of its connection context,
+// or by its connection ID if it has no user. Connections leave their rooms when they disconnect
+type Hub struct {
+	signalr.Hub
+	options Options
+	once    sync.Once
+	rooms   *Rooms
+}
+
+// NewHub creates a Hub
+func NewHub(options Options) *Hub {
+	return &Hub{options: options}
+}
+
+// Initialize initializes the hub and creates its Rooms
+func (h *Hub) Initialize(hubContext signalr.HubContext) {
+	h.Hub.Initialize(hubContext)
+	h.once.Do(func() {
+		h.rooms = New(hubContext, h.options)
+	})
+}
+
+// Rooms returns the rooms of the hub. It is nil until the hub has been initialized by the server
+func (h *Hub) Rooms() *Rooms {
+	return h.rooms
+}
+
+// OnDisconnected removes the connection from its rooms
+func (h *Hub) OnDisconnected(connectionID string) {
+	h.rooms.LeaveAll(connectionID)
+}
+
+// Join joins the room and returns its history
+func (h *Hub) Join(ctx signalr.ConnectionContext, room string) ([]Message, error) {
+	user := ctx.UserID()
+	if user == "" {
+		user = ctx.ConnectionID()
+	}
+	return h.rooms.Join(room, ctx.ConnectionID(), user)
+}
+
+// Leave leaves the room
+func (h *Hub) Leave(ctx signalr.ConnectionContext, room string) {
+	h.rooms.Leave(room, ctx.ConnectionID())
+}
+
+// Send sends text to the room
+func (h *Hub) Send(ctx signalr.ConnectionContext, room string, text string) error {
+	_, err := h.rooms.Send(room, ctx.ConnectionID(), text)
+	return err
+}
+
+// Members returns the users present in the room
+func (h *Hub) Members(room string) []string {
+	return h.rooms.Members(room)
+}
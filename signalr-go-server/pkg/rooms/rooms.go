@@ -0,0 +1,212 @@
+// Package rooms provides chat rooms on top of the groups of a signalr hub: joining and leaving rooms,
+// sending messages to a room, a bounded history of each room for members joining later,
+// and the presence of the users in each room. Hub is a ready to use hub, Rooms can be used by own hubs
+package rooms
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"../signalr"
+)
+
+// ErrNotMember is returned when a connection sends to a room it has not joined
+var ErrNotMember = errors.New("not a member of the room")
+
+// ErrEmptyMessage is returned when an empty message is sent
+var ErrEmptyMessage = errors.New("empty message")
+
+// Message is a message sent to a room
+type Message struct {
+	Room string    `json:"room"`
+	User string    `json:"user"`
+	Text string    `json:"text"`
+	Sent time.Time `json:"sent"`
+}
+
+// Options configure Rooms. Zero values select the defaults
+type Options struct {
+	// HistorySize is the number of messages kept for each room, default 50
+	HistorySize int
+	// MessageTarget is the client method receiving the messages of a room, default "message"
+	MessageTarget string
+	// PresenceTarget is the client method receiving the room name and the users present in the room
+	// when users join or leave it, default "presence"
+	PresenceTarget string
+}
+
+const (
+	defaultHistorySize    = 50
+	defaultMessageTarget  = "message"
+	defaultPresenceTarget = "presence"
+	// groupPrefix separates the groups of rooms from other groups of the hub
+	groupPrefix = "room:"
+)
+
+// Rooms keeps the rooms of a hub. Rooms are created when the first connection joins them
+// and removed with their last member
+type Rooms struct {
+	mx      sync.Mutex
+	hub     signalr.HubContext
+	options Options
+	rooms   map[string]*room
+	now     func() time.Time
+}
+
+type room struct {
+	// members are the users of the connections in the room by connection ID
+	members map[string]string
+	history []Message
+}
+
+// New creates Rooms using the groups of hubContext
+func New(hubContext signalr.HubContext, options Options) *Rooms {
+	if options.HistorySize == 0 {
+		options.HistorySize = defaultHistorySize
+	}
+	if options.MessageTarget == "" {
+		options.MessageTarget = defaultMessageTarget
+	}
+	if options.PresenceTarget == "" {
+		options.PresenceTarget = defaultPresenceTarget
+	}
+	return &Rooms{hub: hubContext, options: options, rooms: make(map[string]*room), now: time.Now}
+}
+
+// Join adds a connection of user to a room and tells the members of the room who is present.
+// It returns the history of the room
+func (r *Rooms) Join(roomName string, connectionID string, user string) ([]Message, error) {
+	if err := r.hub.Groups().AddToGroup(groupPrefix+roomName, connectionID); err != nil {
+		return nil, err
+	}
+	r.mx.Lock()
+	rm, ok := r.rooms[roomName]
+	if !ok {
+		rm = &room{members: make(map[string]string)}
+		r.rooms[roomName] = rm
+	}
+	rm.members[connectionID] = user
+	history := append([]Message(nil), rm.history...)
+	members := rm.users()
+	r.mx.Unlock()
+	r.hub.Clients().Group(groupPrefix+roomName).Send(r.options.PresenceTarget, roomName, members)
+	return history, nil
+}
+
+// Leave removes a connection from a room and tells the remaining members who is present
+func (r *Rooms) Leave(roomName string, connectionID string) {
+	r.hub.Groups().RemoveFromGroup(groupPrefix+roomName, connectionID)
+	r.mx.Lock()
+	rm, ok := r.rooms[roomName]
+	if !ok {
+		r.mx.Unlock()
+		return
+	}
+	if _, ok := rm.members[connectionID]; !ok {
+		r.mx.Unlock()
+		return
+	}
+	delete(rm.members, connectionID)
+	if len(rm.members) == 0 {
+		delete(r.rooms, roomName)
+		r.mx.Unlock()
+		return
+	}
+	members := rm.users()
+	r.mx.Unlock()
+	r.hub.Clients().Group(groupPrefix+roomName).Send(r.options.PresenceTarget, roomName, members)
+}
+
+// LeaveAll removes a connection from all its rooms, e.g. in OnDisconnected of the hub
+func (r *Rooms) LeaveAll(connectionID string) {
+	for _, roomName := range r.RoomsOf(connectionID) {
+		r.Leave(roomName, connectionID)
+	}
+}
+
+// Send sends text from a connection to the members of a room and adds it to the history of the room
+func (r *Rooms) Send(roomName string, connectionID string, text string) (Message, error) {
+	if text == "" {
+		return Message{}, ErrEmptyMessage
+	}
+	r.mx.Lock()
+	rm, ok := r.rooms[roomName]
+	var user string
+	if ok {
+		user, ok = rm.members[connectionID]
+	}
+	if !ok {
+		r.mx.Unlock()
+		return Message{}, ErrNotMember
+	}
+	message := Message{Room: roomName, User: user, Text: text, Sent: r.now()}
+	rm.history = append(rm.history, message)
+	if len(rm.history) > r.options.HistorySize {
+		rm.history = rm.history[len(rm.history)-r.options.HistorySize:]
+	}
+	r.mx.Unlock()
+	r.hub.Clients().Group(groupPrefix+roomName).Send(r.options.MessageTarget, message)
+	return message, nil
+}
+
+// History returns the kept messages of a room, the oldest first
+func (r *Rooms) History(roomName string) []Message {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	if rm, ok := r.rooms[roomName]; ok {
+		return append([]Message(nil), rm.history...)
+	}
+	return nil
+}
+
+// Members returns the users present in a room, each user once
+func (r *Rooms) Members(roomName string) []string {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	if rm, ok := r.rooms[roomName]; ok {
+		return rm.users()
+	}
+	return nil
+}
+
+// Names returns the names of the rooms with members
+func (r *Rooms) Names() []string {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	names := make([]string, 0, len(r.rooms))
+	for name := range r.rooms {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// RoomsOf returns the names of the rooms a connection has joined
+func (r *Rooms) RoomsOf(connectionID string) []string {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	var names []string
+	for name, rm := range r.rooms {
+		if _, ok := rm.members[connectionID]; ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// users returns the sorted users of the members
+func (rm *room) users() []string {
+	present := make(map[string]bool)
+	users := make([]string, 0, len(rm.members))
+	for _, user := range rm.members {
+		if !present[user] {
+			present[user] = true
+			users = append(users, user)
+		}
+	}
+	sort.Strings(users)
+	return users
+}
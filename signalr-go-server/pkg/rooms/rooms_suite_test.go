@@ -0,0 +1,98 @@
+package rooms
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"testing"
+
+	"../signalr"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestRooms(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Rooms Suite")
+}
+
+// pipeConnection is a signalr.Connection to a client in the test
+type pipeConnection struct {
+	connectionID string
+	io.Reader
+	io.Writer
+	client   io.Writer
+	received chan map[string]interface{}
+}
+
+func (p *pipeConnection) ConnectionID() string {
+	return p.connectionID
+}
+
+// connect runs server on a new connection and returns it after the handshake
+func connect(server *signalr.Server, connectionID string) *pipeConnection {
+	clientReader, serverWriter := io.Pipe()
+	serverReader, clientWriter := io.Pipe()
+	conn := &pipeConnection{
+		connectionID: connectionID,
+		Reader:       serverReader,
+		Writer:       serverWriter,
+		client:       clientWriter,
+		received:     make(chan map[string]interface{}, 100),
+	}
+	go server.Run(conn)
+	go func() {
+		reader := bufio.NewReader(clientReader)
+		for {
+			data, err := reader.ReadBytes(30)
+			if err != nil {
+				return
+			}
+			var message map[string]interface{}
+			if json.Unmarshal(data[:len(data)-1], &message) == nil && message["type"] != float64(6) {
+				conn.received <- message
+			}
+		}
+	}()
+	conn.send(`{"protocol": "json","version": 1}`)
+	Expect(<-conn.received).To(BeEmpty())
+	return conn
+}
+
+func (p *pipeConnection) send(message string) {
+	go func() {
+		defer GinkgoRecover()
+		_, err := p.client.Write(append([]byte(message), 30))
+		Expect(err).To(BeNil())
+	}()
+}
+
+// invoke invokes a hub method and returns its completion. Invocations received meanwhile are kept
+func (p *pipeConnection) invoke(id string, target string, args ...interface{}) map[string]interface{} {
+	arguments, _ := json.Marshal(args)
+	p.send(fmt.Sprintf(`{"type":1,"invocationId":"%v","target":"%v","arguments":%s}`, id, target, arguments))
+	var kept []map[string]interface{}
+	defer func() {
+		for _, message := range kept {
+			p.received <- message
+		}
+	}()
+	for message := range p.received {
+		if message["type"] == float64(3) && message["invocationId"] == id {
+			return message
+		}
+		kept = append(kept, message)
+	}
+	return nil
+}
+
+// expectInvocation returns the arguments of the next invocation of target
+func (p *pipeConnection) expectInvocation(target string) []interface{} {
+	for message := range p.received {
+		if message["type"] == float64(1) && message["target"] == target {
+			return message["arguments"].([]interface{})
+		}
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package rooms
+
+import (
+	"../signalr"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Rooms", func() {
+
+	Describe("Chat in a room", func() {
+		hub := NewHub(Options{HistorySize: 2})
+		server := signalr.NewServer(hub)
+		Context("When connections join, send and leave", func() {
+			It("should deliver messages, history and presence to the members", func() {
+				alice := connect(server, "alice")
+				bob := connect(server, "bob")
+				Expect(alice.invoke("1", "join", "lobby")["error"]).To(BeNil())
+				Expect(alice.expectInvocation("presence")).To(Equal([]interface{}{"lobby", []interface{}{"alice"}}))
+				for i, text := range []string{"one", "two", "three"} {
+					Expect(alice.invoke(string(rune('a'+i)), "send", "lobby", text)["error"]).To(BeNil())
+				}
+				joined := bob.invoke("2", "join", "lobby")
+				history := joined["result"].([]interface{})
+				Expect(history).To(HaveLen(2))
+				Expect(history[0].(map[string]interface{})["text"]).To(Equal("two"))
+				Expect(history[1].(map[string]interface{})["user"]).To(Equal("alice"))
+				Expect(alice.expectInvocation("presence")).To(Equal([]interface{}{"lobby", []interface{}{"alice", "bob"}}))
+				Expect(bob.invoke("3", "send", "lobby", "hi")["error"]).To(BeNil())
+				Expect(alice.expectInvocation("message")[0].(map[string]interface{})["text"]).To(Equal("hi"))
+				Expect(bob.invoke("4", "leave", "lobby")["error"]).To(BeNil())
+				Expect(alice.expectInvocation("presence")).To(Equal([]interface{}{"lobby", []interface{}{"alice"}}))
+				Expect(hub.Rooms().Members("lobby")).To(Equal([]string{"alice"}))
+			})
+		})
+		Context("When a connection sends to a room it has not joined", func() {
+			It("should return an error", func() {
+				carol := connect(server, "carol")
+				Expect(carol.invoke("5", "send", "lobby", "hello")["error"]).To(Equal(ErrNotMember.Error()))
+				Expect(carol.invoke("6", "send", "nowhere", "")["error"]).To(Equal(ErrEmptyMessage.Error()))
+			})
+		})
+	})
+})
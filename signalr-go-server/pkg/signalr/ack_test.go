@@ -0,0 +1,104 @@
+package signalr
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"./signalrtest"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Acknowledged invocation", func() {
+
+	Describe("Invoke a client with ack", func() {
+		server := NewServer(&contextHub{})
+		conn := newTestingConnection()
+		go server.Run(conn)
+		invokeWithAck := func(ctx context.Context, connectionID string) chan error {
+			result := make(chan error, 1)
+			go func() {
+				result <- server.HubContext().Clients().InvokeClientWithAck(ctx, connectionID, "confirm", "payment")
+			}()
+			return result
+		}
+		Context("When the client acknowledges the invocation", func() {
+			It("should return nil, or the error sent by the client", func() {
+				_, err := conn.clientSend(`{"type":1,"invocationId": "ack","target":"ready"}`)
+				Expect(err).To(BeNil())
+				Expect((<-conn.received).(CompletionMessage).InvocationID).To(Equal("ack"))
+				result := invokeWithAck(context.Background(), "test")
+				invocation := (<-conn.received).(InvocationMessage)
+				Expect(invocation.Target).To(Equal("confirm"))
+				Expect(invocation.InvocationID).NotTo(Equal(""))
+				_, err = conn.clientSend(fmt.Sprintf(`{"type":3,"invocationId": "%v"}`, invocation.InvocationID))
+				Expect(err).To(BeNil())
+				Eventually(result).Should(Receive(BeNil()))
+				result = invokeWithAck(context.Background(), "test")
+				invocation = (<-conn.received).(InvocationMessage)
+				_, err = conn.clientSend(fmt.Sprintf(`{"type":3,"invocationId": "%v","error":"declined"}`, invocation.InvocationID))
+				Expect(err).To(BeNil())
+				Eventually(result).Should(Receive(MatchError("declined")))
+			})
+		})
+		Context("When the client does not acknowledge in time", func() {
+			It("should return the context error", func() {
+				ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+				defer cancel()
+				result := invokeWithAck(ctx, "test")
+				<-conn.received
+				Eventually(result).Should(Receive(Equal(context.DeadlineExceeded)))
+			})
+		})
+		Context("When the connection does not exist", func() {
+			It("should return ErrUnknownConnection", func() {
+				Eventually(invokeWithAck(context.Background(), "unknown")).Should(Receive(Equal(ErrUnknownConnection)))
+			})
+		})
+	})
+
+	Describe("Invoke a client with ack and a ClientResultTimeout", func() {
+		clock := signalrtest.NewFakeClock(time.Now())
+		server := NewServer(&contextHub{}, UseClock(clock), ClientResultTimeout(time.Second))
+		conn := newTestingConnection()
+		go server.Run(conn)
+		Context("When the client does not acknowledge within the timeout", func() {
+			It("should return ErrTimeout and forget the invocation", func() {
+				_, err := conn.clientSend(`{"type":1,"invocationId": "ack","target":"ready"}`)
+				Expect(err).To(BeNil())
+				Expect((<-conn.received).(CompletionMessage).InvocationID).To(Equal("ack"))
+				result := make(chan error, 1)
+				go func() {
+					result <- server.HubContext().Clients().InvokeClientWithAck(context.Background(), "test", "confirm")
+				}()
+				<-conn.received
+				// The timer starts after the invocation has been sent
+				Eventually(func() int {
+					clock.Advance(time.Second)
+					return len(result)
+				}).Should(Equal(1))
+				Expect(<-result).To(Equal(ErrTimeout))
+				hubConn := server.connections.hubConnections()[0].(*defaultHubConnection)
+				hubConn.invocationsMx.Lock()
+				defer hubConn.invocationsMx.Unlock()
+				Expect(hubConn.invocations).To(BeEmpty())
+			})
+		})
+	})
+
+	Describe("Invoke a client with result on a closed connection", func() {
+		conn := &blockingConnection{release: make(chan bool), closed: make(chan bool, 1)}
+		close(conn.release)
+		hubConn := newHubConnection(conn, &JsonHubProtocol{}, hubConnectionOptions{})
+		hubConn.Start()
+		Context("When the connection has been closed", func() {
+			It("should return ErrConnectionClosed without waiting", func() {
+				hubConn.Close("")
+				_, completed, err := hubConn.InvokeWithResult("confirm", nil)
+				Expect(err).To(Equal(ErrConnectionClosed))
+				Expect(completed).To(BeNil())
+			})
+		})
+	})
+})
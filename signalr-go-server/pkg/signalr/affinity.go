@@ -0,0 +1,55 @@
+package signalr
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// AffinityCookie sets a cookie with the name of this node on negotiate responses, for multi-node deployments
+// without a backplane where the load balancer routes the requests of a client to one node by this cookie.
+// The transport requests of a connection must reach the node which answered its negotiate.
+// Transport requests carrying the cookie of another node are answered with 409 and an error describing
+// the misrouting, instead of failing with an unknown connection ID. If node is empty, the host name is used
+func AffinityCookie(name string, node string) Option {
+	return func(s *Server) {
+		if node == "" {
+			node, _ = os.Hostname()
+		}
+		s.affinityCookie = name
+		s.affinityNode = node
+	}
+}
+
+// setAffinity sets the affinity cookie on a negotiate response
+func (s *Server) setAffinity(w http.ResponseWriter, req *http.Request) {
+	if s.affinityCookie == "" {
+		return
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     s.affinityCookie,
+		Value:    s.affinityNode,
+		Path:     strings.TrimSuffix(req.URL.Path, "/negotiate"),
+		HttpOnly: true,
+	})
+}
+
+// checkAffinity checks the affinity cookie of a transport request. It returns false if req has been answered
+// because it reached another node than the one which negotiated the connection
+func (s *Server) checkAffinity(w http.ResponseWriter, req *http.Request) bool {
+	if s.affinityCookie == "" {
+		return true
+	}
+	cookie, err := req.Cookie(s.affinityCookie)
+	if err != nil || cookie.Value == s.affinityNode {
+		// Without cookie, e.g. a client which does not send cookies, the connection ID decides
+		return true
+	}
+	message := fmt.Sprintf("connection %v was negotiated by node %q but its request reached node %q. "+
+		"The load balancer must route requests with the cookie %v to the node named by it",
+		req.URL.Query().Get("id"), cookie.Value, s.affinityNode, s.affinityCookie)
+	_ = s.logger.Log("event", "affinity violated", "error", message)
+	http.Error(w, message, 409)
+	return false
+}
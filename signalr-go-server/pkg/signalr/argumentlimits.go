@@ -0,0 +1,116 @@
+package signalr
+
+import (
+	"fmt"
+)
+
+// ArgumentLimits limits the values clients send with the json hub protocol, see LimitArguments. Zero fields mean no limit
+type ArgumentLimits struct {
+	// MaxArguments is the maximum number of arguments of an invocation
+	MaxArguments int
+	// MaxStringLength is the maximum number of bytes of a string in a value, as sent, escapes included
+	MaxStringLength int
+	// MaxArrayLength is the maximum number of elements of an array in an argument, stream item or result
+	MaxArrayLength int
+	// MaxDepth is the maximum nesting of arrays and objects in an argument, stream item or result
+	MaxDepth int
+}
+
+// LimitArguments sets the ArgumentLimits of the json hub protocol. The limits are checked while a message is scanned,
+// before it is parsed, so a client can not make the server allocate large values or recurse deeply by sending them.
+// A message exceeding a limit ends the connection with a protocol error. By default, only the size of a message is
+// limited, see MaximumReceiveMessageSize
+func LimitArguments(limits ArgumentLimits) Option {
+	return func(s *Server) {
+		s.argumentLimits = &limits
+	}
+}
+
+// scanFrame is an array or object which is open while a message is scanned
+type scanFrame struct {
+	array bool
+	// envelope is "arguments" or "streamIds" for these arrays of the message, which hold no value
+	envelope string
+	// elements is the number of elements of an array found so far
+	elements int
+}
+
+// checkJSON scans the json message data and fails if one of its values exceeds the limits.
+// The values of a message are its arguments, its item or its result. Malformed json is left to the parser
+func (l *ArgumentLimits) checkJSON(data []byte) error {
+	var frames []scanFrame
+	// key is the key of the current member of the top level object, member tells that its value is scanned
+	key, member := "", false
+	for i := 0; i < len(data); i++ {
+		c := data[i]
+		switch c {
+		case ' ', '\t', '\r', '\n', ':', ']', '}', ',':
+		default:
+			// A value starts
+			if n := len(frames); n > 0 && frames[n-1].array && frames[n-1].elements == 0 {
+				frames[n-1].elements = 1
+			}
+		}
+		switch c {
+		case '"':
+			start := i
+			for i++; i < len(data) && data[i] != '"'; i++ {
+				if data[i] == '\\' {
+					i++
+				}
+			}
+			if len(frames) == 1 && !member {
+				if i < len(data) {
+					key = string(data[start+1 : i])
+				}
+				continue
+			}
+			value := len(frames) > 2 || len(frames) == 2 && frames[1].envelope != "streamIds" ||
+				len(frames) == 1 && (key == "item" || key == "result")
+			if value && l.MaxStringLength > 0 && i-start-1 > l.MaxStringLength {
+				return fmt.Errorf("string exceeds the maximum length of %v bytes", l.MaxStringLength)
+			}
+		case ':':
+			if len(frames) == 1 {
+				member = true
+			}
+		case '[', '{':
+			frame := scanFrame{array: c == '['}
+			if len(frames) == 1 && (key == "arguments" || key == "streamIds") {
+				frame.envelope = key
+			}
+			// The depth of values counts from the top level object, or from the envelope array they are in
+			depth := len(frames)
+			if len(frames) > 1 && frames[1].envelope != "" {
+				depth--
+			}
+			if l.MaxDepth > 0 && frame.envelope == "" && depth > l.MaxDepth {
+				return fmt.Errorf("value exceeds the maximum depth of %v", l.MaxDepth)
+			}
+			frames = append(frames, frame)
+		case ']', '}':
+			if len(frames) > 0 {
+				frames = frames[:len(frames)-1]
+			}
+		case ',':
+			if len(frames) == 1 {
+				member = false
+			}
+			if n := len(frames); n > 0 && frames[n-1].array && frames[n-1].envelope == "" {
+				frames[n-1].elements++
+				if l.MaxArrayLength > 0 && frames[n-1].elements > l.MaxArrayLength {
+					return fmt.Errorf("array exceeds the maximum length of %v elements", l.MaxArrayLength)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// checkArguments fails if arguments are more than MaxArguments
+func (l *ArgumentLimits) checkArguments(arguments int) error {
+	if l.MaxArguments > 0 && arguments > l.MaxArguments {
+		return fmt.Errorf("invocation exceeds the maximum of %v arguments", l.MaxArguments)
+	}
+	return nil
+}
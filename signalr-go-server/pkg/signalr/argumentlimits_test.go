@@ -0,0 +1,57 @@
+package signalr
+
+import (
+	"bytes"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ArgumentLimits", func() {
+
+	Describe("JsonHubProtocol with ArgumentLimits", func() {
+		protocol := &JsonHubProtocol{limits: &ArgumentLimits{MaxArguments: 2, MaxStringLength: 5, MaxArrayLength: 3, MaxDepth: 2}}
+		read := func(message string) error {
+			_, _, err := protocol.ReadMessage(bytes.NewBufferString(message + "\u001e"))
+			return err
+		}
+		Context("When a message is within the limits", func() {
+			It("should parse it", func() {
+				Expect(read(`{"type":1,"invocationId":"1","target":"send","arguments":[[1,2,3],{"a":["x"]}],"streamIds":["1","2","3","4"]}`)).To(Succeed())
+				Expect(read(`{"type":2,"invocationId":"1","item":{"a":[1,2]}}`)).To(Succeed())
+				Expect(read(`{"type":3,"invocationId":"1","result":[]}`)).To(Succeed())
+			})
+		})
+		Context("When a message exceeds a limit", func() {
+			It("should fail with the limit", func() {
+				Expect(read(`{"type":1,"target":"send","arguments":[1,2,3]}`)).To(MatchError(ContainSubstring("maximum of 2 arguments")))
+				Expect(read(`{"type":1,"target":"send","arguments":["abcdef"]}`)).To(MatchError(ContainSubstring("maximum length of 5 bytes")))
+				Expect(read(`{"type":1,"target":"send","arguments":[[1,2,3,4]]}`)).To(MatchError(ContainSubstring("maximum length of 3 elements")))
+				Expect(read(`{"type":1,"target":"send","arguments":[{"a":{"b":[1]}}]}`)).To(MatchError(ContainSubstring("maximum depth of 2")))
+				Expect(read(`{"type":2,"invocationId":"1","item":[[[1]]]}`)).To(MatchError(ContainSubstring("maximum depth of 2")))
+				Expect(read(`{"type":3,"invocationId":"1","result":[1,2,3,4]}`)).To(MatchError(ContainSubstring("maximum length of 3 elements")))
+			})
+		})
+		Context("When a string contains escaped quotes and brackets", func() {
+			It("should scan past them", func() {
+				Expect(read(`{"type":1,"target":"send","arguments":["\"[[["]}`)).To(Succeed())
+			})
+		})
+	})
+
+	Describe("Connection sending an argument nested too deeply", func() {
+		hub := &reasonHub{reasons: make(chan DisconnectReason, 1)}
+		server := NewServer(hub, LimitArguments(ArgumentLimits{MaxDepth: 10}))
+		conn := newTestingConnection()
+		go server.Run(conn)
+		Context("When the client sends the invocation", func() {
+			It("should end the connection with a protocol error", func() {
+				_, err := conn.clientSend(`{"type":1,"target":"ready","arguments":[` + strings.Repeat("[", 11) + strings.Repeat("]", 11) + `]}`)
+				Expect(err).To(BeNil())
+				Expect(<-hub.reasons).To(Equal(DisconnectProtocolError))
+				Expect((<-conn.closed).Error).To(ContainSubstring("maximum depth of 10"))
+			})
+		})
+	})
+})
@@ -0,0 +1,157 @@
+package signalr
+
+import (
+	"bufio"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"time"
+
+	"./signalrtest"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// pipeConnection is a Connection whose client side is driven by the test without any preset handshake
+type pipeConnection struct {
+	*io.PipeReader
+	*io.PipeWriter
+	clientReader *bufio.Reader
+	clientWriter io.WriteCloser
+}
+
+func newPipeConnection() *pipeConnection {
+	cliReader, srvWriter := io.Pipe()
+	srvReader, cliWriter := io.Pipe()
+	return &pipeConnection{PipeReader: srvReader, PipeWriter: srvWriter, clientReader: bufio.NewReader(cliReader), clientWriter: cliWriter}
+}
+
+func (p *pipeConnection) ConnectionID() string {
+	return "pipe"
+}
+
+// Close ends both directions, like closing a network connection
+func (p *pipeConnection) Close() error {
+	_ = p.PipeReader.Close()
+	return p.PipeWriter.Close()
+}
+
+var _ = Describe("ASP.NET compatibility", func() {
+
+	Describe("Handshake errors", func() {
+		mux := http.NewServeMux()
+		MapHub(mux, "/hub", &longPollingHub{})
+		handshake := func(request string) string {
+			httpServer := httptest.NewServer(mux)
+			defer httpServer.Close()
+			pollURL := httpServer.URL + "/hub?id=" + url.QueryEscape(negotiate(mux, "/hub")["connectionId"].(string))
+			status, _ := longPoll(pollURL)
+			Expect(status).To(Equal(200))
+			longPollSend(pollURL, request)
+			status, messages := longPoll(pollURL)
+			Expect(status).To(Equal(200))
+			Expect(messages).To(HaveLen(1))
+			return messages[0]
+		}
+		Context("When the client requests an unknown protocol", func() {
+			It("should send the error of ASP.NET", func() {
+				Expect(handshake(`{"protocol":"xml","version":1}`)).To(Equal(`{"error":"The protocol 'xml' is not supported."}`))
+			})
+		})
+		Context("When the client requests an unsupported version", func() {
+			It("should send the error of ASP.NET", func() {
+				Expect(handshake(`{"protocol":"json","version":2}`)).To(Equal(`{"error":"The server does not support version 2 of the 'json' protocol."}`))
+			})
+		})
+		Context("When the handshake request is malformed", func() {
+			It("should send an error instead of closing silently", func() {
+				Expect(handshake(`{"protocol":`)).To(HavePrefix(`{"error":"An unexpected error occurred during connection handshake. `))
+			})
+		})
+	})
+
+	Describe("Handshake timeout", func() {
+		clock := signalrtest.NewFakeClock(time.Now())
+		server := NewServer(&contextHub{}, UseClock(clock), HandshakeTimeout(5*time.Second))
+		Context("When the client does not send the handshake in time", func() {
+			It("should cancel the handshake and close the connection", func() {
+				conn := newPipeConnection()
+				done := make(chan struct{})
+				go func() {
+					defer close(done)
+					server.Run(conn)
+				}()
+				responses := make(chan string, 1)
+				go func() {
+					response, _ := conn.clientReader.ReadString(30)
+					responses <- response
+				}()
+				var response string
+				Eventually(func() bool {
+					clock.Advance(time.Second)
+					select {
+					case response = <-responses:
+						return true
+					default:
+						return false
+					}
+				}).Should(BeTrue())
+				Expect(response).To(Equal(`{"error":"Handshake was canceled."}` + "\u001e"))
+				Eventually(done).Should(BeClosed())
+			})
+		})
+	})
+
+	Describe("Failed transport requests", func() {
+		mux := http.NewServeMux()
+		MapHub(mux, "/hub", &longPollingHub{}, AllowTransports(TransportLongPolling, TransportServerSentEvents))
+		request := func(method string, target string, accept string) *httptest.ResponseRecorder {
+			recorder := httptest.NewRecorder()
+			req := httptest.NewRequest(method, target, nil)
+			if accept != "" {
+				req.Header.Set("Accept", accept)
+			}
+			mux.ServeHTTP(recorder, req)
+			return recorder
+		}
+		Context("When negotiate is not a POST", func() {
+			It("should answer 405", func() {
+				recorder := request("GET", "/hub/negotiate", "")
+				Expect(recorder.Code).To(Equal(405))
+				Expect(recorder.Header().Get("Allow")).To(Equal("POST"))
+			})
+		})
+		Context("When a transport request has no connection ID", func() {
+			It("should answer 400 with the error of ASP.NET", func() {
+				for _, accept := range []string{"", "text/event-stream"} {
+					recorder := request("GET", "/hub", accept)
+					Expect(recorder.Code).To(Equal(400))
+					Expect(recorder.Body.String()).To(Equal("Connection ID required\n"))
+				}
+			})
+		})
+		Context("When a transport request has an unknown connection ID", func() {
+			It("should answer 404 with the error of ASP.NET", func() {
+				for _, accept := range []string{"", "text/event-stream"} {
+					recorder := request("GET", "/hub?id=unknown", accept)
+					Expect(recorder.Code).To(Equal(404))
+					Expect(recorder.Body.String()).To(Equal("No Connection with that ID\n"))
+				}
+				recorder := request("POST", "/hub?id=unknown", "")
+				Expect(recorder.Code).To(Equal(404))
+				Expect(recorder.Body.String()).To(Equal("No Connection with that ID\n"))
+			})
+		})
+		Context("When a transport is not allowed", func() {
+			It("should answer 404 with the error of ASP.NET", func() {
+				recorder := httptest.NewRecorder()
+				req := httptest.NewRequest("GET", "/hub?id=unknown", nil)
+				req.Header.Set("Upgrade", "websocket")
+				mux.ServeHTTP(recorder, req)
+				Expect(recorder.Code).To(Equal(404))
+				Expect(recorder.Body.String()).To(Equal("WebSockets transport not supported by this end point type\n"))
+			})
+		})
+	})
+})
@@ -0,0 +1,166 @@
+package signalr
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const defaultAuthenticationTTL = 5 * time.Minute
+
+// RequestAuthenticator authenticates a request to the server, e.g. by introspecting its bearer token at the
+// identity provider. It returns the Identity of the request, or an error if the request is not authenticated
+type RequestAuthenticator func(req *http.Request) (Identity, error)
+
+// ClaimsCache caches the identities of the requests of connections, see AuthenticateRequests. A shared cache,
+// e.g. in Redis, lets servers behind a load balancer reuse the identities of each other. Caches must be safe
+// for concurrent use. The keys are made of the connection ID and a hash of the access token of the request.
+// Get() returns the identity cached with key, false if none is cached or it has expired
+// Set() caches identity with key until expires
+type ClaimsCache interface {
+	Get(key string) (Identity, bool)
+	Set(key string, identity Identity, expires time.Time)
+}
+
+// AuthenticationOptions configures the authentication of the requests of a server, see AuthenticateRequests.
+// A nil Cache keeps the identities in memory, a zero TTL is 5 minutes
+type AuthenticationOptions struct {
+	Authenticate RequestAuthenticator
+	Cache        ClaimsCache
+	// TTL is the time an identity is cached. Identities which expire earlier are cached until they expire
+	TTL time.Duration
+}
+
+// AuthenticateRequests authenticates the negotiate and transport requests of the server with Authenticate.
+// Requests which are not authenticated are refused with 401. The Identity of the request which starts a connection
+// is its FeatureIdentity, its Claims are the FeatureClaims and its UserID is the user of the connection, unless the
+// server has a TokenValidator or a UserIDProvider.
+// Long polling sends each poll and each message as a request of its own. So Authenticate is not called for each of
+// them, the identity of a connection is cached with the connection ID and the access token of its requests. The
+// identity of negotiate is cached for the connection ID issued by it. Requests with another access token than the
+// cached one are authenticated again. By default, requests are not authenticated by the server
+func AuthenticateRequests(options AuthenticationOptions) Option {
+	return func(s *Server) {
+		if options.Cache == nil {
+			options.Cache = &memoryClaimsCache{identities: make(map[string]cachedIdentity)}
+		}
+		if options.TTL <= 0 {
+			options.TTL = defaultAuthenticationTTL
+		}
+		s.authentication = &requestAuthentication{options: options}
+	}
+}
+
+// requestAuthentication authenticates the requests of a server
+type requestAuthentication struct {
+	options AuthenticationOptions
+	clock   Clock
+}
+
+// claimsCacheKey returns the key of the identity of the request req of the connection with connectionID
+func claimsCacheKey(connectionID string, req *http.Request) string {
+	hash := sha256.Sum256([]byte(bearerToken(req)))
+	return connectionID + ":" + base64.RawURLEncoding.EncodeToString(hash[:])
+}
+
+// authenticate returns the Identity of req, which is taken from the cache for connectionID, if it is not ""
+func (r *requestAuthentication) authenticate(req *http.Request, connectionID string) (Identity, error) {
+	if connectionID != "" {
+		if identity, ok := r.options.Cache.Get(claimsCacheKey(connectionID, req)); ok {
+			return identity, nil
+		}
+	}
+	identity, err := r.options.Authenticate(req)
+	if err == nil && connectionID != "" {
+		r.remember(req, connectionID, identity)
+	}
+	return identity, err
+}
+
+// remember caches identity for the requests of the connection with connectionID, which have the access token of req
+func (r *requestAuthentication) remember(req *http.Request, connectionID string, identity Identity) {
+	expires := r.clock.Now().Add(r.options.TTL)
+	if !identity.Expires.IsZero() && identity.Expires.Before(expires) {
+		expires = identity.Expires
+	}
+	r.options.Cache.Set(claimsCacheKey(connectionID, req), identity, expires)
+}
+
+// authenticateRequest authenticates req, which belongs to the connection with connectionID, or if it is "",
+// to no connection yet. It returns req with the identity attached, or false if req has been refused
+func (s *Server) authenticateRequest(w http.ResponseWriter, req *http.Request, connectionID string) (*http.Request, Identity, bool) {
+	if s.authentication == nil {
+		return req, Identity{}, true
+	}
+	identity, err := s.authentication.authenticate(req, connectionID)
+	if err != nil {
+		_ = s.logger.Log("event", "request not authenticated", "error", err)
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return nil, Identity{}, false
+	}
+	ctx := WithFeature(req.Context(), FeatureIdentity, identity)
+	if identity.Claims != nil {
+		ctx = WithFeature(ctx, FeatureClaims, identity.Claims)
+	}
+	return req.WithContext(ctx), identity, true
+}
+
+// cachedIdentity is an identity in the memoryClaimsCache
+type cachedIdentity struct {
+	identity Identity
+	expires  time.Time
+}
+
+// minSweepSize is the size below which maps of expiring entries are not swept, see nextSweep
+const minSweepSize = 64
+
+// nextSweep returns the size at which a map of expiring entries with size entries left after a sweep is swept
+// again. Sweeping when the map has doubled keeps the cost per insert constant
+func nextSweep(size int) int {
+	if size < minSweepSize/2 {
+		return minSweepSize
+	}
+	return 2 * size
+}
+
+// memoryClaimsCache is the default ClaimsCache
+type memoryClaimsCache struct {
+	clock      Clock
+	mx         sync.Mutex
+	identities map[string]cachedIdentity
+	// sweepAt is the number of identities at which Set drops the expired ones
+	sweepAt int
+}
+
+func (m *memoryClaimsCache) Get(key string) (Identity, bool) {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	cached, ok := m.identities[key]
+	if !ok {
+		return Identity{}, false
+	}
+	if !m.clock.Now().Before(cached.expires) {
+		delete(m.identities, key)
+		return Identity{}, false
+	}
+	return cached.identity, true
+}
+
+func (m *memoryClaimsCache) Set(key string, identity Identity, expires time.Time) {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	m.identities[key] = cachedIdentity{identity: identity, expires: expires}
+	if len(m.identities) < m.sweepAt {
+		return
+	}
+	// The identities of ended connections, which are not read anymore, are dropped when they expire
+	now := m.clock.Now()
+	for cachedKey, cached := range m.identities {
+		if !now.Before(cached.expires) {
+			delete(m.identities, cachedKey)
+		}
+	}
+	m.sweepAt = nextSweep(len(m.identities))
+}
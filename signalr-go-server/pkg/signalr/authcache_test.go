@@ -0,0 +1,108 @@
+package signalr
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"./signalrtest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type authHub struct {
+	Hub
+}
+
+func (a *authHub) Whoami(ctx ConnectionContext) string {
+	claims, _ := ctx.Features().Get(FeatureClaims)
+	return ctx.UserID() + " " + claims.(map[string]interface{})["role"].(string)
+}
+
+var _ = Describe("AuthenticateRequests", func() {
+
+	Describe("Long polling connection of a server which authenticates requests", func() {
+		var authenticated int32
+		mux := http.NewServeMux()
+		MapHub(mux, "/hub", &authHub{}, AuthenticateRequests(AuthenticationOptions{Authenticate: func(req *http.Request) (Identity, error) {
+			atomic.AddInt32(&authenticated, 1)
+			if token := bearerToken(req); token == "alice" || token == "alice-refreshed" {
+				return Identity{UserID: "alice", Claims: map[string]interface{}{"role": "admin"}}, nil
+			}
+			return Identity{}, errors.New("unknown token")
+		}}))
+		httpServer := httptest.NewServer(mux)
+		send := func(method string, url string, body string, token string) *http.Response {
+			req, err := http.NewRequest(method, url, strings.NewReader(body))
+			Expect(err).To(BeNil())
+			req.Header.Set("Authorization", "Bearer "+token)
+			resp, err := http.DefaultClient.Do(req)
+			Expect(err).To(BeNil())
+			return resp
+		}
+		status := func(method string, url string, body string, token string) int {
+			resp := send(method, url, body, token)
+			resp.Body.Close()
+			return resp.StatusCode
+		}
+		Context("When the client polls and sends messages", func() {
+			It("should authenticate negotiate only and cache the identity for the connection", func() {
+				defer httpServer.Close()
+				resp := send("POST", httpServer.URL+"/hub/negotiate", "", "alice")
+				var response map[string]interface{}
+				Expect(json.NewDecoder(resp.Body).Decode(&response)).To(Succeed())
+				resp.Body.Close()
+				pollURL := httpServer.URL + "/hub?id=" + url.QueryEscape(response["connectionId"].(string))
+				Expect(status("GET", pollURL, "", "alice")).To(Equal(200))
+				Expect(status("POST", pollURL, `{"protocol":"json","version":1}`+"\u001e", "alice")).To(Equal(200))
+				status("GET", pollURL, "", "alice")
+				Expect(status("POST", pollURL, `{"type":1,"invocationId":"w","target":"whoami"}`+"\u001e", "alice")).To(Equal(200))
+				resp = send("GET", pollURL, "", "alice")
+				var completion CompletionMessage
+				Expect(json.NewDecoder(resp.Body).Decode(&completion)).To(Succeed())
+				resp.Body.Close()
+				Expect(completion.Result).To(Equal("alice admin"))
+				Expect(atomic.LoadInt32(&authenticated)).To(Equal(int32(1)))
+				Expect(status("POST", pollURL, "", "mallory")).To(Equal(401))
+				Expect(status("POST", pollURL, `{"type":6}`+"\u001e", "alice-refreshed")).To(Equal(200))
+				Expect(atomic.LoadInt32(&authenticated)).To(Equal(int32(3)))
+			})
+		})
+	})
+
+	Describe("Memory claims cache with expired identities", func() {
+		clock := signalrtest.NewFakeClock(time.Now())
+		cache := &memoryClaimsCache{clock: clock, identities: make(map[string]cachedIdentity)}
+		for i := 0; i < minSweepSize; i++ {
+			cache.Set(strconv.Itoa(i), Identity{UserID: "alice"}, clock.Now().Add(time.Minute))
+		}
+		clock.Advance(2 * time.Minute)
+		Context("When an expired identity is read", func() {
+			It("should drop it", func() {
+				_, ok := cache.Get("0")
+				Expect(ok).To(BeFalse())
+				Expect(cache.identities).To(HaveLen(minSweepSize - 1))
+			})
+		})
+		Context("When the cache has doubled since it has been swept", func() {
+			It("should drop the expired identities", func() {
+				fresh := 0
+				for len(cache.identities) < cache.sweepAt-1 {
+					cache.Set("fresh"+strconv.Itoa(fresh), Identity{UserID: "bob"}, clock.Now().Add(time.Minute))
+					fresh++
+				}
+				Expect(cache.identities).To(HaveKey("1"))
+				cache.Set("fresh"+strconv.Itoa(fresh), Identity{UserID: "bob"}, clock.Now().Add(time.Minute))
+				Expect(cache.identities).To(HaveLen(fresh + 1))
+				Expect(cache.identities).NotTo(HaveKey("1"))
+			})
+		})
+	})
+})
@@ -0,0 +1,126 @@
+package signalr
+
+import (
+	"errors"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// QuotaAction is what happens to a connection which exceeds its BandwidthQuota
+type QuotaAction int
+
+const (
+	// QuotaThrottle delays reading from and writing to the connection until its quota is renewed,
+	// as a slow network would. Pings and close messages are not delayed
+	QuotaThrottle QuotaAction = iota
+	// QuotaDisconnect closes the connection
+	QuotaDisconnect
+)
+
+// BandwidthQuota limits the bytes a connection receives from and sends to its client within each Period.
+// A zero MaxBytesIn or MaxBytesOut is not checked. Messages are read and written in one piece, so a connection can
+// exceed its quota by one message before the quota is enforced.
+// OnQuotaExceeded, if set, is called with the stats of the connection each time the Action is taken
+type BandwidthQuota struct {
+	MaxBytesIn      int64
+	MaxBytesOut     int64
+	Period          time.Duration
+	Action          QuotaAction
+	OnQuotaExceeded func(stats ConnectionStats, action QuotaAction)
+}
+
+// LimitBandwidth sets the BandwidthQuota of each connection of the server. By default, the bandwidth is not limited
+func LimitBandwidth(quota BandwidthQuota) Option {
+	return func(s *Server) {
+		s.bandwidthQuota = &quota
+	}
+}
+
+// errQuotaExceeded ends the receive loop of a connection closed by QuotaDisconnect
+var errQuotaExceeded = errors.New("bandwidth quota exceeded")
+
+// bandwidthMeter counts the bytes of a connection within the current period of its BandwidthQuota
+type bandwidthMeter struct {
+	quota BandwidthQuota
+	clock Clock
+	mx    sync.Mutex
+	// start is the start of the current period, in and out are the bytes received and sent since then
+	start time.Time
+	in    int64
+	out   int64
+}
+
+func newBandwidthMeter(quota *BandwidthQuota, clock Clock) *bandwidthMeter {
+	if quota == nil {
+		return nil
+	}
+	return &bandwidthMeter{quota: *quota, clock: clock, start: clock.Now()}
+}
+
+// add counts n bytes received or sent. It returns true and the time until the quota is renewed if the quota
+// of the direction is exceeded
+func (m *bandwidthMeter) add(n int, inbound bool) (time.Duration, bool) {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	now := m.clock.Now()
+	if now.Sub(m.start) >= m.quota.Period {
+		m.start, m.in, m.out = now, 0, 0
+	}
+	var exceeded bool
+	if inbound {
+		m.in += int64(n)
+		exceeded = m.quota.MaxBytesIn > 0 && m.in > m.quota.MaxBytesIn
+	} else {
+		m.out += int64(n)
+		exceeded = m.quota.MaxBytesOut > 0 && m.out > m.quota.MaxBytesOut
+	}
+	return m.start.Add(m.quota.Period).Sub(now), exceeded
+}
+
+// enforceQuota counts n bytes received or sent and applies the BandwidthQuota of the connection.
+// With wait false, an exceeded quota is not throttled. It returns errQuotaExceeded if the connection has been closed
+func (c *defaultHubConnection) enforceQuota(n int, inbound bool, wait bool) error {
+	if c.bandwidth == nil {
+		return nil
+	}
+	renewed, exceeded := c.bandwidth.add(n, inbound)
+	if !exceeded {
+		return nil
+	}
+	quota := c.bandwidth.quota
+	if quota.OnQuotaExceeded != nil {
+		quota.OnQuotaExceeded(c.Stats(), quota.Action)
+	}
+	if quota.Action == QuotaDisconnect {
+		if atomic.CompareAndSwapInt32(&c.Connected, 1, 0) {
+			c.SetDisconnectReason(DisconnectKicked)
+			if closer, ok := c.Connection.(io.Closer); ok {
+				_ = closer.Close()
+			}
+		}
+		return errQuotaExceeded
+	}
+	if wait {
+		<-c.bandwidth.clock.After(renewed)
+	}
+	return nil
+}
+
+// countingWriter counts the bytes written to w
+type countingWriter struct {
+	w io.Writer
+	n int
+	// err is the last error of w
+	err error
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += n
+	if err != nil {
+		c.err = err
+	}
+	return n, err
+}
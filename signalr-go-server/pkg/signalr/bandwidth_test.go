@@ -0,0 +1,102 @@
+package signalr
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"./signalrtest"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type bandwidthHub struct {
+	Hub
+	reasons chan DisconnectReason
+}
+
+func (b *bandwidthHub) Ready() {}
+
+func (b *bandwidthHub) Echo(text string) string {
+	return text
+}
+
+func (b *bandwidthHub) OnDisconnectedReason(connectionID string, reason DisconnectReason) {
+	b.reasons <- reason
+}
+
+var _ = Describe("Bandwidth", func() {
+
+	Describe("Server counting bytes", func() {
+		server := NewServer(&bandwidthHub{}, IdentifyUser(func(ctx ConnectionContext) string {
+			return ctx.Query().Get("user")
+		}))
+		Context("When a user invokes a method", func() {
+			It("should count the bytes of the connection and the user", func() {
+				conn := connectUser(server, "a", "alice")
+				invocation := `{"type":1,"invocationId": "echo","target":"echo","arguments":["hello"]}`
+				before := server.ConnectionStats()[0]
+				_, err := conn.clientSend(invocation)
+				Expect(err).To(BeNil())
+				<-conn.received
+				Eventually(func() int64 { return server.ConnectionStats()[0].BytesIn }).Should(Equal(before.BytesIn + int64(len(invocation)+1)))
+				completion := `{"type":3,"invocationId":"echo","result":"hello"}` + "\u001e"
+				Eventually(func() int64 { return server.ConnectionStats()[0].BytesOut }).Should(BeNumerically(">=", before.BytesOut+int64(len(completion))))
+				users := server.UserStats()
+				Expect(users).To(HaveKey("alice"))
+				Expect(users["alice"].Connections).To(Equal(1))
+				Expect(users["alice"].BytesOut).To(Equal(server.Stats().BytesOut))
+			})
+		})
+	})
+
+	Describe("Server with a quota which disconnects", func() {
+		hub := &bandwidthHub{reasons: make(chan DisconnectReason, 1)}
+		exceeded := make(chan ConnectionStats, 1)
+		server := NewServer(hub, LimitBandwidth(BandwidthQuota{
+			MaxBytesIn: 100,
+			Period:     time.Minute,
+			Action:     QuotaDisconnect,
+			OnQuotaExceeded: func(stats ConnectionStats, action QuotaAction) {
+				exceeded <- stats
+			},
+		}))
+		Context("When the client sends more than the quota", func() {
+			It("should close the connection", func() {
+				conn := newTestingConnection()
+				go server.Run(conn)
+				_, err := conn.clientSend(fmt.Sprintf(`{"type":1,"invocationId": "echo","target":"echo","arguments":["%v"]}`, strings.Repeat("x", 100)))
+				Expect(err).To(BeNil())
+				Expect((<-exceeded).BytesIn).To(BeNumerically(">", 100))
+				Expect(<-hub.reasons).To(Equal(DisconnectKicked))
+			})
+		})
+	})
+
+	Describe("Server with a quota which throttles", func() {
+		clock := signalrtest.NewFakeClock(time.Now())
+		server := NewServer(&bandwidthHub{}, UseClock(clock), LimitBandwidth(BandwidthQuota{
+			MaxBytesOut: 40,
+			Period:      time.Second,
+			Action:      QuotaThrottle,
+		}))
+		Context("When more than the quota is sent to the client", func() {
+			It("should delay the messages until the quota is renewed", func() {
+				conn := newTestingConnection()
+				go server.Run(conn)
+				go func() {
+					defer GinkgoRecover()
+					// The server does not read while it waits to write the first completion
+					for _, text := range []string{"first message", "second message"} {
+						_, err := conn.clientSend(fmt.Sprintf(`{"type":1,"invocationId": "echo","target":"echo","arguments":["%v"]}`, text))
+						Expect(err).To(BeNil())
+					}
+				}()
+				Expect((<-conn.received).(CompletionMessage).Result).To(Equal("first message"))
+				Consistently(conn.received, 100*time.Millisecond).ShouldNot(Receive())
+				clock.Advance(time.Second)
+				Expect((<-conn.received).(CompletionMessage).Result).To(Equal("second message"))
+			})
+		})
+	})
+})
@@ -0,0 +1,49 @@
+package signalr
+
+import (
+	"bytes"
+	"errors"
+	"io"
+)
+
+// parseBinaryMessageFormat returns the next VarInt length prefixed message from buf.
+// If buf contains no complete message, it returns io.EOF and leaves buf untouched.
+// Messages are at most 2GB - 1, so the fifth byte of the prefix has only 3 bits
+func parseBinaryMessageFormat(buf *bytes.Buffer) ([]byte, error) {
+	var length, shift uint
+	data := buf.Bytes()
+	for i := 0; i < len(data); i++ {
+		if i >= 5 {
+			return nil, errors.New("message length prefix exceeds 5 bytes")
+		}
+		if i == 4 && data[i] > 0x07 {
+			return nil, errors.New("message length exceeds 2GB")
+		}
+		length |= uint(data[i]&0x7f) << shift
+		if data[i]&0x80 == 0 {
+			if uint(len(data)-i-1) < length {
+				return nil, io.EOF
+			}
+			return buf.Next(i + 1 + int(length))[i+1:], nil
+		}
+		shift += 7
+	}
+	return nil, io.EOF
+}
+
+// writeBinaryMessageFormat prefixes message with its VarInt encoded length
+func writeBinaryMessageFormat(message []byte) []byte {
+	var prefix []byte
+	length := uint(len(message))
+	for {
+		b := byte(length & 0x7f)
+		length >>= 7
+		if length > 0 {
+			prefix = append(prefix, b|0x80)
+		} else {
+			prefix = append(prefix, b)
+			break
+		}
+	}
+	return append(prefix, message...)
+}
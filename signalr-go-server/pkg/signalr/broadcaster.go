@@ -0,0 +1,20 @@
+package signalr
+
+// broadcastHub is the hub of a broadcaster. It has no methods clients can invoke
+type broadcastHub struct {
+	Hub
+}
+
+// NewBroadcaster creates a server whose clients only receive messages, e.g. for market data or scores.
+// The server has no hub: invocations of clients are answered with an error and the per connection
+// method lookup of hubs is skipped. Messages are sent by Broadcast or the HubContext of the server
+func NewBroadcaster(options ...Option) *Server {
+	server := NewServer(&broadcastHub{}, options...)
+	server.broadcaster = true
+	return server
+}
+
+// Broadcast sends an invocation of target with args to all clients of the server
+func (s *Server) Broadcast(target string, args ...interface{}) {
+	s.hubContext.Clients().All().Send(target, args...)
+}
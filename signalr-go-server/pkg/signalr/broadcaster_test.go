@@ -0,0 +1,32 @@
+package signalr
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Broadcaster", func() {
+
+	Describe("Broadcaster with a connected client", func() {
+		server := NewBroadcaster()
+		conn := newTestingConnection()
+		go server.Run(conn)
+		Context("When the client invokes a method", func() {
+			It("should return an error", func() {
+				_, err := conn.clientSend(`{"type":1,"invocationId": "nohub","target":"initialize","arguments":[null]}`)
+				Expect(err).To(BeNil())
+				recv := (<-conn.received).(CompletionMessage)
+				Expect(recv.InvocationID).To(Equal("nohub"))
+				Expect(recv.Error).NotTo(Equal(""))
+			})
+		})
+		Context("When the server broadcasts", func() {
+			It("should send the invocation to the client", func() {
+				server.Broadcast("score", 3, 1)
+				recv := (<-conn.received).(InvocationMessage)
+				Expect(recv.Target).To(Equal("score"))
+				Expect(recv.Arguments).To(Equal([]interface{}{float64(3), float64(1)}))
+			})
+		})
+	})
+})
@@ -0,0 +1,209 @@
+package signalr
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"unicode"
+)
+
+// CamelCaseJSON makes the json hub protocol write the fields of structs sent as arguments, stream items and results
+// with camelCase keys, e.g. {"streetName":"Main"} for struct{ StreetName string }, so Go structs interop with
+// JavaScript clients without json tags on every field. Fields with a name in their json tag keep it. Leading
+// initialisms are lowered as a whole, "ID" is "id" and "URLPath" is "urlPath", others are kept, "OrderID" is "orderID".
+// Keys of maps and the output of types implementing json.Marshaler or encoding.TextMarshaler are not changed,
+// the fields of embedded structs of unexported types are left out. Arguments sent by clients are parsed in either
+// case, as encoding/json matches keys to fields case insensitively. By default, fields are written with their Go names
+func CamelCaseJSON() Option {
+	return func(s *Server) {
+		s.camelCaseJSON = true
+	}
+}
+
+var jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+var textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+
+// camelCase returns value with the fields of all structs it contains replaced by camelObjects
+func camelCase(value interface{}) interface{} {
+	if value == nil {
+		return nil
+	}
+	return camelCaseValue(reflect.ValueOf(value))
+}
+
+func camelCaseValue(v reflect.Value) interface{} {
+	if v.Type().Implements(jsonMarshalerType) || v.Type().Implements(textMarshalerType) {
+		return v.Interface()
+	}
+	if v.CanAddr() && (v.Addr().Type().Implements(jsonMarshalerType) || v.Addr().Type().Implements(textMarshalerType)) {
+		return v.Addr().Interface()
+	}
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return camelCaseValue(v.Elem())
+	case reflect.Struct:
+		var object camelObject
+		appendCamelFields(&object, v)
+		return object
+	case reflect.Map:
+		if v.IsNil() {
+			return nil
+		}
+		m := reflect.MakeMapWithSize(reflect.MapOf(v.Type().Key(), reflect.TypeOf((*interface{})(nil)).Elem()), v.Len())
+		for _, key := range v.MapKeys() {
+			element := camelCaseValue(v.MapIndex(key))
+			if element == nil {
+				m.SetMapIndex(key, reflect.Zero(m.Type().Elem()))
+			} else {
+				m.SetMapIndex(key, reflect.ValueOf(element))
+			}
+		}
+		return m.Interface()
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return nil
+		}
+		// []byte is written as base64 string
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return v.Interface()
+		}
+		elements := make([]interface{}, v.Len())
+		for i := range elements {
+			elements[i] = camelCaseValue(v.Index(i))
+		}
+		return elements
+	default:
+		return v.Interface()
+	}
+}
+
+// appendCamelFields appends the exported fields of the struct v, and those of its exported embedded structs, to object
+func appendCamelFields(object *camelObject, v reflect.Value) {
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		name, options := field.Name, ""
+		tag, tagged := field.Tag.Lookup("json")
+		if tagged {
+			if tag == "-" {
+				continue
+			}
+			if comma := strings.Index(tag, ","); comma >= 0 {
+				tag, options = tag[:comma], tag[comma:]
+			}
+		}
+		if field.PkgPath != "" {
+			continue
+		}
+		value := v.Field(i)
+		if field.Anonymous && tag == "" {
+			if value.Kind() == reflect.Ptr {
+				if value.IsNil() {
+					continue
+				}
+				value = value.Elem()
+			}
+			if value.Kind() == reflect.Struct {
+				appendCamelFields(object, value)
+				continue
+			}
+		}
+		if strings.Contains(options, ",omitempty") && isEmptyJSON(value) {
+			continue
+		}
+		if tag != "" {
+			name = tag
+		} else {
+			name = camelCaseName(name)
+		}
+		object.fields = append(object.fields, camelField{name: name, value: camelCaseValue(value)})
+	}
+}
+
+// isEmptyJSON returns if v is omitted by encoding/json with the omitempty option
+func isEmptyJSON(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	}
+	return false
+}
+
+// camelCaseName lowers the leading upper case letters of name, except the last one if it starts the next word
+func camelCaseName(name string) string {
+	runes := []rune(name)
+	for i := 0; i < len(runes) && unicode.IsUpper(runes[i]); i++ {
+		if i > 0 && i+1 < len(runes) && unicode.IsLower(runes[i+1]) {
+			break
+		}
+		runes[i] = unicode.ToLower(runes[i])
+	}
+	return string(runes)
+}
+
+// camelObject is a struct converted by camelCase. It is written as JSON object with the fields in the order of the struct
+type camelObject struct {
+	fields []camelField
+}
+
+type camelField struct {
+	name  string
+	value interface{}
+}
+
+func (c camelObject) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, field := range c.fields {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		name, err := json.Marshal(field.name)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(name)
+		buf.WriteByte(':')
+		value, err := json.Marshal(field.value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// camelCaseMessage returns message with its arguments, item or result converted by camelCase
+func camelCaseMessage(message interface{}) interface{} {
+	switch m := message.(type) {
+	case InvocationMessage:
+		arguments := make([]interface{}, len(m.Arguments))
+		for i, argument := range m.Arguments {
+			arguments[i] = camelCase(argument)
+		}
+		m.Arguments = arguments
+		return m
+	case StreamItemMessage:
+		m.Item = camelCase(m.Item)
+		return m
+	case CompletionMessage:
+		m.Result = camelCase(m.Result)
+		return m
+	}
+	return message
+}
@@ -0,0 +1,67 @@
+package signalr
+
+import (
+	"bytes"
+	"encoding/json"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type CamelAddress struct {
+	StreetName string
+	ZIP        string `json:"zip"`
+}
+
+type camelOrder struct {
+	CamelAddress
+	OrderID   int
+	URLPath   string
+	Lines     []CamelAddress
+	Labels    map[string]CamelAddress
+	Placed    time.Time
+	Note      string `json:",omitempty"`
+	Internal  string `json:"-"`
+	reference string
+}
+
+var _ = Describe("CamelCaseJSON", func() {
+
+	Describe("Json hub protocol writing camelCase", func() {
+		protocol := &JsonHubProtocol{camelCase: true}
+		Context("When an argument is a struct without json tags", func() {
+			It("should write its fields and the fields of the structs it contains with camelCase keys", func() {
+				placed := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+				order := camelOrder{
+					CamelAddress: CamelAddress{StreetName: "Main", ZIP: "8000"},
+					OrderID:      7,
+					URLPath:      "/orders/7",
+					Lines:        []CamelAddress{{StreetName: "Side"}},
+					Labels:       map[string]CamelAddress{"Home": {ZIP: "1"}},
+					Placed:       placed,
+					Internal:     "x",
+					reference:    "y",
+				}
+				var buf bytes.Buffer
+				Expect(protocol.WriteMessage(InvocationMessage{Type: 1, Target: "order", Arguments: []interface{}{&order, 1}}, &buf)).To(Succeed())
+				Expect(buf.String()).To(ContainSubstring(`"arguments":[{"streetName":"Main","zip":"8000","orderID":7,"urlPath":"/orders/7",` +
+					`"lines":[{"streetName":"Side","zip":""}],"labels":{"Home":{"streetName":"","zip":"1"}},"placed":"2026-01-02T03:04:05Z"},1]`))
+			})
+		})
+		Context("When a client sends an argument with camelCase keys", func() {
+			It("should parse it into the struct", func() {
+				var address CamelAddress
+				Expect(protocol.UnmarshalArgument(json.RawMessage(`{"streetName":"Main","zip":"8000"}`), &address)).To(Succeed())
+				Expect(address).To(Equal(CamelAddress{StreetName: "Main", ZIP: "8000"}))
+			})
+		})
+	})
+
+	Describe("Server with CamelCaseJSON", func() {
+		server := NewServer(&recordingHub{}, CamelCaseJSON())
+		It("should write camelCase keys with its json protocol", func() {
+			Expect(server.protocols["json"].(*JsonHubProtocol).camelCase).To(BeTrue())
+		})
+	})
+})
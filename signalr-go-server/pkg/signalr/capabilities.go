@@ -0,0 +1,81 @@
+package signalr
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Capabilities are the features a client announces in the handshake, e.g.
+// {"protocol":"json","version":1,"capabilities":{"binary":true,"appVersion":"2.3.1"}}
+// Clients which do not announce capabilities have none
+type Capabilities map[string]interface{}
+
+// CapabilityTargets is the capability with which a client subscribes to the targets it handles, e.g.
+// {"protocol":"json","version":1,"capabilities":{"targets":["news","scores"]}}
+// Invocations of other targets are not sent to the client, so broadcasts of many topics do not cost
+// serializing and sending the topics a client ignores. Invocations with result are always sent.
+// Clients which do not announce the capability get all invocations
+const CapabilityTargets = "targets"
+
+// CapabilityLocale is the capability with which a client tells its language, e.g. "de-CH", see ConnectionContext.Locale.
+// It takes precedence over the Accept-Language header of the request which started the connection
+const CapabilityLocale = "locale"
+
+// Supports returns if the client announced the feature with the value true
+func (c Capabilities) Supports(feature string) bool {
+	supported, ok := c[feature].(bool)
+	return ok && supported
+}
+
+// String returns the string value of a capability, or "" if it is not a string
+func (c Capabilities) String(name string) string {
+	value, _ := c[name].(string)
+	return value
+}
+
+// targets returns the set of targets announced with CapabilityTargets, nil if the client did not announce them
+func (c Capabilities) targets() map[string]bool {
+	values, ok := c[CapabilityTargets].([]interface{})
+	if !ok {
+		return nil
+	}
+	targets := make(map[string]bool, len(values))
+	for _, value := range values {
+		if target, ok := value.(string); ok {
+			targets[target] = true
+		}
+	}
+	return targets
+}
+
+// AtLeastVersion returns if the capability is a dotted version string, e.g. "2.3.1",
+// which is equal to or higher than version. Missing parts count as 0
+func (c Capabilities) AtLeastVersion(name string, version string) bool {
+	value := c.String(name)
+	if value == "" {
+		return false
+	}
+	have := strings.Split(value, ".")
+	want := strings.Split(version, ".")
+	for i := 0; i < len(have) || i < len(want); i++ {
+		h, err := versionPart(have, i)
+		if err != nil {
+			return false
+		}
+		w, err := versionPart(want, i)
+		if err != nil {
+			return false
+		}
+		if h != w {
+			return h > w
+		}
+	}
+	return true
+}
+
+func versionPart(parts []string, i int) (int, error) {
+	if i >= len(parts) {
+		return 0, nil
+	}
+	return strconv.Atoi(parts[i])
+}
@@ -0,0 +1,167 @@
+package signalr
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+// CborHubProtocol is a binary hub protocol based on CBOR (RFC 7049), for clients where MessagePack
+// is not available. Messages are encoded as arrays with the layout of the MessagePack hub protocol,
+// each of them prefixed by its VarInt encoded length. Register it with HubProtocols(&CborHubProtocol{})
+type CborHubProtocol struct {
+}
+
+// Name returns "cbor"
+func (c *CborHubProtocol) Name() string {
+	return "cbor"
+}
+
+// Version returns 1
+func (c *CborHubProtocol) Version() int {
+	return 1
+}
+
+// TransferFormat returns "Binary"
+func (c *CborHubProtocol) TransferFormat() string {
+	return "Binary"
+}
+
+func (c *CborHubProtocol) UnmarshalArgument(argument interface{}, value interface{}) error {
+	return cbor.Unmarshal(argument.(cbor.RawMessage), value)
+}
+
+func (c *CborHubProtocol) ReadMessage(buf *bytes.Buffer) (interface{}, bool, error) {
+	data, err := parseBinaryMessageFormat(buf)
+	switch {
+	case errors.Is(err, io.EOF):
+		return nil, false, err
+	case err != nil:
+		return nil, true, err
+	}
+
+	var fields []cbor.RawMessage
+	if err = cbor.Unmarshal(data, &fields); err != nil {
+		return nil, true, err
+	}
+	if len(fields) == 0 {
+		return nil, true, errors.New("empty message")
+	}
+	var messageType int
+	if err = cbor.Unmarshal(fields[0], &messageType); err != nil {
+		return nil, true, err
+	}
+
+	switch messageType {
+	case 1, 4:
+		// [Type, Headers, InvocationId, Target, [Arguments], [StreamIds]]
+		invocation := InvocationMessage{Type: messageType}
+		var arguments []cbor.RawMessage
+		if err = unmarshalFields(fields, 2, &invocation.InvocationID, &invocation.Target, &arguments, &invocation.StreamIds); err != nil {
+			return nil, true, err
+		}
+		invocation.Arguments = make([]interface{}, len(arguments))
+		for i, a := range arguments {
+			invocation.Arguments[i] = a
+		}
+		return invocation, true, nil
+	case 2:
+		// [Type, Headers, InvocationId, Item]
+		streamItem := StreamItemMessage{Type: messageType}
+		var item cbor.RawMessage
+		err = unmarshalFields(fields, 2, &streamItem.InvocationID, &item)
+		streamItem.Item = item
+		return streamItem, true, err
+	case 3:
+		// [Type, Headers, InvocationId, ResultKind, Result?]
+		completion := CompletionMessage{Type: messageType}
+		var resultKind int
+		if err = unmarshalFields(fields, 2, &completion.InvocationID, &resultKind); err != nil {
+			return nil, true, err
+		}
+		switch resultKind {
+		case 1:
+			err = unmarshalFields(fields, 4, &completion.Error)
+		case 3:
+			err = unmarshalFields(fields, 4, &completion.Result)
+		}
+		return completion, true, err
+	case 5:
+		// [Type, Headers, InvocationId]
+		cancel := CancelInvocationMessage{Type: messageType}
+		err = unmarshalFields(fields, 2, &cancel.InvocationID)
+		return cancel, true, err
+	case 7:
+		// [Type, Error, AllowReconnect]
+		closeMessage := CloseMessage{Type: messageType}
+		err = unmarshalFields(fields, 1, &closeMessage.Error, &closeMessage.AllowReconnect)
+		return closeMessage, true, err
+	default:
+		return HubMessage{Type: messageType}, true, nil
+	}
+}
+
+// unmarshalFields unmarshals the fields of a message, starting at index start, into values.
+// Missing trailing fields keep their zero value
+func unmarshalFields(fields []cbor.RawMessage, start int, values ...interface{}) error {
+	for i, value := range values {
+		if start+i >= len(fields) {
+			return nil
+		}
+		if err := cbor.Unmarshal(fields[start+i], value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *CborHubProtocol) WriteMessage(message interface{}, writer io.Writer) error {
+	headers := map[string]string{}
+	var fields []interface{}
+	switch m := message.(type) {
+	case InvocationMessage:
+		var invocationID interface{}
+		if m.InvocationID != "" {
+			invocationID = m.InvocationID
+		}
+		streamIds := m.StreamIds
+		if streamIds == nil {
+			streamIds = []string{}
+		}
+		fields = []interface{}{m.Type, headers, invocationID, m.Target, m.Arguments, streamIds}
+	case StreamItemMessage:
+		fields = []interface{}{m.Type, headers, m.InvocationID, m.Item}
+	case CompletionMessage:
+		switch {
+		case m.Error != "":
+			fields = []interface{}{m.Type, headers, m.InvocationID, 1, m.Error}
+		case m.Result == nil:
+			fields = []interface{}{m.Type, headers, m.InvocationID, 2}
+		default:
+			fields = []interface{}{m.Type, headers, m.InvocationID, 3, m.Result}
+		}
+	case CancelInvocationMessage:
+		fields = []interface{}{m.Type, headers, m.InvocationID}
+	case CloseMessage:
+		var closeError interface{}
+		if m.Error != "" {
+			closeError = m.Error
+		}
+		fields = []interface{}{m.Type, closeError, m.AllowReconnect}
+	case HubMessage:
+		fields = []interface{}{m.Type}
+	default:
+		return fmt.Errorf("cbor hub protocol can not write message %#v", message)
+	}
+
+	data, err := cbor.Marshal(fields)
+	if err != nil {
+		return err
+	}
+	// One write per message, so the underlying Writer gets complete messages
+	_, err = writer.Write(writeBinaryMessageFormat(data))
+	return err
+}
@@ -0,0 +1,90 @@
+package signalr
+
+import (
+	"bytes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("CborHubProtocol", func() {
+	protocol := &CborHubProtocol{}
+
+	roundtrip := func(message interface{}) interface{} {
+		var buf bytes.Buffer
+		Expect(protocol.WriteMessage(message, &buf)).To(Succeed())
+		read, complete, err := protocol.ReadMessage(&buf)
+		Expect(err).To(BeNil())
+		Expect(complete).To(BeTrue())
+		Expect(buf.Len()).To(Equal(0))
+		return read
+	}
+
+	Describe("Invocation", func() {
+		Context("When written and read", func() {
+			It("should keep target, invocation id, arguments and stream ids", func() {
+				read := roundtrip(InvocationMessage{Type: 1, Target: "send", InvocationID: "1", Arguments: []interface{}{"a", 5}, StreamIds: []string{"s"}})
+				invocation := read.(InvocationMessage)
+				Expect(invocation.Target).To(Equal("send"))
+				Expect(invocation.InvocationID).To(Equal("1"))
+				Expect(invocation.StreamIds).To(Equal([]string{"s"}))
+				Expect(invocation.Arguments).To(HaveLen(2))
+				var s string
+				var i int
+				Expect(protocol.UnmarshalArgument(invocation.Arguments[0], &s)).To(Succeed())
+				Expect(protocol.UnmarshalArgument(invocation.Arguments[1], &i)).To(Succeed())
+				Expect(s).To(Equal("a"))
+				Expect(i).To(Equal(5))
+			})
+		})
+	})
+
+	Describe("Completion", func() {
+		Context("When written and read", func() {
+			It("should keep error, void and non void results", func() {
+				Expect(roundtrip(CompletionMessage{Type: 3, InvocationID: "1", Error: "failed"})).To(Equal(CompletionMessage{Type: 3, InvocationID: "1", Error: "failed"}))
+				Expect(roundtrip(CompletionMessage{Type: 3, InvocationID: "2"})).To(Equal(CompletionMessage{Type: 3, InvocationID: "2"}))
+				Expect(roundtrip(CompletionMessage{Type: 3, InvocationID: "3", Result: "ok"})).To(Equal(CompletionMessage{Type: 3, InvocationID: "3", Result: "ok"}))
+			})
+		})
+	})
+
+	Describe("Ping and Close", func() {
+		Context("When written and read", func() {
+			It("should be read as the same messages", func() {
+				Expect(roundtrip(HubMessage{Type: 6})).To(Equal(HubMessage{Type: 6}))
+				Expect(roundtrip(CloseMessage{Type: 7, Error: "bye", AllowReconnect: true})).To(Equal(CloseMessage{Type: 7, Error: "bye", AllowReconnect: true}))
+			})
+		})
+	})
+
+	Describe("Partial message", func() {
+		Context("When the buffer contains only a part of a message", func() {
+			It("should not be complete and leave the buffer untouched", func() {
+				var buf bytes.Buffer
+				Expect(protocol.WriteMessage(StreamItemMessage{Type: 2, InvocationID: "1", Item: "item"}, &buf)).To(Succeed())
+				full := buf.Bytes()
+				partial := bytes.NewBuffer(append([]byte(nil), full[:len(full)-2]...))
+				_, complete, _ := protocol.ReadMessage(partial)
+				Expect(complete).To(BeFalse())
+				Expect(partial.Len()).To(Equal(len(full) - 2))
+			})
+		})
+	})
+
+	Describe("Length prefix", func() {
+		Context("When the prefix announces a message larger than 2GB", func() {
+			It("should fail without waiting for the message", func() {
+				_, complete, err := protocol.ReadMessage(bytes.NewBuffer([]byte{0xff, 0xff, 0xff, 0xff, 0x08}))
+				Expect(complete).To(BeTrue())
+				Expect(err).NotTo(BeNil())
+			})
+		})
+		Context("When the prefix announces a message of 2GB - 1", func() {
+			It("should wait for the message", func() {
+				_, complete, _ := protocol.ReadMessage(bytes.NewBuffer([]byte{0xff, 0xff, 0xff, 0xff, 0x07}))
+				Expect(complete).To(BeFalse())
+			})
+		})
+	})
+})
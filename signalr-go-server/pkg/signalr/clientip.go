@@ -0,0 +1,92 @@
+package signalr
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// TrustProxies sets the reverse proxies, e.g. nginx or a load balancer, whose X-Forwarded-For and Forwarded headers
+// are used to find the IP of the client. proxies are IP addresses or CIDR networks like "10.0.0.0/8".
+// Without trusted proxies, the client IP is the remote address of the request. TrustProxies panics on invalid addresses
+func TrustProxies(proxies ...string) Option {
+	networks := make([]*net.IPNet, 0, len(proxies))
+	for _, proxy := range proxies {
+		if !strings.Contains(proxy, "/") {
+			if ip := net.ParseIP(proxy); ip != nil && ip.To4() != nil {
+				proxy += "/32"
+			} else {
+				proxy += "/128"
+			}
+		}
+		_, network, err := net.ParseCIDR(proxy)
+		if err != nil {
+			panic(fmt.Sprintf("signalr: invalid trusted proxy: %v", err))
+		}
+		networks = append(networks, network)
+	}
+	return func(s *Server) {
+		s.trustedProxies = append(s.trustedProxies, networks...)
+	}
+}
+
+func (s *Server) isTrustedProxy(ip net.IP) bool {
+	for _, network := range s.trustedProxies {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientIP returns the IP of the client which sent req. Starting with the remote address of the request,
+// the addresses the trusted proxies forwarded for are followed from the nearest to the farthest,
+// until an address is reached which is not a trusted proxy
+func (s *Server) clientIP(req *http.Request) string {
+	remote := req.RemoteAddr
+	if host, _, err := net.SplitHostPort(remote); err == nil {
+		remote = host
+	}
+	hops := forwardedFor(req.Header)
+	for i := len(hops) - 1; i >= 0; i-- {
+		ip := net.ParseIP(remote)
+		if ip == nil || !s.isTrustedProxy(ip) {
+			break
+		}
+		remote = hops[i]
+	}
+	return remote
+}
+
+// forwardedFor returns the client addresses of the Forwarded header, or of X-Forwarded-For if there is no
+// Forwarded header, the address of the client first and the address of the proxy nearest to the server last
+func forwardedFor(header http.Header) []string {
+	var hops []string
+	if forwarded := header.Values("Forwarded"); len(forwarded) > 0 {
+		for _, element := range strings.Split(strings.Join(forwarded, ","), ",") {
+			for _, pair := range strings.Split(element, ";") {
+				if kv := strings.SplitN(strings.TrimSpace(pair), "=", 2); len(kv) == 2 && strings.EqualFold(kv[0], "for") {
+					hops = append(hops, forwardedNode(strings.Trim(kv[1], `"`)))
+				}
+			}
+		}
+		return hops
+	}
+	for _, value := range header.Values("X-Forwarded-For") {
+		for _, hop := range strings.Split(value, ",") {
+			if hop = strings.TrimSpace(hop); hop != "" {
+				hops = append(hops, hop)
+			}
+		}
+	}
+	return hops
+}
+
+// forwardedNode strips the port and the brackets of IPv6 addresses from a node of the Forwarded header
+func forwardedNode(node string) string {
+	if host, _, err := net.SplitHostPort(node); err == nil {
+		return host
+	}
+	return strings.TrimSuffix(strings.TrimPrefix(node, "["), "]")
+}
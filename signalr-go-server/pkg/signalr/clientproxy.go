@@ -30,3 +30,39 @@ type groupClientProxy struct {
 func (g *groupClientProxy) Send(target string, args ...interface{}) {
 	g.lifetimeManager.InvokeGroup(g.groupName, target, args)
 }
+
+type multiClientProxy struct {
+	connectionIDs   []string
+	lifetimeManager HubLifetimeManager
+}
+
+func (m *multiClientProxy) Send(target string, args ...interface{}) {
+	m.lifetimeManager.InvokeClients(m.connectionIDs, target, args)
+}
+
+type usersClientProxy struct {
+	userIDs         []string
+	lifetimeManager HubLifetimeManager
+}
+
+func (u *usersClientProxy) Send(target string, args ...interface{}) {
+	u.lifetimeManager.InvokeUsers(u.userIDs, target, args)
+}
+
+type groupsClientProxy struct {
+	groupNames      []string
+	lifetimeManager HubLifetimeManager
+}
+
+func (g *groupsClientProxy) Send(target string, args ...interface{}) {
+	g.lifetimeManager.InvokeGroups(g.groupNames, target, args)
+}
+
+type taggedClientProxy struct {
+	expression      *TagExpression
+	lifetimeManager HubLifetimeManager
+}
+
+func (t *taggedClientProxy) Send(target string, args ...interface{}) {
+	t.lifetimeManager.InvokeTagged(t.expression, target, args)
+}
@@ -0,0 +1,27 @@
+package signalr
+
+import "time"
+
+// Clock is the source of time for the timeouts, keep alive intervals and TTLs of a server.
+// signalrtest.FakeClock is a Clock for tests which only advances when told to.
+// AfterFunc calls f in its own goroutine after d and returns a function which stops the timer.
+// Stop returns false if the timer has already fired or has been stopped
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+	AfterFunc(d time.Duration, f func()) (stop func() bool)
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+func (realClock) AfterFunc(d time.Duration, f func()) func() bool {
+	return time.AfterFunc(d, f).Stop
+}
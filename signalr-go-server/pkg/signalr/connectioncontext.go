@@ -0,0 +1,243 @@
+package signalr
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"reflect"
+	"time"
+)
+
+// ConnectionContext gives hub methods access to the connection they are invoked on.
+// A hub method gets the ConnectionContext passed when its first parameter is of type ConnectionContext
+// Query() returns the query string parameters of the request which started the connection
+// Header() returns the headers of the request which started the connection. Only the headers configured with ConnectionHeaders are available
+// UserID() returns the ID of the user of the connection, as given by the UserIDProvider configured with IdentifyUser
+// Tenant() returns the key of the tenant of the connection, as given by the TenantProvider configured with IsolateTenants
+// Capabilities() returns the features the client announced in the handshake
+// ClientIP() returns the IP of the client. Behind proxies trusted with TrustProxies, it is taken from the
+// X-Forwarded-For or Forwarded headers, otherwise it is the remote address of the request which started the connection
+// Features() returns the features of the connection, published by its transport or attached by middleware
+// Locale() returns the preferred language of the client, e.g. "de-CH", to localize the messages sent to it.
+// It is the CapabilityLocale of the handshake or the preferred language of the Accept-Language header
+// of the request which started the connection, "" if the client did not tell it
+// CorrelationID() returns the correlation ID of the connection, which is added to its log entries, see CorrelationHeader
+// RoundTrip() returns the last round trip time of the connection, if the server measures it with MeasureRoundTrip
+// Context() returns a context with the values of the context of the request which started the connection,
+// e.g. set by authentication middleware. It is cancelled when the connection has been closed completely, after
+// OnDisconnected has been called and the connection has been removed from its groups. Goroutines started by hub methods
+// can end with the connection by waiting for Context().Done().
+// A hub method whose first parameters are of type ConnectionContext or context.Context gets them passed, in any order.
+// The context.Context passed to a hub method is created for the invocation and derived from Context()
+type ConnectionContext interface {
+	ConnectionID() string
+	UserID() string
+	Tenant() string
+	Query() url.Values
+	Header() http.Header
+	Capabilities() Capabilities
+	ClientIP() string
+	Locale() string
+	CorrelationID() string
+	Features() *Features
+	RoundTrip() time.Duration
+	Context() context.Context
+}
+
+var connectionContextType = reflect.TypeOf((*ConnectionContext)(nil)).Elem()
+var contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+
+// requestMetadata is embedded by transport connections which are started by a http request
+type requestMetadata struct {
+	query    url.Values
+	header   http.Header
+	ctx      context.Context
+	features map[string]interface{}
+	// accessToken is the access token sent with the request, see RefreshTokens
+	accessToken string
+}
+
+func (r requestMetadata) requestContext() context.Context {
+	return r.ctx
+}
+
+func (r requestMetadata) requestFeatures() map[string]interface{} {
+	return r.features
+}
+
+func (r requestMetadata) requestAccessToken() string {
+	return r.accessToken
+}
+
+// valuesContext carries the values of a request context, but not its deadline and cancellation,
+// which end with the request, not with the connection started by it
+type valuesContext struct {
+	context.Context
+}
+
+func (valuesContext) Deadline() (time.Time, bool) {
+	return time.Time{}, false
+}
+
+func (valuesContext) Done() <-chan struct{} {
+	return nil
+}
+
+func (valuesContext) Err() error {
+	return nil
+}
+
+func (r requestMetadata) Query() url.Values {
+	return r.query
+}
+
+func (r requestMetadata) Header() http.Header {
+	return r.header
+}
+
+// newRequestMetadata builds the metadata of a transport request. negotiateHeader are the headers kept
+// from the negotiate request of the connection. Headers sent with both requests are taken from the transport request
+func (s *Server) newRequestMetadata(req *http.Request, negotiateHeader http.Header) requestMetadata {
+	query := url.Values{}
+	for key, values := range req.URL.Query() {
+		// id is the connection ID and access_token the bearer token of the client, both are not client metadata
+		if key != "id" && key != "access_token" {
+			query[key] = values
+		}
+	}
+	header := http.Header{}
+	for key, values := range negotiateHeader {
+		// The correlation ID is kept with the negotiate headers, but it is a header of the connection only if configured
+		if key == http.CanonicalHeaderKey(s.correlationHeader) && !s.selectsHeader(key) {
+			continue
+		}
+		header[key] = values
+	}
+	for key, values := range s.selectHeaders(req) {
+		header[key] = values
+	}
+	features := map[string]interface{}{FeatureRemoteAddr: req.RemoteAddr, FeatureClientIP: s.clientIP(req), FeatureHost: req.Host,
+		FeatureCorrelationID: s.requestCorrelationID(req, negotiateHeader)}
+	if req.TLS != nil {
+		features[FeatureTLS] = req.TLS
+	}
+	if locale := preferredLanguage(req.Header.Get("Accept-Language")); locale != "" {
+		features[FeatureLocale] = locale
+	}
+	if attached, ok := req.Context().Value(featuresKey{}).(map[string]interface{}); ok {
+		for name, value := range attached {
+			features[name] = value
+		}
+	}
+	return requestMetadata{query: query, header: header, ctx: valuesContext{req.Context()}, features: features, accessToken: bearerToken(req)}
+}
+
+// selectHeaders returns the headers of req which are configured with ConnectionHeaders
+func (s *Server) selectHeaders(req *http.Request) http.Header {
+	header := http.Header{}
+	for _, name := range s.connectionHeaders {
+		if values, ok := req.Header[http.CanonicalHeaderKey(name)]; ok {
+			header[http.CanonicalHeaderKey(name)] = values
+		}
+	}
+	return header
+}
+
+// selectsHeader returns if the header name is configured with ConnectionHeaders
+func (s *Server) selectsHeader(name string) bool {
+	for _, selected := range s.connectionHeaders {
+		if http.CanonicalHeaderKey(selected) == http.CanonicalHeaderKey(name) {
+			return true
+		}
+	}
+	return false
+}
+
+type defaultConnectionContext struct {
+	requestMetadata
+	connectionID string
+	userID       string
+	tenant       string
+	capabilities Capabilities
+	features     *Features
+	cancel       context.CancelFunc
+	// hubConn is the hubConnection running on the connection, nil until it has been created
+	hubConn hubConnection
+}
+
+func newConnectionContext(conn Connection) *defaultConnectionContext {
+	connectionContext := &defaultConnectionContext{
+		requestMetadata: requestMetadata{query: url.Values{}, header: http.Header{}, ctx: context.Background()},
+		connectionID:    conn.ConnectionID(),
+		capabilities:    Capabilities{},
+	}
+	if metadata, ok := conn.(interface {
+		Query() url.Values
+		Header() http.Header
+	}); ok {
+		connectionContext.query = metadata.Query()
+		connectionContext.header = metadata.Header()
+	}
+	if metadata, ok := conn.(interface{ requestContext() context.Context }); ok && metadata.requestContext() != nil {
+		connectionContext.ctx = metadata.requestContext()
+	}
+	if metadata, ok := conn.(interface{ requestFeatures() map[string]interface{} }); ok {
+		connectionContext.features = newFeatures(metadata.requestFeatures())
+	} else {
+		connectionContext.features = newFeatures(nil)
+	}
+	connectionContext.ctx, connectionContext.cancel = context.WithCancel(connectionContext.ctx)
+	return connectionContext
+}
+
+func (d *defaultConnectionContext) Context() context.Context {
+	return d.ctx
+}
+
+func (d *defaultConnectionContext) ConnectionID() string {
+	return d.connectionID
+}
+
+func (d *defaultConnectionContext) UserID() string {
+	return d.userID
+}
+
+func (d *defaultConnectionContext) Tenant() string {
+	return d.tenant
+}
+
+func (d *defaultConnectionContext) ClientIP() string {
+	if clientIP, ok := d.features.Get(FeatureClientIP); ok {
+		return clientIP.(string)
+	}
+	return ""
+}
+
+func (d *defaultConnectionContext) Locale() string {
+	if locale, ok := d.features.Get(FeatureLocale); ok {
+		return locale.(string)
+	}
+	return ""
+}
+
+func (d *defaultConnectionContext) CorrelationID() string {
+	if correlationID, ok := d.features.Get(FeatureCorrelationID); ok {
+		return correlationID.(string)
+	}
+	return ""
+}
+
+func (d *defaultConnectionContext) RoundTrip() time.Duration {
+	if d.hubConn == nil {
+		return 0
+	}
+	return d.hubConn.Stats().RoundTrip
+}
+
+func (d *defaultConnectionContext) Features() *Features {
+	return d.features
+}
+
+func (d *defaultConnectionContext) Capabilities() Capabilities {
+	return d.capabilities
+}
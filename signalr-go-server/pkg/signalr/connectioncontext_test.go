@@ -0,0 +1,270 @@
+package signalr
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/net/websocket"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type connectionContextHub struct {
+	Hub
+}
+
+func (c *connectionContextHub) Caller(connectionContext ConnectionContext, value string) []string {
+	return []string{connectionContext.ConnectionID(), connectionContext.Query().Get("version"), connectionContext.Header().Get("X-Device"), value}
+}
+
+func (c *connectionContextHub) Payload(connectionContext ConnectionContext) string {
+	if connectionContext.Capabilities().Supports("compact") && !connectionContext.Capabilities().AtLeastVersion("appVersion", "2.10") {
+		return "compact"
+	}
+	return "full"
+}
+
+func (c *connectionContextHub) Locale(connectionContext ConnectionContext) string {
+	return connectionContext.Locale()
+}
+
+type requestValueKey struct{}
+
+func (c *connectionContextHub) RequestValue(ctx context.Context, connectionContext ConnectionContext) []interface{} {
+	return []interface{}{ctx.Value(requestValueKey{}), connectionContext.Context().Value(requestValueKey{}), InvocationID(ctx), ctx.Err()}
+}
+
+func (c *connectionContextHub) Features(connectionContext ConnectionContext) []interface{} {
+	transport, _ := connectionContext.Features().Get(FeatureTransport)
+	tenant, _ := connectionContext.Features().Get("Tenant")
+	_, remote := connectionContext.Features().Get(FeatureRemoteAddr)
+	return []interface{}{transport, tenant, remote}
+}
+
+// lifetimeHub starts a goroutine tied to the connection and reports if the hub had been disconnected when it ended
+type lifetimeHub struct {
+	Hub
+	disconnected int32
+	stopped      chan bool
+}
+
+func (l *lifetimeHub) Watch(connectionContext ConnectionContext) {
+	go func() {
+		<-connectionContext.Context().Done()
+		l.stopped <- atomic.LoadInt32(&l.disconnected) == 1
+	}()
+}
+
+func (l *lifetimeHub) OnDisconnected(string) {
+	atomic.StoreInt32(&l.disconnected, 1)
+}
+
+type metadataConnection struct {
+	requestMetadata
+	*testingConnection
+}
+
+var _ = Describe("ConnectionContext", func() {
+
+	Describe("Invocation with ConnectionContext parameter", func() {
+		server := NewServer(&connectionContextHub{}, ConnectionHeaders("x-device"))
+		req := httptest.NewRequest("GET", "/hub?id=test&version=1.2", nil)
+		req.Header.Set("X-Device", "phone")
+		req.Header.Set("X-Other", "other")
+		conn := newTestingConnection()
+		go server.Run(&metadataConnection{server.newRequestMetadata(req, nil), conn})
+		Context("When invoked by the client", func() {
+			It("should get the ConnectionContext with the connection metadata and the client arguments", func() {
+				_, err := conn.clientSend(`{"type":1,"invocationId": "ctx","target":"caller","arguments":["value"]}`)
+				Expect(err).To(BeNil())
+				recv := (<-conn.received).(CompletionMessage)
+				Expect(recv.Error).To(Equal(""))
+				Expect(recv.Result).To(Equal([]interface{}{"test", "1.2", "phone", "value"}))
+			})
+		})
+	})
+
+	Describe("Request metadata", func() {
+		server := NewServer(&connectionContextHub{}, ConnectionHeaders("X-Device"))
+		req := httptest.NewRequest("GET", "/hub?id=abc&access_token=secret&version=1.2", nil)
+		req.Header.Set("X-Device", "phone")
+		req.Header.Set("X-Other", "other")
+		Context("When built from a request", func() {
+			It("should contain the query parameters without the connection id and access token and only the selected headers", func() {
+				metadata := server.newRequestMetadata(req, nil)
+				Expect(metadata.Query()).NotTo(HaveKey("id"))
+				Expect(metadata.Query()).NotTo(HaveKey("access_token"))
+				Expect(metadata.Query().Get("version")).To(Equal("1.2"))
+				Expect(metadata.Header()).To(Equal(http.Header{"X-Device": []string{"phone"}}))
+			})
+		})
+		Context("When built from a request with headers kept from negotiate", func() {
+			It("should contain the negotiate headers, overridden by the transport request headers", func() {
+				metadata := server.newRequestMetadata(req, http.Header{"X-Device": []string{"tablet"}, "X-Build": []string{"42"}})
+				Expect(metadata.Header()).To(Equal(http.Header{"X-Device": []string{"phone"}, "X-Build": []string{"42"}}))
+			})
+		})
+	})
+
+	Describe("Client IP", func() {
+		server := NewServer(&connectionContextHub{}, TrustProxies("10.0.0.0/8", "192.168.1.1"))
+		clientIP := func(remoteAddr string, header http.Header) string {
+			req := httptest.NewRequest("GET", "/hub?id=abc", nil)
+			req.RemoteAddr = remoteAddr
+			req.Header = header
+			return server.newRequestMetadata(req, nil).features[FeatureClientIP].(string)
+		}
+		Context("When the request comes from a trusted proxy", func() {
+			It("should follow X-Forwarded-For until the first untrusted address", func() {
+				header := http.Header{"X-Forwarded-For": []string{"203.0.113.7, 198.51.100.2", "10.1.1.1"}}
+				Expect(clientIP("192.168.1.1:4711", header)).To(Equal("198.51.100.2"))
+			})
+			It("should prefer the Forwarded header", func() {
+				header := http.Header{
+					"Forwarded":       []string{`for=203.0.113.7;proto=https, for="[2001:db8::1]:4711"`},
+					"X-Forwarded-For": []string{"198.51.100.2"},
+				}
+				Expect(clientIP("10.0.0.1:4711", header)).To(Equal("2001:db8::1"))
+			})
+		})
+		Context("When the request does not come from a trusted proxy", func() {
+			It("should ignore the forwarding headers", func() {
+				header := http.Header{"X-Forwarded-For": []string{"203.0.113.7"}}
+				Expect(clientIP("198.51.100.9:4711", header)).To(Equal("198.51.100.9"))
+			})
+		})
+		Context("When a trusted proxy is invalid", func() {
+			It("should panic", func() {
+				Expect(func() { TrustProxies("10.0.0.0/33") }).To(Panic())
+			})
+		})
+	})
+
+	Describe("Request context values", func() {
+		mux := http.NewServeMux()
+		MapHub(mux, "/hub", &connectionContextHub{})
+		// middleware which sets a request scoped value, e.g. the result of authentication
+		middleware := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			mux.ServeHTTP(w, req.WithContext(context.WithValue(req.Context(), requestValueKey{}, "from middleware")))
+		})
+		Context("When a hub method is invoked over a connection started through middleware", func() {
+			It("should get the request context values in the invocation context and the connection context", func() {
+				httpServer := httptest.NewServer(middleware)
+				defer httpServer.Close()
+				ws, err := dialHub(httpServer, "")
+				Expect(err).To(BeNil())
+				defer ws.Close()
+				Expect(websocket.Message.Send(ws, `{"type":1,"invocationId":"value","target":"requestvalue"}`+"\u001e")).To(Succeed())
+				for {
+					var message string
+					Expect(websocket.Message.Receive(ws, &message)).To(Succeed())
+					if strings.Contains(message, `"type":3`) {
+						Expect(message).To(ContainSubstring(`"result":["from middleware","from middleware","value",null]`))
+						break
+					}
+				}
+			})
+		})
+	})
+
+	Describe("Connection features", func() {
+		mux := http.NewServeMux()
+		MapHub(mux, "/hub", &connectionContextHub{})
+		middleware := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			mux.ServeHTTP(w, req.WithContext(WithFeature(req.Context(), "Tenant", "acme")))
+		})
+		Context("When a hub method inspects the features of a WebSocket connection", func() {
+			It("should get the features of the transport and the features attached by middleware", func() {
+				httpServer := httptest.NewServer(middleware)
+				defer httpServer.Close()
+				ws, err := dialHub(httpServer, "")
+				Expect(err).To(BeNil())
+				defer ws.Close()
+				Expect(websocket.Message.Send(ws, `{"type":1,"invocationId":"features","target":"features"}`+"\u001e")).To(Succeed())
+				for {
+					var message string
+					Expect(websocket.Message.Receive(ws, &message)).To(Succeed())
+					if strings.Contains(message, `"type":3`) {
+						Expect(message).To(ContainSubstring(`"result":["WebSockets","acme",true]`))
+						break
+					}
+				}
+			})
+		})
+	})
+
+	Describe("Handshake with capabilities", func() {
+		server := NewServer(&connectionContextHub{})
+		conn := newTestingConnectionWithHandshake(`{"protocol": "json","version": 1,"capabilities":{"compact":true,"appVersion":"2.9.3"}}`)
+		go server.Run(conn)
+		Context("When the hub branches on the capabilities of the client", func() {
+			It("should get the capabilities announced in the handshake", func() {
+				_, err := conn.clientSend(`{"type":1,"invocationId": "caps","target":"payload"}`)
+				Expect(err).To(BeNil())
+				Expect((<-conn.received).(CompletionMessage).Result).To(Equal("compact"))
+			})
+		})
+	})
+
+	Describe("Handshake without capabilities", func() {
+		server := NewServer(&connectionContextHub{})
+		conn := newTestingConnection()
+		go server.Run(conn)
+		Context("When the hub branches on the capabilities of the client", func() {
+			It("should get no capabilities", func() {
+				_, err := conn.clientSend(`{"type":1,"invocationId": "nocaps","target":"payload"}`)
+				Expect(err).To(BeNil())
+				Expect((<-conn.received).(CompletionMessage).Result).To(Equal("full"))
+			})
+		})
+	})
+
+	Describe("Locale", func() {
+		server := NewServer(&connectionContextHub{})
+		req := httptest.NewRequest("GET", "/hub", nil)
+		req.Header.Set("Accept-Language", "en;q=0.8, de-CH, *;q=0.5")
+		locale := func(connectionID string, conn *testingConnection) interface{} {
+			go server.Run(&userConnection{server.newRequestMetadata(req, nil), conn, connectionID})
+			_, err := conn.clientSend(`{"type":1,"invocationId": "locale","target":"locale"}`)
+			Expect(err).To(BeNil())
+			return (<-conn.received).(CompletionMessage).Result
+		}
+		Context("When the client sends an Accept-Language header", func() {
+			It("should be the preferred language of the header", func() {
+				Expect(locale("header", newTestingConnection())).To(Equal("de-CH"))
+			})
+		})
+		Context("When the client announces its locale in the handshake", func() {
+			It("should be the locale of the handshake", func() {
+				Expect(locale("handshake", newTestingConnectionWithHandshake(`{"protocol": "json","version": 1,"capabilities":{"locale":"fr-FR"}}`))).To(Equal("fr-FR"))
+			})
+		})
+	})
+
+	Describe("Connection lifetime", func() {
+		hub := &lifetimeHub{stopped: make(chan bool, 1)}
+		server := NewServer(hub)
+		conn := newTestingConnection()
+		go server.Run(conn)
+		Context("When a hub method starts a goroutine waiting for the connection context", func() {
+			It("should keep the goroutine running until the connection has been closed completely", func() {
+				_, err := conn.clientSend(`{"type":1,"invocationId": "watch","target":"watch"}`)
+				Expect(err).To(BeNil())
+				Expect((<-conn.received).(CompletionMessage).InvocationID).To(Equal("watch"))
+				Consistently(hub.stopped, 100*time.Millisecond).ShouldNot(Receive())
+				go func() {
+					for range conn.received {
+					}
+				}()
+				_, err = conn.clientSend(`{"type":7}`)
+				Expect(err).To(BeNil())
+				Eventually(hub.stopped).Should(Receive(BeTrue()))
+			})
+		})
+	})
+})
@@ -0,0 +1,247 @@
+package signalr
+
+import (
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const defaultNegotiateTimeout = 30 * time.Second
+
+// connectionRegistry keeps track of the connection IDs issued by negotiate
+// and the connection IDs which are bound to a live transport connection
+type connectionRegistry struct {
+	mx               sync.Mutex
+	negotiateTimeout time.Duration
+	negotiated       map[string]negotiatedConnection
+	live             map[string]*liveConnection
+	draining         bool
+	clock            Clock
+	// sweepAt is the number of negotiated connection IDs at which addNegotiated removes the expired ones
+	sweepAt int
+	// maxConnections and maxUserConnections limit the live connections in total and per user, 0 means no limit
+	maxConnections     int
+	maxUserConnections int
+	// users is the number of attached connections by user ID
+	users map[string]int
+}
+
+type negotiatedConnection struct {
+	issued time.Time
+	header http.Header
+	// fallback is why the connection did not start on WebSockets, if it starts on another transport
+	fallback TransportFallback
+}
+
+type liveConnection struct {
+	conn    Connection
+	hubConn hubConnection
+	done    chan struct{}
+	// connected is the time the connection has been bound
+	connected time.Time
+}
+
+func newConnectionRegistry() *connectionRegistry {
+	return &connectionRegistry{
+		negotiateTimeout: defaultNegotiateTimeout,
+		clock:            realClock{},
+		negotiated:       make(map[string]negotiatedConnection),
+		live:             make(map[string]*liveConnection),
+		users:            make(map[string]int),
+	}
+}
+
+// addNegotiated registers a connection ID issued by negotiate together with the headers kept from
+// the negotiate request. When the registered IDs have doubled since they have been swept, the expired ones
+// are removed
+func (r *connectionRegistry) addNegotiated(connectionID string, header http.Header) {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	now := r.clock.Now()
+	r.negotiated[connectionID] = negotiatedConnection{issued: now, header: header}
+	if len(r.negotiated) < r.sweepAt {
+		return
+	}
+	for id, negotiated := range r.negotiated {
+		if now.Sub(negotiated.issued) > r.negotiateTimeout {
+			delete(r.negotiated, id)
+		}
+	}
+	r.sweepAt = nextSweep(len(r.negotiated))
+}
+
+// claimNegotiated claims a connection ID issued by negotiate and returns the headers kept from the negotiate request.
+// Each ID can only be claimed once
+func (r *connectionRegistry) claimNegotiated(connectionID string) (http.Header, bool) {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	negotiated, ok := r.negotiated[connectionID]
+	if !ok {
+		return nil, false
+	}
+	delete(r.negotiated, connectionID)
+	return negotiated.header, r.clock.Now().Sub(negotiated.issued) <= r.negotiateTimeout
+}
+
+// noteFallback records why the connection with the negotiated ID could not start on WebSockets.
+// The first failed upgrade is kept, it tells the cause best
+func (r *connectionRegistry) noteFallback(connectionID string, reason string, err string) {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	if negotiated, ok := r.negotiated[connectionID]; ok && negotiated.fallback.Reason != FallbackUpgradeFailed {
+		negotiated.fallback = TransportFallback{Reason: reason, Error: err}
+		r.negotiated[connectionID] = negotiated
+	}
+}
+
+// fallback returns why the connection with the negotiated ID did not start on WebSockets. It must be called before
+// the ID is claimed
+func (r *connectionRegistry) fallback(connectionID string) TransportFallback {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	if fallback := r.negotiated[connectionID].fallback; fallback.Reason != "" {
+		return fallback
+	}
+	return TransportFallback{Reason: FallbackNoAttempt}
+}
+
+// isNegotiated returns if the connection ID has been issued by negotiate, has not expired and has not been claimed yet
+func (r *connectionRegistry) isNegotiated(connectionID string) bool {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	negotiated, ok := r.negotiated[connectionID]
+	return ok && r.clock.Now().Sub(negotiated.issued) <= r.negotiateTimeout
+}
+
+// bind binds the connection ID of conn to conn. If the ID is already bound to another live connection,
+// bind fails, or, with takeover, closes the other connection and waits until it has been cleaned up.
+// While the registry is draining or when it has the maximum number of connections, bind fails
+func (r *connectionRegistry) bind(conn Connection, takeover bool) (*liveConnection, bool) {
+	for {
+		r.mx.Lock()
+		if r.draining {
+			r.mx.Unlock()
+			return nil, false
+		}
+		existing, ok := r.live[conn.ConnectionID()]
+		if !ok {
+			if r.maxConnections > 0 && len(r.live) >= r.maxConnections {
+				r.mx.Unlock()
+				return nil, false
+			}
+			live := &liveConnection{conn: conn, done: make(chan struct{}), connected: r.clock.Now()}
+			r.live[conn.ConnectionID()] = live
+			r.mx.Unlock()
+			return live, true
+		}
+		hubConn := existing.hubConn
+		r.mx.Unlock()
+		closer, ok := existing.conn.(io.Closer)
+		if !takeover || !ok {
+			return nil, false
+		}
+		if hubConn != nil {
+			hubConn.SetDisconnectReason(DisconnectKicked)
+		}
+		_ = closer.Close()
+		<-existing.done
+	}
+}
+
+// isLive returns if the connection ID is bound to a live connection
+func (r *connectionRegistry) isLive(connectionID string) bool {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	_, ok := r.live[connectionID]
+	return ok
+}
+
+// liveCount returns the number of live connections
+func (r *connectionRegistry) liveCount() int {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	return len(r.live)
+}
+
+// release unbinds the connection ID of a live connection
+func (r *connectionRegistry) release(live *liveConnection) {
+	r.mx.Lock()
+	if r.live[live.conn.ConnectionID()] == live {
+		delete(r.live, live.conn.ConnectionID())
+	}
+	if live.hubConn != nil && live.hubConn.GetUserID() != "" {
+		if r.users[live.hubConn.GetUserID()]--; r.users[live.hubConn.GetUserID()] <= 0 {
+			delete(r.users, live.hubConn.GetUserID())
+		}
+	}
+	r.mx.Unlock()
+	close(live.done)
+}
+
+// attach sets the hubConnection which runs on a live connection after the handshake succeeded.
+// It fails when the user of the hubConnection has the maximum number of connections
+func (r *connectionRegistry) attach(live *liveConnection, hubConn hubConnection) bool {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	if userID := hubConn.GetUserID(); userID != "" {
+		if r.maxUserConnections > 0 && r.users[userID] >= r.maxUserConnections {
+			return false
+		}
+		r.users[userID]++
+	}
+	live.hubConn = hubConn
+	return true
+}
+
+// atCapacity returns if the registry has the maximum number of live connections
+func (r *connectionRegistry) atCapacity() bool {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	return r.maxConnections > 0 && len(r.live) >= r.maxConnections
+}
+
+// drain stops binding new connections and returns the live connections with the hubConnection attached to them
+func (r *connectionRegistry) drain() []liveConnection {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	r.draining = true
+	live := make([]liveConnection, 0, len(r.live))
+	for _, l := range r.live {
+		live = append(live, *l)
+	}
+	return live
+}
+
+// hubConnections returns the hubConnections attached to live connections
+func (r *connectionRegistry) hubConnections() []hubConnection {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	hubConns := make([]hubConnection, 0, len(r.live))
+	for _, l := range r.live {
+		if l.hubConn != nil {
+			hubConns = append(hubConns, l.hubConn)
+		}
+	}
+	return hubConns
+}
+
+// attached returns the live connections which have a hubConnection attached
+func (r *connectionRegistry) attached() []liveConnection {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	live := make([]liveConnection, 0, len(r.live))
+	for _, l := range r.live {
+		if l.hubConn != nil {
+			live = append(live, *l)
+		}
+	}
+	return live
+}
+
+// isDraining returns if drain has been called
+func (r *connectionRegistry) isDraining() bool {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	return r.draining
+}
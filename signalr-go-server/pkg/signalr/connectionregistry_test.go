@@ -0,0 +1,207 @@
+package signalr
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"./signalrtest"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"golang.org/x/net/websocket"
+)
+
+// duplicateConnection is a Connection with the ID of testingConnection which is never served
+type duplicateConnection struct{}
+
+func (d *duplicateConnection) ConnectionID() string {
+	return "test"
+}
+
+func (d *duplicateConnection) Read([]byte) (int, error) {
+	return 0, io.EOF
+}
+
+func (d *duplicateConnection) Write(b []byte) (int, error) {
+	return len(b), nil
+}
+
+func dialHub(httpServer *httptest.Server, connectionID string) (*websocket.Conn, error) {
+	wsURL := "ws" + strings.TrimPrefix(httpServer.URL, "http") + "/hub?id=" + url.QueryEscape(connectionID)
+	ws, err := websocket.Dial(wsURL, "", httpServer.URL)
+	if err != nil {
+		return nil, err
+	}
+	if err = websocket.Message.Send(ws, "{\"protocol\": \"json\",\"version\": 1}\u001e"); err != nil {
+		return nil, err
+	}
+	var response string
+	if err = websocket.Message.Receive(ws, &response); err != nil {
+		return nil, err
+	}
+	Expect(response).To(Equal("{}\u001e"))
+	return ws, nil
+}
+
+// wsInvoke invokes the Ready method of contextHub and returns the invocationId of the completion
+func wsInvoke(ws *websocket.Conn, invocationID string) string {
+	Expect(websocket.Message.Send(ws, `{"type":1,"invocationId":"`+invocationID+`","target":"ready"}`+"\u001e")).To(Succeed())
+	for {
+		var message string
+		Expect(websocket.Message.Receive(ws, &message)).To(Succeed())
+		if strings.Contains(message, `"type":3`) {
+			return message
+		}
+	}
+}
+
+var _ = Describe("Connection IDs", func() {
+
+	Describe("Duplicate connection ID", func() {
+		server := NewServer(&contextHub{})
+		conn1 := newTestingConnection()
+		go server.Run(conn1)
+		Context("When a second connection with the same ID is run", func() {
+			It("should be rejected while the first one stays connected", func() {
+				_, err := conn1.clientSend(`{"type":1,"invocationId": "first","target":"ready"}`)
+				Expect(err).To(BeNil())
+				Expect((<-conn1.received).(CompletionMessage).InvocationID).To(Equal("first"))
+				done := make(chan bool)
+				go func() {
+					server.Run(&duplicateConnection{})
+					done <- true
+				}()
+				Eventually(done).Should(Receive())
+				_, err = conn1.clientSend(`{"type":1,"invocationId": "second","target":"ready"}`)
+				Expect(err).To(BeNil())
+				Expect((<-conn1.received).(CompletionMessage).InvocationID).To(Equal("second"))
+			})
+		})
+	})
+
+	Describe("Replayed negotiated connection ID", func() {
+		mux := http.NewServeMux()
+		MapHub(mux, "/hub", &contextHub{})
+		httpServer := httptest.NewServer(mux)
+		Context("When a second WebSocket connection presents the same negotiated ID", func() {
+			It("should be rejected while the first one stays connected", func() {
+				defer httpServer.Close()
+				connectionID := negotiate(mux, "/hub")["connectionId"].(string)
+				ws1, err := dialHub(httpServer, connectionID)
+				Expect(err).To(BeNil())
+				defer ws1.Close()
+				_, err = dialHub(httpServer, connectionID)
+				Expect(err).NotTo(BeNil())
+				Expect(wsInvoke(ws1, "first")).To(ContainSubstring(`"first"`))
+			})
+		})
+	})
+
+	Describe("Connection takeover", func() {
+		mux := http.NewServeMux()
+		MapHub(mux, "/hub", &contextHub{}, AllowConnectionTakeover(true))
+		httpServer := httptest.NewServer(mux)
+		Context("When a second WebSocket connection presents the ID of a live connection", func() {
+			It("should close the old connection and serve the new one", func() {
+				defer httpServer.Close()
+				connectionID := negotiate(mux, "/hub")["connectionId"].(string)
+				ws1, err := dialHub(httpServer, connectionID)
+				Expect(err).To(BeNil())
+				defer ws1.Close()
+				ws2, err := dialHub(httpServer, connectionID)
+				Expect(err).To(BeNil())
+				defer ws2.Close()
+				// The old connection gets closed
+				Eventually(func() error {
+					var message string
+					return websocket.Message.Receive(ws1, &message)
+				}).ShouldNot(Succeed())
+				Expect(wsInvoke(ws2, "second")).To(ContainSubstring(`"second"`))
+			})
+		})
+	})
+
+	Describe("Maximum connections per user", func() {
+		server := NewServer(&contextHub{}, MaxConnectionsPerUser(1), IdentifyUser(func(ctx ConnectionContext) string {
+			return ctx.Query().Get("user")
+		}))
+		Context("When a user opens more connections than allowed", func() {
+			It("should close the connection exceeding the limit with an error", func() {
+				connectUser(server, "a", "alice")
+				connectUser(server, "b", "bob")
+				conn := newTestingConnection()
+				req := httptest.NewRequest("GET", "/hub?user=alice", nil)
+				go server.Run(&userConnection{server.newRequestMetadata(req, nil), conn, "c"})
+				Expect((<-conn.closed).Error).To(Equal("Too many connections of the user"))
+			})
+		})
+	})
+
+	Describe("Maximum connections of the server", func() {
+		mux := http.NewServeMux()
+		server := MapHub(mux, "/hub", &contextHub{}, MaxConnections(1))
+		Context("When the server has the maximum number of connections", func() {
+			It("should refuse negotiate with 429 and close further connections", func() {
+				connectUser(server, "a", "")
+				recorder := httptest.NewRecorder()
+				mux.ServeHTTP(recorder, httptest.NewRequest("POST", "/hub/negotiate", nil))
+				Expect(recorder.Code).To(Equal(429))
+				_, ok := server.connections.bind(&duplicateConnection{}, false)
+				Expect(ok).To(BeFalse())
+			})
+		})
+	})
+
+	Describe("Negotiated connection IDs", func() {
+		Context("When a negotiated ID is claimed", func() {
+			It("should only be claimable once", func() {
+				registry := newConnectionRegistry()
+				registry.addNegotiated("abc", http.Header{"X-Device": []string{"phone"}})
+				header, ok := registry.claimNegotiated("abc")
+				Expect(ok).To(BeTrue())
+				Expect(header.Get("X-Device")).To(Equal("phone"))
+				_, ok = registry.claimNegotiated("abc")
+				Expect(ok).To(BeFalse())
+				_, ok = registry.claimNegotiated("unknown")
+				Expect(ok).To(BeFalse())
+			})
+		})
+		Context("When a negotiated ID is not claimed in time", func() {
+			It("should expire", func() {
+				registry := newConnectionRegistry()
+				clock := signalrtest.NewFakeClock(time.Now())
+				registry.clock = clock
+				registry.negotiateTimeout = time.Second
+				registry.addNegotiated("abc", nil)
+				clock.Advance(2 * time.Second)
+				_, ok := registry.claimNegotiated("abc")
+				Expect(ok).To(BeFalse())
+			})
+		})
+		Context("When IDs are negotiated and never claimed", func() {
+			It("should remove the expired ones once the IDs have doubled", func() {
+				registry := newConnectionRegistry()
+				clock := signalrtest.NewFakeClock(time.Now())
+				registry.clock = clock
+				registry.negotiateTimeout = time.Second
+				for i := 0; i < minSweepSize; i++ {
+					registry.addNegotiated(strconv.Itoa(i), nil)
+				}
+				clock.Advance(2 * time.Second)
+				fresh := 0
+				for len(registry.negotiated) < registry.sweepAt-1 {
+					registry.addNegotiated("fresh"+strconv.Itoa(fresh), nil)
+					fresh++
+				}
+				Expect(registry.negotiated).To(HaveKey("0"))
+				registry.addNegotiated("fresh"+strconv.Itoa(fresh), nil)
+				Expect(registry.negotiated).To(HaveLen(fresh + 1))
+				Expect(registry.negotiated).NotTo(HaveKey("0"))
+			})
+		})
+	})
+})
@@ -0,0 +1,189 @@
+package signalr
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// ConnectionTokenOptions configures the connection tokens of a server, see SignConnectionTokens
+type ConnectionTokenOptions struct {
+	// Key is the secret key of the server the tokens are signed with, it should have at least 32 random bytes.
+	// Servers sharing a key accept the tokens of each other
+	Key []byte
+	// Encrypt encrypts the tokens, so clients can not read when they have been issued
+	Encrypt bool
+	// MaxAge is the time after negotiate in which a token can start a connection, a zero MaxAge is 30 seconds
+	MaxAge time.Duration
+}
+
+// SignConnectionTokens lets negotiate issue connection IDs which are tokens signed with HMAC-SHA256 by the Key,
+// or with Encrypt, encrypted and authenticated with AES-GCM by a key derived from it. A token carries the time it
+// has been issued and is bound to the user the negotiate request has been authenticated as, see AuthenticateRequests.
+// Without AuthenticateRequests, it is bound to the access token of the negotiate request, and the binding is only
+// checked by the transport request which starts the connection: clients refresh their access token, e.g. the
+// JavaScript client gets a token from its accessTokenFactory for each long polling request, see RefreshTokens.
+// Transport requests whose connection ID is not a valid token of the server, belongs to another user or access token
+// or, for a connection which has not started yet, is older than MaxAge are refused with 404, so IDs can not be forged
+// or swapped between users. The token is the ID of the connection, e.g. in ConnectionContext.ConnectionID().
+// By default, connection IDs are random and only checked against the IDs issued by negotiate
+func SignConnectionTokens(options ConnectionTokenOptions) Option {
+	return func(s *Server) {
+		if options.MaxAge <= 0 {
+			options.MaxAge = defaultNegotiateTimeout
+		}
+		s.connectionTokens = &connectionTokens{
+			options:    options,
+			signingKey: deriveKey(options.Key, "signalr connection token signing"),
+			sealingKey: deriveKey(options.Key, "signalr connection token encryption"),
+		}
+	}
+}
+
+// connectionTokens issues and verifies the connection tokens of a server
+type connectionTokens struct {
+	options    ConnectionTokenOptions
+	signingKey []byte
+	sealingKey []byte
+}
+
+// Token payloads are a random ID, the issue time in unix seconds and the start of the hash of the binding
+const (
+	tokenIDSize      = 16
+	tokenIssuedSize  = 8
+	tokenBindingSize = 16
+	tokenPayloadSize = tokenIDSize + tokenIssuedSize + tokenBindingSize
+)
+
+var errInvalidConnectionToken = errors.New("invalid connection token")
+
+// deriveKey derives the key for purpose from key
+func deriveKey(key []byte, purpose string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(purpose))
+	return mac.Sum(nil)
+}
+
+// issueConnectionID returns the connection ID negotiate issues for req, which has been authenticated as identity
+func (s *Server) issueConnectionID(req *http.Request, identity Identity) string {
+	if s.connectionTokens == nil {
+		return s.getConnectionID()
+	}
+	subject, _ := s.tokenSubject(req, identity)
+	token, err := s.connectionTokens.issue(subject, s.clock.Now())
+	if err != nil {
+		_ = s.logger.Log("event", "cannot issue connection token", "error", err)
+		return s.getConnectionID()
+	}
+	return token
+}
+
+// verifyConnectionID returns if the connection ID of a transport request, which has been authenticated as identity,
+// is acceptable
+func (s *Server) verifyConnectionID(req *http.Request, connectionID string, identity Identity) bool {
+	if s.connectionTokens == nil {
+		return true
+	}
+	subject, stable := s.tokenSubject(req, identity)
+	negotiated := s.connections.isNegotiated(connectionID)
+	issued, err := s.connectionTokens.verify(connectionID, subject, stable || negotiated)
+	if err != nil {
+		_ = s.logger.Log("event", "connection token refused", "error", err)
+		return false
+	}
+	if negotiated && s.clock.Now().Sub(issued) > s.connectionTokens.options.MaxAge {
+		_ = s.logger.Log("event", "connection token refused", "error", "token expired")
+		return false
+	}
+	return true
+}
+
+// tokenSubject returns what the connection tokens of req are bound to: the user of identity if the server
+// authenticates requests, otherwise the access token of req. stable tells that the subject stays the same
+// when the client refreshes its access token
+func (s *Server) tokenSubject(req *http.Request, identity Identity) (subject string, stable bool) {
+	if s.authentication != nil {
+		return "user:" + identity.UserID, true
+	}
+	return "access token:" + bearerToken(req), false
+}
+
+// binding returns the part of the payload which binds a token to subject
+func binding(subject string) []byte {
+	hash := sha256.Sum256([]byte(subject))
+	return hash[:tokenBindingSize]
+}
+
+func (c *connectionTokens) issue(subject string, now time.Time) (string, error) {
+	payload := make([]byte, tokenPayloadSize)
+	if _, err := rand.Read(payload[:tokenIDSize]); err != nil {
+		return "", err
+	}
+	binary.BigEndian.PutUint64(payload[tokenIDSize:], uint64(now.Unix()))
+	copy(payload[tokenIDSize+tokenIssuedSize:], binding(subject))
+	if !c.options.Encrypt {
+		mac := hmac.New(sha256.New, c.signingKey)
+		mac.Write(payload)
+		return base64.RawURLEncoding.EncodeToString(mac.Sum(payload)), nil
+	}
+	aead, err := c.aead()
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+tokenPayloadSize+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(aead.Seal(nonce, nonce, payload, nil)), nil
+}
+
+// verify checks token, and with checkBinding that it is bound to subject, and returns when it has been issued
+func (c *connectionTokens) verify(token string, subject string, checkBinding bool) (time.Time, error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return time.Time{}, errInvalidConnectionToken
+	}
+	var payload []byte
+	if !c.options.Encrypt {
+		if len(data) != tokenPayloadSize+sha256.Size {
+			return time.Time{}, errInvalidConnectionToken
+		}
+		mac := hmac.New(sha256.New, c.signingKey)
+		mac.Write(data[:tokenPayloadSize])
+		if !hmac.Equal(mac.Sum(nil), data[tokenPayloadSize:]) {
+			return time.Time{}, errInvalidConnectionToken
+		}
+		payload = data[:tokenPayloadSize]
+	} else {
+		aead, err := c.aead()
+		if err != nil {
+			return time.Time{}, err
+		}
+		if len(data) < aead.NonceSize() {
+			return time.Time{}, errInvalidConnectionToken
+		}
+		if payload, err = aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], nil); err != nil || len(payload) != tokenPayloadSize {
+			return time.Time{}, errInvalidConnectionToken
+		}
+	}
+	if checkBinding && !bytes.Equal(payload[tokenIDSize+tokenIssuedSize:], binding(subject)) {
+		return time.Time{}, errors.New("connection token of another user or access token")
+	}
+	return time.Unix(int64(binary.BigEndian.Uint64(payload[tokenIDSize:])), 0), nil
+}
+
+func (c *connectionTokens) aead() (cipher.AEAD, error) {
+	block, err := aes.NewCipher(c.sealingKey)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
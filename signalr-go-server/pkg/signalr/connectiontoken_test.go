@@ -0,0 +1,113 @@
+package signalr
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"time"
+
+	"./signalrtest"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("SignConnectionTokens", func() {
+
+	for _, encrypt := range []bool{false, true} {
+		encrypt := encrypt
+		Describe("Server with signed connection tokens", func() {
+			clock := signalrtest.NewFakeClock(time.Now())
+			mux := http.NewServeMux()
+			MapHub(mux, "/hub", &longPollingHub{}, UseClock(clock), NegotiateTimeout(time.Hour),
+				SignConnectionTokens(ConnectionTokenOptions{Key: []byte("0123456789abcdef0123456789abcdef"), Encrypt: encrypt, MaxAge: time.Minute}))
+			negotiate := func(accessToken string) string {
+				req := httptest.NewRequest("POST", "/hub/negotiate", nil)
+				req.Header.Set("Authorization", "Bearer "+accessToken)
+				recorder := httptest.NewRecorder()
+				mux.ServeHTTP(recorder, req)
+				var response map[string]interface{}
+				Expect(json.Unmarshal(recorder.Body.Bytes(), &response)).To(Succeed())
+				return response["connectionId"].(string)
+			}
+			transport := func(method string, connectionID string, accessToken string, body string) *httptest.ResponseRecorder {
+				req := httptest.NewRequest(method, "/hub?id="+url.QueryEscape(connectionID), strings.NewReader(body))
+				req.Header.Set("Authorization", "Bearer "+accessToken)
+				recorder := httptest.NewRecorder()
+				mux.ServeHTTP(recorder, req)
+				return recorder
+			}
+			poll := func(connectionID string, accessToken string) int {
+				return transport("DELETE", connectionID, accessToken, "").Code
+			}
+			Context("When a transport request has the token of its access token", func() {
+				It("should accept it", func() {
+					Expect(poll(negotiate("alice"), "alice")).To(Equal(202))
+				})
+			})
+			Context("When a transport request has a forged token", func() {
+				It("should refuse it", func() {
+					token := negotiate("alice")
+					forged := strings.Map(func(r rune) rune {
+						if r == 'A' {
+							return 'B'
+						}
+						return 'A'
+					}, token[:1]) + token[1:]
+					Expect(poll(forged, "alice")).To(Equal(404))
+					Expect(poll("AAAAAAAAAAAAAAAAAAAAAA==", "alice")).To(Equal(404))
+				})
+			})
+			Context("When a transport request has the token of another access token", func() {
+				It("should refuse it", func() {
+					Expect(poll(negotiate("alice"), "mallory")).To(Equal(404))
+				})
+			})
+			Context("When the client refreshes its access token between polls", func() {
+				It("should keep accepting the requests of the started connection", func() {
+					token := negotiate("alice-1")
+					Expect(transport("GET", token, "alice-1", "").Code).To(Equal(200))
+					Expect(transport("POST", token, "alice-2", `{"protocol":"json","version":1}`+"\u001e").Code).To(Equal(200))
+					poll := transport("GET", token, "alice-3", "")
+					Expect(poll.Code).To(Equal(200))
+					Expect(poll.Body.String()).To(HavePrefix("{}\u001e"))
+					Expect(transport("DELETE", token, "alice-4", "").Code).To(Equal(202))
+				})
+			})
+			Context("When the token is used after its MaxAge", func() {
+				It("should refuse it", func() {
+					token := negotiate("alice")
+					clock.Advance(2 * time.Minute)
+					Expect(poll(token, "alice")).To(Equal(404))
+				})
+			})
+		})
+	}
+
+	Describe("Server with signed connection tokens and authenticated requests", func() {
+		mux := http.NewServeMux()
+		MapHub(mux, "/hub", &longPollingHub{}, NegotiateTimeout(time.Hour),
+			AuthenticateRequests(AuthenticationOptions{Authenticate: func(req *http.Request) (Identity, error) {
+				// Access tokens are "<user>-<n>", refreshed by incrementing n
+				return Identity{UserID: strings.Split(bearerToken(req), "-")[0]}, nil
+			}}),
+			SignConnectionTokens(ConnectionTokenOptions{Key: []byte("0123456789abcdef0123456789abcdef")}))
+		request := func(method string, path string, accessToken string) *httptest.ResponseRecorder {
+			req := httptest.NewRequest(method, path, nil)
+			req.Header.Set("Authorization", "Bearer "+accessToken)
+			recorder := httptest.NewRecorder()
+			mux.ServeHTTP(recorder, req)
+			return recorder
+		}
+		Context("When a transport request has a refreshed access token", func() {
+			It("should accept it for the same user and refuse it for another", func() {
+				var response map[string]interface{}
+				Expect(json.Unmarshal(request("POST", "/hub/negotiate", "alice-1").Body.Bytes(), &response)).To(Succeed())
+				path := "/hub?id=" + url.QueryEscape(response["connectionId"].(string))
+				Expect(request("DELETE", path, "mallory-1").Code).To(Equal(404))
+				Expect(request("DELETE", path, "alice-2").Code).To(Equal(202))
+			})
+		})
+	})
+})
@@ -0,0 +1,58 @@
+package signalr
+
+import (
+	"net/http"
+)
+
+const defaultCorrelationHeader = "X-Correlation-ID"
+
+// CorrelationHeader sets the name of the header which carries the correlation ID of a connection. A client can send
+// its own ID with the negotiate request or, without negotiate, with the transport request. Otherwise the server
+// generates one at negotiate and sends it back in the header of the negotiate response. The ID is available to hubs
+// as ConnectionContext.CorrelationID() and FeatureCorrelationID, and added to each log entry of the connection under
+// the key "correlation", so one client can be traced across negotiate, its transport requests and its invocations.
+// Default is "X-Correlation-ID"
+func CorrelationHeader(name string) Option {
+	return func(s *Server) {
+		s.correlationHeader = name
+	}
+}
+
+// requestCorrelationID returns the correlation ID of a transport request: the ID of its negotiate request,
+// the ID sent with the request or a new one
+func (s *Server) requestCorrelationID(req *http.Request, negotiateHeader http.Header) string {
+	if correlationID := negotiateHeader.Get(s.correlationHeader); correlationID != "" {
+		return correlationID
+	}
+	if correlationID := req.Header.Get(s.correlationHeader); correlationID != "" {
+		return correlationID
+	}
+	return s.getConnectionID()
+}
+
+// connectionCorrelationID returns the correlation ID published by the transport of conn,
+// or a new one for connections without transport request
+func (s *Server) connectionCorrelationID(conn Connection) string {
+	if metadata, ok := conn.(interface{ requestFeatures() map[string]interface{} }); ok {
+		if correlationID, ok := metadata.requestFeatures()[FeatureCorrelationID].(string); ok {
+			return correlationID
+		}
+	}
+	return s.getConnectionID()
+}
+
+// correlatingLogger adds the correlation ID of the connection to the entries which are logged for a connection,
+// i.e. whose first key is "connection"
+type correlatingLogger struct {
+	logger StructuredLogger
+	server *Server
+}
+
+func (c *correlatingLogger) Log(keyVals ...interface{}) error {
+	if len(keyVals) > 1 && keyVals[0] == "connection" {
+		if correlationID, ok := c.server.correlationIDs.Load(keyVals[1]); ok {
+			keyVals = append(append(keyVals[:2:2], "correlation", correlationID), keyVals[2:]...)
+		}
+	}
+	return c.logger.Log(keyVals...)
+}
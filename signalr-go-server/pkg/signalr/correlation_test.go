@@ -0,0 +1,102 @@
+package signalr
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type correlationHub struct {
+	Hub
+}
+
+func (c *correlationHub) Correlation(connectionContext ConnectionContext) string {
+	return connectionContext.CorrelationID()
+}
+
+// correlationLogger keeps the correlation IDs of the connection events logged to it
+type correlationLogger struct {
+	mx           sync.Mutex
+	correlations map[string]bool
+}
+
+func (c *correlationLogger) Log(keyVals ...interface{}) error {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	for i := 0; i+1 < len(keyVals); i += 2 {
+		if keyVals[i] == "correlation" {
+			c.correlations[fmt.Sprint(keyVals[i+1])] = true
+		}
+	}
+	return nil
+}
+
+func (c *correlationLogger) logged() map[string]bool {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	logged := make(map[string]bool)
+	for correlationID := range c.correlations {
+		logged[correlationID] = true
+	}
+	return logged
+}
+
+var _ = Describe("Correlation", func() {
+
+	Describe("Long polling connection negotiated with a correlation ID", func() {
+		logger := &correlationLogger{correlations: make(map[string]bool)}
+		mux := http.NewServeMux()
+		MapHub(mux, "/hub", &correlationHub{}, Logger(logger, true))
+		httpServer := httptest.NewServer(mux)
+		Context("When the client invokes a method", func() {
+			It("should pass the correlation ID, answer negotiate with it and log it", func() {
+				defer httpServer.Close()
+				req := httptest.NewRequest("POST", "/hub/negotiate", nil)
+				req.Header.Set("X-Correlation-ID", "trace-1")
+				recorder := httptest.NewRecorder()
+				mux.ServeHTTP(recorder, req)
+				Expect(recorder.Header().Get("X-Correlation-ID")).To(Equal("trace-1"))
+				var response map[string]interface{}
+				Expect(json.Unmarshal(recorder.Body.Bytes(), &response)).To(Succeed())
+				pollURL := httpServer.URL + "/hub?id=" + url.QueryEscape(response["connectionId"].(string))
+				longPoll(pollURL)
+				longPollSend(pollURL, `{"protocol": "json","version": 1}`)
+				longPoll(pollURL)
+				longPollSend(pollURL, `{"type":1,"invocationId": "c","target":"correlation"}`)
+				Expect(pollCompletion(pollURL).Result).To(Equal("trace-1"))
+				Expect(logger.logged()).To(Equal(map[string]bool{"trace-1": true}))
+			})
+		})
+	})
+
+	Describe("Negotiate without correlation ID", func() {
+		mux := http.NewServeMux()
+		MapHub(mux, "/hub", &correlationHub{}, CorrelationHeader("X-Request-ID"))
+		Context("When the client negotiates", func() {
+			It("should answer with a new correlation ID in the configured header", func() {
+				recorder := httptest.NewRecorder()
+				mux.ServeHTTP(recorder, httptest.NewRequest("POST", "/hub/negotiate", nil))
+				Expect(recorder.Header().Get("X-Request-ID")).NotTo(BeEmpty())
+			})
+		})
+	})
+
+	Describe("Connection without transport request", func() {
+		server := NewServer(&correlationHub{})
+		conn := newTestingConnection()
+		go server.Run(conn)
+		Context("When the client invokes a method", func() {
+			It("should have a new correlation ID", func() {
+				_, err := conn.clientSend(`{"type":1,"invocationId": "c","target":"correlation"}`)
+				Expect(err).To(BeNil())
+				Expect((<-conn.received).(CompletionMessage).Result).NotTo(BeEmpty())
+			})
+		})
+	})
+})
@@ -0,0 +1,120 @@
+package signalr
+
+import (
+	"encoding/json"
+	"html/template"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DebugReport lists the connections and groups of a server, to find out why a client did not receive a message
+type DebugReport struct {
+	Connections []DebugConnection `json:"connections"`
+	Groups      []DebugGroup      `json:"groups"`
+}
+
+// DebugConnection is a connected client as listed by a DebugReport
+type DebugConnection struct {
+	ConnectionID string    `json:"connectionId"`
+	UserID       string    `json:"userId"`
+	Transport    string    `json:"transport"`
+	Protocol     string    `json:"protocol"`
+	Connected    time.Time `json:"connected"`
+	Tenant       string    `json:"tenant"`
+	// QueueDepth is the number of messages waiting to be written to the connection
+	QueueDepth int `json:"queueDepth"`
+}
+
+// DebugGroup is a group as listed by a DebugReport, with the connection IDs of its members
+type DebugGroup struct {
+	Name    string   `json:"name"`
+	Tenant  string   `json:"tenant"`
+	Members []string `json:"members"`
+}
+
+// Debug returns the connections and groups of the server, sorted by connection ID and by tenant and group name
+func (s *Server) Debug() DebugReport {
+	report := DebugReport{Connections: []DebugConnection{}, Groups: []DebugGroup{}}
+	for _, live := range s.connections.attached() {
+		transport, _ := live.hubConn.Features().Get(FeatureTransport)
+		name, _ := transport.(string)
+		tenant, _ := live.hubConn.Features().Get(FeatureTenant)
+		key, _ := tenant.(string)
+		report.Connections = append(report.Connections, DebugConnection{
+			ConnectionID: live.hubConn.GetConnectionID(),
+			UserID:       live.hubConn.GetUserID(),
+			Transport:    name,
+			Protocol:     live.hubConn.GetProtocolName(),
+			Connected:    live.connected,
+			Tenant:       key,
+			QueueDepth:   live.hubConn.Stats().QueueDepth,
+		})
+	}
+	sort.Slice(report.Connections, func(i, j int) bool {
+		return report.Connections[i].ConnectionID < report.Connections[j].ConnectionID
+	})
+	for _, key := range s.tenantKeys() {
+		groups := make([]DebugGroup, 0)
+		for name, members := range s.tenant(key).lifetimeManager.GroupMembers() {
+			sort.Strings(members)
+			groups = append(groups, DebugGroup{Name: name, Tenant: key, Members: members})
+		}
+		sort.Slice(groups, func(i, j int) bool { return groups[i].Name < groups[j].Name })
+		report.Groups = append(report.Groups, groups...)
+	}
+	return report
+}
+
+// DebugHandler returns a handler which answers with the Debug report of the server, as HTML page when the request
+// accepts text/html, otherwise as JSON. The report reveals connection and user IDs, so requests are only answered
+// if authorize returns true. If authorize is nil, only requests from the local host are answered.
+// Other requests are answered with 403
+func (s *Server) DebugHandler(authorize func(req *http.Request) bool) http.Handler {
+	if authorize == nil {
+		authorize = isLocalRequest
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if !authorize(req) {
+			w.WriteHeader(403)
+			return
+		}
+		report := s.Debug()
+		if strings.Contains(req.Header.Get("Accept"), "text/html") {
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			_ = debugPage.Execute(w, report)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(report)
+	})
+}
+
+func isLocalRequest(req *http.Request) bool {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return false
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+var debugPage = template.Must(template.New("debug").Parse(`<!DOCTYPE html>
+<html>
+<head><title>SignalR connections</title></head>
+<body>
+<h1>Connections ({{len .Connections}})</h1>
+<table border="1">
+<tr><th>Connection ID</th><th>User ID</th><th>Tenant</th><th>Transport</th><th>Protocol</th><th>Connected</th><th>Queue depth</th></tr>
+{{range .Connections}}<tr><td>{{.ConnectionID}}</td><td>{{.UserID}}</td><td>{{.Tenant}}</td><td>{{.Transport}}</td><td>{{.Protocol}}</td><td>{{.Connected.Format "2006-01-02 15:04:05"}}</td><td>{{.QueueDepth}}</td></tr>
+{{end}}</table>
+<h1>Groups ({{len .Groups}})</h1>
+<table border="1">
+<tr><th>Group</th><th>Tenant</th><th>Members</th></tr>
+{{range .Groups}}<tr><td>{{.Name}}</td><td>{{.Tenant}}</td><td>{{range $i, $m := .Members}}{{if $i}}, {{end}}{{$m}}{{end}}</td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
@@ -0,0 +1,81 @@
+package signalr
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"./signalrtest"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Debug", func() {
+
+	Describe("Debug report of a server with connections in groups", func() {
+		started := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+		server := NewServer(&contextHub{}, UseClock(signalrtest.NewFakeClock(started)), IdentifyUser(func(ctx ConnectionContext) string {
+			return ctx.Query().Get("user")
+		}))
+		connectUser(server, "b", "bob")
+		connectUser(server, "a", "alice")
+		Expect(server.HubContext().Groups().AddToGroup("team", "b")).To(Succeed())
+		Expect(server.HubContext().Groups().AddToGroup("team", "a")).To(Succeed())
+		Context("When the report is taken", func() {
+			It("should list the connections and the members of the groups", func() {
+				report := server.Debug()
+				Expect(report.Connections).To(Equal([]DebugConnection{
+					{ConnectionID: "a", UserID: "alice", Protocol: "json", Connected: started},
+					{ConnectionID: "b", UserID: "bob", Protocol: "json", Connected: started},
+				}))
+				Expect(report.Groups).To(Equal([]DebugGroup{{Name: "team", Members: []string{"a", "b"}}}))
+			})
+		})
+		Context("When the handler is requested by an authorized request", func() {
+			handler := server.DebugHandler(func(req *http.Request) bool {
+				return req.Header.Get("Authorization") == "Bearer admin"
+			})
+			It("should answer with the report as JSON", func() {
+				req := httptest.NewRequest("GET", "/debug", nil)
+				req.Header.Set("Authorization", "Bearer admin")
+				recorder := httptest.NewRecorder()
+				handler.ServeHTTP(recorder, req)
+				Expect(recorder.Code).To(Equal(200))
+				var report DebugReport
+				Expect(json.Unmarshal(recorder.Body.Bytes(), &report)).To(Succeed())
+				Expect(report.Connections).To(HaveLen(2))
+				Expect(report.Groups[0].Members).To(Equal([]string{"a", "b"}))
+			})
+			It("should answer browsers with a HTML page", func() {
+				req := httptest.NewRequest("GET", "/debug", nil)
+				req.Header.Set("Authorization", "Bearer admin")
+				req.Header.Set("Accept", "text/html")
+				recorder := httptest.NewRecorder()
+				handler.ServeHTTP(recorder, req)
+				Expect(recorder.Header().Get("Content-Type")).To(HavePrefix("text/html"))
+				Expect(recorder.Body.String()).To(ContainSubstring("<td>alice</td>"))
+				Expect(recorder.Body.String()).To(ContainSubstring("<td>a, b</td>"))
+			})
+		})
+		Context("When the handler is requested by an unauthorized request", func() {
+			It("should answer with 403", func() {
+				recorder := httptest.NewRecorder()
+				server.DebugHandler(func(req *http.Request) bool { return false }).ServeHTTP(recorder, httptest.NewRequest("GET", "/debug", nil))
+				Expect(recorder.Code).To(Equal(403))
+			})
+		})
+		Context("When the handler without authorization is requested from another host", func() {
+			It("should answer only requests from the local host", func() {
+				recorder := httptest.NewRecorder()
+				server.DebugHandler(nil).ServeHTTP(recorder, httptest.NewRequest("GET", "/debug", nil))
+				Expect(recorder.Code).To(Equal(403))
+				req := httptest.NewRequest("GET", "/debug", nil)
+				req.RemoteAddr = "127.0.0.1:4711"
+				recorder = httptest.NewRecorder()
+				server.DebugHandler(nil).ServeHTTP(recorder, req)
+				Expect(recorder.Code).To(Equal(200))
+			})
+		})
+	})
+})
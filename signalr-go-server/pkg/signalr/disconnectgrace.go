@@ -0,0 +1,123 @@
+package signalr
+
+import (
+	"sync"
+	"time"
+)
+
+// DisconnectGrace delays the end of a connection which dropped without the client closing it, e.g. a mobile client
+// switching networks, by grace. Until then, the other members of its groups are not told that it left, and
+// OnDisconnected is not called. If the client connects again within grace, the connection is taken over by the new
+// connection instead of ending: a new connection of the same user takes over its groups, a connection resuming its
+// session, see ResumableSessions, takes over the session. No OnDisconnected is called for the dropped connection, and
+// hubs which implement ReconnectHub get OnReconnected instead of OnConnected for the new connection.
+// Only connections of users, see IdentifyUser, and connections with a session can be taken over, and each dropped
+// connection is taken over once. By default, connections end when they drop
+func DisconnectGrace(grace time.Duration) Option {
+	return func(s *Server) {
+		s.graces = &graceRegistry{grace: grace}
+	}
+}
+
+// ReconnectHub is implemented by hubs which need to know when a connection takes over a connection which dropped,
+// see DisconnectGrace. OnReconnected is called instead of OnConnected, e.g. to move the state kept for previousID
+type ReconnectHub interface {
+	OnReconnected(previousID string, connectionID string)
+}
+
+// graceRegistry keeps the dropped connections which wait for their client during the grace period
+type graceRegistry struct {
+	grace   time.Duration
+	clock   Clock
+	mx      sync.Mutex
+	pending []*gracedConnection
+}
+
+// gracedConnection is a dropped connection waiting for its client
+type gracedConnection struct {
+	lifetimeManager *defaultHubLifetimeManager
+	connectionID    string
+	userID          string
+	// detached is the session of the connection, nil if it has none
+	detached *detachedSession
+	// parked stands in for the connection in its lifetime manager, nil if the session of the connection does
+	parked *parkedConnection
+	// disconnected calls OnDisconnected of the hub of the connection
+	disconnected func()
+	stop         func() bool
+}
+
+// graced returns if the end of a dropped connection of userID is delayed. issued tells if its client got a session token
+func (r *graceRegistry) graced(userID string, issued bool) bool {
+	return r != nil && (userID != "" || issued)
+}
+
+// park keeps hubConn, whose session is detached or nil, for the grace period. disconnected is called if it ends
+func (r *graceRegistry) park(lifetimeManager *defaultHubLifetimeManager, hubConn hubConnection, detached *detachedSession, disconnected func()) {
+	graced := &gracedConnection{
+		lifetimeManager: lifetimeManager,
+		connectionID:    hubConn.GetConnectionID(),
+		userID:          hubConn.GetUserID(),
+		detached:        detached,
+		disconnected:    disconnected,
+	}
+	if detached == nil {
+		// The connection stays in its groups, messages sent to it are dropped
+		graced.parked = &parkedConnection{connectionID: graced.connectionID, userID: graced.userID, features: hubConn.Features()}
+		lifetimeManager.transfer(graced.connectionID, graced.parked)
+	}
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	r.pending = append(r.pending, graced)
+	graced.stop = r.clock.AfterFunc(r.grace, func() {
+		if r.remove(func(pending *gracedConnection) bool { return pending == graced }) != nil {
+			graced.expire()
+		}
+	})
+}
+
+// expire ends the graced connection
+func (g *gracedConnection) expire() {
+	g.disconnected()
+	if g.parked != nil {
+		g.lifetimeManager.OnDisconnected(g.parked)
+	}
+}
+
+// reconnect lets hubConn take over the oldest graced connection of its session resumed, or if it resumed none,
+// of its user. It returns the ID of the connection taken over, "" if there was none
+func (r *graceRegistry) reconnect(lifetimeManager *defaultHubLifetimeManager, hubConn hubConnection, resumed *detachedSession) string {
+	if r == nil {
+		return ""
+	}
+	graced := r.remove(func(pending *gracedConnection) bool {
+		if pending.lifetimeManager != lifetimeManager {
+			return false
+		}
+		if resumed != nil {
+			return pending.detached == resumed
+		}
+		return pending.detached == nil && pending.userID != "" && pending.userID == hubConn.GetUserID()
+	})
+	if graced == nil {
+		return ""
+	}
+	graced.stop()
+	if graced.parked != nil {
+		lifetimeManager.transfer(graced.connectionID, hubConn)
+	}
+	return graced.connectionID
+}
+
+// remove removes the first graced connection matching match and returns it, nil if none matches
+func (r *graceRegistry) remove(match func(graced *gracedConnection) bool) *gracedConnection {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	for i, graced := range r.pending {
+		if match(graced) {
+			r.pending = append(r.pending[:i], r.pending[i+1:]...)
+			return graced
+		}
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package signalr
+
+import (
+	"io"
+	"time"
+
+	"./signalrtest"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type graceHub struct {
+	Hub
+	events chan string
+}
+
+func (g *graceHub) Ready() {}
+
+func (g *graceHub) OnConnected(connectionID string) {
+	g.events <- "connected " + connectionID
+}
+
+func (g *graceHub) OnDisconnected(connectionID string) {
+	g.events <- "disconnected " + connectionID
+}
+
+func (g *graceHub) OnReconnected(previousID string, connectionID string) {
+	g.events <- "reconnected " + previousID + " " + connectionID
+}
+
+var _ = Describe("DisconnectGrace", func() {
+
+	Describe("Server with a disconnect grace period", func() {
+		clock := signalrtest.NewFakeClock(time.Now())
+		hub := &graceHub{events: make(chan string, 10)}
+		server := NewServer(hub, UseClock(clock), DisconnectGrace(time.Minute), IdentifyUser(func(ctx ConnectionContext) string {
+			return ctx.Query().Get("user")
+		}))
+		drop := func(connectionID string, conn *testingConnection) {
+			Expect(conn.cliWriter.(*io.PipeWriter).Close()).To(Succeed())
+			Eventually(func() []DebugConnection { return server.Debug().Connections }).ShouldNot(ContainElement(HaveField("ConnectionID", connectionID)))
+		}
+		Context("When a connection of a user drops and the user connects again within the grace period", func() {
+			It("should let the new connection take over the groups without OnDisconnected", func() {
+				drop("a", connectUser(server, "a", "alice"))
+				Expect(<-hub.events).To(Equal("connected a"))
+				Expect(server.HubContext().Groups().AddToGroup("team", "a")).To(Succeed())
+				Consistently(hub.events, 50*time.Millisecond).ShouldNot(Receive())
+				Expect(server.Debug().Groups).To(Equal([]DebugGroup{{Name: "team", Members: []string{"a"}}}))
+				connectUser(server, "b", "alice")
+				Expect(<-hub.events).To(Equal("reconnected a b"))
+				Expect(server.Debug().Groups).To(Equal([]DebugGroup{{Name: "team", Members: []string{"b"}}}))
+				clock.Advance(time.Minute)
+				Consistently(hub.events, 50*time.Millisecond).ShouldNot(Receive())
+			})
+		})
+		Context("When a client invokes OnReconnected", func() {
+			It("should answer that the method does not exist", func() {
+				conn := connectUser(server, "spoof", "mallory")
+				Expect(<-hub.events).To(Equal("connected spoof"))
+				_, err := conn.clientSend(`{"type":1,"invocationId":"fake","target":"onreconnected","arguments":["a","spoof"]}`)
+				Expect(err).To(BeNil())
+				Expect((<-conn.received).(CompletionMessage).Error).To(Equal("Method does not exist"))
+				Consistently(hub.events, 50*time.Millisecond).ShouldNot(Receive())
+				drop("spoof", conn)
+				clock.Advance(time.Minute)
+				Expect(<-hub.events).To(Equal("disconnected spoof"))
+			})
+		})
+		Context("When the user does not connect again within the grace period", func() {
+			It("should end the connection when the grace period is over", func() {
+				drop("c", connectUser(server, "c", "carol"))
+				Expect(<-hub.events).To(Equal("connected c"))
+				Expect(server.HubContext().Groups().AddToGroup("crew", "c")).To(Succeed())
+				clock.Advance(time.Minute)
+				Eventually(hub.events).Should(Receive(Equal("disconnected c")))
+				Expect(server.Debug().Groups).NotTo(ContainElement(HaveField("Name", "crew")))
+			})
+		})
+	})
+})
@@ -0,0 +1,53 @@
+package signalr
+
+// DisconnectReason is the reason why a connection ended
+type DisconnectReason int
+
+const (
+	// DisconnectClientClose means the client closed the connection
+	DisconnectClientClose DisconnectReason = iota
+	// DisconnectTimeout means a message could not be written in time, or a long polling client stopped polling
+	DisconnectTimeout
+	// DisconnectServerShutdown means the connection was closed by Drain
+	DisconnectServerShutdown
+	// DisconnectProtocolError means the client sent a message which could not be parsed
+	DisconnectProtocolError
+	// DisconnectKicked means the server evicted the connection, e.g. as slow consumer or by a connection takeover
+	DisconnectKicked
+	// DisconnectIdle means the client did not invoke a hub method within the IdleTimeout
+	DisconnectIdle
+	// DisconnectLoopFailure means a loop of the connection crashed, see LoopRestarts
+	DisconnectLoopFailure
+	// DisconnectTransportError means the transport ended without the client closing the connection,
+	// e.g. because the network dropped. It is the reason of connections for which no other reason is known
+	DisconnectTransportError
+)
+
+func (d DisconnectReason) String() string {
+	switch d {
+	case DisconnectClientClose:
+		return "client close"
+	case DisconnectTimeout:
+		return "timeout"
+	case DisconnectServerShutdown:
+		return "server shutdown"
+	case DisconnectProtocolError:
+		return "protocol error"
+	case DisconnectKicked:
+		return "kicked"
+	case DisconnectIdle:
+		return "idle"
+	case DisconnectLoopFailure:
+		return "loop failure"
+	case DisconnectTransportError:
+		return "transport error"
+	default:
+		return "unknown"
+	}
+}
+
+// DisconnectReasonHub is implemented by hubs which need to know why their connections ended.
+// OnDisconnectedReason is called right after OnDisconnected
+type DisconnectReasonHub interface {
+	OnDisconnectedReason(connectionID string, reason DisconnectReason)
+}
@@ -0,0 +1,175 @@
+package signalr
+
+import (
+	"io"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"./signalrtest"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type reasonHub struct {
+	Hub
+	reasons chan DisconnectReason
+}
+
+func (r *reasonHub) Ready() {}
+
+func (r *reasonHub) OnDisconnectedReason(connectionID string, reason DisconnectReason) {
+	r.reasons <- reason
+}
+
+var _ = Describe("DisconnectReason", func() {
+
+	Describe("Connection closed by the client", func() {
+		hub := &reasonHub{reasons: make(chan DisconnectReason, 1)}
+		server := NewServer(hub)
+		conn := newTestingConnection()
+		go server.Run(conn)
+		Context("When the client sends a close message", func() {
+			It("should end the connection with DisconnectClientClose", func() {
+				_, err := conn.clientSend(`{"type":7}`)
+				Expect(err).To(BeNil())
+				Expect(<-hub.reasons).To(Equal(DisconnectClientClose))
+			})
+		})
+	})
+
+	Describe("Connection whose transport drops", func() {
+		hub := &reasonHub{reasons: make(chan DisconnectReason, 1)}
+		server := NewServer(hub)
+		conn := newTestingConnection()
+		go server.Run(conn)
+		Context("When the transport ends without a close message", func() {
+			It("should end the connection with DisconnectTransportError", func() {
+				_, err := conn.clientSend(`{"type":1,"invocationId": "ready","target":"ready"}`)
+				Expect(err).To(BeNil())
+				Expect((<-conn.received).(CompletionMessage).InvocationID).To(Equal("ready"))
+				Expect(conn.cliWriter.(io.Closer).Close()).To(Succeed())
+				Expect(<-hub.reasons).To(Equal(DisconnectTransportError))
+				Expect(DisconnectTransportError.String()).To(Equal("transport error"))
+			})
+		})
+	})
+
+	Describe("Client invoking OnDisconnectedReason", func() {
+		hub := &reasonHub{reasons: make(chan DisconnectReason, 1)}
+		server := NewServer(hub)
+		conn := newTestingConnection()
+		go server.Run(conn)
+		Context("When the client sends the invocation", func() {
+			It("should answer that the method does not exist", func() {
+				_, err := conn.clientSend(`{"type":1,"invocationId": "fake","target":"onDisconnectedReason","arguments":["other",0]}`)
+				Expect(err).To(BeNil())
+				Expect((<-conn.received).(CompletionMessage).Error).To(Equal("Method does not exist"))
+				Consistently(hub.reasons, 50*time.Millisecond).ShouldNot(Receive())
+			})
+		})
+	})
+
+	Describe("Connection with a protocol error", func() {
+		hub := &reasonHub{reasons: make(chan DisconnectReason, 1)}
+		server := NewServer(hub)
+		conn := newTestingConnection()
+		go server.Run(conn)
+		Context("When the client sends invalid json", func() {
+			It("should end the connection with DisconnectProtocolError", func() {
+				_, err := conn.clientSend(`{"type":1,"invocationId": "bad",`)
+				Expect(err).To(BeNil())
+				Expect(<-hub.reasons).To(Equal(DisconnectProtocolError))
+			})
+			It("should tell the client the reason and count the protocol error", func() {
+				closeMessage := <-conn.closed
+				Expect(closeMessage.Error).To(HavePrefix("Protocol error: "))
+				Expect(server.Stats().ProtocolErrors).To(Equal(int64(1)))
+			})
+		})
+	})
+
+	Describe("Connection receiving a message exceeding the maximum size", func() {
+		hub := &reasonHub{reasons: make(chan DisconnectReason, 1)}
+		server := NewServer(hub, MaximumReceiveMessageSize(1000))
+		conn := newTestingConnection()
+		go server.Run(conn)
+		Context("When the client sends more data than the maximum size without completing the message", func() {
+			It("should end the connection with DisconnectProtocolError", func() {
+				_, err := conn.clientSend(`{"type":1,"invocationId": "small","target":"ready"}`)
+				Expect(err).To(BeNil())
+				Expect((<-conn.received).(CompletionMessage).InvocationID).To(Equal("small"))
+				// The server stops reading, so the write does not return
+				go func() {
+					_, _ = conn.cliWriter.Write([]byte(`{"type":1,"invocationId": "large","target":"ready","arguments":["` + strings.Repeat("x", 10000)))
+				}()
+				Expect(<-hub.reasons).To(Equal(DisconnectProtocolError))
+				Expect((<-conn.closed).Error).To(ContainSubstring("maximum size of 1000 bytes"))
+			})
+		})
+	})
+
+	Describe("Connection closed by Drain", func() {
+		hub := &reasonHub{reasons: make(chan DisconnectReason, 1)}
+		server := NewServer(hub)
+		conn := &closableConnection{newTestingConnection()}
+		go server.Run(conn)
+		Context("When the server is drained", func() {
+			It("should end the connection with DisconnectServerShutdown", func() {
+				_, err := conn.clientSend(`{"type":1,"invocationId": "ready","target":"ready"}`)
+				Expect(err).To(BeNil())
+				Expect((<-conn.received).(CompletionMessage).InvocationID).To(Equal("ready"))
+				go server.Drain(10 * time.Millisecond)
+				Expect(<-hub.reasons).To(Equal(DisconnectServerShutdown))
+				Expect(DisconnectServerShutdown.String()).To(Equal("server shutdown"))
+			})
+		})
+	})
+	Describe("Connection of an idle client", func() {
+		clock := signalrtest.NewFakeClock(time.Now())
+		hub := &reasonHub{reasons: make(chan DisconnectReason, 1)}
+		server := NewServer(hub, UseClock(clock), IdleTimeout(time.Minute))
+		Context("When the client does not invoke a hub method within the idle timeout", func() {
+			It("should end the connection with DisconnectIdle", func() {
+				conn := newTestingConnection()
+				go server.Run(conn)
+				clock.Advance(40 * time.Second)
+				_, err := conn.clientSend(`{"type":1,"invocationId": "ready","target":"ready"}`)
+				Expect(err).To(BeNil())
+				Expect((<-conn.received).(CompletionMessage).InvocationID).To(Equal("ready"))
+				// The invocation keeps the connection beyond the first timeout
+				clock.Advance(30 * time.Second)
+				Consistently(conn.closed, 50*time.Millisecond).ShouldNot(Receive())
+				clock.Advance(40 * time.Second)
+				Expect((<-conn.closed).Error).To(Equal("Connection idle"))
+				// The testing connection can not be closed by the server, the client ends it
+				Expect(conn.cliWriter.(io.Closer).Close()).To(Succeed())
+				Expect(<-hub.reasons).To(Equal(DisconnectIdle))
+			})
+		})
+	})
+	Describe("Connection whose round trip loop crashes", func() {
+		clock := signalrtest.NewFakeClock(time.Now())
+		hub := &reasonHub{reasons: make(chan DisconnectReason, 1)}
+		var crashes int32
+		server := NewServer(hub, UseClock(clock), MeasureRoundTrip("roundTrip", time.Minute), LoopRestarts(1),
+			InterceptOutbound(func(ctx ConnectionContext, target string, args []interface{}) ([]interface{}, bool) {
+				if target == "roundTrip" {
+					atomic.AddInt32(&crashes, 1)
+					panic("interceptor failed")
+				}
+				return args, true
+			}))
+		Context("When the loop crashes more often than it is restarted", func() {
+			It("should end the connection with DisconnectLoopFailure", func() {
+				conn := newTestingConnection()
+				go server.Run(conn)
+				Expect((<-conn.closed).Error).To(Equal("Internal server error"))
+				Expect(atomic.LoadInt32(&crashes)).To(Equal(int32(2)))
+				Expect(conn.cliWriter.(io.Closer).Close()).To(Succeed())
+				Expect(<-hub.reasons).To(Equal(DisconnectLoopFailure))
+				Expect(DisconnectLoopFailure.String()).To(Equal("loop failure"))
+			})
+		})
+	})
+})
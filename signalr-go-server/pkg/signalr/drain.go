@@ -0,0 +1,73 @@
+package signalr
+
+import (
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+const defaultDrainWaves = 10
+
+// Drain closes all connections of the server, telling the clients they are allowed to reconnect.
+// The connections are closed in waves spread over window, so the clients do not reconnect all at once.
+// After Drain has been called, negotiate requests are answered with 503 and new connections are refused.
+// Drain returns when all connections have ended
+func (s *Server) Drain(window time.Duration) {
+	live := s.connections.drain()
+	waves := s.drainWaves
+	if waves < 1 {
+		waves = 1
+	}
+	waveSize := (len(live) + waves - 1) / waves
+	for i := 0; i < len(live); i += waveSize {
+		if i > 0 {
+			<-s.clock.After(window / time.Duration(waves))
+		}
+		end := i + waveSize
+		if end > len(live) {
+			end = len(live)
+		}
+		for _, l := range live[i:end] {
+			l.shutdown("Server is shutting down")
+		}
+	}
+	for _, l := range live {
+		<-l.done
+	}
+}
+
+// DrainOnSignal drains the server over window when the process receives one of signals, by default SIGTERM.
+// Before draining, unready is called, which should make the readiness probe of the server fail,
+// so no new clients are routed to it. With Kubernetes, terminationGracePeriodSeconds must be longer than
+// the preStop hook and window together. The returned channel is closed when draining has finished
+func (s *Server) DrainOnSignal(unready func(), window time.Duration, signals ...os.Signal) <-chan struct{} {
+	if len(signals) == 0 {
+		signals = []os.Signal{syscall.SIGTERM}
+	}
+	received := make(chan os.Signal, 1)
+	signal.Notify(received, signals...)
+	drained := make(chan struct{})
+	go func() {
+		defer close(drained)
+		<-received
+		signal.Stop(received)
+		if unready != nil {
+			unready()
+		}
+		s.Drain(window)
+	}()
+	return drained
+}
+
+// shutdown sends a close message to the client and closes the transport connection
+func (l liveConnection) shutdown(error string) {
+	if l.hubConn != nil {
+		l.hubConn.SetDisconnectReason(DisconnectServerShutdown)
+		l.hubConn.Close(error)
+	}
+	if closer, ok := l.conn.(io.Closer); ok {
+		_ = closer.Close()
+	}
+}
@@ -0,0 +1,45 @@
+package signalr
+
+import (
+	"io"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type closableConnection struct {
+	*testingConnection
+}
+
+func (c *closableConnection) Close() error {
+	return c.srvReader.(io.Closer).Close()
+}
+
+var _ = Describe("Drain", func() {
+
+	Describe("Drain a server with a connected client", func() {
+		server := NewServer(&contextHub{})
+		conn := &closableConnection{newTestingConnection()}
+		go server.Run(conn)
+		Context("When Drain is called", func() {
+			It("should close the connection allowing reconnect and refuse new connections", func() {
+				_, err := conn.clientSend(`{"type":1,"invocationId": "drain","target":"ready"}`)
+				Expect(err).To(BeNil())
+				Expect((<-conn.received).(CompletionMessage).InvocationID).To(Equal("drain"))
+				drained := make(chan struct{})
+				go func() {
+					server.Drain(100 * time.Millisecond)
+					close(drained)
+				}()
+				closeMessage := <-conn.closed
+				Expect(closeMessage.AllowReconnect).To(BeTrue())
+				Eventually(drained).Should(BeClosed())
+				recorder := httptest.NewRecorder()
+				server.negotiateHandler(recorder, httptest.NewRequest("POST", "/chat/negotiate", nil))
+				Expect(recorder.Code).To(Equal(503))
+			})
+		})
+	})
+})
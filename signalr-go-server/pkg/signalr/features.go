@@ -0,0 +1,100 @@
+package signalr
+
+import (
+	"context"
+	"sync"
+)
+
+// Names of the features published by the transports of the server
+const (
+	// FeatureTransport is the name of the transport of the connection, "WebSockets", "ServerSentEvents" or "LongPolling"
+	FeatureTransport = "Transport"
+	// FeatureBinary is true if the transport can carry binary hub protocols
+	FeatureBinary = "Binary"
+	// FeatureRemoteAddr is the network address of the client, as in http.Request.RemoteAddr
+	FeatureRemoteAddr = "RemoteAddr"
+	// FeatureClientIP is the IP of the client, see ConnectionContext.ClientIP
+	FeatureClientIP = "ClientIP"
+	// FeatureTLS is the *tls.ConnectionState of the request which started the connection. It is missing for connections without TLS
+	FeatureTLS = "TLS"
+	// FeatureHost is the host of the request which started the connection, as in http.Request.Host
+	FeatureHost = "Host"
+	// FeatureTenant is the key of the tenant of the connection, see IsolateTenants. It is missing if tenants are not isolated
+	FeatureTenant = "Tenant"
+	// FeatureClaims are the claims of the access token of the connection, see RefreshTokens. It is missing if tokens are not validated
+	FeatureClaims = "Claims"
+	// FeatureLocale is the preferred language of the client, see ConnectionContext.Locale. It is missing if the client did not tell it
+	FeatureLocale = "Locale"
+	// FeatureIdentity is the Identity of the request which started the connection, see AuthenticateRequests.
+	// It is missing if requests are not authenticated
+	FeatureIdentity = "Identity"
+	// FeatureCorrelationID is the correlation ID of the connection, see CorrelationHeader
+	FeatureCorrelationID = "CorrelationID"
+)
+
+// Features is the collection of features of a connection. Transports publish the capabilities of the connection
+// as features, and middleware or hubs can attach their own features without changing the interfaces of the server.
+// Features is safe for concurrent use
+type Features struct {
+	mx       sync.RWMutex
+	features map[string]interface{}
+}
+
+func newFeatures(features map[string]interface{}) *Features {
+	f := &Features{features: make(map[string]interface{}, len(features))}
+	for name, value := range features {
+		f.features[name] = value
+	}
+	return f
+}
+
+// Get returns the feature with name
+func (f *Features) Get(name string) (interface{}, bool) {
+	f.mx.RLock()
+	defer f.mx.RUnlock()
+	value, ok := f.features[name]
+	return value, ok
+}
+
+// Set adds or replaces the feature with name
+func (f *Features) Set(name string, value interface{}) {
+	f.mx.Lock()
+	defer f.mx.Unlock()
+	f.features[name] = value
+}
+
+// Delete removes the feature with name
+func (f *Features) Delete(name string) {
+	f.mx.Lock()
+	defer f.mx.Unlock()
+	delete(f.features, name)
+}
+
+// Names returns the names of all features
+func (f *Features) Names() []string {
+	f.mx.RLock()
+	defer f.mx.RUnlock()
+	names := make([]string, 0, len(f.features))
+	for name := range f.features {
+		names = append(names, name)
+	}
+	return names
+}
+
+type featuresKey struct{}
+
+// WithFeature returns a copy of ctx which carries a feature. HTTP middleware in front of the server uses it
+// to attach features to the connections started by a request, e.g.
+//
+//	next.ServeHTTP(w, req.WithContext(signalr.WithFeature(req.Context(), "Tenant", tenant)))
+func WithFeature(ctx context.Context, name string, value interface{}) context.Context {
+	features := map[string]interface{}{name: value}
+	if parent, ok := ctx.Value(featuresKey{}).(map[string]interface{}); ok {
+		for parentName, parentValue := range parent {
+			if parentName != name {
+				features[parentName] = parentValue
+			}
+		}
+	}
+	return context.WithValue(ctx, featuresKey{}, features)
+}
@@ -0,0 +1,79 @@
+package signalr
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+// fuzzHubProtocol feeds arbitrary client data to the message parser of protocol, as Receive does.
+// Parsing must not panic, a partial message must leave the data untouched and a valid one must consume it.
+// The parsed messages are processed as the server does, their arguments and items are unmarshaled and they are written
+func fuzzHubProtocol(f *testing.F, protocol HubProtocol, seeds ...[]byte) {
+	for _, seed := range seeds {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		buf := bytes.NewBuffer(data)
+		for buf.Len() > 0 {
+			before := buf.Len()
+			message, complete, err := protocol.ReadMessage(buf)
+			if !complete {
+				if buf.Len() != before {
+					t.Fatalf("partial message consumed %v bytes", before-buf.Len())
+				}
+				return
+			}
+			if err != nil {
+				// The server closes the connection
+				return
+			}
+			if buf.Len() >= before {
+				t.Fatalf("complete message consumed no data")
+			}
+			var value interface{}
+			switch m := message.(type) {
+			case InvocationMessage:
+				for _, argument := range m.Arguments {
+					_ = protocol.UnmarshalArgument(argument, &value)
+				}
+			case StreamItemMessage:
+				_ = protocol.UnmarshalArgument(m.Item, &value)
+			}
+			_ = protocol.WriteMessage(message, io.Discard)
+		}
+	})
+}
+
+func FuzzJsonHubProtocol(f *testing.F) {
+	fuzzHubProtocol(f, &JsonHubProtocol{},
+		[]byte(`{"type":1,"invocationId":"1","target":"send","arguments":["a",1,{"b":[true,null]}],"streamIds":["s"]}`+"\u001e"),
+		[]byte(`{"type":2,"invocationId":"s","item":{"x":1.5}}`+"\u001e"+`{"type":3,"invocationId":"s"}`+"\u001e"),
+		[]byte(`{"type":3,"invocationId":"1","error":"failed"}`+"\u001e"+`{"type":5,"invocationId":"2"}`+"\u001e"),
+		[]byte(`{"type":6}`+"\u001e"+`{"type":7}`+"\u001e"+`{"type":4,"target":"stream","arguments":[]}`),
+	)
+}
+
+func FuzzCborHubProtocol(f *testing.F) {
+	protocol := &CborHubProtocol{}
+	var seeds [][]byte
+	for _, message := range []interface{}{
+		InvocationMessage{Type: 1, Target: "send", InvocationID: "1", Arguments: []interface{}{"a", 1, map[string]interface{}{"b": []interface{}{true, nil}}}, StreamIds: []string{"s"}},
+		InvocationMessage{Type: 4, Target: "stream", Arguments: []interface{}{}},
+		StreamItemMessage{Type: 2, InvocationID: "s", Item: 1.5},
+		CompletionMessage{Type: 3, InvocationID: "1", Error: "failed"},
+		CompletionMessage{Type: 3, InvocationID: "2", Result: []interface{}{"x"}},
+		CancelInvocationMessage{Type: 5, InvocationID: "2"},
+		HubMessage{Type: 6},
+		CloseMessage{Type: 7, Error: "bye", AllowReconnect: true},
+	} {
+		var buf bytes.Buffer
+		if err := protocol.WriteMessage(message, &buf); err != nil {
+			f.Fatal(err)
+		}
+		seeds = append(seeds, buf.Bytes())
+	}
+	// A length prefix of the maximum size, with no message following
+	seeds = append(seeds, []byte{0xff, 0xff, 0xff, 0xff, 0x07})
+	fuzzHubProtocol(f, protocol, seeds...)
+}
@@ -0,0 +1,61 @@
+//go:build go1.18
+// +build go1.18
+
+package signalr
+
+// Invoke1 invokes the client method target with one argument on clients. The argument type is checked by the compiler,
+// e.g. Invoke1[ChatMessage](h.Clients().Group(room), "receiveMessage", message), which Send can not do
+func Invoke1[T1 any](clients ClientProxy, target string, a1 T1) {
+	clients.Send(target, a1)
+}
+
+// Invoke2 invokes the client method target with two arguments on clients, see Invoke1
+func Invoke2[T1, T2 any](clients ClientProxy, target string, a1 T1, a2 T2) {
+	clients.Send(target, a1, a2)
+}
+
+// Invoke3 invokes the client method target with three arguments on clients, see Invoke1
+func Invoke3[T1, T2, T3 any](clients ClientProxy, target string, a1 T1, a2 T2, a3 T3) {
+	clients.Send(target, a1, a2, a3)
+}
+
+// Target1 is a client method with one argument. Declaring the client methods once, e.g.
+//
+//	var ReceiveMessage = signalr.Target1[ChatMessage]("receiveMessage")
+//
+// checks the name and the argument type of each invocation by the compiler: ReceiveMessage.Send(clients, message)
+type Target1[T1 any] string
+
+// Send invokes the client method on clients
+func (t Target1[T1]) Send(clients ClientProxy, a1 T1) {
+	clients.Send(string(t), a1)
+}
+
+// Target2 is a client method with two arguments, see Target1
+type Target2[T1, T2 any] string
+
+// Send invokes the client method on clients
+func (t Target2[T1, T2]) Send(clients ClientProxy, a1 T1, a2 T2) {
+	clients.Send(string(t), a1, a2)
+}
+
+// Target3 is a client method with three arguments, see Target1
+type Target3[T1, T2, T3 any] string
+
+// Send invokes the client method on clients
+func (t Target3[T1, T2, T3]) Send(clients ClientProxy, a1 T1, a2 T2, a3 T3) {
+	clients.Send(string(t), a1, a2, a3)
+}
+
+// StreamTo returns a channel whose values are sent to clients as invocations of target with the value as argument,
+// e.g. to push the ticks of a price feed to a group. The values are sent in order, a send to the channel blocks
+// until the previous value has been passed to clients. Closing the channel ends the stream
+func StreamTo[T any](clients ClientProxy, target string) chan<- T {
+	values := make(chan T)
+	go func() {
+		for value := range values {
+			clients.Send(target, value)
+		}
+	}()
+	return values
+}
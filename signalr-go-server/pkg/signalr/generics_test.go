@@ -0,0 +1,76 @@
+//go:build go1.18
+// +build go1.18
+
+package signalr
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// sentInvocation is an invocation sent to a recordingProxy
+type sentInvocation struct {
+	target string
+	args   []interface{}
+}
+
+// recordingProxy is a ClientProxy which records the invocations sent to it
+type recordingProxy struct {
+	sent chan sentInvocation
+}
+
+func (r *recordingProxy) Send(target string, args ...interface{}) {
+	r.sent <- sentInvocation{target: target, args: args}
+}
+
+type tick struct {
+	Symbol string
+	Price  float64
+}
+
+var _ = Describe("Typed invocations", func() {
+
+	Describe("Invoke and Target", func() {
+		proxy := &recordingProxy{sent: make(chan sentInvocation, 10)}
+		Context("When a client method is invoked with typed arguments", func() {
+			It("should send the arguments to the clients", func() {
+				Invoke1[string](proxy, "one", "a")
+				Invoke2(proxy, "two", "a", 2)
+				Invoke3(proxy, "three", "a", 2, true)
+				Expect(<-proxy.sent).To(Equal(sentInvocation{target: "one", args: []interface{}{"a"}}))
+				Expect(<-proxy.sent).To(Equal(sentInvocation{target: "two", args: []interface{}{"a", 2}}))
+				Expect(<-proxy.sent).To(Equal(sentInvocation{target: "three", args: []interface{}{"a", 2, true}}))
+				receiveTick := Target1[tick]("receiveTick")
+				receiveTick.Send(proxy, tick{Symbol: "GO", Price: 1.5})
+				Expect(<-proxy.sent).To(Equal(sentInvocation{target: "receiveTick", args: []interface{}{tick{Symbol: "GO", Price: 1.5}}}))
+				Target2[string, int]("pair").Send(proxy, "a", 1)
+				Expect(<-proxy.sent).To(Equal(sentInvocation{target: "pair", args: []interface{}{"a", 1}}))
+			})
+		})
+	})
+
+	Describe("StreamTo a connected client", func() {
+		server := NewServer(&contextHub{})
+		conn := newTestingConnection()
+		go server.Run(conn)
+		Context("When values are sent to the stream", func() {
+			It("should invoke the target with each value in order", func() {
+				_, err := conn.clientSend(`{"type":1,"invocationId": "ctx","target":"ready"}`)
+				Expect(err).To(BeNil())
+				Expect((<-conn.received).(CompletionMessage).InvocationID).To(Equal("ctx"))
+				ticks := StreamTo[tick](server.HubContext().Clients().All(), "receiveTick")
+				go func() {
+					for i := 0; i < 3; i++ {
+						ticks <- tick{Symbol: "GO", Price: float64(i)}
+					}
+					close(ticks)
+				}()
+				for i := 0; i < 3; i++ {
+					recv := (<-conn.received).(InvocationMessage)
+					Expect(recv.Target).To(Equal("receiveTick"))
+					Expect(recv.Arguments).To(Equal([]interface{}{map[string]interface{}{"Symbol": "GO", "Price": float64(i)}}))
+				}
+			})
+		})
+	})
+})
@@ -1,8 +1,23 @@
 package signalr
 
 // GroupManager manages the client groups of the hub
+// CreateGroup() creates a group with an owner and a maximum size. A maxSize of 0 means no limit.
+// It returns ErrGroupExists if the group exists already
+// GroupInfo() returns the metadata of a group
+// Members() returns the connection IDs of the members of a group
+// RetainMessages() sets the Retention of a group, which is created if it does not exist and kept without members.
+// AddToGroup() adds a connection to a group, which is created if it does not exist.
+// The messages retained by the group are replayed to the connection before AddToGroup() returns.
+// Messages sent to the group while they are replayed can arrive at the connection before the end of the replay.
+// It returns ErrGroupFull when the group has its maximum size and ErrUnknownConnection when the connection does not exist
+// RemoveFromGroup() removes a connection from a group. Groups not created by CreateGroup() are removed with their last member
+// With NotifyGroupMembers, the other members of the group are notified when a connection joins or leaves it
 type GroupManager interface {
-	AddToGroup(groupName string, connectionID string)
+	CreateGroup(groupName string, owner string, maxSize int) error
+	GroupInfo(groupName string) (GroupInfo, bool)
+	Members(groupName string) []string
+	RetainMessages(groupName string, retention Retention)
+	AddToGroup(groupName string, connectionID string) error
 	RemoveFromGroup(groupName string, connectionID string)
 }
 
@@ -10,8 +25,24 @@ type defaultGroupManager struct {
 	lifetimeManager HubLifetimeManager
 }
 
-func (d *defaultGroupManager) AddToGroup(groupName string, connectionID string) {
-	d.lifetimeManager.AddToGroup(groupName, connectionID)
+func (d *defaultGroupManager) CreateGroup(groupName string, owner string, maxSize int) error {
+	return d.lifetimeManager.CreateGroup(groupName, owner, maxSize)
+}
+
+func (d *defaultGroupManager) GroupInfo(groupName string) (GroupInfo, bool) {
+	return d.lifetimeManager.GroupInfo(groupName)
+}
+
+func (d *defaultGroupManager) Members(groupName string) []string {
+	return d.lifetimeManager.GroupMembers()[groupName]
+}
+
+func (d *defaultGroupManager) RetainMessages(groupName string, retention Retention) {
+	d.lifetimeManager.RetainMessages(groupName, retention)
+}
+
+func (d *defaultGroupManager) AddToGroup(groupName string, connectionID string) error {
+	return d.lifetimeManager.AddToGroup(groupName, connectionID)
 }
 
 func (d *defaultGroupManager) RemoveFromGroup(groupName string, connectionID string) {
@@ -0,0 +1,210 @@
+package signalr
+
+import (
+	"time"
+
+	"./signalrtest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Groups", func() {
+
+	Describe("Groups of a server with a clock", func() {
+		clock := signalrtest.NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+		server := NewServer(&contextHub{}, UseClock(clock))
+		Context("When a group is created", func() {
+			It("should stamp it with the time of the clock", func() {
+				clock.Advance(time.Hour)
+				Expect(server.HubContext().Groups().CreateGroup("clocked", "alice", 0)).To(Succeed())
+				info, ok := server.HubContext().Groups().GroupInfo("clocked")
+				Expect(ok).To(BeTrue())
+				Expect(info.Created).To(Equal(clock.Now()))
+			})
+		})
+	})
+
+	Describe("Groups with metadata", func() {
+		server := NewServer(&contextHub{})
+		Context("When a group with a maximum size is created", func() {
+			It("should keep the metadata and refuse connections exceeding the size", func() {
+				connectUser(server, "a", "")
+				connectUser(server, "b", "")
+				groups := server.HubContext().Groups()
+				Expect(groups.CreateGroup("room", "alice", 1)).To(Succeed())
+				Expect(groups.CreateGroup("room", "bob", 2)).To(Equal(ErrGroupExists))
+				Expect(groups.AddToGroup("room", "a")).To(Succeed())
+				Expect(groups.AddToGroup("room", "b")).To(Equal(ErrGroupFull))
+				Expect(groups.AddToGroup("room", "unknown")).To(Equal(ErrUnknownConnection))
+				info, ok := groups.GroupInfo("room")
+				Expect(ok).To(BeTrue())
+				Expect(info.Owner).To(Equal("alice"))
+				Expect(info.MaxSize).To(Equal(1))
+				Expect(info.Size).To(Equal(1))
+				Expect(info.Created).NotTo(BeZero())
+				groups.RemoveFromGroup("room", "a")
+				info, ok = groups.GroupInfo("room")
+				Expect(ok).To(BeTrue())
+				Expect(info.Size).To(Equal(0))
+			})
+		})
+		Context("When a group is not created explicitly", func() {
+			It("should be created by AddToGroup and removed with its last member", func() {
+				groups := server.HubContext().Groups()
+				Expect(groups.AddToGroup("implicit", "b")).To(Succeed())
+				info, ok := groups.GroupInfo("implicit")
+				Expect(ok).To(BeTrue())
+				Expect(info.MaxSize).To(Equal(0))
+				Expect(info.Size).To(Equal(1))
+				groups.RemoveFromGroup("implicit", "b")
+				_, ok = groups.GroupInfo("implicit")
+				Expect(ok).To(BeFalse())
+			})
+		})
+	})
+
+	Describe("Large groups fanned out in parallel", func() {
+		server := NewServer(&contextHub{}, FanOutLargeGroups(GroupFanOut{Threshold: 2, Parallelism: 2}))
+		Context("When invocations are sent to a group above the threshold", func() {
+			It("should write them to all members in the order they were sent", func() {
+				received := make(chan []string, 5)
+				for _, id := range []string{"f1", "f2", "f3", "f4", "f5"} {
+					conn := connectUser(server, id, "")
+					Expect(server.HubContext().Groups().AddToGroup("everyone", id)).To(Succeed())
+					go func() {
+						var targets []string
+						for len(targets) < 2 {
+							targets = append(targets, (<-conn.received).(InvocationMessage).Target)
+						}
+						received <- targets
+					}()
+				}
+				server.HubContext().Clients().Group("everyone").Send("first")
+				server.HubContext().Clients().Group("everyone").Send("second")
+				for i := 0; i < 5; i++ {
+					Expect(<-received).To(Equal([]string{"first", "second"}))
+				}
+			})
+		})
+	})
+
+	Describe("Groups retaining messages", func() {
+		server := NewServer(&contextHub{})
+		Context("When a connection joins a group which retains the last messages", func() {
+			It("should replay the retained messages to it", func() {
+				a := connectUser(server, "a", "")
+				late := connectUser(server, "late", "")
+				groups := server.HubContext().Groups()
+				groups.RetainMessages("dashboard", Retention{Count: 2})
+				Expect(groups.AddToGroup("dashboard", "a")).To(Succeed())
+				go func() {
+					for i := 1; i <= 3; i++ {
+						server.HubContext().Clients().Group("dashboard").Send("update", i)
+					}
+				}()
+				expectTargets(a, "update", "update", "update")
+				go func() {
+					defer GinkgoRecover()
+					Expect(groups.AddToGroup("dashboard", "late")).To(Succeed())
+				}()
+				Expect((<-late.received).(InvocationMessage).Arguments).To(Equal([]interface{}{float64(2)}))
+				Expect((<-late.received).(InvocationMessage).Arguments).To(Equal([]interface{}{float64(3)}))
+			})
+		})
+		Context("When a connection joins a group with a snapshot provider", func() {
+			It("should replay the snapshot to it", func() {
+				joiner := connectUser(server, "joiner", "")
+				groups := server.HubContext().Groups()
+				groups.RetainMessages("scores", Retention{Snapshot: func(groupName string) []RetainedMessage {
+					return []RetainedMessage{{Target: "state", Arguments: []interface{}{groupName}}}
+				}})
+				go func() {
+					defer GinkgoRecover()
+					Expect(groups.AddToGroup("scores", "joiner")).To(Succeed())
+				}()
+				recv := (<-joiner.received).(InvocationMessage)
+				Expect(recv.Target).To(Equal("state"))
+				Expect(recv.Arguments).To(Equal([]interface{}{"scores"}))
+			})
+		})
+	})
+
+	Describe("Groups notifying their members", func() {
+		server := NewServer(&contextHub{}, NotifyGroupMembers(GroupNotifications{Joined: "memberJoined", Left: "memberLeft"}),
+			IdentifyUser(func(ctx ConnectionContext) string {
+				return ctx.Query().Get("user")
+			}))
+		Context("When connections join and leave a group", func() {
+			It("should notify the other members", func() {
+				member := connectUser(server, "member", "alice")
+				guest := connectUser(server, "guest", "bob")
+				groups := server.HubContext().Groups()
+				Expect(groups.AddToGroup("room", "member")).To(Succeed())
+				go func() {
+					defer GinkgoRecover()
+					Expect(groups.AddToGroup("room", "guest")).To(Succeed())
+					groups.RemoveFromGroup("room", "guest")
+					Expect(groups.AddToGroup("room", "guest")).To(Succeed())
+					_, err := guest.clientSend(`{"type":7}`)
+					Expect(err).To(BeNil())
+				}()
+				for _, target := range []string{"memberJoined", "memberLeft", "memberJoined", "memberLeft"} {
+					recv := (<-member.received).(InvocationMessage)
+					Expect(recv.Target).To(Equal(target))
+					Expect(recv.Arguments).To(Equal([]interface{}{"room", "guest", "bob"}))
+				}
+				Consistently(guest.received).ShouldNot(Receive())
+				info, _ := groups.GroupInfo("room")
+				Expect(info.Size).To(Equal(1))
+			})
+		})
+	})
+
+	Describe("Hierarchical groups with WildcardGroups", func() {
+		server := NewServer(&contextHub{}, WildcardGroups())
+		Context("When invocations are sent to patterns of groups", func() {
+			It("should send them once to the members of each matching group", func() {
+				memberships := map[string][]string{
+					"w1": {"building.3.floor.1", "building.3.floor.2"},
+					"w2": {"building.3.floor.2"},
+					"w3": {"building.4.floor.1"},
+					"w4": {"building.3"},
+				}
+				received := make(map[string]chan []string)
+				for _, id := range []string{"w1", "w2", "w3", "w4"} {
+					conn := connectUser(server, id, "")
+					for _, groupName := range memberships[id] {
+						Expect(server.HubContext().Groups().AddToGroup(groupName, id)).To(Succeed())
+					}
+					received[id] = make(chan []string, 1)
+					go func(received chan []string) {
+						var targets []string
+						for len(targets) == 0 || targets[len(targets)-1] != "done" {
+							targets = append(targets, (<-conn.received).(InvocationMessage).Target)
+						}
+						received <- targets
+					}(received[id])
+				}
+				clients := server.HubContext().Clients()
+				clients.Group("building.3.floor.*").Send("floors")
+				clients.Group("building.**").Send("buildings")
+				clients.Group("*.4.*.1").Send("first")
+				clients.All().Send("done")
+				Expect(<-received["w1"]).To(Equal([]string{"floors", "buildings", "done"}))
+				Expect(<-received["w2"]).To(Equal([]string{"floors", "buildings", "done"}))
+				Expect(<-received["w3"]).To(Equal([]string{"buildings", "first", "done"}))
+				Expect(<-received["w4"]).To(Equal([]string{"buildings", "done"}))
+			})
+		})
+		Context("When the last member of a group leaves it", func() {
+			It("should not match the group anymore", func() {
+				groups := server.HubContext().Groups()
+				Expect(groups.AddToGroup("sensor.1", "w1")).To(Succeed())
+				Expect(server.lifetimeManager.(*defaultHubLifetimeManager).groups.expand([]string{"sensor.*"})).To(Equal([]string{"sensor.1"}))
+				groups.RemoveFromGroup("sensor.1", "w1")
+				Expect(server.lifetimeManager.(*defaultHubLifetimeManager).groups.expand([]string{"sensor.*"})).To(BeEmpty())
+			})
+		})
+	})
+})
@@ -0,0 +1,278 @@
+package signalr
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrGroupExists is returned when a group is created which exists already
+var ErrGroupExists = errors.New("group exists")
+
+// ErrGroupFull is returned when a connection is added to a group which has its maximum size
+var ErrGroupFull = errors.New("group is full")
+
+// GroupInfo is the metadata of a group
+type GroupInfo struct {
+	Name    string
+	Created time.Time
+	Owner   string
+	// MaxSize is the maximum number of connections in the group, 0 means no limit
+	MaxSize int
+	// Size is the number of connections in the group
+	Size int
+}
+
+// RetainedMessage is an invocation which is replayed to connections joining a group
+type RetainedMessage struct {
+	Target    string
+	Arguments []interface{}
+}
+
+// SnapshotProvider returns the invocations which bring a connection joining a group up to date,
+// e.g. the current state of a dashboard. It is called for each connection added to the group
+type SnapshotProvider func(groupName string) []RetainedMessage
+
+// Retention configures the messages a group replays to a connection right after it has been added to the group.
+// If Snapshot is set, the messages returned by it are replayed. Otherwise, the last Count messages sent to the group are replayed
+type Retention struct {
+	Count    int
+	Snapshot SnapshotProvider
+}
+
+// GroupNotifications are invocations sent to the members of a group when a connection joins or leaves the group,
+// with the arguments group name, connection ID and user ID of the connection. The connection itself
+// does not receive them. An empty target sends no invocation. Connections which disconnect leave all their groups
+type GroupNotifications struct {
+	Joined string
+	Left   string
+}
+
+type group struct {
+	info      GroupInfo
+	created   bool
+	members   map[string]hubConnection
+	retention Retention
+	retained  []RetainedMessage
+}
+
+// groupRegistry keeps the groups of a lifetime manager
+type groupRegistry struct {
+	mx     sync.Mutex
+	groups map[string]*group
+	// others sets if add and remove return the other members of the group, to notify them
+	others bool
+	// tree keeps the group names by their segments with WildcardGroups, nil without
+	tree *groupNode
+	// clock stamps the creation of the groups, the real clock if nil
+	clock Clock
+}
+
+func (r *groupRegistry) get(groupName string, created bool) *group {
+	if r.groups == nil {
+		r.groups = make(map[string]*group)
+	}
+	g, ok := r.groups[groupName]
+	if !ok {
+		clock := r.clock
+		if clock == nil {
+			clock = realClock{}
+		}
+		g = &group{
+			info:    GroupInfo{Name: groupName, Created: clock.Now()},
+			created: created,
+			members: make(map[string]hubConnection),
+		}
+		r.groups[groupName] = g
+		if r.tree != nil {
+			r.tree.insert(strings.Split(groupName, "."))
+		}
+	}
+	return g
+}
+
+func (r *groupRegistry) create(groupName string, owner string, maxSize int) error {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	if _, ok := r.groups[groupName]; ok {
+		return ErrGroupExists
+	}
+	g := r.get(groupName, true)
+	g.info.Owner = owner
+	g.info.MaxSize = maxSize
+	return nil
+}
+
+func (r *groupRegistry) info(groupName string) (GroupInfo, bool) {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	g, ok := r.groups[groupName]
+	if !ok {
+		return GroupInfo{}, false
+	}
+	info := g.info
+	info.Size = len(g.members)
+	return info, true
+}
+
+// retain sets the Retention of a group, which is created if it does not exist
+func (r *groupRegistry) retain(groupName string, retention Retention) {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	g := r.get(groupName, true)
+	g.created = true
+	g.retention = retention
+	if len(g.retained) > retention.Count {
+		g.retained = g.retained[len(g.retained)-retention.Count:]
+	}
+}
+
+// memberIDs returns the connection IDs of the members of each group
+func (r *groupRegistry) memberIDs() map[string][]string {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	groups := make(map[string][]string, len(r.groups))
+	for name, g := range r.groups {
+		ids := make([]string, 0, len(g.members))
+		for id := range g.members {
+			ids = append(ids, id)
+		}
+		groups[name] = ids
+	}
+	return groups
+}
+
+// groupsOf returns the names of the groups connectionID is a member of, sorted
+func (r *groupRegistry) groupsOf(connectionID string) []string {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	var names []string
+	for name, g := range r.groups {
+		if _, ok := g.members[connectionID]; ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (r *groupRegistry) count() int {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	return len(r.groups)
+}
+
+// add adds a connection to a group. If the connection was not a member of the group before,
+// it returns the messages to replay to it and the other members of the group
+func (r *groupRegistry) add(groupName string, conn hubConnection) ([]RetainedMessage, []hubConnection, error) {
+	r.mx.Lock()
+	g := r.get(groupName, false)
+	if _, ok := g.members[conn.GetConnectionID()]; ok {
+		r.mx.Unlock()
+		return nil, nil, nil
+	}
+	if g.info.MaxSize > 0 && len(g.members) >= g.info.MaxSize {
+		r.mx.Unlock()
+		return nil, nil, ErrGroupFull
+	}
+	others := r.otherMembers(g)
+	g.members[conn.GetConnectionID()] = conn
+	snapshot := g.retention.Snapshot
+	replay := append([]RetainedMessage(nil), g.retained...)
+	r.mx.Unlock()
+	if snapshot != nil {
+		// The provider is called without holding the lock, so it can use the groups
+		return snapshot(groupName), others, nil
+	}
+	return replay, others, nil
+}
+
+// remove removes a connection from a group. If it was a member, remove returns it and the other members of the group
+func (r *groupRegistry) remove(groupName string, connectionID string) (hubConnection, []hubConnection) {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	if g, ok := r.groups[groupName]; ok {
+		return r.removeMember(groupName, g, connectionID)
+	}
+	return nil, nil
+}
+
+// removeFromAll removes a connection from all its groups and returns the other members by group name
+func (r *groupRegistry) removeFromAll(connectionID string) map[string][]hubConnection {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	left := make(map[string][]hubConnection)
+	for groupName, g := range r.groups {
+		if conn, others := r.removeMember(groupName, g, connectionID); conn != nil {
+			left[groupName] = others
+		}
+	}
+	return left
+}
+
+// transfer replaces the connection with fromID by conn in all groups of the connection
+func (r *groupRegistry) transfer(fromID string, conn hubConnection) {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	for _, g := range r.groups {
+		if _, ok := g.members[fromID]; ok {
+			delete(g.members, fromID)
+			g.members[conn.GetConnectionID()] = conn
+		}
+	}
+}
+
+func (r *groupRegistry) removeMember(groupName string, g *group, connectionID string) (hubConnection, []hubConnection) {
+	conn, ok := g.members[connectionID]
+	if !ok {
+		return nil, nil
+	}
+	delete(g.members, connectionID)
+	if len(g.members) == 0 && !g.created {
+		delete(r.groups, groupName)
+		if r.tree != nil {
+			r.tree.remove(strings.Split(groupName, "."))
+		}
+	}
+	return conn, r.otherMembers(g)
+}
+
+// otherMembers returns the members of g, if the registry returns the other members
+func (r *groupRegistry) otherMembers(g *group) []hubConnection {
+	if !r.others {
+		return nil
+	}
+	members := make([]hubConnection, 0, len(g.members))
+	for _, conn := range g.members {
+		members = append(members, conn)
+	}
+	return members
+}
+
+// members returns the connections of the groups, each connection once. With WildcardGroups, the group names can be patterns.
+// The invocation sent to them is retained by the groups which retain messages
+func (r *groupRegistry) members(groupNames []string, target string, args []interface{}) []hubConnection {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	var members []hubConnection
+	added := make(map[string]bool)
+	for _, groupName := range r.expand(groupNames) {
+		if g, ok := r.groups[groupName]; ok {
+			if g.retention.Count > 0 {
+				g.retained = append(g.retained, RetainedMessage{Target: target, Arguments: args})
+				if len(g.retained) > g.retention.Count {
+					g.retained = g.retained[len(g.retained)-g.retention.Count:]
+				}
+			}
+			for connectionID, conn := range g.members {
+				if !added[connectionID] {
+					added[connectionID] = true
+					members = append(members, conn)
+				}
+			}
+		}
+	}
+	return members
+}
@@ -0,0 +1,131 @@
+package signalr
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"sync"
+	"time"
+)
+
+// HandshakeRequest is the handshake request of a connection as passed to a HandshakeFunc.
+// Fields are all fields of the request, including protocol, version and capabilities
+type HandshakeRequest struct {
+	ConnectionID string
+	Protocol     string
+	Version      int
+	Capabilities Capabilities
+	Fields       map[string]json.RawMessage
+}
+
+// HandshakeFunc is called after the protocol of a connection has been accepted. It reads the fields
+// the client sent with the handshake request and returns the fields added to the handshake response,
+// e.g. the server version, feature flags or a session ID. If it returns an error, the error is sent
+// to the client as handshake error and the connection is refused, e.g. when the app version of the
+// client is not supported anymore. A field named "error" in the response is ignored
+type HandshakeFunc func(request HandshakeRequest) (map[string]interface{}, error)
+
+// HandshakeHandler sets the HandshakeFunc of the server. By default, the handshake response has no fields
+func HandshakeHandler(handler HandshakeFunc) Option {
+	return func(s *Server) {
+		s.handshakeHandler = handler
+	}
+}
+
+// HandshakeTimeout sets the time in which a client has to complete its handshake. A client exceeding it is sent
+// the handshake error "Handshake was canceled." and the connection is closed. Default is 15 seconds, like ASP.NET
+func HandshakeTimeout(timeout time.Duration) Option {
+	return func(s *Server) {
+		s.handshakeTimeout = timeout
+	}
+}
+
+const defaultHandshakeTimeout = 15 * time.Second
+
+// errHandshakeCanceled is the handshake error of a client which exceeded the HandshakeTimeout
+var errHandshakeCanceled = errors.New("Handshake was canceled.")
+
+// handshakeConnection is the Connection a handshake is processed with. It cancels the handshake if the
+// handshake response has not been written when the HandshakeTimeout expires
+type handshakeConnection struct {
+	Connection
+	mx        sync.Mutex
+	responded bool
+	timedOut  bool
+}
+
+func (h *handshakeConnection) Write(p []byte) (int, error) {
+	h.mx.Lock()
+	defer h.mx.Unlock()
+	if h.responded {
+		return 0, errHandshakeCanceled
+	}
+	h.responded = true
+	n, err := h.Connection.Write(p)
+	if err == nil {
+		err = flushConnection(h.Connection)
+	}
+	return n, err
+}
+
+// canceled returns if the handshake has been canceled by the HandshakeTimeout
+func (h *handshakeConnection) canceled() bool {
+	h.mx.Lock()
+	defer h.mx.Unlock()
+	return h.timedOut
+}
+
+// cancel sends the handshake error and closes the connection, unless the handshake response has been written
+func (h *handshakeConnection) cancel() {
+	h.mx.Lock()
+	defer h.mx.Unlock()
+	if h.responded {
+		return
+	}
+	h.responded, h.timedOut = true, true
+	encodedError, _ := json.Marshal(errHandshakeCanceled.Error())
+	_, _ = h.Connection.Write([]byte("{\"error\":" + string(encodedError) + "}\u001e"))
+	_ = flushConnection(h.Connection)
+	if closer, ok := h.Connection.(io.Closer); ok {
+		_ = closer.Close()
+	}
+}
+
+// WelcomeFunc returns the invocation sent to a connection right after its handshake, e.g. the initial state
+// of the app. An empty target sends nothing
+type WelcomeFunc func(ctx ConnectionContext) (target string, args []interface{})
+
+// Welcome sets the WelcomeFunc of the server. The welcome invocation is sent before the connection is added
+// to the lifetime manager and before the pending messages of a resumed session, so the client receives it
+// before any broadcast, group or user message. By default, no welcome invocation is sent
+func Welcome(welcome WelcomeFunc) Option {
+	return func(s *Server) {
+		s.welcome = welcome
+	}
+}
+
+// handshakeResponse returns the handshake response of an accepted handshake, with the fields returned by the HandshakeFunc
+func handshakeResponse(handler HandshakeFunc, connectionID string, request handshakeRequest, rawHandshake []byte) ([]byte, error) {
+	fields := map[string]interface{}{}
+	if handler != nil {
+		handshake := HandshakeRequest{
+			ConnectionID: connectionID,
+			Protocol:     request.Protocol,
+			Version:      request.Version,
+			Capabilities: request.Capabilities,
+		}
+		if err := json.Unmarshal(rawHandshake, &handshake.Fields); err != nil {
+			return nil, err
+		}
+		var err error
+		if fields, err = handler(handshake); err != nil {
+			return nil, err
+		}
+		delete(fields, "error")
+	}
+	response, err := json.Marshal(fields)
+	if err != nil {
+		return nil, err
+	}
+	return append(response, 30), nil
+}
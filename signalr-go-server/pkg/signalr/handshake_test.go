@@ -0,0 +1,129 @@
+package signalr
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"golang.org/x/net/websocket"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type welcomeHub struct {
+	Hub
+}
+
+func (w *welcomeHub) Ready() {}
+
+var _ = Describe("Handshake", func() {
+
+	Describe("Server with a HandshakeFunc", func() {
+		requests := make(chan HandshakeRequest, 2)
+		mux := http.NewServeMux()
+		MapHub(mux, "/hub", &contextHub{}, HandshakeHandler(func(request HandshakeRequest) (map[string]interface{}, error) {
+			requests <- request
+			var appVersion string
+			if err := json.Unmarshal(request.Fields["appVersion"], &appVersion); err != nil {
+				return nil, err
+			}
+			if !request.Capabilities.AtLeastVersion("minVersion", "1.0") || appVersion < "2" {
+				return nil, fmt.Errorf("app version %q is not supported", appVersion)
+			}
+			return map[string]interface{}{"serverVersion": "1.4.0", "sessionId": "s-" + appVersion, "error": "ignored"}, nil
+		}))
+		handshake := func(request string) string {
+			httpServer := httptest.NewServer(mux)
+			defer httpServer.Close()
+			ws, err := websocket.Dial("ws"+strings.TrimPrefix(httpServer.URL, "http")+"/hub", "", httpServer.URL)
+			Expect(err).To(BeNil())
+			defer ws.Close()
+			Expect(websocket.Message.Send(ws, request+"\u001e")).To(Succeed())
+			var response string
+			Expect(websocket.Message.Receive(ws, &response)).To(Succeed())
+			return response
+		}
+		Context("When the client sends extra fields the HandshakeFunc accepts", func() {
+			It("should pass the fields to the HandshakeFunc and add its fields to the response", func() {
+				response := handshake(`{"protocol":"json","version":1,"appVersion":"2.1","capabilities":{"minVersion":"1.2"}}`)
+				Expect(response).To(Equal(`{"serverVersion":"1.4.0","sessionId":"s-2.1"}` + "\u001e"))
+				request := <-requests
+				Expect(request.Protocol).To(Equal("json"))
+				Expect(request.ConnectionID).NotTo(BeEmpty())
+				Expect(request.Fields).To(HaveKey("appVersion"))
+			})
+		})
+		Context("When the HandshakeFunc rejects the handshake", func() {
+			It("should send its error as handshake error", func() {
+				response := handshake(`{"protocol":"json","version":1,"appVersion":"1.9","capabilities":{"minVersion":"1.2"}}`)
+				Expect(response).To(Equal(`{"error":"app version \"1.9\" is not supported"}` + "\u001e"))
+			})
+		})
+	})
+
+	Describe("Server with WebSocket subprotocols", func() {
+		mux := http.NewServeMux()
+		MapHub(mux, "/hub", &bandwidthHub{})
+		dial := func(httpServer *httptest.Server, subprotocol string) *websocket.Conn {
+			ws, err := websocket.Dial("ws"+strings.TrimPrefix(httpServer.URL, "http")+"/hub", subprotocol, httpServer.URL)
+			Expect(err).To(BeNil())
+			return ws
+		}
+		echo := func(ws *websocket.Conn) string {
+			Expect(websocket.Message.Send(ws, `{"type":1,"invocationId":"echo","target":"echo","arguments":["hi"]}`+"\u001e")).To(Succeed())
+			var message string
+			for !strings.Contains(message, `"type":3`) {
+				Expect(websocket.Message.Receive(ws, &message)).To(Succeed())
+			}
+			return message
+		}
+		Context("When the client selects the protocol by subprotocol", func() {
+			It("should accept invocations without handshake", func() {
+				httpServer := httptest.NewServer(mux)
+				defer httpServer.Close()
+				ws := dial(httpServer, "json")
+				defer ws.Close()
+				Expect(echo(ws)).To(ContainSubstring(`"result":"hi"`))
+			})
+		})
+		Context("When the client requests an unknown subprotocol", func() {
+			It("should not accept the subprotocol and expect the handshake", func() {
+				httpServer := httptest.NewServer(mux)
+				defer httpServer.Close()
+				ws := dial(httpServer, "mqtt")
+				defer ws.Close()
+				Expect(websocket.Message.Send(ws, `{"protocol":"json","version":1}`+"\u001e")).To(Succeed())
+				var response string
+				Expect(websocket.Message.Receive(ws, &response)).To(Succeed())
+				Expect(response).To(Equal("{}\u001e"))
+				Expect(echo(ws)).To(ContainSubstring(`"result":"hi"`))
+			})
+		})
+	})
+
+	Describe("Server with a WelcomeFunc", func() {
+		server := NewServer(&welcomeHub{}, IdentifyUser(func(ctx ConnectionContext) string {
+			return ctx.Query().Get("user")
+		}), Welcome(func(ctx ConnectionContext) (string, []interface{}) {
+			return "welcome", []interface{}{ctx.UserID()}
+		}))
+		Context("When a connection has been accepted", func() {
+			It("should send the welcome invocation before any other message", func() {
+				conn := newTestingConnection()
+				req := httptest.NewRequest("GET", "/hub?user=wendy", nil)
+				go server.Run(&userConnection{server.newRequestMetadata(req, nil), conn, "w"})
+				welcome := (<-conn.received).(InvocationMessage)
+				Expect(welcome.Target).To(Equal("welcome"))
+				Expect(welcome.Arguments).To(Equal([]interface{}{"wendy"}))
+				_, err := conn.clientSend(`{"type":1,"invocationId":"w","target":"ready"}`)
+				Expect(err).To(BeNil())
+				Expect((<-conn.received).(CompletionMessage).InvocationID).To(Equal("w"))
+				server.HubContext().Clients().All().Send("news")
+				expectTargets(conn, "news")
+			})
+		})
+	})
+})
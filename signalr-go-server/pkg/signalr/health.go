@@ -0,0 +1,84 @@
+package signalr
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"runtime"
+	"sync/atomic"
+)
+
+// HealthCheck is a check of a dependency of the server, e.g. a backplane connection.
+// It returns an error when the dependency is not usable
+type HealthCheck func() error
+
+type namedHealthCheck struct {
+	name  string
+	check HealthCheck
+}
+
+// healthReport is the response of the health and readiness handlers
+type healthReport struct {
+	Status      string `json:"status"`
+	Error       string `json:"error,omitempty"`
+	Connections int    `json:"connections"`
+	Loops       int32  `json:"loops"`
+	Goroutines  int    `json:"goroutines"`
+}
+
+// Healthy returns an error if one of the health checks of the server fails
+// or the number of goroutines of the process exceeds the limit set with MaxGoroutines
+func (s *Server) Healthy() error {
+	for _, check := range s.healthChecks {
+		if err := check.check(); err != nil {
+			return fmt.Errorf("%s: %v", check.name, err)
+		}
+	}
+	if s.maxGoroutines > 0 {
+		if n := runtime.NumGoroutine(); n > s.maxGoroutines {
+			return fmt.Errorf("%v goroutines exceed the limit of %v", n, s.maxGoroutines)
+		}
+	}
+	return nil
+}
+
+// Ready returns an error if the server should not get new clients, because it is draining or not healthy
+func (s *Server) Ready() error {
+	if s.connections.isDraining() {
+		return errors.New("server is draining")
+	}
+	return s.Healthy()
+}
+
+// HealthHandler returns a handler for liveness probes, e.g. /healthz. It answers with 200 when the server
+// is healthy and with 503 when not. The response reports the number of connections, running connection loops and goroutines
+func (s *Server) HealthHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		s.writeHealthReport(w, s.Healthy())
+	})
+}
+
+// ReadinessHandler returns a handler for readiness probes, e.g. /readyz. It answers with 200 when the server
+// is ready and with 503 when not
+func (s *Server) ReadinessHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		s.writeHealthReport(w, s.Ready())
+	})
+}
+
+func (s *Server) writeHealthReport(w http.ResponseWriter, err error) {
+	report := healthReport{
+		Status:      "Healthy",
+		Connections: s.connections.liveCount(),
+		Loops:       atomic.LoadInt32(&s.runningLoops),
+		Goroutines:  runtime.NumGoroutine(),
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err != nil {
+		report.Status = "Unhealthy"
+		report.Error = err.Error()
+		w.WriteHeader(503)
+	}
+	_ = json.NewEncoder(w).Encode(report)
+}
@@ -0,0 +1,72 @@
+package signalr
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func probe(handler http.Handler) (int, healthReport) {
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/healthz", nil))
+	var report healthReport
+	Expect(json.Unmarshal(recorder.Body.Bytes(), &report)).To(Succeed())
+	return recorder.Code, report
+}
+
+var _ = Describe("Health", func() {
+
+	Describe("Healthy server", func() {
+		server := NewServer(&contextHub{})
+		conn := newTestingConnection()
+		go server.Run(conn)
+		Context("When the probes are called with a connected client", func() {
+			It("should report healthy and ready with the connection", func() {
+				_, err := conn.clientSend(`{"type":1,"invocationId": "health","target":"ready"}`)
+				Expect(err).To(BeNil())
+				Expect((<-conn.received).(CompletionMessage).InvocationID).To(Equal("health"))
+				code, report := probe(server.HealthHandler())
+				Expect(code).To(Equal(200))
+				Expect(report.Status).To(Equal("Healthy"))
+				Expect(report.Connections).To(Equal(1))
+				Expect(report.Loops).To(Equal(int32(1)))
+				code, _ = probe(server.ReadinessHandler())
+				Expect(code).To(Equal(200))
+			})
+		})
+	})
+
+	Describe("Server with a failing health check", func() {
+		server := NewServer(&contextHub{}, AddHealthCheck("backplane", func() error {
+			return errors.New("not connected")
+		}))
+		Context("When the probes are called", func() {
+			It("should report unhealthy and not ready", func() {
+				code, report := probe(server.HealthHandler())
+				Expect(code).To(Equal(503))
+				Expect(report.Error).To(Equal("backplane: not connected"))
+				code, _ = probe(server.ReadinessHandler())
+				Expect(code).To(Equal(503))
+			})
+		})
+	})
+
+	Describe("Draining server", func() {
+		server := NewServer(&contextHub{})
+		Context("When the probes are called after Drain", func() {
+			It("should report healthy but not ready", func() {
+				server.Drain(time.Millisecond)
+				code, _ := probe(server.HealthHandler())
+				Expect(code).To(Equal(200))
+				code, report := probe(server.ReadinessHandler())
+				Expect(code).To(Equal(503))
+				Expect(report.Error).To(Equal("server is draining"))
+			})
+		})
+	})
+})
@@ -27,6 +27,16 @@ func (h *Hub) Groups() GroupManager {
 	return h.context.Groups()
 }
 
+// Tags returns the connection tags of this hub
+func (h *Hub) Tags() TagManager {
+	return h.context.Tags()
+}
+
+// Tenant returns the HubContext of the connections of a tenant, see IsolateTenants
+func (h *Hub) Tenant(key string) HubContext {
+	return h.context.Tenant(key)
+}
+
 // OnConnected is called when the hub is connected
 func (h *Hub) OnConnected(string) {}
 
@@ -1,13 +1,29 @@
 package signalr
 
+import "context"
+
 // HubClients gives the hub access to various client groups
 // All() gets a ClientProxy that can be used to invoke methods on all clients connected to the hub
 // Client() gets a ClientProxy that can be used to invoke methods on the specified client connection
 // Group() gets a ClientProxy that can be used to invoke methods on all connections in the specified group
+// Clients() gets a ClientProxy that can be used to invoke methods on the specified client connections
+// User() gets a ClientProxy that can be used to invoke methods on all connections of the specified user
+// Users() gets a ClientProxy that can be used to invoke methods on all connections of the specified users
+// Groups() gets a ClientProxy that can be used to invoke methods on all connections in the specified groups
+// Tagged() gets a ClientProxy that can be used to invoke methods on all connections with tags matching the expression
+// InvokeClientWithAck() invokes a method on the specified client connection and waits until the client handler has run.
+// It returns the error sent by the client, ErrConnectionClosed, ErrUnknownConnection, ErrTimeout after the ClientResultTimeout
+// or the error of ctx when it is done first. The invocation is forgotten when the wait ends, a late completion is ignored
 type HubClients interface {
 	All() ClientProxy
 	Client(connectionID string) ClientProxy
 	Group(groupName string) ClientProxy
+	Clients(connectionIDs []string) ClientProxy
+	User(userID string) ClientProxy
+	Users(userIDs []string) ClientProxy
+	Groups(groupNames []string) ClientProxy
+	Tagged(expression *TagExpression) ClientProxy
+	InvokeClientWithAck(ctx context.Context, connectionID string, target string, args ...interface{}) error
 }
 
 type defaultHubClients struct {
@@ -26,3 +42,27 @@ func (c *defaultHubClients) Client(connectionID string) ClientProxy {
 func (c *defaultHubClients) Group(groupName string) ClientProxy {
 	return &groupClientProxy{groupName: groupName, lifetimeManager: c.lifetimeManager}
 }
+
+func (c *defaultHubClients) Clients(connectionIDs []string) ClientProxy {
+	return &multiClientProxy{connectionIDs: connectionIDs, lifetimeManager: c.lifetimeManager}
+}
+
+func (c *defaultHubClients) User(userID string) ClientProxy {
+	return &usersClientProxy{userIDs: []string{userID}, lifetimeManager: c.lifetimeManager}
+}
+
+func (c *defaultHubClients) Users(userIDs []string) ClientProxy {
+	return &usersClientProxy{userIDs: userIDs, lifetimeManager: c.lifetimeManager}
+}
+
+func (c *defaultHubClients) Groups(groupNames []string) ClientProxy {
+	return &groupsClientProxy{groupNames: groupNames, lifetimeManager: c.lifetimeManager}
+}
+
+func (c *defaultHubClients) Tagged(expression *TagExpression) ClientProxy {
+	return &taggedClientProxy{expression: expression, lifetimeManager: c.lifetimeManager}
+}
+
+func (c *defaultHubClients) InvokeClientWithAck(ctx context.Context, connectionID string, target string, args ...interface{}) error {
+	return c.lifetimeManager.InvokeClientWithAck(ctx, connectionID, target, args)
+}
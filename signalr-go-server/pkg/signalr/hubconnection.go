@@ -2,33 +2,345 @@ package signalr
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
+	"io"
+	"net"
+	"sync"
 	"sync/atomic"
+	"time"
 )
 
+const defaultWriteTimeout = 30 * time.Second
+
+const defaultMaxMessageSize = 1 << 20 // 1MB
+
+const defaultStreamBufferSize = 1 << 20 // 1MB
+
+const defaultClientResultTimeout = 30 * time.Second
+
 type hubConnection interface {
 	Start()
 	IsConnected() bool
 	Close(error string)
+	SetDisconnectReason(reason DisconnectReason)
+	SetRoundTrip(roundTrip time.Duration)
+	DisconnectReason() DisconnectReason
 	GetConnectionID() string
+	GetUserID() string
+	GetProtocolName() string
+	Features() *Features
 	Receive() (interface{}, error)
 	SendInvocation(target string, args []interface{})
+	SendPrepared(message *preparedMessage)
+	Stats() ConnectionStats
+	InvokeWithResult(target string, args []interface{}) (string, <-chan CompletionMessage, error)
+	CompleteInvocation(completion CompletionMessage) bool
+	CancelInvocation(id string)
 	StreamItem(id string, item interface{})
 	Completion(id string, result interface{}, error string)
 	Ping()
 }
 
-func newHubConnection(connection Connection, protocol HubProtocol) hubConnection {
+// hubConnectionOptions are the settings of a hubConnection
+type hubConnectionOptions struct {
+	writeTimeout time.Duration
+	userID       string
+	features     *Features
+	slowConsumer *SlowConsumerPolicy
+	intercept    func(target string, args []interface{}) ([]interface{}, bool)
+	readModel    ReadModel
+	messageTTL   time.Duration
+	// maxMessageSize is the maximum size of a received message, 0 means no limit
+	maxMessageSize int
+	// streamBufferSize is the number of queued bytes at which streams to the connection pause, 0 means no limit
+	streamBufferSize int
+	// readBufferSize is the size of the buffer the connection reads into, readSize if 0
+	readBufferSize int
+	// logger logs the errors of the connection, by default to stdout
+	logger StructuredLogger
+	// bandwidthQuota limits the bytes transferred by the connection, timed by clock, if not nil
+	bandwidthQuota *BandwidthQuota
+	// clock times the messages and bandwidth of the connection, by default the real clock
+	clock Clock
+	// targets are the targets the client subscribed to with CapabilityTargets, nil if it gets all
+	targets map[string]bool
+}
+
+func newHubConnection(connection Connection, protocol HubProtocol, options hubConnectionOptions) hubConnection {
+	logger := options.logger
+	if logger == nil {
+		logger = stdoutLogger
+	}
+	clock := options.clock
+	if clock == nil {
+		clock = realClock{}
+	}
+	readBufferSize := options.readBufferSize
+	if readBufferSize <= 0 {
+		readBufferSize = readSize
+	}
 	return &defaultHubConnection{
-		Protocol:   protocol,
-		Connection: connection,
+		Protocol:         protocol,
+		Connection:       connection,
+		WriteTimeout:     options.writeTimeout,
+		UserID:           options.userID,
+		features:         options.features,
+		SlowConsumer:     options.slowConsumer,
+		Intercept:        options.intercept,
+		ReadModel:        options.readModel,
+		MessageTTL:       options.messageTTL,
+		MaxMessageSize:   options.maxMessageSize,
+		StreamBufferSize: options.streamBufferSize,
+		readBufferSize:   readBufferSize,
+		bandwidth:        newBandwidthMeter(options.bandwidthQuota, clock),
+		targets:          options.targets,
+		logger:           logger,
+		clock:            clock,
 	}
 }
 
 type defaultHubConnection struct {
-	Protocol   HubProtocol
-	Connected  int32
-	Connection Connection
+	Protocol     HubProtocol
+	Connected    int32
+	Connection   Connection
+	WriteTimeout time.Duration
+	UserID       string
+	// features are the features of the connection, shared with its ConnectionContext
+	features     *Features
+	SlowConsumer *SlowConsumerPolicy
+	// Intercept applies the OutboundInterceptors of the server to the invocations sent to the client, if not nil
+	Intercept func(target string, args []interface{}) ([]interface{}, bool)
+	ReadModel ReadModel
+	// MessageTTL is the time after which an invocation which has not been written yet is dropped, 0 means never
+	MessageTTL time.Duration
+	// MaxMessageSize is the maximum size of a received message, 0 means no limit
+	MaxMessageSize int
+	// StreamBufferSize is the number of bytes queued by the connection at which streams pause, 0 means no limit
+	StreamBufferSize int
+	// buf keeps received data which has not been parsed yet. Its storage is reused for all messages
+	buf bytes.Buffer
+	// readBuf is the buffer the connection reads into, it is allocated once with readBufferSize bytes
+	readBuf        []byte
+	readBufferSize int
+	// messageSize is the size of the last received message
+	messageSize int
+	// scanned is the number of bytes at the start of buf which have been searched for the end of a text message
+	scanned int
+	// writeLock serializes the writes of all goroutines sending over the connection, by the lane of their message
+	writeLock laneLock
+	// queueDepth, latency and writing are the outbound statistics in nanoseconds.
+	// writing is the time the message being written was sent, 0 when no message is written
+	queueDepth int32
+	latency    int64
+	writing    int64
+	// messagesIn and messagesOut count the messages received and written
+	messagesIn      int64
+	messagesOut     int64
+	messagesExpired int64
+	// bytesIn and bytesOut count the bytes received and written
+	bytesIn  int64
+	bytesOut int64
+	// bandwidth enforces the BandwidthQuota of the connection, nil if it has none
+	bandwidth *bandwidthMeter
+	logger    StructuredLogger
+	// clock times the latency and the MessageTTL of the messages
+	clock Clock
+	// targets are the targets the client subscribed to, nil if it gets all invocations
+	targets map[string]bool
+	// roundTrip is the last round trip time measured in nanoseconds, see MeasureRoundTrip
+	roundTrip int64
+	// invocations are the invocations sent to the client which wait for its completion
+	invocationsMx sync.Mutex
+	invocations   map[string]chan CompletionMessage
+	lastID        int64
+	// invocationsClosed is set by Close, invocations sent after it fail with ErrConnectionClosed
+	invocationsClosed bool
+	// disconnectReason is the DisconnectReason + 1 of the first party which ended the connection, 0 if none did
+	disconnectReason int32
+}
+
+// writeMessage writes one message. If the Connection supports write deadlines and the message
+// can not be written within WriteTimeout, the Connection is closed, which ends the connection.
+// Messages are written one after the other. Waiting pings and close messages are written first, then completions,
+// stream items and invocations waiting for a result, then the other invocations. Messages of the same kind are
+// written in the order writeMessage is called
+func (c *defaultHubConnection) writeMessage(message interface{}) error {
+	sent := c.clock.Now()
+	atomic.AddInt32(&c.queueDepth, 1)
+	defer atomic.AddInt32(&c.queueDepth, -1)
+	c.writeLock.lock(messageLane(message))
+	defer c.writeLock.unlock()
+	atomic.StoreInt64(&c.writing, sent.UnixNano())
+	defer func() {
+		atomic.StoreInt64(&c.writing, 0)
+		atomic.StoreInt64(&c.latency, int64(c.clock.Now().Sub(sent)))
+	}()
+	deadliner, canDeadline := c.Connection.(interface{ SetWriteDeadline(t time.Time) error })
+	if canDeadline && c.WriteTimeout > 0 {
+		// Write deadlines of network connections are wall clock times
+		if err := deadliner.SetWriteDeadline(time.Now().Add(c.WriteTimeout)); err != nil {
+			return err
+		}
+	}
+	var writer io.Writer = c.Connection
+	if c.MessageTTL > 0 && expires(message) {
+		ttl := c.MessageTTL - c.clock.Now().Sub(sent)
+		if ttl <= 0 {
+			// The invocation waited too long for the connection, its content is stale
+			atomic.AddInt64(&c.messagesExpired, 1)
+			return c.flushIdle()
+		}
+		if expiring, ok := c.Connection.(interface {
+			writeWithTTL(p []byte, ttl time.Duration) (int, error)
+		}); ok {
+			// The transport queues messages itself, e.g. long polling, and drops them when they expire there
+			writer = ttlWriter{ttl: ttl, write: expiring.writeWithTTL}
+		}
+	}
+	counter := &countingWriter{w: writer}
+	var err error
+	if prepared, ok := message.(*preparedMessage); ok {
+		var data []byte
+		if data, err = prepared.encode(c.Protocol); err != nil {
+			_ = c.flushIdle()
+			return &serializationError{err: err}
+		}
+		_, err = counter.Write(data)
+	} else if err = encodeMessage(c.Protocol, message, counter); err != nil && counter.err == nil && counter.n == 0 {
+		_ = c.flushIdle()
+		return &serializationError{err: err}
+	}
+	atomic.AddInt64(&c.bytesOut, int64(counter.n))
+	if err == nil {
+		err = c.flushIdle()
+	}
+	if err == nil {
+		atomic.AddInt64(&c.messagesOut, 1)
+		// Throttling holds back the following messages. Pings and close messages must not wait
+		_ = c.enforceQuota(counter.n, false, !isControlMessage(message))
+	}
+	if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+		c.SetDisconnectReason(DisconnectTimeout)
+		atomic.StoreInt32(&c.Connected, 0)
+		if closer, ok := c.Connection.(io.Closer); ok {
+			_ = closer.Close()
+		}
+	}
+	return err
+}
+
+// flushIdle flushes the messages a buffering transport collected, unless further messages wait to be written after them.
+// The last of the waiting messages flushes, so the collected messages are not held back
+func (c *defaultHubConnection) flushIdle() error {
+	if _, ok := c.Connection.(bufferedConnection); !ok || c.writeLock.contended() {
+		return nil
+	}
+	return flushConnection(c.Connection)
+}
+
+// isControlMessage returns if message is a ping or close message
+func isControlMessage(message interface{}) bool {
+	switch message.(type) {
+	case HubMessage, CloseMessage:
+		return true
+	default:
+		return false
+	}
+}
+
+// expires returns if message can expire. Only invocations without result expire
+func expires(message interface{}) bool {
+	switch message := message.(type) {
+	case *preparedMessage:
+		return true
+	case InvocationMessage:
+		return message.InvocationID == ""
+	default:
+		return false
+	}
+}
+
+// ttlWriter writes to a transport which drops messages after ttl
+type ttlWriter struct {
+	ttl   time.Duration
+	write func(p []byte, ttl time.Duration) (int, error)
+}
+
+func (t ttlWriter) Write(p []byte) (int, error) {
+	return t.write(p, t.ttl)
+}
+
+// Stats returns the outbound statistics of the connection
+func (c *defaultHubConnection) Stats() ConnectionStats {
+	latency := time.Duration(atomic.LoadInt64(&c.latency))
+	if writing := atomic.LoadInt64(&c.writing); writing != 0 {
+		if waiting := c.clock.Now().Sub(time.Unix(0, writing)); waiting > latency {
+			latency = waiting
+		}
+	}
+	return ConnectionStats{
+		ConnectionID:    c.GetConnectionID(),
+		QueueDepth:      int(atomic.LoadInt32(&c.queueDepth)),
+		Latency:         latency,
+		MessagesIn:      atomic.LoadInt64(&c.messagesIn),
+		MessagesOut:     atomic.LoadInt64(&c.messagesOut),
+		MessagesExpired: atomic.LoadInt64(&c.messagesExpired),
+		BytesIn:         atomic.LoadInt64(&c.bytesIn),
+		BytesOut:        atomic.LoadInt64(&c.bytesOut),
+		RoundTrip:       time.Duration(atomic.LoadInt64(&c.roundTrip)),
+	}
+}
+
+// admit applies the SlowConsumerPolicy before an invocation is sent. It returns false if the invocation must not be sent
+func (c *defaultHubConnection) admit() bool {
+	if c.SlowConsumer == nil {
+		return true
+	}
+	stats := c.Stats()
+	if !c.SlowConsumer.lagging(stats) {
+		return true
+	}
+	if c.SlowConsumer.Action == Disconnect {
+		if !atomic.CompareAndSwapInt32(&c.Connected, 1, 0) {
+			// Already disconnected
+			return false
+		}
+		c.SetDisconnectReason(DisconnectKicked)
+		if closer, ok := c.Connection.(io.Closer); ok {
+			_ = closer.Close()
+		}
+	}
+	if c.SlowConsumer.OnSlowConsumer != nil {
+		c.SlowConsumer.OnSlowConsumer(stats, c.SlowConsumer.Action)
+	}
+	return false
+}
+
+// intercept applies Intercept to an invocation. It returns false if the invocation must not be sent
+func (c *defaultHubConnection) intercept(target string, args []interface{}) ([]interface{}, bool) {
+	if c.Intercept == nil {
+		return args, true
+	}
+	return c.Intercept(target, args)
+}
+
+// SetRoundTrip records the round trip time measured for the connection
+func (c *defaultHubConnection) SetRoundTrip(roundTrip time.Duration) {
+	atomic.StoreInt64(&c.roundTrip, int64(roundTrip))
+}
+
+// SetDisconnectReason records why the connection ends. Only the first reason is kept
+func (c *defaultHubConnection) SetDisconnectReason(reason DisconnectReason) {
+	atomic.CompareAndSwapInt32(&c.disconnectReason, 0, int32(reason)+1)
+}
+
+// DisconnectReason returns the recorded reason, DisconnectTransportError if none was recorded
+func (c *defaultHubConnection) DisconnectReason() DisconnectReason {
+	if reason := atomic.LoadInt32(&c.disconnectReason); reason > 0 {
+		return DisconnectReason(reason - 1)
+	}
+	return DisconnectTransportError
 }
 
 func (c *defaultHubConnection) Start() {
@@ -39,16 +351,27 @@ func (c *defaultHubConnection) IsConnected() bool {
 	return atomic.LoadInt32(&c.Connected) == 1
 }
 
+// Close sends a close message to the client, if the connection has not been closed before.
+// Invocations waiting for the completion from the client are ended without completion
 func (c *defaultHubConnection) Close(error string) {
-	atomic.StoreInt32(&c.Connected, 0)
+	c.invocationsMx.Lock()
+	for id, completed := range c.invocations {
+		close(completed)
+		delete(c.invocations, id)
+	}
+	c.invocationsClosed = true
+	c.invocationsMx.Unlock()
+	if !atomic.CompareAndSwapInt32(&c.Connected, 1, 0) {
+		return
+	}
 
-	var closeMessage = closeMessage{
+	var closeMessage = CloseMessage{
 		Type:           7,
 		Error:          error,
 		AllowReconnect: true,
 	}
-	if err := c.Protocol.WriteMessage(closeMessage, c.Connection); err != nil {
-		fmt.Printf("cannot close connection %v: %v", c.GetConnectionID(), err)
+	if err := c.writeMessage(closeMessage); err != nil {
+		_ = c.logger.Log("connection", c.GetConnectionID(), "event", "cannot close", "error", err)
 	}
 }
 
@@ -56,40 +379,197 @@ func (c *defaultHubConnection) GetConnectionID() string {
 	return c.Connection.ConnectionID()
 }
 
+func (c *defaultHubConnection) GetProtocolName() string {
+	return c.Protocol.Name()
+}
+
+func (c *defaultHubConnection) GetUserID() string {
+	return c.UserID
+}
+
+// Features returns the features of the connection
+func (c *defaultHubConnection) Features() *Features {
+	return c.features
+}
+
+// SendPrepared sends a message which has been prepared for sending to many connections
+func (c *defaultHubConnection) SendPrepared(message *preparedMessage) {
+	if invocation, ok := message.message.(InvocationMessage); ok && !c.subscribed(invocation.Target) {
+		return
+	}
+	if !c.admit() {
+		return
+	}
+	if invocation, ok := message.message.(InvocationMessage); ok && c.Intercept != nil {
+		// The arguments may differ for each connection, so the shared encoding can not be used
+		c.SendInvocation(invocation.Target, invocation.Arguments)
+		return
+	}
+	if err := c.writeMessage(message); err != nil {
+		_ = c.logger.Log("connection", c.GetConnectionID(), "event", "cannot send prepared message", "message", fmt.Sprintf("%v", message.message), "error", err)
+	}
+}
+
 func (c *defaultHubConnection) SendInvocation(target string, args []interface{}) {
-	var invocationMessage = invocationMessage{
+	if !c.subscribed(target) {
+		return
+	}
+	if !c.admit() {
+		return
+	}
+	var ok bool
+	if args, ok = c.intercept(target, args); !ok {
+		return
+	}
+	var invocationMessage = InvocationMessage{
 		Type:      1,
 		Target:    target,
 		Arguments: args,
 	}
 
-	if err := c.Protocol.WriteMessage(invocationMessage, c.Connection); err != nil {
-		fmt.Printf("cannot send invocation %v %v over connection %v: %v", target, args, c.GetConnectionID(), err)
+	if err := c.writeMessage(invocationMessage); err != nil {
+		_ = c.logger.Log("connection", c.GetConnectionID(), "event", "cannot send invocation", "target", target, "error", err)
 	}
 }
 
+// subscribed returns if the client subscribed to invocations of target
+func (c *defaultHubConnection) subscribed(target string) bool {
+	return c.targets == nil || c.targets[target]
+}
+
 func (c *defaultHubConnection) Ping() {
-	var pingMessage = hubMessage{
+	var pingMessage = HubMessage{
 		Type: 6,
 	}
 
-	if err := c.Protocol.WriteMessage(pingMessage, c.Connection); err != nil {
-		fmt.Printf("cannot ping over connection %v: %v", c.GetConnectionID(), err)
+	if err := c.writeMessage(pingMessage); err != nil {
+		_ = c.logger.Log("connection", c.GetConnectionID(), "event", "cannot ping", "error", err)
 	}
 }
 
 func (c *defaultHubConnection) Receive() (interface{}, error) {
-	var buf bytes.Buffer
-	var data = make([]byte, 1<<12) // 4K
-	var n int
+	if c.ReadModel == SharedKeepAlive {
+		return c.receivePooled()
+	}
 	for {
-		if message, complete, err := c.Protocol.ReadMessage(&buf); !complete {
+		if message, complete, err := c.parseMessage(); !complete {
 			// Partial message, need more data
-			// ReadMessage read data out of the buf, so its gone there: refill
-			buf.Write(data[:n])
-			if n, err = c.Connection.Read(data); err == nil {
-				buf.Write(data[:n])
-			} else {
+			if err := c.checkMessageSize(); err != nil {
+				return nil, err
+			}
+			// The read buffer is reused for all reads of the connection
+			if c.readBuf == nil {
+				c.readBuf = make([]byte, c.readBufferSize)
+			}
+			n, err := c.Connection.Read(c.readBuf)
+			if err != nil {
+				return nil, err
+			}
+			c.buf.Write(c.readBuf[:n])
+			if err := c.received(n); err != nil {
+				return nil, err
+			}
+		} else {
+			return message, err
+		}
+	}
+}
+
+// readSize is the default free space the buffer of a connection has for each read
+const readSize = 1 << 12 // 4K
+
+// maxProtocolErrorData is the maximum number of bytes of a malformed message kept in a protocolError
+const maxProtocolErrorData = 128
+
+// protocolError is returned by Receive when the client sent a message which could not be parsed
+type protocolError struct {
+	err error
+	// data are the first bytes of the malformed message
+	data []byte
+}
+
+func (p *protocolError) Error() string {
+	return p.err.Error()
+}
+
+// parseMessage parses the next message from the received data. It returns false if the data does not contain a complete message
+func (c *defaultHubConnection) parseMessage() (interface{}, bool, error) {
+	received := c.buf.Bytes()
+	if c.Protocol.TransferFormat() == "Text" {
+		// Text messages end with a record separator. Only the data received since the last call is searched for it,
+		// so a large message arriving in many reads is not scanned again for each read
+		if bytes.IndexByte(received[c.scanned:], 30) == -1 {
+			c.scanned = len(received)
+			return nil, false, nil
+		}
+		// The data behind the separator has not been scanned yet
+		c.scanned = 0
+	}
+	message, complete, err := c.Protocol.ReadMessage(&c.buf)
+	if !complete {
+		return nil, false, nil
+	}
+	if err != nil {
+		c.SetDisconnectReason(DisconnectProtocolError)
+		data := received[:len(received)-c.buf.Len()]
+		if len(data) > maxProtocolErrorData {
+			data = data[:maxProtocolErrorData]
+		}
+		return nil, true, &protocolError{err: err, data: append([]byte(nil), data...)}
+	}
+	atomic.AddInt64(&c.messagesIn, 1)
+	c.messageSize = len(received) - c.buf.Len()
+	return message, true, nil
+}
+
+// checkMessageSize fails with a protocolError if the partial message received so far exceeds MaxMessageSize,
+// so a client can not make the server buffer a message of unbounded size
+func (c *defaultHubConnection) checkMessageSize() error {
+	if c.MaxMessageSize <= 0 || c.buf.Len() <= c.MaxMessageSize {
+		return nil
+	}
+	c.SetDisconnectReason(DisconnectProtocolError)
+	data := c.buf.Bytes()
+	if len(data) > maxProtocolErrorData {
+		data = data[:maxProtocolErrorData]
+	}
+	return &protocolError{err: fmt.Errorf("message exceeds the maximum size of %v bytes", c.MaxMessageSize), data: append([]byte(nil), data...)}
+}
+
+// receivedSize returns the size of the message last received by hubConn
+func receivedSize(hubConn hubConnection) int {
+	if sized, ok := hubConn.(*defaultHubConnection); ok {
+		return sized.messageSize
+	}
+	return 0
+}
+
+// received counts n received bytes and applies the BandwidthQuota of the connection to them
+func (c *defaultHubConnection) received(n int) error {
+	atomic.AddInt64(&c.bytesIn, int64(n))
+	return c.enforceQuota(n, true, true)
+}
+
+// receivePooled reads with a buffer of the pool, which is only held while a message is read.
+// The buffer of the received data is dropped as soon as no partial message is left in it
+func (c *defaultHubConnection) receivePooled() (interface{}, error) {
+	pool := readBuffers(c.readBufferSize)
+	data := pool.Get().(*[]byte)
+	defer pool.Put(data)
+	for {
+		if message, complete, err := c.parseMessage(); !complete {
+			if err := c.checkMessageSize(); err != nil {
+				return nil, err
+			}
+			if c.buf.Len() == 0 {
+				c.buf = bytes.Buffer{}
+			}
+			n, err := c.Connection.Read(*data)
+			if err != nil {
+				return nil, err
+			}
+			c.buf.Write((*data)[:n])
+			if err := c.received(n); err != nil {
 				return nil, err
 			}
 		} else {
@@ -99,26 +579,94 @@ func (c *defaultHubConnection) Receive() (interface{}, error) {
 }
 
 func (c *defaultHubConnection) Completion(id string, result interface{}, error string) {
-	var completionMessage = completionMessage{
+	var completionMessage = CompletionMessage{
 		Type:         3,
 		InvocationID: id,
 		Result:       result,
 		Error:        error,
 	}
 
-	if err := c.Protocol.WriteMessage(completionMessage, c.Connection); err != nil {
-		fmt.Printf("cannot send completion for invocation %v over connection %v: %v", id, c.GetConnectionID(), err)
+	err := c.writeMessage(completionMessage)
+	var serializationErr *serializationError
+	if errors.As(err, &serializationErr) && result != nil {
+		// The client is told that the invocation failed instead of waiting for its result
+		_ = c.logger.Log("connection", c.GetConnectionID(), "event", "cannot serialize result", "invocation", id, "error", err)
+		completionMessage.Result, completionMessage.Error = nil, fmt.Sprintf("Result could not be serialized: %v", serializationErr.err)
+		err = c.writeMessage(completionMessage)
+	}
+	if err != nil {
+		_ = c.logger.Log("connection", c.GetConnectionID(), "event", "cannot send completion", "invocation", id, "error", err)
 	}
 }
 
 func (c *defaultHubConnection) StreamItem(id string, item interface{}) {
-	var streamItemMessage = streamItemMessage{
+	var streamItemMessage = StreamItemMessage{
 		Type:         2,
 		InvocationID: id,
 		Item:         item,
 	}
 
-	if err := c.Protocol.WriteMessage(streamItemMessage, c.Connection); err != nil {
-		fmt.Printf("cannot send stream item for invocation %v over connection %v: %v", id, c.GetConnectionID(), err)
+	// Connections which queue messages instead of writing them to the network block the stream until
+	// the client has taken enough of them, so the producer of the stream is blocked in sending to its chan
+	if queue, ok := c.Connection.(interface{ waitForCapacity(size int) error }); ok && c.StreamBufferSize > 0 {
+		if err := queue.waitForCapacity(c.StreamBufferSize); err != nil {
+			_ = c.logger.Log("connection", c.GetConnectionID(), "event", "cannot send stream item", "invocation", id, "error", err)
+			return
+		}
+	}
+	if err := c.writeMessage(streamItemMessage); err != nil {
+		_ = c.logger.Log("connection", c.GetConnectionID(), "event", "cannot send stream item", "invocation", id, "error", err)
+	}
+}
+
+// InvokeWithResult sends an invocation with an ID to the client. The client answers it with a completion,
+// which is sent on the returned channel. The channel is closed without completion if the connection closes
+func (c *defaultHubConnection) InvokeWithResult(target string, args []interface{}) (string, <-chan CompletionMessage, error) {
+	args, ok := c.intercept(target, args)
+	if !ok {
+		return "", nil, ErrInvocationDropped
+	}
+	id := fmt.Sprintf("s%v", atomic.AddInt64(&c.lastID, 1))
+	completed := make(chan CompletionMessage, 1)
+	c.invocationsMx.Lock()
+	if c.invocationsClosed {
+		// Close has ended the waiting invocations already, nobody would end this one
+		c.invocationsMx.Unlock()
+		return "", nil, ErrConnectionClosed
+	}
+	if c.invocations == nil {
+		c.invocations = make(map[string]chan CompletionMessage)
+	}
+	c.invocations[id] = completed
+	c.invocationsMx.Unlock()
+	if err := c.writeMessage(InvocationMessage{
+		Type:         1,
+		Target:       target,
+		InvocationID: id,
+		Arguments:    args,
+	}); err != nil {
+		c.CancelInvocation(id)
+		return "", nil, err
+	}
+	return id, completed, nil
+}
+
+// CompleteInvocation passes a completion received from the client to the invocation waiting for it.
+// It returns false if no invocation is waiting for it
+func (c *defaultHubConnection) CompleteInvocation(completion CompletionMessage) bool {
+	c.invocationsMx.Lock()
+	defer c.invocationsMx.Unlock()
+	completed, ok := c.invocations[completion.InvocationID]
+	if ok {
+		delete(c.invocations, completion.InvocationID)
+		completed <- completion
 	}
+	return ok
+}
+
+// CancelInvocation stops waiting for the completion of an invocation
+func (c *defaultHubConnection) CancelInvocation(id string) {
+	c.invocationsMx.Lock()
+	defer c.invocationsMx.Unlock()
+	delete(c.invocations, id)
 }
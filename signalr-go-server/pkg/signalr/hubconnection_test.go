@@ -0,0 +1,239 @@
+package signalr
+
+import (
+	"net"
+	"time"
+
+	"./signalrtest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// stalledConnection is a Connection with a peer which never reads
+type stalledConnection struct {
+	deadline time.Time
+	closed   chan bool
+}
+
+func (s *stalledConnection) ConnectionID() string {
+	return "stalled"
+}
+
+func (s *stalledConnection) Read([]byte) (int, error) {
+	select {}
+}
+
+func (s *stalledConnection) Write([]byte) (int, error) {
+	<-time.After(time.Until(s.deadline))
+	return 0, &net.OpError{Op: "write", Err: timeoutError{}}
+}
+
+func (s *stalledConnection) SetWriteDeadline(t time.Time) error {
+	s.deadline = t
+	return nil
+}
+
+func (s *stalledConnection) Close() error {
+	s.closed <- true
+	return nil
+}
+
+// blockingConnection is a Connection with a peer which only reads when released
+type blockingConnection struct {
+	release chan bool
+	closed  chan bool
+}
+
+func (b *blockingConnection) ConnectionID() string {
+	return "blocking"
+}
+
+func (b *blockingConnection) Read([]byte) (int, error) {
+	select {}
+}
+
+func (b *blockingConnection) Write(p []byte) (int, error) {
+	<-b.release
+	return len(p), nil
+}
+
+func (b *blockingConnection) Close() error {
+	b.closed <- true
+	return nil
+}
+
+// recordingBlockingConnection is a blockingConnection which records the messages it writes
+type recordingBlockingConnection struct {
+	blockingConnection
+	written chan string
+}
+
+func (r *recordingBlockingConnection) Write(p []byte) (int, error) {
+	<-r.release
+	r.written <- string(p)
+	return len(p), nil
+}
+
+func sendBlocked(hubConn hubConnection, depth int) {
+	go hubConn.SendInvocation("first", nil)
+	go hubConn.SendInvocation("second", nil)
+	Eventually(func() int { return hubConn.Stats().QueueDepth }).Should(Equal(depth))
+}
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+var _ = Describe("HubConnection", func() {
+
+	Describe("Write to a stalled peer", func() {
+		conn := &stalledConnection{closed: make(chan bool, 1)}
+		hubConn := newHubConnection(conn, &JsonHubProtocol{}, hubConnectionOptions{writeTimeout: 50 * time.Millisecond})
+		hubConn.Start()
+		Context("When the write deadline is exceeded", func() {
+			It("should close the connection", func() {
+				done := make(chan bool)
+				go func() {
+					hubConn.SendInvocation("target", []interface{}{1})
+					done <- true
+				}()
+				Eventually(done).Should(Receive())
+				Expect(hubConn.IsConnected()).To(BeFalse())
+				Expect(conn.closed).To(Receive())
+			})
+		})
+	})
+
+	Describe("Two messages in one read", func() {
+		conn := connect(&invocationHub{})
+		Context("When the client sends two invocations with one write", func() {
+			It("should answer both", func() {
+				_, err := conn.clientSend(`{"type":1,"invocationId": "one","target":"simple"}` + "\u001e" + `{"type":1,"invocationId": "two","target":"simple"}`)
+				Expect(err).To(BeNil())
+				Expect(<-invocationQueue).To(Equal("Simple()"))
+				Expect(<-invocationQueue).To(Equal("Simple()"))
+				Expect((<-conn.received).(CompletionMessage).InvocationID).To(Equal("one"))
+				Expect((<-conn.received).(CompletionMessage).InvocationID).To(Equal("two"))
+			})
+		})
+	})
+
+	Describe("Messages in many reads", func() {
+		conn := connect(&invocationHub{})
+		Context("When the client sends invocations in small pieces", func() {
+			It("should answer each once it is complete", func() {
+				<-conn.handshakeSent
+				messages := `{"type":1,"invocationId": "one","target":"simple"}` + "\u001e" + `{"type":1,"invocationId": "two","target":"simple"}` + "\u001e"
+				go func() {
+					defer GinkgoRecover()
+					for i := 0; i < len(messages); i += 5 {
+						end := i + 5
+						if end > len(messages) {
+							end = len(messages)
+						}
+						_, err := conn.cliWriter.Write([]byte(messages[i:end]))
+						Expect(err).To(BeNil())
+					}
+				}()
+				Expect(<-invocationQueue).To(Equal("Simple()"))
+				Expect((<-conn.received).(CompletionMessage).InvocationID).To(Equal("one"))
+				Expect(<-invocationQueue).To(Equal("Simple()"))
+				Expect((<-conn.received).(CompletionMessage).InvocationID).To(Equal("two"))
+			})
+		})
+	})
+
+	Describe("Send to a slow consumer with the DropMessages policy", func() {
+		conn := &blockingConnection{release: make(chan bool), closed: make(chan bool, 1)}
+		slow := make(chan ConnectionStats, 1)
+		hubConn := newHubConnection(conn, &JsonHubProtocol{}, hubConnectionOptions{slowConsumer: &SlowConsumerPolicy{
+			MaxQueueDepth: 1,
+			Action:        DropMessages,
+			OnSlowConsumer: func(stats ConnectionStats, action SlowConsumerAction) {
+				slow <- stats
+			},
+		}})
+		hubConn.Start()
+		Context("When the queue depth exceeds the maximum", func() {
+			It("should drop the invocation and report the slow consumer", func() {
+				sendBlocked(hubConn, 2)
+				hubConn.SendInvocation("third", nil)
+				stats := <-slow
+				Expect(stats.ConnectionID).To(Equal("blocking"))
+				Expect(stats.QueueDepth).To(Equal(2))
+				conn.release <- true
+				conn.release <- true
+				Eventually(func() int { return hubConn.Stats().QueueDepth }).Should(Equal(0))
+				Expect(hubConn.IsConnected()).To(BeTrue())
+			})
+		})
+	})
+
+	Describe("Send to a slow consumer with the Disconnect policy", func() {
+		conn := &blockingConnection{release: make(chan bool), closed: make(chan bool, 1)}
+		hubConn := newHubConnection(conn, &JsonHubProtocol{}, hubConnectionOptions{slowConsumer: &SlowConsumerPolicy{
+			MaxQueueDepth: 1,
+			Action:        Disconnect,
+		}})
+		hubConn.Start()
+		Context("When the queue depth exceeds the maximum", func() {
+			It("should close the connection", func() {
+				sendBlocked(hubConn, 2)
+				hubConn.SendInvocation("third", nil)
+				Expect(conn.closed).To(Receive())
+				Expect(hubConn.IsConnected()).To(BeFalse())
+				close(conn.release)
+			})
+		})
+	})
+
+	Describe("Send behind a blocked write with a MessageTTL", func() {
+		conn := &blockingConnection{release: make(chan bool), closed: make(chan bool, 1)}
+		clock := signalrtest.NewFakeClock(time.Now())
+		hubConn := newHubConnection(conn, &JsonHubProtocol{}, hubConnectionOptions{messageTTL: 50 * time.Millisecond, clock: clock})
+		hubConn.Start()
+		Context("When the invocation waits longer than the TTL", func() {
+			It("should drop the invocation", func() {
+				sendBlocked(hubConn, 2)
+				clock.Advance(100 * time.Millisecond)
+				conn.release <- true
+				Eventually(func() int64 { return hubConn.Stats().MessagesExpired }).Should(Equal(int64(1)))
+				Expect(hubConn.Stats().QueueDepth).To(Equal(0))
+				Expect(hubConn.IsConnected()).To(BeTrue())
+			})
+		})
+	})
+
+	Describe("Messages waiting behind a blocked write", func() {
+		conn := &recordingBlockingConnection{blockingConnection: blockingConnection{release: make(chan bool), closed: make(chan bool, 1)}, written: make(chan string, 4)}
+		hubConn := newHubConnection(conn, &JsonHubProtocol{}, hubConnectionOptions{})
+		hubConn.Start()
+		Context("When a broadcast, a completion and a ping wait", func() {
+			It("should write the ping first, then the completion, then the broadcast", func() {
+				waitFor := func(depth int) {
+					Eventually(func() int { return hubConn.Stats().QueueDepth }).Should(Equal(depth))
+				}
+				go hubConn.SendInvocation("blocked", nil)
+				waitFor(1)
+				go hubConn.SendInvocation("broadcast", nil)
+				waitFor(2)
+				go hubConn.Completion("1", "result", "")
+				waitFor(3)
+				go hubConn.Ping()
+				waitFor(4)
+				var written []string
+				for i := 0; i < 4; i++ {
+					conn.release <- true
+					written = append(written, <-conn.written)
+				}
+				Expect(written[0]).To(ContainSubstring(`"blocked"`))
+				Expect(written[1]).To(ContainSubstring(`"type":6`))
+				Expect(written[2]).To(ContainSubstring(`"result"`))
+				Expect(written[3]).To(ContainSubstring(`"broadcast"`))
+			})
+		})
+	})
+})
@@ -3,14 +3,20 @@ package signalr
 // HubContext is a context abstraction for a hub
 // Clients() gets a HubClients that can be used to invoke methods on clients connected to the hub
 // Groups() gets a GroupManager that can be used to add and remove connections to named groups
+// Tags() gets a TagManager that can be used to tag connections
+// Tenant() gets the HubContext of the connections of a tenant, see IsolateTenants. The empty key is the default tenant
 type HubContext interface {
 	Clients() HubClients
 	Groups() GroupManager
+	Tags() TagManager
+	Tenant(key string) HubContext
 }
 
 type defaultHubContext struct {
 	clients HubClients
 	groups  GroupManager
+	tags    TagManager
+	server  *Server
 }
 
 func (d *defaultHubContext) Clients() HubClients {
@@ -20,3 +26,11 @@ func (d *defaultHubContext) Clients() HubClients {
 func (d *defaultHubContext) Groups() GroupManager {
 	return d.groups
 }
+
+func (d *defaultHubContext) Tags() TagManager {
+	return d.tags
+}
+
+func (d *defaultHubContext) Tenant(key string) HubContext {
+	return d.server.tenant(key).hubContext
+}
@@ -0,0 +1,114 @@
+package signalr
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type contextHub struct {
+	Hub
+}
+
+func (c *contextHub) Ready() {}
+
+// Unserializable returns a result the json protocol can not encode
+func (c *contextHub) Unserializable() interface{} {
+	return func() {}
+}
+
+// panickingValue panics when it is serialized
+type panickingValue struct{}
+
+func (panickingValue) MarshalJSON() ([]byte, error) {
+	panic("cannot marshal")
+}
+
+var _ = Describe("HubContext", func() {
+
+	Describe("Send from outside the hub", func() {
+		server := NewServer(&contextHub{})
+		conn := newTestingConnection()
+		go server.Run(conn)
+		Context("When the server HubContext is used to send to all clients", func() {
+			It("should send the invocation to the connected client", func() {
+				// Wait until the connection is established
+				_, err := conn.clientSend(`{"type":1,"invocationId": "ctx","target":"ready"}`)
+				Expect(err).To(BeNil())
+				Expect((<-conn.received).(CompletionMessage).InvocationID).To(Equal("ctx"))
+				server.HubContext().Clients().All().Send("fromOutside", "hello")
+				recv := (<-conn.received).(InvocationMessage)
+				Expect(recv.Target).To(Equal("fromOutside"))
+				Expect(recv.Arguments).To(Equal([]interface{}{"hello"}))
+			})
+		})
+	})
+
+	Describe("Send an argument which can not be serialized", func() {
+		server := NewServer(&contextHub{})
+		conn := newTestingConnection()
+		go server.Run(conn)
+		Context("When the serialization of a broadcast panics", func() {
+			It("should skip the connection and deliver the next messages", func() {
+				_, err := conn.clientSend(`{"type":1,"invocationId": "ctx","target":"ready"}`)
+				Expect(err).To(BeNil())
+				Expect((<-conn.received).(CompletionMessage).InvocationID).To(Equal("ctx"))
+				Expect(func() { server.HubContext().Clients().All().Send("broken", panickingValue{}) }).NotTo(Panic())
+				server.HubContext().Clients().All().Send("fine", "hello")
+				Expect((<-conn.received).(InvocationMessage).Target).To(Equal("fine"))
+			})
+		})
+		Context("When the result of a hub method can not be serialized", func() {
+			It("should send a completion with an error", func() {
+				_, err := conn.clientSend(`{"type":1,"invocationId": "bad","target":"unserializable"}`)
+				Expect(err).To(BeNil())
+				completion := (<-conn.received).(CompletionMessage)
+				Expect(completion.InvocationID).To(Equal("bad"))
+				Expect(completion.Error).To(HavePrefix("Result could not be serialized: "))
+			})
+		})
+	})
+
+	Describe("Send many messages from outside the hub", func() {
+		server := NewServer(&contextHub{}, BroadcastOrdering(FIFOOrdering))
+		conn := newTestingConnection()
+		go server.Run(conn)
+		Context("When one publisher sends with FIFOOrdering", func() {
+			It("should deliver the messages in the order they were sent", func() {
+				_, err := conn.clientSend(`{"type":1,"invocationId": "fifo","target":"ready"}`)
+				Expect(err).To(BeNil())
+				Expect((<-conn.received).(CompletionMessage).InvocationID).To(Equal("fifo"))
+				go func() {
+					for i := 0; i < 20; i++ {
+						server.HubContext().Clients().All().Send("ordered", i)
+					}
+				}()
+				for i := 0; i < 20; i++ {
+					Expect((<-conn.received).(InvocationMessage).Arguments).To(Equal([]interface{}{float64(i)}))
+				}
+			})
+		})
+	})
+
+	Describe("Send many messages from outside the hub with relaxed ordering", func() {
+		server := NewServer(&contextHub{}, BroadcastOrdering(RelaxedOrdering))
+		conn := newTestingConnection()
+		go server.Run(conn)
+		Context("When one publisher sends with RelaxedOrdering", func() {
+			It("should deliver all messages", func() {
+				_, err := conn.clientSend(`{"type":1,"invocationId": "relaxed","target":"ready"}`)
+				Expect(err).To(BeNil())
+				Expect((<-conn.received).(CompletionMessage).InvocationID).To(Equal("relaxed"))
+				for i := 0; i < 20; i++ {
+					server.HubContext().Clients().All().Send("relaxed", i)
+				}
+				received := make([]interface{}, 0)
+				for i := 0; i < 20; i++ {
+					received = append(received, (<-conn.received).(InvocationMessage).Arguments[0])
+				}
+				for i := 0; i < 20; i++ {
+					Expect(received).To(ContainElement(float64(i)))
+				}
+			})
+		})
+	})
+})
@@ -0,0 +1,66 @@
+package signalr
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// HubError is an error a hub method can return to the client. Code and Message are sent
+// to the client in the Completion of the invocation. Internal is only logged on the server,
+// so details like stack traces or database errors do not leak to clients
+type HubError struct {
+	Code     string
+	Message  string
+	Internal error
+}
+
+func (h *HubError) Error() string {
+	if h.Internal != nil {
+		return fmt.Sprintf("%s: %v", h.clientError(), h.Internal)
+	}
+	return h.clientError()
+}
+
+// Unwrap returns the Internal error
+func (h *HubError) Unwrap() error {
+	return h.Internal
+}
+
+// clientError is the error sent to the client
+func (h *HubError) clientError() string {
+	if h.Code != "" {
+		return fmt.Sprintf("%s: %s", h.Code, h.Message)
+	}
+	return h.Message
+}
+
+var hubErrorType = reflect.TypeOf((*HubError)(nil))
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+// splitHubError splits a *HubError or error returned as last value of a hub method from the other results.
+// An error which is no *HubError is sent to the client as message of a HubError
+func splitHubError(result []reflect.Value) ([]reflect.Value, *HubError) {
+	if len(result) == 0 {
+		return result, nil
+	}
+	last := result[len(result)-1]
+	switch last.Type() {
+	case hubErrorType:
+		return result[:len(result)-1], last.Interface().(*HubError)
+	case errorType:
+		if last.IsNil() {
+			return result[:len(result)-1], nil
+		}
+		return result[:len(result)-1], toHubError(last.Interface().(error))
+	default:
+		return result, nil
+	}
+}
+
+// toHubError returns err if it is a *HubError, else a HubError with the message of err
+func toHubError(err error) *HubError {
+	if hubErr, ok := err.(*HubError); ok {
+		return hubErr
+	}
+	return &HubError{Message: err.Error()}
+}
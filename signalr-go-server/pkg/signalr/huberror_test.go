@@ -0,0 +1,142 @@
+package signalr
+
+import (
+	"errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type hubErrorHub struct {
+	Hub
+}
+
+func (h *hubErrorHub) Fail() *HubError {
+	return &HubError{Code: "E42", Message: "try again later", Internal: errors.New("database password wrong")}
+}
+
+func (h *hubErrorHub) Lookup(key string) (string, *HubError) {
+	if key == "" {
+		return "", &HubError{Message: "key is missing"}
+	}
+	return "value of " + key, nil
+}
+
+type errorHub struct {
+	Hub
+}
+
+func (e *errorHub) Divide(a, b int) (int, error) {
+	if b == 0 {
+		return 0, errors.New("division by zero")
+	}
+	return a / b, nil
+}
+
+func (e *errorHub) Check(ok bool) error {
+	if !ok {
+		return &HubError{Code: "E1", Message: "check failed"}
+	}
+	return nil
+}
+
+type misplacedErrorHub struct {
+	Hub
+}
+
+func (m *misplacedErrorHub) Wrong() (error, int) {
+	return nil, 0
+}
+
+type misplacedContextHub struct {
+	Hub
+}
+
+func (m *misplacedContextHub) Wrong(value string, connectionContext ConnectionContext) {}
+
+var _ = Describe("HubError", func() {
+
+	Describe("Invocation of a method returning a HubError", func() {
+		conn := connect(&hubErrorHub{})
+		Context("When the method fails", func() {
+			It("should send code and message but not the internal error to the client", func() {
+				_, err := conn.clientSend(`{"type":1,"invocationId": "fail","target":"fail"}`)
+				Expect(err).To(BeNil())
+				recv := (<-conn.received).(CompletionMessage)
+				Expect(recv.InvocationID).To(Equal("fail"))
+				Expect(recv.Result).To(BeNil())
+				Expect(recv.Error).To(Equal("E42: try again later"))
+			})
+		})
+		Context("When the method returns a result and a HubError", func() {
+			It("should send the error when the HubError is not nil", func() {
+				_, err := conn.clientSend(`{"type":1,"invocationId": "missing","target":"lookup","arguments":[""]}`)
+				Expect(err).To(BeNil())
+				recv := (<-conn.received).(CompletionMessage)
+				Expect(recv.Result).To(BeNil())
+				Expect(recv.Error).To(Equal("key is missing"))
+			})
+			It("should send only the result when the HubError is nil", func() {
+				_, err := conn.clientSend(`{"type":1,"invocationId": "found","target":"lookup","arguments":["k"]}`)
+				Expect(err).To(BeNil())
+				recv := (<-conn.received).(CompletionMessage)
+				Expect(recv.Error).To(Equal(""))
+				Expect(recv.Result).To(Equal("value of k"))
+			})
+		})
+	})
+
+	Describe("Invocation of methods returning error", func() {
+		conn := connect(&errorHub{})
+		Context("When the method returns a result and an error", func() {
+			It("should send the result when the error is nil", func() {
+				_, err := conn.clientSend(`{"type":1,"invocationId": "ok","target":"divide","arguments":[6,3]}`)
+				Expect(err).To(BeNil())
+				recv := (<-conn.received).(CompletionMessage)
+				Expect(recv.Error).To(Equal(""))
+				Expect(recv.Result).To(Equal(float64(2)))
+			})
+			It("should send the error message when the error is not nil", func() {
+				_, err := conn.clientSend(`{"type":1,"invocationId": "zero","target":"divide","arguments":[6,0]}`)
+				Expect(err).To(BeNil())
+				recv := (<-conn.received).(CompletionMessage)
+				Expect(recv.Result).To(BeNil())
+				Expect(recv.Error).To(Equal("division by zero"))
+			})
+		})
+		Context("When the method returns only an error", func() {
+			It("should send an empty completion when the error is nil", func() {
+				_, err := conn.clientSend(`{"type":1,"invocationId": "passed","target":"check","arguments":[true]}`)
+				Expect(err).To(BeNil())
+				recv := (<-conn.received).(CompletionMessage)
+				Expect(recv.Result).To(BeNil())
+				Expect(recv.Error).To(Equal(""))
+			})
+			It("should send a HubError returned as error with its code", func() {
+				_, err := conn.clientSend(`{"type":1,"invocationId": "failed","target":"check","arguments":[false]}`)
+				Expect(err).To(BeNil())
+				Expect((<-conn.received).(CompletionMessage).Error).To(Equal("E1: check failed"))
+			})
+		})
+	})
+
+	Describe("Validation of hub methods", func() {
+		Context("When a hub has only methods which can be bound", func() {
+			It("should be valid", func() {
+				Expect(ValidateHub(&errorHub{})).To(Succeed())
+				Expect(ValidateHub(&connectionContextHub{})).To(Succeed())
+			})
+		})
+		Context("When a hub method returns an error which is not the last result", func() {
+			It("should be refused with the name of the method", func() {
+				Expect(ValidateHub(&misplacedErrorHub{})).To(MatchError(ContainSubstring("misplacedErrorHub.Wrong")))
+				Expect(func() { NewServer(&misplacedErrorHub{}) }).To(Panic())
+			})
+		})
+		Context("When a hub method has a ConnectionContext after a client parameter", func() {
+			It("should be refused", func() {
+				Expect(ValidateHub(&misplacedContextHub{})).To(MatchError(ContainSubstring("must come before")))
+			})
+		})
+	})
+})
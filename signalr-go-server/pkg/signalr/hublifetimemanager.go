@@ -1,28 +1,93 @@
 package signalr
 
-import "sync"
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrUnknownConnection is returned when an acknowledged invocation is sent to a connection which does not exist
+var ErrUnknownConnection = errors.New("unknown connection")
+
+// ErrConnectionClosed is returned when a connection closes before the client acknowledged an invocation
+var ErrConnectionClosed = errors.New("connection closed")
+
+// ErrTimeout is returned when the client did not acknowledge an invocation within the ClientResultTimeout
+var ErrTimeout = errors.New("timeout waiting for the client")
+
+// ErrInvocationDropped is returned when an OutboundInterceptor dropped an acknowledged invocation
+var ErrInvocationDropped = errors.New("invocation dropped by interceptor")
 
 // HubLifetimeManager is a lifetime manager abstraction for hub instances
 // OnConnected() is called when a connection is started
 // OnDisconnected() is called when a connection is finished
 // InvokeAll() sends an invocation message to all hub connections
 // InvokeClient() sends an invocation message to a specified hub connection
-// InvokeGroup() sends an invocation message to a specified group of hub connections
-// AddToGroup() adds a connection to the specified group
+// InvokeGroup() sends an invocation message to a specified group of hub connections. With WildcardGroups, the group can be a pattern
+// InvokeClients() sends an invocation message to the specified hub connections
+// InvokeUsers() sends an invocation message to all hub connections of the specified users
+// InvokeGroups() sends an invocation message to the connections of the specified groups, once to each connection
+// InvokeTagged() sends an invocation message to the hub connections whose tags match the expression
+// InvokeClientWithAck() sends an invocation message to a specified hub connection and waits until the client acknowledged it
+// CreateGroup() creates a group with metadata. Groups which are not created are created by AddToGroup() without owner and size limit
+// GroupInfo() returns the metadata of a group
+// GroupCount() returns the number of groups
+// GroupMembers() returns the connection IDs of the members of each group
+// RetainMessages() sets the Retention of a group, which is created if it does not exist
+// AddToGroup() adds a connection to the specified group and replays the messages retained by the group to it
 // RemoveFromGroup() removes a connection from the specified group
+// TagConnection() adds tags to a connection
+// UntagConnection() removes tags from a connection
+// ConnectionTags() returns the tags of a connection
 type HubLifetimeManager interface {
 	OnConnected(conn hubConnection)
 	OnDisconnected(conn hubConnection)
 	InvokeAll(target string, args []interface{})
 	InvokeClient(connectionID string, target string, args []interface{})
 	InvokeGroup(groupName string, target string, args []interface{})
-	AddToGroup(groupName, connectionID string)
+	InvokeClients(connectionIDs []string, target string, args []interface{})
+	InvokeUsers(userIDs []string, target string, args []interface{})
+	InvokeGroups(groupNames []string, target string, args []interface{})
+	InvokeTagged(expression *TagExpression, target string, args []interface{})
+	InvokeClientWithAck(ctx context.Context, connectionID string, target string, args []interface{}) error
+	CreateGroup(groupName string, owner string, maxSize int) error
+	GroupInfo(groupName string) (GroupInfo, bool)
+	GroupCount() int
+	GroupMembers() map[string][]string
+	RetainMessages(groupName string, retention Retention)
+	AddToGroup(groupName, connectionID string) error
 	RemoveFromGroup(groupName, connectionID string)
+	TagConnection(connectionID string, tags []string) error
+	UntagConnection(connectionID string, tags []string)
+	ConnectionTags(connectionID string) []string
 }
 
 type defaultHubLifetimeManager struct {
-	clients sync.Map
-	groups  sync.Map
+	clients  sync.Map
+	groups   groupRegistry
+	tags     tagRegistry
+	ordering Ordering
+	// notifications are sent to the members of groups which a connection joins or leaves, if not nil
+	notifications *GroupNotifications
+	// resultTimeout is the time acknowledged invocations wait for the client, timed by clock. 0 means no limit
+	resultTimeout time.Duration
+	clock         Clock
+	// fanOut writes invocations of large groups in parallel, if not nil
+	fanOut *GroupFanOut
+	// inbox keeps the invocations of users without connection, if not nil. tenant is the key of the tenant in it
+	inbox  *offlineInbox
+	tenant string
+}
+
+// send sends a prepared invocation to one connection of a broadcast. With RelaxedOrdering, each connection
+// is sent to from its own goroutine, so a slow connection does not hold back the others
+func (d *defaultHubLifetimeManager) send(conn hubConnection, message *preparedMessage) {
+	if d.ordering == RelaxedOrdering {
+		go conn.SendPrepared(message)
+	} else {
+		conn.SendPrepared(message)
+	}
 }
 
 func (d *defaultHubLifetimeManager) OnConnected(conn hubConnection) {
@@ -31,11 +96,25 @@ func (d *defaultHubLifetimeManager) OnConnected(conn hubConnection) {
 
 func (d *defaultHubLifetimeManager) OnDisconnected(conn hubConnection) {
 	d.clients.Delete(conn.GetConnectionID())
+	for groupName, others := range d.groups.removeFromAll(conn.GetConnectionID()) {
+		d.notifyMembers(others, d.notificationTarget(false), groupName, conn)
+	}
+	d.tags.removeAll(conn.GetConnectionID())
+}
+
+// transfer replaces the connection with fromID by conn, which takes over its groups and tags.
+// The members of the groups are not notified
+func (d *defaultHubLifetimeManager) transfer(fromID string, conn hubConnection) {
+	d.clients.Delete(fromID)
+	d.clients.Store(conn.GetConnectionID(), conn)
+	d.groups.transfer(fromID, conn)
+	d.tags.transfer(fromID, conn.GetConnectionID())
 }
 
 func (d *defaultHubLifetimeManager) InvokeAll(target string, args []interface{}) {
+	message := newPreparedInvocation(target, args)
 	d.clients.Range(func(key, value interface{}) bool {
-		value.(hubConnection).SendInvocation(target, args)
+		d.send(value.(hubConnection), message)
 		return true
 	})
 }
@@ -47,41 +126,199 @@ func (d *defaultHubLifetimeManager) InvokeClient(connectionID string, target str
 		return
 	}
 
-	client.(hubConnection).SendInvocation(target, args)
+	d.send(client.(hubConnection), newPreparedInvocation(target, args))
 }
 
 func (d *defaultHubLifetimeManager) InvokeGroup(groupName string, target string, args []interface{}) {
-	groups, ok := d.groups.Load(groupName)
+	d.InvokeGroups([]string{groupName}, target, args)
+}
 
-	if !ok {
-		// No such group
+func (d *defaultHubLifetimeManager) InvokeClients(connectionIDs []string, target string, args []interface{}) {
+	message := newPreparedInvocation(target, args)
+	sent := make(map[string]bool)
+	for _, connectionID := range connectionIDs {
+		if client, ok := d.clients.Load(connectionID); ok && !sent[connectionID] {
+			sent[connectionID] = true
+			d.send(client.(hubConnection), message)
+		}
+	}
+}
+
+func (d *defaultHubLifetimeManager) InvokeUsers(userIDs []string, target string, args []interface{}) {
+	users := make(map[string]bool)
+	for _, userID := range userIDs {
+		if userID != "" {
+			users[userID] = true
+		}
+	}
+	message := newPreparedInvocation(target, args)
+	reached := make(map[string]bool)
+	d.clients.Range(func(key, value interface{}) bool {
+		if userID := value.(hubConnection).GetUserID(); users[userID] {
+			reached[userID] = true
+			d.send(value.(hubConnection), message)
+		}
+		return true
+	})
+	if d.inbox != nil {
+		for userID := range users {
+			if !reached[userID] {
+				d.inbox.add(d.tenant, userID, target, args)
+			}
+		}
+	}
+}
+
+func (d *defaultHubLifetimeManager) InvokeGroups(groupNames []string, target string, args []interface{}) {
+	message := newPreparedInvocation(target, args)
+	members := d.groups.members(groupNames, target, args)
+	if d.fanOut != nil && d.ordering == FIFOOrdering && len(members) > d.fanOut.Threshold {
+		d.sendSharded(members, message)
 		return
 	}
+	for _, conn := range members {
+		d.send(conn, message)
+	}
+}
 
-	for _, v := range groups.(map[string]hubConnection) {
-		v.SendInvocation(target, args)
+// sendSharded writes message to the shards of members from one goroutine each and returns when all are written
+func (d *defaultHubLifetimeManager) sendSharded(members []hubConnection, message *preparedMessage) {
+	shardSize := (len(members) + d.fanOut.Parallelism - 1) / d.fanOut.Parallelism
+	var written sync.WaitGroup
+	for start := 0; start < len(members); start += shardSize {
+		end := start + shardSize
+		if end > len(members) {
+			end = len(members)
+		}
+		written.Add(1)
+		go func(shard []hubConnection) {
+			defer written.Done()
+			for _, conn := range shard {
+				conn.SendPrepared(message)
+			}
+		}(members[start:end])
 	}
+	written.Wait()
 }
 
-func (d *defaultHubLifetimeManager) AddToGroup(groupName string, connectionID string) {
-	client, ok := d.clients.Load(connectionID)
+// InvokeTagged tests the expression only against connections which have tags, so an expression like "!muted"
+// does not select connections without tags
+func (d *defaultHubLifetimeManager) InvokeTagged(expression *TagExpression, target string, args []interface{}) {
+	message := newPreparedInvocation(target, args)
+	for connectionID := range d.tags.matching(expression) {
+		if client, ok := d.clients.Load(connectionID); ok {
+			d.send(client.(hubConnection), message)
+		}
+	}
+}
 
+func (d *defaultHubLifetimeManager) InvokeClientWithAck(ctx context.Context, connectionID string, target string, args []interface{}) error {
+	client, ok := d.clients.Load(connectionID)
 	if !ok {
-		// No such client
-		return
+		return ErrUnknownConnection
 	}
+	conn := client.(hubConnection)
+	id, completed, err := conn.InvokeWithResult(target, args)
+	if err != nil {
+		return err
+	}
+	var timeout <-chan time.Time
+	if d.resultTimeout > 0 {
+		timeout = d.clock.After(d.resultTimeout)
+	}
+	select {
+	case completion, ok := <-completed:
+		if !ok {
+			return ErrConnectionClosed
+		}
+		if completion.Error != "" {
+			return errors.New(completion.Error)
+		}
+		return nil
+	case <-ctx.Done():
+		conn.CancelInvocation(id)
+		return ctx.Err()
+	case <-timeout:
+		conn.CancelInvocation(id)
+		return ErrTimeout
+	}
+}
 
-	groups, _ := d.groups.LoadOrStore(groupName, make(map[string]hubConnection))
+func (d *defaultHubLifetimeManager) CreateGroup(groupName string, owner string, maxSize int) error {
+	return d.groups.create(groupName, owner, maxSize)
+}
 
-	groups.(map[string]hubConnection)[connectionID] = client.(hubConnection)
+func (d *defaultHubLifetimeManager) GroupInfo(groupName string) (GroupInfo, bool) {
+	return d.groups.info(groupName)
 }
 
-func (d *defaultHubLifetimeManager) RemoveFromGroup(groupName string, connectionID string) {
-	groups, ok := d.groups.Load(groupName)
+func (d *defaultHubLifetimeManager) GroupCount() int {
+	return d.groups.count()
+}
 
+func (d *defaultHubLifetimeManager) GroupMembers() map[string][]string {
+	return d.groups.memberIDs()
+}
+
+func (d *defaultHubLifetimeManager) AddToGroup(groupName string, connectionID string) error {
+	client, ok := d.clients.Load(connectionID)
 	if !ok {
+		return ErrUnknownConnection
+	}
+	conn := client.(hubConnection)
+	replay, others, err := d.groups.add(groupName, conn)
+	d.notifyMembers(others, d.notificationTarget(true), groupName, conn)
+	for _, message := range replay {
+		conn.SendInvocation(message.Target, message.Arguments)
+	}
+	return err
+}
+
+func (d *defaultHubLifetimeManager) RetainMessages(groupName string, retention Retention) {
+	d.groups.retain(groupName, retention)
+}
+
+func (d *defaultHubLifetimeManager) RemoveFromGroup(groupName string, connectionID string) {
+	if conn, others := d.groups.remove(groupName, connectionID); conn != nil {
+		d.notifyMembers(others, d.notificationTarget(false), groupName, conn)
+	}
+}
+
+// notificationTarget returns the target of the GroupNotifications for joining or leaving a group
+func (d *defaultHubLifetimeManager) notificationTarget(joined bool) string {
+	switch {
+	case d.notifications == nil:
+		return ""
+	case joined:
+		return d.notifications.Joined
+	default:
+		return d.notifications.Left
+	}
+}
+
+// notifyMembers sends the GroupNotification target about conn to the members of a group
+func (d *defaultHubLifetimeManager) notifyMembers(members []hubConnection, target string, groupName string, conn hubConnection) {
+	if target == "" || len(members) == 0 {
 		return
 	}
+	message := newPreparedInvocation(target, []interface{}{groupName, conn.GetConnectionID(), conn.GetUserID()})
+	for _, member := range members {
+		d.send(member, message)
+	}
+}
+
+func (d *defaultHubLifetimeManager) TagConnection(connectionID string, tags []string) error {
+	if _, ok := d.clients.Load(connectionID); !ok {
+		return ErrUnknownConnection
+	}
+	d.tags.add(connectionID, tags)
+	return nil
+}
+
+func (d *defaultHubLifetimeManager) UntagConnection(connectionID string, tags []string) {
+	d.tags.remove(connectionID, tags)
+}
 
-	delete(groups.(map[string]hubConnection), connectionID)
+func (d *defaultHubLifetimeManager) ConnectionTags(connectionID string) []string {
+	return d.tags.get(connectionID)
 }
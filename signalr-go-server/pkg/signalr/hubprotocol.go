@@ -5,19 +5,31 @@ import (
 	"io"
 )
 
-// HubProtocol interface
+// HubProtocol is the interface of the hub protocols which can be negotiated by name during the handshake
+// Name() returns the name used by the client in the handshake request
+// Version() returns the highest protocol version supported
+// TransferFormat() returns "Text" or "Binary"
+// ReadMessage() parses the next message from buf. It returns false and leaves buf untouched if buf contains only a partial message
+// WriteMessage() writes a message as one complete frame to writer
+// UnmarshalArgument() converts an invocation argument or a stream item parsed by ReadMessage() to the type of value
 type HubProtocol interface {
+	Name() string
+	Version() int
+	TransferFormat() string
 	ReadMessage(buf *bytes.Buffer) (interface{}, bool, error)
 	WriteMessage(message interface{}, writer io.Writer) error
 	UnmarshalArgument(argument interface{}, value interface{}) error
 }
 
-// Protocol
-type hubMessage struct {
+// Protocol messages. ReadMessage() returns and WriteMessage() accepts these types
+
+// HubMessage is a message which has no other content than its type, e.g. a Ping
+type HubMessage struct {
 	Type int `json:"type"`
 }
 
-type invocationMessage struct {
+// InvocationMessage is an Invocation (Type 1) or a StreamInvocation (Type 4)
+type InvocationMessage struct {
 	Type         int           `json:"type"`
 	Target       string        `json:"target"`
 	InvocationID string        `json:"invocationId,omitempty"`
@@ -25,31 +37,36 @@ type invocationMessage struct {
 	StreamIds    []string      `json:"streamIds,omitempty"`
 }
 
-type completionMessage struct {
+// CompletionMessage is the Completion (Type 3) of an invocation or a client stream
+type CompletionMessage struct {
 	Type         int         `json:"type"`
 	InvocationID string      `json:"invocationId"`
 	Result       interface{} `json:"result,omitempty"`
 	Error        string      `json:"error,omitempty"`
 }
 
-type streamItemMessage struct {
+// StreamItemMessage is an item (Type 2) of a server or client stream
+type StreamItemMessage struct {
 	Type         int         `json:"type"`
 	InvocationID string      `json:"invocationId"`
 	Item         interface{} `json:"item"`
 }
 
-type cancelInvocationMessage struct {
+// CancelInvocationMessage cancels (Type 5) a server stream
+type CancelInvocationMessage struct {
 	Type         int    `json:"type"`
 	InvocationID string `json:"invocationId"`
 }
 
-type closeMessage struct {
+// CloseMessage is sent (Type 7) when the connection is closed
+type CloseMessage struct {
 	Type           int    `json:"type"`
 	Error          string `json:"error"`
 	AllowReconnect bool   `json:"allowReconnect"`
 }
 
 type handshakeRequest struct {
-	Protocol string `json:"Protocol"`
-	Version  int    `json:"version"`
+	Protocol     string       `json:"Protocol"`
+	Version      int          `json:"version"`
+	Capabilities Capabilities `json:"capabilities,omitempty"`
 }
@@ -0,0 +1,48 @@
+package signalr
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type customHubProtocol struct {
+	JsonHubProtocol
+}
+
+func (c *customHubProtocol) Name() string {
+	return "custom"
+}
+
+var _ = Describe("HubProtocol", func() {
+
+	Describe("Custom protocol", func() {
+		server := NewServer(&invocationHub{}, HubProtocols(&customHubProtocol{}))
+		conn := newTestingConnectionWithHandshake(`{"protocol": "custom","version": 1}`)
+		go server.Run(conn)
+		Context("When the client selects a registered custom protocol", func() {
+			It("should use the custom protocol", func() {
+				_, err := conn.clientSend(`{"type":1,"invocationId": "custom","target":"simpleint","arguments":[1]}`)
+				Expect(err).To(BeNil())
+				Expect(<-invocationQueue).To(Equal("SimpleInt(1)"))
+				recv := (<-conn.received).(CompletionMessage)
+				Expect(recv.InvocationID).To(Equal("custom"))
+				Expect(recv.Result).To(Equal(float64(2)))
+			})
+		})
+	})
+
+	Describe("Unsupported protocol version", func() {
+		server := NewServer(&invocationHub{})
+		conn := newTestingConnectionWithHandshake(`{"protocol": "json","version": 2}`)
+		Context("When the client requests a higher version than the protocol supports", func() {
+			It("should end the connection", func() {
+				done := make(chan bool)
+				go func() {
+					server.Run(conn)
+					done <- true
+				}()
+				Eventually(done).Should(Receive())
+			})
+		})
+	})
+})
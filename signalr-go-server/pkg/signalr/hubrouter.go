@@ -0,0 +1,84 @@
+package signalr
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// ErrHubExists is returned when a hub is added to a HubRouter at a path which serves another hub
+var ErrHubExists = errors.New("hub exists")
+
+// HubRouter is a http.Handler serving hubs which can be added and removed while it is running,
+// e.g. by plugins bringing their own hubs. Unlike MapHub, which registers a hub with a http.ServeMux for
+// the lifetime of the mux, a hub can be removed from a HubRouter. Requests for paths without hub are answered with 404
+type HubRouter struct {
+	mx   sync.RWMutex
+	hubs map[string]*routedHub
+}
+
+type routedHub struct {
+	server *Server
+	mux    *http.ServeMux
+}
+
+// NewHubRouter creates a HubRouter without hubs
+func NewHubRouter() *HubRouter {
+	return &HubRouter{hubs: make(map[string]*routedHub)}
+}
+
+// AddHub serves hub at path, like MapHub. It returns ErrHubExists if path serves another hub
+func (r *HubRouter) AddHub(path string, hub HubInterface, options ...Option) (*Server, error) {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	if _, ok := r.hubs[path]; ok {
+		return nil, ErrHubExists
+	}
+	mux := http.NewServeMux()
+	server := MapHub(mux, path, hub, options...)
+	r.hubs[path] = &routedHub{server: server, mux: mux}
+	return server, nil
+}
+
+// RemoveHub stops serving the hub at path. The connections to the hub are sent a close message and closed.
+// RemoveHub returns false if path serves no hub, otherwise it returns when all connections have ended
+func (r *HubRouter) RemoveHub(path string) bool {
+	r.mx.Lock()
+	routed, ok := r.hubs[path]
+	delete(r.hubs, path)
+	r.mx.Unlock()
+	if !ok {
+		return false
+	}
+	live := routed.server.connections.drain()
+	for _, l := range live {
+		l.shutdown("Hub has been removed")
+	}
+	for _, l := range live {
+		<-l.done
+	}
+	return true
+}
+
+// Paths returns the paths of the hubs of the router
+func (r *HubRouter) Paths() []string {
+	r.mx.RLock()
+	defer r.mx.RUnlock()
+	paths := make([]string, 0, len(r.hubs))
+	for path := range r.hubs {
+		paths = append(paths, path)
+	}
+	return paths
+}
+
+func (r *HubRouter) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.mx.RLock()
+	routed, ok := r.hubs[strings.TrimSuffix(req.URL.Path, "/negotiate")]
+	r.mx.RUnlock()
+	if !ok {
+		w.WriteHeader(404)
+		return
+	}
+	routed.mux.ServeHTTP(w, req)
+}
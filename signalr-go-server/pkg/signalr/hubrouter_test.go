@@ -0,0 +1,62 @@
+package signalr
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"golang.org/x/net/websocket"
+)
+
+var _ = Describe("HubRouter", func() {
+
+	Describe("Hubs added and removed at runtime", func() {
+		router := NewHubRouter()
+		httpServer := httptest.NewServer(router)
+		negotiateStatus := func() int {
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, httptest.NewRequest("POST", "/hub/negotiate", nil))
+			return recorder.Code
+		}
+		Context("When a hub is added", func() {
+			It("should serve it and refuse another hub at its path", func() {
+				Expect(negotiateStatus()).To(Equal(404))
+				_, err := router.AddHub("/hub", &contextHub{})
+				Expect(err).To(BeNil())
+				Expect(negotiateStatus()).To(Equal(200))
+				_, err = router.AddHub("/hub", &contextHub{})
+				Expect(err).To(Equal(ErrHubExists))
+				Expect(router.Paths()).To(Equal([]string{"/hub"}))
+			})
+		})
+		Context("When a hub with a live connection is removed", func() {
+			It("should close the connection and stop serving the hub", func() {
+				defer httpServer.Close()
+				recorder := httptest.NewRecorder()
+				router.ServeHTTP(recorder, httptest.NewRequest("POST", "/hub/negotiate", nil))
+				var response negotiateResponse
+				Expect(json.Unmarshal(recorder.Body.Bytes(), &response)).To(Succeed())
+				ws, err := dialHub(httpServer, response.ConnectionID)
+				Expect(err).To(BeNil())
+				Expect(wsInvoke(ws, "ready")).To(ContainSubstring(`"invocationId":"ready"`))
+				removed := make(chan bool)
+				go func() {
+					removed <- router.RemoveHub("/hub")
+				}()
+				for {
+					var message string
+					Expect(websocket.Message.Receive(ws, &message)).To(Succeed())
+					if strings.Contains(message, `"type":7`) {
+						Expect(message).To(ContainSubstring("Hub has been removed"))
+						break
+					}
+				}
+				Expect(<-removed).To(BeTrue())
+				Expect(negotiateStatus()).To(Equal(404))
+				Expect(router.RemoveHub("/hub")).To(BeFalse())
+			})
+		})
+	})
+})
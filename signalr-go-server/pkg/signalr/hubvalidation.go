@@ -0,0 +1,51 @@
+package signalr
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// ValidateHub checks that the methods of a hub can be invoked by clients. It returns an error naming the first
+// method with a signature the server can not bind. A hub method can return nothing, results, results and an error,
+// or only an error, with error being error or *HubError. The error must be the last result. Parameters of type ConnectionContext and context.Context
+// must come before the parameters sent by the client. Clients can omit the arguments of trailing pointer parameters,
+// which are nil then, and send any number of arguments for a variadic last parameter. NewServer panics with the error of ValidateHub
+func ValidateHub(hub HubInterface) error {
+	hubType := reflect.TypeOf(hub)
+	for i := 0; i < hubType.NumMethod(); i++ {
+		m := hubType.Method(i)
+		// Parameter 0 is the receiver
+		if err := validateHubMethod(m.Type, 1); err != nil {
+			return fmt.Errorf("hub method %v.%v: %v", hubType, m.Name, err)
+		}
+	}
+	return nil
+}
+
+// validateHubMethod checks the parameters of methodType from parameter first on, and its results
+func validateHubMethod(methodType reflect.Type, first int) error {
+	injected := true
+	for i := first; i < methodType.NumIn(); i++ {
+		t := methodType.In(i)
+		switch {
+		case t == connectionContextType || t == contextType:
+			if !injected {
+				return fmt.Errorf("parameter %v of type %v must come before the parameters sent by the client", i, t)
+			}
+			continue
+		case t.Kind() == reflect.Func || t.Kind() == reflect.UnsafePointer:
+			return fmt.Errorf("parameter %v of type %v can not be sent by clients", i, t)
+		case t.Kind() == reflect.Chan && t.ChanDir() == reflect.SendDir:
+			return fmt.Errorf("parameter %v is a send only chan, client streams need a chan the hub can receive from", i)
+		}
+		injected = false
+	}
+	for i := 0; i < methodType.NumOut(); i++ {
+		t := methodType.Out(i)
+		isError := t == errorType || t == hubErrorType
+		if isError && i != methodType.NumOut()-1 {
+			return fmt.Errorf("result %v of type %v must be the last result", i, t)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package signalr
+
+import (
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// IdleTimeout closes connections whose client has not invoked a hub method for timeout, with DisconnectIdle,
+// e.g. to reclaim the resources of idle kiosks or free-tier clients. Pings, completions and stream items
+// sent by the client do not count as activity, and neither do invocations the server sends to the client.
+// By default, idle connections are kept
+func IdleTimeout(timeout time.Duration) Option {
+	return func(s *Server) {
+		s.idleTimeout = timeout
+	}
+}
+
+// idleWatch closes a connection which has not invoked a hub method for the idle timeout of the server
+type idleWatch struct {
+	timeout time.Duration
+	clock   Clock
+	conn    Connection
+	hubConn hubConnection
+	// last is the time of the last invocation in nanoseconds since the epoch
+	last int64
+	mx   sync.Mutex
+	// stop stops the timer, nil when the connection has ended
+	stop func() bool
+}
+
+// watchIdle starts watching hubConn, nil if the server keeps idle connections
+func (s *Server) watchIdle(conn Connection, hubConn hubConnection) *idleWatch {
+	if s.idleTimeout <= 0 {
+		return nil
+	}
+	w := &idleWatch{timeout: s.idleTimeout, clock: s.clock, conn: conn, hubConn: hubConn, last: s.clock.Now().UnixNano()}
+	w.mx.Lock()
+	w.stop = w.clock.AfterFunc(w.timeout, w.check)
+	w.mx.Unlock()
+	return w
+}
+
+// active records an invocation of the client. The timer is not reset, check takes the time into account
+func (w *idleWatch) active() {
+	if w != nil {
+		atomic.StoreInt64(&w.last, w.clock.Now().UnixNano())
+	}
+}
+
+// check closes the connection if it has been idle for the timeout, otherwise it waits for the rest of the timeout
+func (w *idleWatch) check() {
+	w.mx.Lock()
+	defer w.mx.Unlock()
+	if w.stop == nil {
+		return
+	}
+	idle := w.clock.Now().Sub(time.Unix(0, atomic.LoadInt64(&w.last)))
+	if idle < w.timeout {
+		w.stop = w.clock.AfterFunc(w.timeout-idle, w.check)
+		return
+	}
+	w.stop = nil
+	w.hubConn.SetDisconnectReason(DisconnectIdle)
+	w.hubConn.Close("Connection idle")
+	if closer, ok := w.conn.(io.Closer); ok {
+		_ = closer.Close()
+	}
+}
+
+// end stops watching the ended connection
+func (w *idleWatch) end() {
+	if w == nil {
+		return
+	}
+	w.mx.Lock()
+	defer w.mx.Unlock()
+	if w.stop != nil {
+		w.stop()
+		w.stop = nil
+	}
+}
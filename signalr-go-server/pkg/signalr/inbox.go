@@ -0,0 +1,133 @@
+package signalr
+
+import (
+	"sync"
+	"time"
+)
+
+const defaultInboxTTL = 24 * time.Hour
+
+const defaultInboxMaxMessages = 100
+
+// OfflineMessage is an invocation sent to a user while the user had no connection, see OfflineInbox
+type OfflineMessage struct {
+	Tenant    string
+	UserID    string
+	Target    string
+	Arguments []interface{}
+	Sent      time.Time
+	// Expires is the time after which the message is not delivered anymore
+	Expires time.Time
+}
+
+// InboxStore keeps the OfflineMessages of users until they connect. A store which persists them, e.g. in a database,
+// lets them survive a restart of the server. The arguments are the values passed to Send, a store which persists
+// them encodes them, e.g. as JSON, and returns the decoded values. Stores must be safe for concurrent use.
+// Add() stores a message of its user. If the user has more than max messages then, the oldest are dropped
+// Take() removes the messages of the user of the tenant and returns them, the oldest first
+type InboxStore interface {
+	Add(message OfflineMessage, max int) error
+	Take(tenant string, userID string) ([]OfflineMessage, error)
+}
+
+// InboxOptions configures the offline inbox of a server, see OfflineInbox.
+// A nil Store keeps the messages in memory, a zero TTL is 24 hours, a zero MaxMessages is 100
+type InboxOptions struct {
+	Store InboxStore
+	// TTL is the time a message is kept for its user
+	TTL time.Duration
+	// MaxMessages is the number of messages kept for a user. When it is exceeded, the oldest are dropped
+	MaxMessages int
+}
+
+// OfflineInbox keeps the invocations sent to users with Clients().User() or Clients().Users() while they have no
+// connection, e.g. the messages of a chat, and delivers them when the user connects again, before the first
+// message of the client is processed. Only users with a user ID, see IdentifyUser, have an inbox.
+// Messages sent while the first connection of a user is starting may be kept until its next connection.
+// By default, invocations of users without connection are dropped
+func OfflineInbox(options InboxOptions) Option {
+	return func(s *Server) {
+		if options.Store == nil {
+			options.Store = &memoryInboxStore{messages: make(map[inboxKey][]OfflineMessage)}
+		}
+		if options.TTL <= 0 {
+			options.TTL = defaultInboxTTL
+		}
+		if options.MaxMessages <= 0 {
+			options.MaxMessages = defaultInboxMaxMessages
+		}
+		s.inbox = &offlineInbox{options: options}
+	}
+}
+
+// offlineInbox stores and delivers the OfflineMessages of a server
+type offlineInbox struct {
+	options InboxOptions
+	clock   Clock
+	logger  StructuredLogger
+}
+
+// add stores an invocation of userID of tenant
+func (o *offlineInbox) add(tenant string, userID string, target string, args []interface{}) {
+	sent := o.clock.Now()
+	message := OfflineMessage{Tenant: tenant, UserID: userID, Target: target, Arguments: args, Sent: sent, Expires: sent.Add(o.options.TTL)}
+	if err := o.options.Store.Add(message, o.options.MaxMessages); err != nil {
+		_ = o.logger.Log("event", "cannot store offline message", "user", userID, "target", target, "error", err)
+	}
+}
+
+// deliver sends the stored invocations of the user of hubConn to it
+func (o *offlineInbox) deliver(tenant string, hubConn hubConnection) {
+	if o == nil || hubConn.GetUserID() == "" {
+		return
+	}
+	messages, err := o.options.Store.Take(tenant, hubConn.GetUserID())
+	if err != nil {
+		_ = o.logger.Log("connection", hubConn.GetConnectionID(), "event", "cannot take offline messages", "error", err)
+	}
+	now := o.clock.Now()
+	for _, message := range messages {
+		if now.Before(message.Expires) {
+			hubConn.SendInvocation(message.Target, message.Arguments)
+		}
+	}
+}
+
+// inboxKey is the key of the messages of a user in the memoryInboxStore
+type inboxKey struct {
+	tenant string
+	userID string
+}
+
+// memoryInboxStore is the default InboxStore
+type memoryInboxStore struct {
+	mx       sync.Mutex
+	messages map[inboxKey][]OfflineMessage
+}
+
+func (m *memoryInboxStore) Add(message OfflineMessage, max int) error {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	key := inboxKey{tenant: message.Tenant, userID: message.UserID}
+	// Expired messages of users who do not come back are dropped when the next message arrives
+	kept := m.messages[key][:0]
+	for _, stored := range m.messages[key] {
+		if message.Sent.Before(stored.Expires) {
+			kept = append(kept, stored)
+		}
+	}
+	if kept = append(kept, message); len(kept) > max {
+		kept = kept[len(kept)-max:]
+	}
+	m.messages[key] = kept
+	return nil
+}
+
+func (m *memoryInboxStore) Take(tenant string, userID string) ([]OfflineMessage, error) {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	key := inboxKey{tenant: tenant, userID: userID}
+	messages := m.messages[key]
+	delete(m.messages, key)
+	return messages, nil
+}
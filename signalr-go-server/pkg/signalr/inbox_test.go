@@ -0,0 +1,55 @@
+package signalr
+
+import (
+	"net/http/httptest"
+	"time"
+
+	"./signalrtest"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("OfflineInbox", func() {
+
+	Describe("Server with an offline inbox", func() {
+		clock := signalrtest.NewFakeClock(time.Now())
+		server := NewServer(&contextHub{}, UseClock(clock), OfflineInbox(InboxOptions{TTL: time.Hour, MaxMessages: 2}),
+			IdentifyUser(func(ctx ConnectionContext) string {
+				return ctx.Query().Get("user")
+			}))
+		connect := func(connectionID string, userID string) *testingConnection {
+			conn := newTestingConnection()
+			req := httptest.NewRequest("GET", "/hub?user="+userID, nil)
+			go server.Run(&userConnection{server.newRequestMetadata(req, nil), conn, connectionID})
+			return conn
+		}
+		Context("When invocations are sent to a user without connection", func() {
+			It("should deliver the last of them which have not expired when the user connects", func() {
+				users := server.HubContext().Clients()
+				users.User("alice").Send("message", "expired")
+				clock.Advance(2 * time.Hour)
+				users.User("alice").Send("message", "one")
+				users.Users([]string{"alice", "bob"}).Send("message", "two")
+				users.User("alice").Send("message", "three")
+				conn := connect("a", "alice")
+				Expect((<-conn.received).(InvocationMessage).Arguments).To(Equal([]interface{}{"two"}))
+				Expect((<-conn.received).(InvocationMessage).Arguments).To(Equal([]interface{}{"three"}))
+				_, err := conn.clientSend(`{"type":1,"invocationId":"r","target":"ready"}`)
+				Expect(err).To(BeNil())
+				Expect((<-conn.received).(CompletionMessage).InvocationID).To(Equal("r"))
+			})
+		})
+		Context("When invocations are sent to a connected user", func() {
+			It("should send them right away and not keep them", func() {
+				conn := connect("b", "bob")
+				Expect((<-conn.received).(InvocationMessage).Arguments).To(Equal([]interface{}{"two"}))
+				_, err := conn.clientSend(`{"type":1,"invocationId":"r","target":"ready"}`)
+				Expect(err).To(BeNil())
+				Expect((<-conn.received).(CompletionMessage).InvocationID).To(Equal("r"))
+				go server.HubContext().Clients().User("bob").Send("message", "live")
+				Expect((<-conn.received).(InvocationMessage).Arguments).To(Equal([]interface{}{"live"}))
+				Expect(server.inbox.options.Store.Take("", "bob")).To(BeEmpty())
+			})
+		})
+	})
+})
@@ -0,0 +1,31 @@
+package signalr
+
+// OutboundInterceptor is called before an invocation is sent to the client of a connection, e.g. to strip
+// fields the user of the connection is not allowed to see or to localize strings for ctx.Locale(). It returns the arguments
+// which are sent instead of args, or false to drop the invocation for this connection.
+// args are shared by all receivers of a broadcast, so changed arguments must be returned in a new slice
+type OutboundInterceptor func(ctx ConnectionContext, target string, args []interface{}) ([]interface{}, bool)
+
+// InterceptOutbound adds OutboundInterceptors to the server. They are applied to all invocations sent to clients,
+// in the order they were added, until one of them drops the invocation
+func InterceptOutbound(interceptors ...OutboundInterceptor) Option {
+	return func(s *Server) {
+		s.interceptors = append(s.interceptors, interceptors...)
+	}
+}
+
+// outboundInterceptor binds the interceptors of the server to a connection. It returns nil if there are no interceptors
+func (s *Server) outboundInterceptor(ctx ConnectionContext) func(target string, args []interface{}) ([]interface{}, bool) {
+	if len(s.interceptors) == 0 {
+		return nil
+	}
+	return func(target string, args []interface{}) ([]interface{}, bool) {
+		for _, interceptor := range s.interceptors {
+			var ok bool
+			if args, ok = interceptor(ctx, target, args); !ok {
+				return nil, false
+			}
+		}
+		return args, true
+	}
+}
@@ -0,0 +1,48 @@
+package signalr
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("OutboundInterceptor", func() {
+
+	Describe("Broadcast with interceptors", func() {
+		server := NewServer(&contextHub{},
+			IdentifyUser(func(ctx ConnectionContext) string {
+				return ctx.Query().Get("user")
+			}),
+			InterceptOutbound(func(ctx ConnectionContext, target string, args []interface{}) ([]interface{}, bool) {
+				// Only admins see the salary
+				if target == "employee" && ctx.UserID() != "admin" {
+					return []interface{}{args[0], nil}, true
+				}
+				return args, true
+			}, func(ctx ConnectionContext, target string, args []interface{}) ([]interface{}, bool) {
+				return args, target != "secret" || ctx.UserID() == "admin"
+			}))
+		Context("When an invocation is sent to all clients", func() {
+			It("should send the arguments returned by the interceptors and skip dropped invocations", func() {
+				admin := connectUser(server, "admin", "admin")
+				guest := connectUser(server, "guest", "guest")
+				go func() {
+					server.HubContext().Clients().All().Send("employee", "bob", 1000)
+					server.HubContext().Clients().All().Send("secret", 42)
+					server.HubContext().Clients().All().Send("end")
+				}()
+				// The broadcasts are written to both connections one after the other, so both must be read concurrently
+				guestReceived := make(chan []interface{}, 1)
+				go func() {
+					defer GinkgoRecover()
+					recv := (<-guest.received).(InvocationMessage)
+					expectTargets(guest, "end")
+					guestReceived <- recv.Arguments
+				}()
+				recv := (<-admin.received).(InvocationMessage)
+				Expect(recv.Arguments).To(Equal([]interface{}{"bob", float64(1000)}))
+				expectTargets(admin, "secret", "end")
+				Expect(<-guestReceived).To(Equal([]interface{}{"bob", nil}))
+			})
+		})
+	})
+})
@@ -55,6 +55,25 @@ func (i *invocationHub) Panic() {
 	panic("Don't panic!")
 }
 
+type optionalHub struct {
+	Hub
+}
+
+func (o *optionalHub) Greet(name string, greeting *string) string {
+	if greeting == nil {
+		return "Hello " + name
+	}
+	return *greeting + " " + name
+}
+
+func (o *optionalHub) Sum(label string, values ...int) string {
+	sum := 0
+	for _, value := range values {
+		sum += value
+	}
+	return fmt.Sprintf("%v %v", label, sum)
+}
+
 var _ = Describe("Invocation", func() {
 
 	Describe("Simple invocation", func() {
@@ -64,7 +83,7 @@ var _ = Describe("Invocation", func() {
 				_, err := conn.clientSend(`{"type":1,"invocationId": "123","target":"simple"}`)
 				Expect(err).To(BeNil())
 				Expect(<-invocationQueue).To(Equal("Simple()"))
-				recv := (<-conn.received).(completionMessage)
+				recv := (<-conn.received).(CompletionMessage)
 				Expect(recv).NotTo(BeNil())
 				Expect(recv.InvocationID).To(Equal("123"))
 				Expect(recv.Result).To(BeNil())
@@ -104,7 +123,7 @@ var _ = Describe("Invocation", func() {
 					`{"type":1,"invocationId": "666","target":"simpleint","arguments":[%v]}`, value))
 				Expect(err).To(BeNil())
 				Expect(<-invocationQueue).To(Equal(fmt.Sprintf("SimpleInt(%v)", value)))
-				recv := (<-conn.received).(completionMessage)
+				recv := (<-conn.received).(CompletionMessage)
 				Expect(recv).NotTo(BeNil())
 				Expect(recv.InvocationID).To(Equal("666"))
 				Expect(recv.Result).To(Equal(float64(value + 1))) // json  makes all numbers float64
@@ -120,7 +139,7 @@ var _ = Describe("Invocation", func() {
 				_, err := conn.clientSend(
 					`{"type":1,"invocationId": "555","target":"simpleint","arguments":["CantParse"]}`)
 				Expect(err).To(BeNil())
-				recv := (<-conn.received).(completionMessage)
+				recv := (<-conn.received).(CompletionMessage)
 				Expect(recv).NotTo(BeNil())
 				Expect(recv.Error).NotTo(Equal(""))
 				Expect(recv.InvocationID).To(Equal("555"))
@@ -138,7 +157,7 @@ var _ = Describe("Invocation", func() {
 					`{"type":1,"invocationId": "8087","target":"simplefloat","arguments":[%v]}`, value))
 				Expect(err).To(BeNil())
 				Expect(<-invocationQueue).To(Equal(fmt.Sprintf("SimpleFloat(%v)", value)))
-				recv := (<-conn.received).(completionMessage)
+				recv := (<-conn.received).(CompletionMessage)
 				Expect(recv).NotTo(BeNil())
 				Expect(recv.InvocationID).To(Equal("8087"))
 				Expect(recv.Result).To(Equal([]interface{}{value * 10.0, value * 100.0}))
@@ -157,7 +176,7 @@ var _ = Describe("Invocation", func() {
 					`{"type":1,"invocationId": "6502","target":"simplestring","arguments":["%v", "%v"]}`, value1, value2))
 				Expect(err).To(BeNil())
 				Expect(<-invocationQueue).To(Equal(fmt.Sprintf("SimpleString(%v, %v)", value1, value2)))
-				recv := (<-conn.received).(completionMessage)
+				recv := (<-conn.received).(CompletionMessage)
 				Expect(recv).NotTo(BeNil())
 				Expect(recv.InvocationID).To(Equal("6502"))
 				Expect(recv.Result).To(Equal(strings.ToLower(value1 + value2)))
@@ -173,7 +192,7 @@ var _ = Describe("Invocation", func() {
 				_, err := conn.clientSend(`{"type":1,"invocationId": "mfg","target":"async"}`)
 				Expect(err).To(BeNil())
 				Expect(<-invocationQueue).To(Equal("Async()"))
-				recv := (<-conn.received).(completionMessage)
+				recv := (<-conn.received).(CompletionMessage)
 				Expect(recv).NotTo(BeNil())
 				Expect(recv.InvocationID).To(Equal("mfg"))
 				Expect(recv.Result).To(Equal(true))
@@ -189,7 +208,7 @@ var _ = Describe("Invocation", func() {
 				_, err := conn.clientSend(`{"type":1,"invocationId": "ouch","target":"asyncclosedchan"}`)
 				Expect(err).To(BeNil())
 				Expect(<-invocationQueue).To(Equal("AsyncClosedChan()"))
-				recv := (<-conn.received).(completionMessage)
+				recv := (<-conn.received).(CompletionMessage)
 				Expect(recv).NotTo(BeNil())
 				Expect(recv.InvocationID).To(Equal("ouch"))
 				Expect(recv.Result).To(BeNil())
@@ -205,7 +224,7 @@ var _ = Describe("Invocation", func() {
 				_, err := conn.clientSend(`{"type":1,"invocationId": "???","target":"panic"}`)
 				Expect(err).To(BeNil())
 				Expect(<-invocationQueue).To(Equal("Panic()"))
-				recv := (<-conn.received).(completionMessage)
+				recv := (<-conn.received).(CompletionMessage)
 				Expect(recv).NotTo(BeNil())
 				Expect(recv.InvocationID).To(Equal("???"))
 				Expect(recv.Result).To(BeNil())
@@ -220,12 +239,62 @@ var _ = Describe("Invocation", func() {
 			It("should return an error", func() {
 				_, err := conn.clientSend(`{"type":1,"invocationId": "0000","target":"missing"}`)
 				Expect(err).To(BeNil())
-				recv := (<-conn.received).(completionMessage)
+				recv := (<-conn.received).(CompletionMessage)
 				Expect(recv).NotTo(BeNil())
 				Expect(recv.InvocationID).To(Equal("0000"))
 				Expect(recv.Result).To(BeNil())
-				Expect(recv.Error).NotTo(BeNil())
-				Expect(len(recv.Error)).To(BeNumerically(">", 0))
+				Expect(recv.Error).To(Equal("Method does not exist"))
+			})
+		})
+	})
+
+	Describe("Invocation with optional and variadic parameters", func() {
+		conn := connect(&optionalHub{})
+		invoke := func(invocation string) CompletionMessage {
+			_, err := conn.clientSend(invocation)
+			Expect(err).To(BeNil())
+			return (<-conn.received).(CompletionMessage)
+		}
+		Context("When the client omits a trailing pointer argument", func() {
+			It("should pass nil", func() {
+				Expect(invoke(`{"type":1,"invocationId": "1","target":"greet","arguments":["Ann"]}`).Result).To(Equal("Hello Ann"))
+				Expect(invoke(`{"type":1,"invocationId": "2","target":"greet","arguments":["Ann","Hi"]}`).Result).To(Equal("Hi Ann"))
+			})
+		})
+		Context("When the client omits a required argument or sends too many", func() {
+			It("should return an error", func() {
+				Expect(invoke(`{"type":1,"invocationId": "3","target":"greet","arguments":[]}`).Error).To(ContainSubstring("expects more arguments"))
+				Expect(invoke(`{"type":1,"invocationId": "4","target":"greet","arguments":["Ann","Hi","!"]}`).Error).To(ContainSubstring("expects less arguments"))
+			})
+		})
+		Context("When the client sends any number of variadic arguments", func() {
+			It("should pass them as variadic slice", func() {
+				Expect(invoke(`{"type":1,"invocationId": "5","target":"sum","arguments":["none"]}`).Result).To(Equal("none 0"))
+				Expect(invoke(`{"type":1,"invocationId": "6","target":"sum","arguments":["three",1,2,3]}`).Result).To(Equal("three 6"))
+				Expect(invoke(`{"type":1,"invocationId": "7","target":"sum","arguments":["bad",1,"two"]}`).Error).NotTo(BeEmpty())
+			})
+		})
+	})
+
+	Describe("Missing method invocation on a server listing its methods", func() {
+		server := NewServer(&invocationHub{}, ListAvailableMethods(true))
+		conn := newTestingConnection()
+		go server.Run(conn)
+		Context("When a missing server method is invoked without and with invocation ID", func() {
+			It("should answer only the invocation with ID, count both and keep the connection", func() {
+				_, err := conn.clientSend(`{"type":1,"target":"missing"}`)
+				Expect(err).To(BeNil())
+				_, err = conn.clientSend(`{"type":1,"invocationId": "0001","target":"missing"}`)
+				Expect(err).To(BeNil())
+				recv := (<-conn.received).(CompletionMessage)
+				Expect(recv.InvocationID).To(Equal("0001"))
+				Expect(recv.Error).To(HavePrefix("Method missing does not exist. Available methods: async, asyncclosedchan, "))
+				Expect(recv.Error).To(ContainSubstring("simpleint"))
+				Expect(server.Stats().UnknownMethods).To(Equal(int64(2)))
+				_, err = conn.clientSend(`{"type":1,"invocationId": "0002","target":"simpleint","arguments":[1]}`)
+				Expect(err).To(BeNil())
+				Expect(<-invocationQueue).To(Equal("SimpleInt(1)"))
+				Expect((<-conn.received).(CompletionMessage).Result).To(Equal(2.0))
 			})
 		})
 	})
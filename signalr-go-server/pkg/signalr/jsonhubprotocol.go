@@ -4,11 +4,45 @@ import (
 	"bytes"
 	"encoding/json"
 	"errors"
-	"fmt"
 	"io"
+	"strings"
 )
 
+// JsonHubProtocol is the json hub protocol. It is registered with each Server
 type JsonHubProtocol struct {
+	// debugLogger logs the messages sent, if not nil. It is set by the Server
+	debugLogger StructuredLogger
+	// camelCase writes the fields of structs with camelCase keys, see CamelCaseJSON. It is set by the Server
+	camelCase bool
+	// limits are the ArgumentLimits of received messages, if not nil. They are set by the Server
+	limits *ArgumentLimits
+}
+
+func (j *JsonHubProtocol) setDebugLogger(logger StructuredLogger) {
+	j.debugLogger = logger
+}
+
+func (j *JsonHubProtocol) setCamelCase(camelCase bool) {
+	j.camelCase = camelCase
+}
+
+func (j *JsonHubProtocol) setArgumentLimits(limits *ArgumentLimits) {
+	j.limits = limits
+}
+
+// Name returns "json"
+func (j *JsonHubProtocol) Name() string {
+	return "json"
+}
+
+// Version returns 1
+func (j *JsonHubProtocol) Version() int {
+	return 1
+}
+
+// TransferFormat returns "Text"
+func (j *JsonHubProtocol) TransferFormat() string {
+	return "Text"
 }
 
 // Protocol specific message for correct unmarshaling of Arguments
@@ -20,6 +54,13 @@ type jsonInvocationMessage struct {
 	StreamIds    []string          `json:"streamIds,omitempty"`
 }
 
+// Protocol specific message for correct unmarshaling of Item
+type jsonStreamItemMessage struct {
+	Type         int             `json:"type"`
+	InvocationID string          `json:"invocationId"`
+	Item         json.RawMessage `json:"item"`
+}
+
 func (j *JsonHubProtocol) UnmarshalArgument(argument interface{}, value interface{}) error {
 	return json.Unmarshal(argument.(json.RawMessage), value)
 }
@@ -33,7 +74,13 @@ func (j *JsonHubProtocol) ReadMessage(buf *bytes.Buffer) (interface{}, bool, err
 		return nil, true, err
 	}
 
-	message := hubMessage{}
+	if j.limits != nil {
+		if err = j.limits.checkJSON(data); err != nil {
+			return nil, true, err
+		}
+	}
+
+	message := HubMessage{}
 	err = json.Unmarshal(data, &message)
 
 	if err != nil {
@@ -44,11 +91,16 @@ func (j *JsonHubProtocol) ReadMessage(buf *bytes.Buffer) (interface{}, bool, err
 	case 1, 4:
 		jsonInvocation := jsonInvocationMessage{}
 		err = json.Unmarshal(data, &jsonInvocation)
+		if err == nil && j.limits != nil {
+			if err = j.limits.checkArguments(len(jsonInvocation.Arguments)); err != nil {
+				return nil, true, err
+			}
+		}
 		arguments := make([]interface{}, len(jsonInvocation.Arguments))
 		for i, a := range jsonInvocation.Arguments {
 			arguments[i] = a
 		}
-		invocation := invocationMessage{
+		invocation := InvocationMessage{
 			Type:         jsonInvocation.Type,
 			Target:       jsonInvocation.Target,
 			InvocationID: jsonInvocation.InvocationID,
@@ -57,15 +109,20 @@ func (j *JsonHubProtocol) ReadMessage(buf *bytes.Buffer) (interface{}, bool, err
 		}
 		return invocation, true, err
 	case 2:
-		streamItem := streamItemMessage{}
-		err = json.Unmarshal(data, &streamItem)
+		jsonStreamItem := jsonStreamItemMessage{}
+		err = json.Unmarshal(data, &jsonStreamItem)
+		streamItem := StreamItemMessage{
+			Type:         jsonStreamItem.Type,
+			InvocationID: jsonStreamItem.InvocationID,
+			Item:         jsonStreamItem.Item,
+		}
 		return streamItem, true, err
 	case 3:
-		completion := completionMessage{}
+		completion := CompletionMessage{}
 		err := json.Unmarshal(data, &completion)
 		return completion, true, err
 	case 5:
-		invocation := cancelInvocationMessage{}
+		invocation := CancelInvocationMessage{}
 		err = json.Unmarshal(data, &invocation)
 		return invocation, true, err
 	default:
@@ -73,15 +130,18 @@ func (j *JsonHubProtocol) ReadMessage(buf *bytes.Buffer) (interface{}, bool, err
 	}
 }
 
+// parseTextMessageFormat returns the next record separator terminated message from buf.
+// If buf contains no complete message, it returns io.EOF and leaves buf untouched
 func parseTextMessageFormat(buf *bytes.Buffer) ([]byte, error) {
 	// 30 = ASCII record separator
-	data, err := buf.ReadBytes(30)
+	end := bytes.IndexByte(buf.Bytes(), 30)
 
-	if err != nil {
-		return data, err
+	if end == -1 {
+		return nil, io.EOF
 	}
+	data := buf.Next(end + 1)
 	// Remove the delimeter
-	return data[0 : len(data)-1], err
+	return data[0 : len(data)-1], nil
 }
 
 func (j *JsonHubProtocol) WriteMessage(message interface{}, writer io.Writer) error {
@@ -91,10 +151,16 @@ func (j *JsonHubProtocol) WriteMessage(message interface{}, writer io.Writer) er
 	// We're copying because we want to write complete messages to the underlying Writer
 	buf := bytes.Buffer{}
 
+	if j.camelCase {
+		message = camelCaseMessage(message)
+	}
+
 	if err := json.NewEncoder(&buf).Encode(message); err != nil {
 		return err
 	}
-	fmt.Printf("Message sent %v", string(buf.Bytes()))
+	if j.debugLogger != nil {
+		_ = j.debugLogger.Log("event", "message sent", "message", strings.TrimSuffix(buf.String(), "\n"))
+	}
 
 	if err := buf.WriteByte(30); err != nil {
 		return err
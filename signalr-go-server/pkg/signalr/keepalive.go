@@ -0,0 +1,88 @@
+package signalr
+
+import (
+	"sync"
+	"time"
+)
+
+const keepAliveInterval = 5 * time.Second
+
+// ReadModel is the way the server reads from its connections and keeps them alive
+type ReadModel int
+
+const (
+	// GoroutinePerConnection gives each connection a goroutine sending pings to the client and its own read buffers
+	GoroutinePerConnection ReadModel = iota
+	// SharedKeepAlive is for servers with many mostly idle connections. The pings to all connections are sent by one
+	// goroutine, and read buffers are taken from a pool and given back when a message has been read, so an idle
+	// connection holds no buffers of the server. Each connection still has the goroutine of its transport reading from it
+	SharedKeepAlive
+)
+
+// readBufferPools are the pools of the read buffers of connections with the SharedKeepAlive read model, by buffer size
+var readBufferPools sync.Map
+
+// readBuffers returns the pool of the read buffers with size bytes
+func readBuffers(size int) *sync.Pool {
+	if pool, ok := readBufferPools.Load(size); ok {
+		return pool.(*sync.Pool)
+	}
+	pool, _ := readBufferPools.LoadOrStore(size, &sync.Pool{
+		New: func() interface{} {
+			buf := make([]byte, size)
+			return &buf
+		},
+	})
+	return pool.(*sync.Pool)
+}
+
+// keepAlive sends pings to all its connections from one goroutine, which runs while there are connections
+type keepAlive struct {
+	clock       Clock
+	mx          sync.Mutex
+	connections map[hubConnection]struct{}
+	running     bool
+}
+
+func newKeepAlive(clock Clock) *keepAlive {
+	return &keepAlive{clock: clock, connections: make(map[hubConnection]struct{})}
+}
+
+func (k *keepAlive) add(conn hubConnection) {
+	k.mx.Lock()
+	defer k.mx.Unlock()
+	k.connections[conn] = struct{}{}
+	if !k.running {
+		k.running = true
+		go k.loop()
+	}
+}
+
+func (k *keepAlive) remove(conn hubConnection) {
+	k.mx.Lock()
+	defer k.mx.Unlock()
+	delete(k.connections, conn)
+}
+
+func (k *keepAlive) loop() {
+	for {
+		k.mx.Lock()
+		if len(k.connections) == 0 {
+			k.running = false
+			k.mx.Unlock()
+			return
+		}
+		connections := make([]hubConnection, 0, len(k.connections))
+		for conn := range k.connections {
+			connections = append(connections, conn)
+		}
+		k.mx.Unlock()
+		for _, conn := range connections {
+			// A connection which is busy writing is kept alive by its messages and would block the other pings
+			if conn.IsConnected() && conn.Stats().QueueDepth == 0 {
+				conn.Ping()
+			}
+		}
+		<-k.clock.After(keepAliveInterval)
+	}
+}
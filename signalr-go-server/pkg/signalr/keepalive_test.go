@@ -0,0 +1,80 @@
+package signalr
+
+import (
+	"sync/atomic"
+	"time"
+
+	"./signalrtest"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// countingConnection counts the messages written to it
+type countingConnection struct {
+	id     string
+	writes int32
+}
+
+func (c *countingConnection) ConnectionID() string {
+	return c.id
+}
+
+func (c *countingConnection) Read([]byte) (int, error) {
+	select {}
+}
+
+func (c *countingConnection) Write(p []byte) (int, error) {
+	atomic.AddInt32(&c.writes, 1)
+	return len(p), nil
+}
+
+var _ = Describe("SharedKeepAlive", func() {
+
+	Describe("Keep alive of many connections", func() {
+		Context("When the keep alive interval elapses", func() {
+			It("should ping all connections from one goroutine until the last connection is removed", func() {
+				clock := signalrtest.NewFakeClock(time.Now())
+				keepAlive := newKeepAlive(clock)
+				conns := []*countingConnection{{id: "a"}, {id: "b"}}
+				var hubConns []hubConnection
+				for _, conn := range conns {
+					hubConn := newHubConnection(conn, &JsonHubProtocol{}, hubConnectionOptions{readModel: SharedKeepAlive})
+					hubConn.Start()
+					keepAlive.add(hubConn)
+					hubConns = append(hubConns, hubConn)
+				}
+				Eventually(clock.Timers).Should(Equal(1))
+				for _, conn := range conns {
+					Expect(atomic.LoadInt32(&conn.writes)).To(BeNumerically(">=", 1))
+				}
+				clock.Advance(keepAliveInterval)
+				for _, conn := range conns {
+					Eventually(func() int32 { return atomic.LoadInt32(&conn.writes) }).Should(BeNumerically(">=", 2))
+				}
+				for _, hubConn := range hubConns {
+					keepAlive.remove(hubConn)
+				}
+				Eventually(clock.Timers).Should(Equal(1))
+				clock.Advance(keepAliveInterval)
+				Eventually(func() bool {
+					keepAlive.mx.Lock()
+					defer keepAlive.mx.Unlock()
+					return keepAlive.running
+				}).Should(BeFalse())
+			})
+		})
+	})
+
+	Describe("Invocation over a connection with the SharedKeepAlive read model", func() {
+		server := NewServer(&contextHub{}, ConnectionReadModel(SharedKeepAlive))
+		conn := newTestingConnection()
+		go server.Run(conn)
+		Context("When the client invokes a method", func() {
+			It("should read the invocation with a pooled buffer and return the completion", func() {
+				_, err := conn.clientSend(`{"type":1,"invocationId": "pooled","target":"ready"}`)
+				Expect(err).To(BeNil())
+				Expect((<-conn.received).(CompletionMessage).InvocationID).To(Equal("pooled"))
+			})
+		})
+	})
+})
@@ -0,0 +1,32 @@
+package signalr
+
+import (
+	"strconv"
+	"strings"
+)
+
+// preferredLanguage returns the language with the highest quality of an Accept-Language header,
+// e.g. "de-CH" for "de-CH, de;q=0.9, en;q=0.8". It returns "" if the header names no language
+func preferredLanguage(acceptLanguage string) string {
+	preferred, best := "", 0.0
+	for _, entry := range strings.Split(acceptLanguage, ",") {
+		parts := strings.Split(entry, ";")
+		language := strings.TrimSpace(parts[0])
+		if language == "" || language == "*" {
+			continue
+		}
+		quality := 1.0
+		for _, parameter := range parts[1:] {
+			if value := strings.TrimSpace(parameter); strings.HasPrefix(value, "q=") {
+				var err error
+				if quality, err = strconv.ParseFloat(strings.TrimPrefix(value, "q="), 64); err != nil {
+					quality = 0
+				}
+			}
+		}
+		if quality > best {
+			preferred, best = language, quality
+		}
+	}
+	return preferred
+}
@@ -0,0 +1,63 @@
+package signalr
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+// StructuredLogger is the logger of a server. Log is called with alternating keys and values, e.g.
+// "connection", id, "error", err. The interface is the one of go-kit/log, so go-kit loggers and their
+// adapters to other logging libraries can be used
+type StructuredLogger interface {
+	Log(keyVals ...interface{}) error
+}
+
+// Logger sets the logger of the server, which logs errors and connection events. With debug set,
+// the handshakes and messages received and sent are logged, too. By default, errors and connection events
+// are printed to stdout and debug events are not logged
+func Logger(logger StructuredLogger, debug bool) Option {
+	return func(s *Server) {
+		s.logger = logger
+		if debug {
+			s.debugLogger = logger
+		} else {
+			s.debugLogger = nopLogger{}
+		}
+	}
+}
+
+// printLogger prints each call of Log as one line of key=value pairs
+type printLogger struct {
+	mx sync.Mutex
+	w  io.Writer
+}
+
+var stdoutLogger = &printLogger{w: os.Stdout}
+
+func (p *printLogger) Log(keyVals ...interface{}) error {
+	var line strings.Builder
+	for i := 0; i < len(keyVals); i += 2 {
+		if i > 0 {
+			line.WriteByte(' ')
+		}
+		if i+1 < len(keyVals) {
+			fmt.Fprintf(&line, "%v=%v", keyVals[i], keyVals[i+1])
+		} else {
+			fmt.Fprintf(&line, "%v", keyVals[i])
+		}
+	}
+	line.WriteByte('\n')
+	p.mx.Lock()
+	defer p.mx.Unlock()
+	_, err := io.WriteString(p.w, line.String())
+	return err
+}
+
+type nopLogger struct{}
+
+func (nopLogger) Log(...interface{}) error {
+	return nil
+}
@@ -0,0 +1,105 @@
+package signalr
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// recordingLogger keeps the events logged to it
+type recordingLogger struct {
+	mx     sync.Mutex
+	events []string
+}
+
+func (r *recordingLogger) Log(keyVals ...interface{}) error {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	for i := 0; i+1 < len(keyVals); i += 2 {
+		if keyVals[i] == "event" {
+			r.events = append(r.events, fmt.Sprint(keyVals[i+1]))
+		}
+	}
+	return nil
+}
+
+func (r *recordingLogger) logged() []string {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	return append([]string(nil), r.events...)
+}
+
+var _ = Describe("Logger", func() {
+
+	Describe("Server without a Logger", func() {
+		server := NewServer(&invocationHub{})
+		It("should print the events to stdout and not log the debug events", func() {
+			Expect(server.logger.(*correlatingLogger).logger).To(Equal(stdoutLogger))
+			Expect(server.debugLogger.(*correlatingLogger).logger).To(Equal(nopLogger{}))
+		})
+	})
+
+	Describe("Server with a logger without debug", func() {
+		logger := &recordingLogger{}
+		server := NewServer(&invocationHub{}, Logger(logger, false))
+		conn := newTestingConnection()
+		go server.Run(conn)
+		Context("When a client invokes an unknown method and closes the connection", func() {
+			It("should log the events but not the messages", func() {
+				_, err := conn.clientSend(`{"type":1,"invocationId": "1","target":"missing"}`)
+				Expect(err).To(BeNil())
+				<-conn.received
+				_, err = conn.clientSend(`{"type":7}`)
+				Expect(err).To(BeNil())
+				Eventually(logger.logged).Should(Equal([]string{"unknown method", "disconnected"}))
+			})
+		})
+	})
+
+	Describe("Server with a debug logger", func() {
+		logger := &recordingLogger{}
+		server := NewServer(&invocationHub{}, Logger(logger, true))
+		conn := newTestingConnection()
+		go server.Run(conn)
+		Context("When a client invokes a method", func() {
+			It("should log the handshake and the messages", func() {
+				_, err := conn.clientSend(`{"type":1,"invocationId": "1","target":"simpleint","arguments":[1]}`)
+				Expect(err).To(BeNil())
+				Expect(<-invocationQueue).To(Equal("SimpleInt(1)"))
+				<-conn.received
+				Eventually(logger.logged).Should(ContainElement("message sent"))
+				Expect(logger.logged()).To(ContainElement("handshake received"))
+				Expect(logger.logged()).To(ContainElement("message received"))
+			})
+		})
+	})
+})
+
+var _ = Describe("Transports", func() {
+
+	Describe("Server allowing only long polling", func() {
+		mux := http.NewServeMux()
+		MapHub(mux, "/hub", &contextHub{}, AllowTransports(TransportLongPolling))
+		Context("When the client negotiates", func() {
+			It("should offer only long polling", func() {
+				transports := negotiate(mux, "/hub")["availableTransports"].([]interface{})
+				Expect(transports).To(HaveLen(1))
+				Expect(transports[0].(map[string]interface{})["transport"]).To(Equal(TransportLongPolling))
+			})
+		})
+		Context("When the client connects with WebSockets", func() {
+			It("should answer 404", func() {
+				req := httptest.NewRequest("GET", "/hub", nil)
+				req.Header.Set("Upgrade", "websocket")
+				req.Header.Set("Connection", "Upgrade")
+				recorder := httptest.NewRecorder()
+				mux.ServeHTTP(recorder, req)
+				Expect(recorder.Code).To(Equal(404))
+			})
+		})
+	})
+})
@@ -0,0 +1,262 @@
+package signalr
+
+import (
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const longPollingChunkSize = 1 << 14 // 16K
+
+type longPollingConnection struct {
+	requestMetadata
+	connectionID string
+	reader       *io.PipeReader
+	writer       *io.PipeWriter
+	mx           sync.Mutex
+	messages     [][]byte
+	// queued is the number of bytes of the messages, drained is signalled when messages have been taken or the connection closed
+	queued  int
+	drained *sync.Cond
+	// bufferSize is the number of queued bytes a message must fit in to be queued, 0 means no limit
+	bufferSize int
+	// expires is the time each of the messages expires, zero if it does not expire
+	expires    []time.Time
+	closed     bool
+	terminated bool
+	// stopped tells that the client closed the connection with a DELETE request
+	stopped      bool
+	signal       chan struct{}
+	currentPoll  chan struct{}
+	clock        Clock
+	stopWatchdog func() bool
+	onTerminated func()
+}
+
+// newLongPollingConnection creates a long polling connection queuing up to bufferSize bytes. onTerminated is called once
+// when the client has been told the connection is closed, or when the client stopped polling for longer than disconnectTimeout
+func newLongPollingConnection(connectionID string, metadata requestMetadata, clock Clock, disconnectTimeout time.Duration, bufferSize int, onTerminated func()) *longPollingConnection {
+	reader, writer := io.Pipe()
+	l := &longPollingConnection{
+		requestMetadata: metadata,
+		connectionID:    connectionID,
+		reader:          reader,
+		writer:          writer,
+		signal:          make(chan struct{}, 1),
+		clock:           clock,
+		bufferSize:      bufferSize,
+		onTerminated:    onTerminated,
+	}
+	l.drained = sync.NewCond(&l.mx)
+	l.stopWatchdog = clock.AfterFunc(disconnectTimeout, l.expire)
+	return l
+}
+
+// pollTimeoutError ends the reads of a connection whose client stopped polling
+type pollTimeoutError struct{}
+
+func (pollTimeoutError) Error() string   { return "long polling client stopped polling" }
+func (pollTimeoutError) Timeout() bool   { return true }
+func (pollTimeoutError) Temporary() bool { return false }
+
+// expire is called by the watchdog when the client stopped polling
+func (l *longPollingConnection) expire() {
+	l.closeWithError(pollTimeoutError{})
+	l.terminate()
+}
+
+func (l *longPollingConnection) ConnectionID() string {
+	return l.connectionID
+}
+
+// requestFeatures returns the features of the request which started the connection and of the transport
+func (l *longPollingConnection) requestFeatures() map[string]interface{} {
+	features := map[string]interface{}{FeatureTransport: TransportLongPolling, FeatureBinary: true}
+	for name, value := range l.features {
+		features[name] = value
+	}
+	return features
+}
+
+func (l *longPollingConnection) Read(p []byte) (n int, err error) {
+	return l.reader.Read(p)
+}
+
+// Write queues one complete message until the client polls for it.
+// A message which does not fit in the bufferSize waits until the client has polled the queued messages
+func (l *longPollingConnection) Write(p []byte) (n int, err error) {
+	l.mx.Lock()
+	defer l.mx.Unlock()
+	for !l.closed && l.bufferSize > 0 && l.queued > 0 && l.queued+len(p) > l.bufferSize {
+		l.drained.Wait()
+	}
+	if l.closed {
+		return 0, io.ErrClosedPipe
+	}
+	l.messages = append(l.messages, append([]byte(nil), p...))
+	l.expires = append(l.expires, time.Time{})
+	l.queued += len(p)
+	l.notify()
+	return len(p), nil
+}
+
+// writeWithTTL queues one complete message, which is dropped if the client has not polled it within ttl
+func (l *longPollingConnection) writeWithTTL(p []byte, ttl time.Duration) (n int, err error) {
+	if n, err = l.Write(p); err == nil {
+		l.mx.Lock()
+		l.expires[len(l.expires)-1] = l.clock.Now().Add(ttl)
+		l.mx.Unlock()
+	}
+	return n, err
+}
+
+// waitForCapacity waits until less than size bytes are queued. It fails when the connection is closed
+func (l *longPollingConnection) waitForCapacity(size int) error {
+	l.mx.Lock()
+	defer l.mx.Unlock()
+	for !l.closed && l.queued >= size {
+		l.drained.Wait()
+	}
+	if l.closed {
+		return io.ErrClosedPipe
+	}
+	return nil
+}
+
+// Close closes the connection. Messages which are already queued are still delivered to the client
+func (l *longPollingConnection) Close() error {
+	l.closeWithError(nil)
+	return nil
+}
+
+// closeWithError closes the connection. The server side reader gets err, or io.EOF if err is nil
+func (l *longPollingConnection) closeWithError(err error) {
+	l.mx.Lock()
+	defer l.mx.Unlock()
+	if !l.closed {
+		l.closed = true
+		_ = l.writer.CloseWithError(err)
+		l.notify()
+		l.drained.Broadcast()
+	}
+}
+
+// closeByClient closes the connection for the DELETE request of the client
+func (l *longPollingConnection) closeByClient() {
+	l.mx.Lock()
+	l.stopped = true
+	l.mx.Unlock()
+	l.closeWithError(nil)
+}
+
+// closedByClient returns if the client closed the connection with a DELETE request
+func (l *longPollingConnection) closedByClient() bool {
+	l.mx.Lock()
+	defer l.mx.Unlock()
+	return l.stopped
+}
+
+func (l *longPollingConnection) terminate() {
+	l.mx.Lock()
+	terminated := l.terminated
+	l.terminated = true
+	l.stopWatchdog()
+	l.mx.Unlock()
+	if !terminated {
+		l.onTerminated()
+	}
+}
+
+func (l *longPollingConnection) notify() {
+	select {
+	case l.signal <- struct{}{}:
+	default:
+	}
+}
+
+// send copies the body of a send request to the server side reader
+func (l *longPollingConnection) send(body io.Reader) error {
+	_, err := io.Copy(l.writer, body)
+	return err
+}
+
+// poll waits for queued messages and writes them to the poll response.
+// The messages in one response are limited to maxResponseSize bytes. A single message exceeding this size
+// is sent as the only message of the response, written and flushed in chunks.
+// A poll which is still waiting when the next poll of the client arrives is ended with 204
+func (l *longPollingConnection) poll(w http.ResponseWriter, req *http.Request, pollTimeout, disconnectTimeout time.Duration, maxResponseSize int) {
+	l.mx.Lock()
+	if l.currentPoll != nil {
+		close(l.currentPoll)
+	}
+	cancel := make(chan struct{})
+	l.currentPoll = cancel
+	l.stopWatchdog()
+	l.mx.Unlock()
+	defer func() {
+		l.mx.Lock()
+		defer l.mx.Unlock()
+		// Only the latest poll of a connection which has not been terminated rearms the watchdog
+		if l.currentPoll == cancel {
+			l.currentPoll = nil
+			if !l.terminated {
+				l.stopWatchdog = l.clock.AfterFunc(disconnectTimeout, l.expire)
+			}
+		}
+	}()
+	select {
+	case <-l.signal:
+	case <-cancel:
+		w.WriteHeader(204)
+		return
+	case <-l.clock.After(pollTimeout):
+		// Nothing to send, the client will poll again
+		w.WriteHeader(200)
+		return
+	case <-req.Context().Done():
+		return
+	}
+	l.mx.Lock()
+	var messages [][]byte
+	size := 0
+	now := l.clock.Now()
+	for len(l.messages) > 0 && (len(messages) == 0 || size+len(l.messages[0]) <= maxResponseSize) {
+		if expires := l.expires[0]; expires.IsZero() || now.Before(expires) {
+			size += len(l.messages[0])
+			messages = append(messages, l.messages[0])
+		}
+		l.queued -= len(l.messages[0])
+		l.messages = l.messages[1:]
+		l.expires = l.expires[1:]
+	}
+	l.drained.Broadcast()
+	closed := l.closed
+	if len(l.messages) > 0 || closed {
+		l.notify()
+	}
+	l.mx.Unlock()
+	if len(messages) == 0 && closed {
+		// The connection has been closed and all messages have been delivered
+		w.WriteHeader(204)
+		l.terminate()
+		return
+	}
+	w.WriteHeader(200)
+	flusher, canFlush := w.(http.Flusher)
+	for _, message := range messages {
+		for len(message) > 0 {
+			chunk := message
+			if len(chunk) > longPollingChunkSize {
+				chunk = chunk[:longPollingChunkSize]
+			}
+			if _, err := w.Write(chunk); err != nil {
+				return
+			}
+			if canFlush && size > maxResponseSize {
+				flusher.Flush()
+			}
+			message = message[len(chunk):]
+		}
+	}
+}
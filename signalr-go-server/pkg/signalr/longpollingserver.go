@@ -0,0 +1,63 @@
+package signalr
+
+import (
+	"net/http"
+	"time"
+)
+
+const (
+	longPollingPollTimeout            = 90 * time.Second
+	longPollingDisconnectTimeout      = 15 * time.Second
+	defaultLongPollingMaxResponseSize = 1 << 16 // 64K
+)
+
+func (s *Server) longPollingHandler(w http.ResponseWriter, req *http.Request) {
+	connectionID := req.URL.Query().Get("id")
+	if len(connectionID) == 0 {
+		http.Error(w, errConnectionIDRequired, 400)
+		return
+	}
+	switch req.Method {
+	case "GET":
+		if conn, ok := s.longPollingConnections.Load(connectionID); ok {
+			conn.(*longPollingConnection).poll(w, req, longPollingPollTimeout, longPollingDisconnectTimeout, s.longPollingMaxResponseSize)
+			return
+		}
+		fallback := s.connections.fallback(connectionID)
+		negotiateHeader, ok := s.connections.claimNegotiated(connectionID)
+		if !ok {
+			http.Error(w, errUnknownConnectionID, 404)
+			return
+		}
+		s.reportFallback(connectionID, TransportLongPolling, fallback)
+		conn := newLongPollingConnection(connectionID, s.newRequestMetadata(req, negotiateHeader), s.clock, longPollingDisconnectTimeout, s.transportOptions.LongPollingBufferSize, func() {
+			s.longPollingConnections.Delete(connectionID)
+		})
+		s.longPollingConnections.Store(connectionID, conn)
+		go func() {
+			s.Run(conn)
+			// The connection is kept until the client has polled the remaining messages
+			_ = conn.Close()
+		}()
+		// The first poll returns immediately to signal the connection has been started
+		w.WriteHeader(200)
+	case "POST":
+		conn, ok := s.longPollingConnections.Load(connectionID)
+		if !ok {
+			http.Error(w, errUnknownConnectionID, 404)
+			return
+		}
+		if err := conn.(*longPollingConnection).send(req.Body); err != nil {
+			http.Error(w, errUnknownConnectionID, 404)
+			return
+		}
+		w.WriteHeader(200)
+	case "DELETE":
+		if conn, ok := s.longPollingConnections.Load(connectionID); ok {
+			conn.(*longPollingConnection).closeByClient()
+		}
+		w.WriteHeader(202)
+	default:
+		w.WriteHeader(405)
+	}
+}
@@ -0,0 +1,279 @@
+package signalr
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"./signalrtest"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type longPollingHub struct {
+	Hub
+	produced int32
+}
+
+// Count streams n items as fast as they are taken
+func (l *longPollingHub) Count(n int) <-chan int {
+	items := make(chan int)
+	go func() {
+		defer close(items)
+		for i := 0; i < n; i++ {
+			items <- i
+			atomic.AddInt32(&l.produced, 1)
+		}
+	}()
+	return items
+}
+
+func (l *longPollingHub) Large(size int) string {
+	return strings.Repeat("x", size)
+}
+
+func longPoll(pollURL string) (int, []string) {
+	resp, err := http.Get(pollURL)
+	Expect(err).To(BeNil())
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	Expect(err).To(BeNil())
+	var messages []string
+	for _, message := range bytes.Split(body, []byte{30}) {
+		if len(message) > 0 {
+			messages = append(messages, string(message))
+		}
+	}
+	return resp.StatusCode, messages
+}
+
+// pollCompletion polls until it receives a completion message
+func pollCompletion(pollURL string) CompletionMessage {
+	for i := 0; i < 100; i++ {
+		status, messages := longPoll(pollURL)
+		Expect(status).To(Equal(200))
+		for _, message := range messages {
+			var completion CompletionMessage
+			Expect(json.Unmarshal([]byte(message), &completion)).To(Succeed())
+			if completion.Type == 3 {
+				return completion
+			}
+		}
+	}
+	Fail("no completion received")
+	return CompletionMessage{}
+}
+
+func longPollSend(pollURL string, message string) {
+	resp, err := http.Post(pollURL, "text/plain", strings.NewReader(message+"\u001e"))
+	Expect(err).To(BeNil())
+	Expect(resp.StatusCode).To(Equal(200))
+	resp.Body.Close()
+}
+
+var _ = Describe("Long polling", func() {
+
+	Describe("Long polling connection", func() {
+		mux := http.NewServeMux()
+		MapHub(mux, "/hub", &longPollingHub{}, LongPollingMaxResponseSize(64))
+		httpServer := httptest.NewServer(mux)
+		Context("When a client connects and invokes a method with a result larger than the response size", func() {
+			It("should receive the handshake response and the complete result", func() {
+				defer httpServer.Close()
+				pollURL := httpServer.URL + "/hub?id=" + url.QueryEscape(negotiate(mux, "/hub")["connectionId"].(string))
+				status, _ := longPoll(pollURL)
+				Expect(status).To(Equal(200))
+				longPollSend(pollURL, `{"protocol": "json","version": 1}`)
+				_, messages := longPoll(pollURL)
+				Expect(messages[0]).To(Equal("{}"))
+				longPollSend(pollURL, `{"type":1,"invocationId": "large","target":"large","arguments":[100000]}`)
+				Expect(pollCompletion(pollURL)).To(Equal(CompletionMessage{Type: 3, InvocationID: "large", Result: strings.Repeat("x", 100000)}))
+				req, _ := http.NewRequest("DELETE", pollURL, nil)
+				resp, err := http.DefaultClient.Do(req)
+				Expect(err).To(BeNil())
+				Expect(resp.StatusCode).To(Equal(202))
+				status, _ = longPoll(pollURL)
+				Expect(status).To(Equal(204))
+			})
+		})
+	})
+
+	Describe("Long polling connection closed by the server", func() {
+		mux := http.NewServeMux()
+		MapHub(mux, "/hub", &longPollingHub{})
+		httpServer := httptest.NewServer(mux)
+		Context("When the server closes the connection", func() {
+			It("should deliver the queued messages, then 204, then forget the connection", func() {
+				defer httpServer.Close()
+				pollURL := httpServer.URL + "/hub?id=" + url.QueryEscape(negotiate(mux, "/hub")["connectionId"].(string))
+				status, _ := longPoll(pollURL)
+				Expect(status).To(Equal(200))
+				// The server ends the connection after the failed handshake
+				longPollSend(pollURL, `{"protocol": "unknown","version": 1}`)
+				status, messages := longPoll(pollURL)
+				Expect(status).To(Equal(200))
+				Expect(messages[0]).To(ContainSubstring("error"))
+				status, _ = longPoll(pollURL)
+				Expect(status).To(Equal(204))
+				status, _ = longPoll(pollURL)
+				Expect(status).To(Equal(404))
+			})
+		})
+	})
+
+	Describe("Concurrent long polls", func() {
+		mux := http.NewServeMux()
+		MapHub(mux, "/hub", &longPollingHub{})
+		httpServer := httptest.NewServer(mux)
+		Context("When a second poll arrives while the first is waiting", func() {
+			It("should end the first poll with 204 and deliver messages to the second", func() {
+				defer httpServer.Close()
+				pollURL := httpServer.URL + "/hub?id=" + url.QueryEscape(negotiate(mux, "/hub")["connectionId"].(string))
+				longPoll(pollURL)
+				longPollSend(pollURL, `{"protocol": "json","version": 1}`)
+				_, messages := longPoll(pollURL)
+				Expect(messages[0]).To(Equal("{}"))
+				first := make(chan int)
+				go func() {
+					defer GinkgoRecover()
+					status, _ := longPoll(pollURL)
+					first <- status
+				}()
+				// Let the first poll start waiting
+				time.Sleep(50 * time.Millisecond)
+				second := make(chan CompletionMessage)
+				go func() {
+					defer GinkgoRecover()
+					second <- pollCompletion(pollURL)
+				}()
+				Eventually(first).Should(Receive(Equal(204)))
+				longPollSend(pollURL, `{"type":1,"invocationId": "second","target":"large","arguments":[1]}`)
+				Eventually(second).Should(Receive(HaveField("InvocationID", "second")))
+			})
+		})
+	})
+
+	Describe("Long polling after a failed WebSocket upgrade", func() {
+		mux := http.NewServeMux()
+		fallbacks := make(chan TransportFallback, 1)
+		server := MapHub(mux, "/hub", &longPollingHub{}, OnTransportFallback(func(fallback TransportFallback) {
+			fallbacks <- fallback
+		}))
+		httpServer := httptest.NewServer(mux)
+		Context("When the upgrade fails and the client falls back to long polling with the same ID", func() {
+			It("should accept the connection ID", func() {
+				defer httpServer.Close()
+				pollURL := httpServer.URL + "/hub?id=" + url.QueryEscape(negotiate(mux, "/hub")["connectionId"].(string))
+				// An upgrade request without Sec-WebSocket-Key fails the WebSocket handshake
+				req, _ := http.NewRequest("GET", pollURL, nil)
+				req.Header.Set("Upgrade", "websocket")
+				req.Header.Set("Connection", "Upgrade")
+				resp, err := http.DefaultClient.Do(req)
+				Expect(err).To(BeNil())
+				resp.Body.Close()
+				Expect(resp.StatusCode).NotTo(Equal(101))
+				status, _ := longPoll(pollURL)
+				Expect(status).To(Equal(200))
+				longPollSend(pollURL, `{"protocol": "json","version": 1}`)
+				_, messages := longPoll(pollURL)
+				Expect(messages[0]).To(Equal("{}"))
+				fallback := <-fallbacks
+				Expect(fallback.Transport).To(Equal(TransportLongPolling))
+				Expect(fallback.Reason).To(Equal(FallbackUpgradeFailed))
+				Expect(fallback.Error).To(Equal("WebSocket handshake failed"))
+				Expect(server.Stats().TransportFallbacks).To(Equal(map[string]int64{FallbackUpgradeFailed: 1}))
+			})
+		})
+	})
+
+	Describe("Long polling client which stops polling", func() {
+		mux := http.NewServeMux()
+		clock := signalrtest.NewFakeClock(time.Now())
+		MapHub(mux, "/hub", &longPollingHub{}, UseClock(clock))
+		httpServer := httptest.NewServer(mux)
+		Context("When the client does not poll within the disconnect timeout", func() {
+			It("should forget the connection", func() {
+				defer httpServer.Close()
+				pollURL := httpServer.URL + "/hub?id=" + url.QueryEscape(negotiate(mux, "/hub")["connectionId"].(string))
+				status, _ := longPoll(pollURL)
+				Expect(status).To(Equal(200))
+				clock.Advance(longPollingDisconnectTimeout - time.Second)
+				longPollSend(pollURL, `{"protocol": "json","version": 1}`)
+				clock.Advance(2 * time.Second)
+				Eventually(func() int {
+					status, _ := longPoll(pollURL)
+					return status
+				}).Should(Equal(404))
+			})
+		})
+	})
+
+	Describe("Long polling connection with queued messages which expire", func() {
+		clock := signalrtest.NewFakeClock(time.Now())
+		conn := newLongPollingConnection("ttl", requestMetadata{}, clock, longPollingDisconnectTimeout, 0, func() {})
+		Context("When the client polls after the TTL of a message", func() {
+			It("should only deliver the messages which have not expired", func() {
+				_, _ = conn.writeWithTTL([]byte("stale"), time.Second)
+				clock.Advance(2 * time.Second)
+				_, _ = conn.writeWithTTL([]byte("fresh"), time.Second)
+				_, _ = conn.Write([]byte("forever"))
+				recorder := httptest.NewRecorder()
+				conn.poll(recorder, httptest.NewRequest("GET", "/hub?id=ttl", nil), longPollingPollTimeout, longPollingDisconnectTimeout, 1000)
+				Expect(recorder.Code).To(Equal(200))
+				Expect(recorder.Body.String()).To(Equal("freshforever"))
+			})
+		})
+	})
+
+	Describe("Long polling connection receiving a stream", func() {
+		hub := &longPollingHub{}
+		mux := http.NewServeMux()
+		MapHub(mux, "/hub", hub, StreamBufferSize(200))
+		httpServer := httptest.NewServer(mux)
+		Context("When the client does not poll", func() {
+			It("should pause the producer until the client polls", func() {
+				defer httpServer.Close()
+				pollURL := httpServer.URL + "/hub?id=" + url.QueryEscape(negotiate(mux, "/hub")["connectionId"].(string))
+				longPoll(pollURL)
+				longPollSend(pollURL, `{"protocol": "json","version": 1}`)
+				longPoll(pollURL)
+				longPollSend(pollURL, `{"type":4,"invocationId": "count","target":"count","arguments":[100]}`)
+				// About 5 items fit into the buffer, one waits in the streamer and one in the producer
+				Eventually(func() int32 { return atomic.LoadInt32(&hub.produced) }).Should(BeNumerically(">", 0))
+				Consistently(func() int32 { return atomic.LoadInt32(&hub.produced) }, 100*time.Millisecond).Should(BeNumerically("<", 10))
+				items := 0
+				for i := 0; i < 200; i++ {
+					_, messages := longPoll(pollURL)
+					for _, message := range messages {
+						if strings.Contains(message, `"type":2`) {
+							items++
+						}
+					}
+					if strings.Contains(strings.Join(messages, ""), `"type":3`) {
+						break
+					}
+				}
+				Expect(items).To(Equal(100))
+				Expect(atomic.LoadInt32(&hub.produced)).To(Equal(int32(100)))
+			})
+		})
+	})
+
+	Describe("Long polling without negotiate", func() {
+		mux := http.NewServeMux()
+		MapHub(mux, "/hub", &longPollingHub{})
+		Context("When a client polls with an unknown connection ID", func() {
+			It("should return 404", func() {
+				recorder := httptest.NewRecorder()
+				mux.ServeHTTP(recorder, httptest.NewRequest("GET", "/hub?id=unknown", nil))
+				Expect(recorder.Code).To(Equal(404))
+			})
+		})
+	})
+})
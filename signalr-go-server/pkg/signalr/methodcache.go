@@ -0,0 +1,116 @@
+package signalr
+
+import (
+	"reflect"
+	"strings"
+)
+
+// hubMethod is a hub method with the parameter lookups done once, when the hub is registered,
+// so dispatching an invocation needs no reflection besides unmarshaling the arguments and the call
+type hubMethod struct {
+	value  reflect.Value
+	params []hubParameter
+	// arguments and streams are the number of parameters the client sends as arguments and as streams
+	arguments int
+	streams   int
+	// variadic methods take the arguments after the last parameter as elements of the variadic slice parameter
+	variadic bool
+}
+
+type hubParameterKind int
+
+const (
+	// argumentParameter is unmarshaled from an argument of the invocation
+	argumentParameter hubParameterKind = iota
+	// streamParameter receives the items of a client stream
+	streamParameter
+	// connectionContextParameter and contextParameter are injected, they must precede all other parameters
+	connectionContextParameter
+	contextParameter
+)
+
+type hubParameter struct {
+	kind hubParameterKind
+	// typ is the parameter type, for streams the bidirectional chan type which is created to send the items
+	typ reflect.Type
+	// optional arguments are the zero value if the client omits them
+	optional bool
+}
+
+func newHubMethod(value reflect.Value) *hubMethod {
+	methodType := value.Type()
+	method := &hubMethod{value: value, params: make([]hubParameter, methodType.NumIn()), variadic: methodType.IsVariadic()}
+	injected := 0
+	for i := range method.params {
+		t := methodType.In(i)
+		switch {
+		case i == injected && t == connectionContextType:
+			method.params[i] = hubParameter{kind: connectionContextParameter, typ: t}
+			injected++
+		case i == injected && t == contextType:
+			method.params[i] = hubParameter{kind: contextParameter, typ: t}
+			injected++
+		case t.Kind() == reflect.Chan && t.ChanDir() != reflect.SendDir:
+			// MakeChan does only accept bidirectional channels and we need to Send to this channel anyway
+			method.params[i] = hubParameter{kind: streamParameter, typ: reflect.ChanOf(reflect.BothDir, t.Elem())}
+			method.streams++
+		default:
+			method.params[i] = hubParameter{kind: argumentParameter, typ: t}
+			method.arguments++
+		}
+	}
+	// Trailing pointer arguments and the variadic arguments can be omitted, JavaScript clients omit trailing undefined arguments
+	for i := len(method.params) - 1; i >= injected; i-- {
+		param := &method.params[i]
+		if param.kind == streamParameter {
+			continue
+		}
+		if param.typ.Kind() != reflect.Ptr && !(method.variadic && i == len(method.params)-1) {
+			break
+		}
+		param.optional = true
+	}
+	return method
+}
+
+// call calls the method with the values built for its parameters. The value of the variadic parameter is a slice
+func (m *hubMethod) call(in []reflect.Value) []reflect.Value {
+	if m.variadic {
+		return m.value.CallSlice(in)
+	}
+	return m.value.Call(in)
+}
+
+// newHubMethods returns the methods of the hub type clients can invoke by their lower case name
+func newHubMethods(hub HubInterface) map[string]*hubMethod {
+	hubType := reflect.TypeOf(hub)
+	hubValue := reflect.ValueOf(hub)
+	methods := make(map[string]*hubMethod, hubType.NumMethod())
+	for i := 0; i < hubType.NumMethod(); i++ {
+		if isHookMethod(hubType.Method(i).Name) {
+			continue
+		}
+		methods[strings.ToLower(hubType.Method(i).Name)] = newHubMethod(hubValue.Method(i))
+	}
+	return methods
+}
+
+// hookInterfaces are the interfaces of the hub methods the server calls, e.g. when a connection starts or ends
+var hookInterfaces = []reflect.Type{
+	reflect.TypeOf((*HubInterface)(nil)).Elem(),
+	reflect.TypeOf((*ReconnectHub)(nil)).Elem(),
+	reflect.TypeOf((*DisconnectReasonHub)(nil)).Elem(),
+	reflect.TypeOf((*OrderedHub)(nil)).Elem(),
+}
+
+// isHookMethod returns if the hub method with name is called by the server or is a method of Hub.
+// Clients can not invoke these methods, otherwise they could fake the events of other connections
+func isHookMethod(name string) bool {
+	for _, hook := range hookInterfaces {
+		if _, ok := hook.MethodByName(name); ok {
+			return true
+		}
+	}
+	_, ok := reflect.TypeOf(&Hub{}).MethodByName(name)
+	return ok
+}
@@ -0,0 +1,131 @@
+package signalr
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type cacheHub struct {
+	Hub
+}
+
+func (c *cacheHub) Add(a int, b int) int {
+	return a + b
+}
+
+func (c *cacheHub) Upload(ctx ConnectionContext, name string, lines <-chan string) {}
+
+func (c *cacheHub) Cancelable(ctx context.Context, conn ConnectionContext, n int) {}
+
+var _ = Describe("Method cache", func() {
+
+	Context("When a hub method is cached", func() {
+		It("should classify its parameters", func() {
+			methods := newHubMethods(&cacheHub{})
+			upload := methods["upload"]
+			Expect(upload).NotTo(BeNil())
+			Expect(upload.params).To(HaveLen(3))
+			Expect(upload.params[0].kind).To(Equal(connectionContextParameter))
+			Expect(upload.params[1].kind).To(Equal(argumentParameter))
+			Expect(upload.params[2].kind).To(Equal(streamParameter))
+			Expect(upload.params[2].typ.ChanDir()).To(Equal(reflect.BothDir))
+			Expect(upload.arguments).To(Equal(1))
+			Expect(upload.streams).To(Equal(1))
+		})
+		It("should inject only the leading context parameters", func() {
+			cancelable := newHubMethods(&cacheHub{})["cancelable"]
+			Expect(cancelable.params[0].kind).To(Equal(contextParameter))
+			Expect(cancelable.params[1].kind).To(Equal(connectionContextParameter))
+			Expect(cancelable.params[2].kind).To(Equal(argumentParameter))
+		})
+	})
+
+	Context("When several connections run on a server", func() {
+		It("should look up the methods once", func() {
+			server := NewServer(&cacheHub{})
+			Expect(server.newHubInfo()).To(BeIdenticalTo(server.newHubInfo()))
+			a := connectUser(server, "a", "")
+			b := connectUser(server, "b", "")
+			for _, conn := range []*testingConnection{a, b} {
+				_, err := conn.clientSend(`{"type":1,"invocationId": "add","target":"add","arguments":[1,2]}`)
+				Expect(err).To(BeNil())
+				Expect((<-conn.received).(CompletionMessage).Result).To(Equal(3.0))
+			}
+		})
+	})
+})
+
+// BenchmarkInvocation measures dispatching an invocation to a hub method,
+// from the method lookup over the arguments to the call
+func BenchmarkInvocation(b *testing.B) {
+	server := NewServer(&cacheHub{})
+	protocol := &JsonHubProtocol{}
+	connectionContext := newConnectionContext(newTestingConnection())
+	streamClient := newStreamClient(protocol, nopLogger{})
+	invocation := InvocationMessage{Type: 1, Target: "add", InvocationID: "1",
+		Arguments: []interface{}{json.RawMessage("1"), json.RawMessage("2")}}
+	hubInfo := server.newHubInfo()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		method := hubInfo.methods[strings.ToLower(invocation.Target)]
+		in, _, err := buildMethodArguments(method, invocation, streamClient, protocol, connectionContext)
+		if err != nil {
+			b.Fatal(err)
+		}
+		server.callMethod(hubInfo, invocation, method, in, connectionContext)
+	}
+}
+
+// BenchmarkConnectionStart measures what each connection needs to dispatch invocations to the hub
+func BenchmarkConnectionStart(b *testing.B) {
+	server := NewServer(&cacheHub{})
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		server.newHubInfo()
+	}
+}
+
+// BenchmarkReceive measures reading and parsing invocations which arrive in pieces
+func BenchmarkReceive(b *testing.B) {
+	message := []byte(`{"type":1,"invocationId":"1","target":"add","arguments":[1,2]}` + "\u001e")
+	reader := &pieceReader{piece: 16}
+	hubConn := newHubConnection(reader, &JsonHubProtocol{}, hubConnectionOptions{})
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		reader.data = message
+		if _, err := hubConn.Receive(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// pieceReader is a Connection which returns its data in pieces
+type pieceReader struct {
+	data  []byte
+	piece int
+}
+
+func (p *pieceReader) ConnectionID() string {
+	return "pieces"
+}
+
+func (p *pieceReader) Read(b []byte) (int, error) {
+	if len(b) > p.piece {
+		b = b[:p.piece]
+	}
+	n := copy(b, p.data)
+	p.data = p.data[n:]
+	return n, nil
+}
+
+func (p *pieceReader) Write(b []byte) (int, error) {
+	return len(b), nil
+}
@@ -0,0 +1,100 @@
+package signalr
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// OrderingKey returns the key of an invocation of a hub method. Invocations with the same key
+// run one after the other, also when they are invoked over different connections.
+// args are the arguments sent by the client
+type OrderingKey func(connectionContext ConnectionContext, args []interface{}) string
+
+// OrderedHub is implemented by hubs with methods which must not run concurrently,
+// e.g. methods mutating the state of a room. OrderedMethods returns the OrderingKey of those methods by method name
+type OrderedHub interface {
+	OrderedMethods() map[string]OrderingKey
+}
+
+// OrderedByConnection serializes the invocations of a connection
+func OrderedByConnection() OrderingKey {
+	return func(connectionContext ConnectionContext, args []interface{}) string {
+		return "connection:" + connectionContext.ConnectionID()
+	}
+}
+
+// OrderedByUser serializes the invocations of all connections of a user
+func OrderedByUser() OrderingKey {
+	return func(connectionContext ConnectionContext, args []interface{}) string {
+		return "user:" + connectionContext.UserID()
+	}
+}
+
+// OrderedByArgument serializes the invocations with the same value of the client argument at index, e.g. a room name
+func OrderedByArgument(index int) OrderingKey {
+	return func(connectionContext ConnectionContext, args []interface{}) string {
+		if index >= len(args) {
+			return "argument:"
+		}
+		return fmt.Sprintf("argument:%v", args[index])
+	}
+}
+
+// keyedMutex is a mutex for each key. A key's mutex exists while it is locked or waited for
+type keyedMutex struct {
+	mx    sync.Mutex
+	locks map[string]*keyedLock
+}
+
+type keyedLock struct {
+	sync.Mutex
+	refs int
+}
+
+func (k *keyedMutex) lock(key string) func() {
+	k.mx.Lock()
+	if k.locks == nil {
+		k.locks = make(map[string]*keyedLock)
+	}
+	l, ok := k.locks[key]
+	if !ok {
+		l = &keyedLock{}
+		k.locks[key] = l
+	}
+	l.refs++
+	k.mx.Unlock()
+	l.Lock()
+	return func() {
+		l.Unlock()
+		k.mx.Lock()
+		l.refs--
+		if l.refs == 0 {
+			delete(k.locks, key)
+		}
+		k.mx.Unlock()
+	}
+}
+
+// callMethod calls a hub method. If the hub orders the method, the call waits until
+// no other invocation with the same OrderingKey runs
+func (s *Server) callMethod(hubInfo *hubInfo, invocation InvocationMessage, method *hubMethod, in []reflect.Value, connectionContext ConnectionContext) []reflect.Value {
+	if key, ok := hubInfo.ordering[strings.ToLower(invocation.Target)]; ok {
+		args := make([]interface{}, 0, len(in))
+		for i, arg := range in {
+			if kind := method.params[i].kind; kind != connectionContextParameter && kind != contextParameter {
+				args = append(args, arg.Interface())
+			}
+		}
+		unlock := s.orderingLocks.lock(key(connectionContext, args))
+		defer unlock()
+	}
+	counters, started, failed := s.methodCounters(hubInfo, invocation.Target), s.clock.Now(), true
+	// A panic is recorded as failed call
+	defer func() { counters.record(s.clock.Now().Sub(started), failed) }()
+	result := method.call(in)
+	_, hubErr := splitHubError(result)
+	failed = hubErr != nil
+	return result
+}
@@ -0,0 +1,69 @@
+package signalr
+
+import (
+	"sync/atomic"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type orderedHub struct {
+	Hub
+	inside    int32
+	maxInside int32
+}
+
+func (o *orderedHub) OrderedMethods() map[string]OrderingKey {
+	return map[string]OrderingKey{"Enter": OrderedByArgument(0)}
+}
+
+func (o *orderedHub) Ready() {}
+
+func (o *orderedHub) Enter(room string) {
+	inside := atomic.AddInt32(&o.inside, 1)
+	defer atomic.AddInt32(&o.inside, -1)
+	for {
+		max := atomic.LoadInt32(&o.maxInside)
+		if inside <= max || atomic.CompareAndSwapInt32(&o.maxInside, max, inside) {
+			break
+		}
+	}
+	time.Sleep(50 * time.Millisecond)
+}
+
+var _ = Describe("Method ordering", func() {
+
+	Describe("Hub with an ordered method", func() {
+		hub := &orderedHub{}
+		server := NewServer(hub)
+		enter := func(conn *testingConnection, room string) {
+			_, err := conn.clientSend(`{"type":1,"invocationId": "enter","target":"enter","arguments":["` + room + `"]}`)
+			Expect(err).To(BeNil())
+		}
+		Context("When two connections invoke the method with the same key", func() {
+			It("should run the invocations one after the other", func() {
+				a := connectUser(server, "a", "")
+				b := connectUser(server, "b", "")
+				go enter(a, "lobby")
+				go enter(b, "lobby")
+				Expect((<-a.received).(CompletionMessage).InvocationID).To(Equal("enter"))
+				Expect((<-b.received).(CompletionMessage).InvocationID).To(Equal("enter"))
+				Expect(atomic.LoadInt32(&hub.maxInside)).To(Equal(int32(1)))
+				go enter(a, "red")
+				go enter(b, "blue")
+				Expect((<-a.received).(CompletionMessage).InvocationID).To(Equal("enter"))
+				Expect((<-b.received).(CompletionMessage).InvocationID).To(Equal("enter"))
+				Expect(atomic.LoadInt32(&hub.maxInside)).To(Equal(int32(2)))
+			})
+		})
+		Context("When a client invokes OrderedMethods", func() {
+			It("should answer that the method does not exist", func() {
+				conn := connectUser(server, "c", "")
+				_, err := conn.clientSend(`{"type":1,"invocationId": "ordered","target":"orderedMethods"}`)
+				Expect(err).To(BeNil())
+				Expect((<-conn.received).(CompletionMessage).Error).To(Equal("Method does not exist"))
+			})
+		})
+	})
+})
@@ -0,0 +1,117 @@
+package signalr
+
+import (
+	"math"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// MethodStats are the execution statistics of a hub method, see Server.MethodStats
+type MethodStats struct {
+	// Calls is the number of invocations of the method
+	Calls int64 `json:"calls"`
+	// Errors is the number of invocations which returned an error or panicked
+	Errors int64 `json:"errors"`
+	// P50 and P99 are the median and the 99th percentile of the time the method took, to about 20%.
+	// Methods returning a channel are done when they return it
+	P50 time.Duration `json:"p50"`
+	P99 time.Duration `json:"p99"`
+	// BytesIn is the size of the invocations of the method received from clients
+	BytesIn int64 `json:"bytesIn"`
+}
+
+// latencyBuckets is the number of buckets of the latency histogram of a method. Bucket i counts the calls which took
+// up to 2^(i/4) microseconds, the last one the longer calls
+const latencyBuckets = 121
+
+// methodCounters are the counters of a hub method. They are updated with atomic operations, by all connections
+type methodCounters struct {
+	calls     int64
+	errors    int64
+	bytesIn   int64
+	latencies [latencyBuckets]int64
+}
+
+// methodCounters returns the counters of the method target of hubInfo, nil if it has no such method
+func (s *Server) methodCounters(hubInfo *hubInfo, target string) *methodCounters {
+	name := strings.ToLower(target)
+	if _, ok := hubInfo.methods[name]; !ok {
+		if _, ok := hubInfo.funcs[name]; !ok {
+			return nil
+		}
+	}
+	if counters, ok := s.methodStats.Load(name); ok {
+		return counters.(*methodCounters)
+	}
+	counters, _ := s.methodStats.LoadOrStore(name, &methodCounters{})
+	return counters.(*methodCounters)
+}
+
+// received counts a received invocation of size bytes
+func (m *methodCounters) received(size int) {
+	if m != nil {
+		atomic.AddInt64(&m.bytesIn, int64(size))
+	}
+}
+
+// record counts a call of the duration took
+func (m *methodCounters) record(took time.Duration, failed bool) {
+	if m == nil {
+		return
+	}
+	atomic.AddInt64(&m.calls, 1)
+	if failed {
+		atomic.AddInt64(&m.errors, 1)
+	}
+	bucket := 0
+	if micros := float64(took) / float64(time.Microsecond); micros > 1 {
+		bucket = int(math.Ceil(4 * math.Log2(micros)))
+		if bucket >= latencyBuckets {
+			bucket = latencyBuckets - 1
+		}
+	}
+	atomic.AddInt64(&m.latencies[bucket], 1)
+}
+
+func (m *methodCounters) stats() MethodStats {
+	stats := MethodStats{
+		Calls:   atomic.LoadInt64(&m.calls),
+		Errors:  atomic.LoadInt64(&m.errors),
+		BytesIn: atomic.LoadInt64(&m.bytesIn),
+	}
+	var latencies [latencyBuckets]int64
+	total := int64(0)
+	for i := range latencies {
+		latencies[i] = atomic.LoadInt64(&m.latencies[i])
+		total += latencies[i]
+	}
+	stats.P50 = percentile(latencies[:], total, 0.5)
+	stats.P99 = percentile(latencies[:], total, 0.99)
+	return stats
+}
+
+// percentile returns the upper bound of the bucket of latencies containing the q quantile of total calls
+func percentile(latencies []int64, total int64, q float64) time.Duration {
+	if total == 0 {
+		return 0
+	}
+	rank := int64(math.Ceil(q * float64(total)))
+	counted := int64(0)
+	for i, count := range latencies {
+		if counted += count; counted >= rank {
+			return time.Duration(math.Pow(2, float64(i)/4) * float64(time.Microsecond))
+		}
+	}
+	return 0
+}
+
+// MethodStats returns the execution statistics of the hub methods which have been invoked, by lower case method name
+func (s *Server) MethodStats() map[string]MethodStats {
+	methods := make(map[string]MethodStats)
+	s.methodStats.Range(func(name, counters interface{}) bool {
+		methods[name.(string)] = counters.(*methodCounters).stats()
+		return true
+	})
+	return methods
+}
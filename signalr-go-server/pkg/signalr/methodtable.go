@@ -0,0 +1,131 @@
+package signalr
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// MethodTable is a hub with methods registered explicitly by Register instead of the methods of the hub type.
+// Only registered methods can be invoked by clients. The table is built once, when the methods are registered,
+// methods which do not fit are rejected at registration. Embed MethodTable in a hub to use it:
+//
+//	type chat struct {
+//		*signalr.MethodTable
+//	}
+//
+//	hub := &chat{signalr.NewMethodTable()}
+//	hub.Register("send", func(ctx signalr.ConnectionContext, user string, message string) error {...})
+type MethodTable struct {
+	Hub
+	methods map[string]*hubMethod
+	funcs   map[string]HubMethodFunc
+}
+
+// HubMethodFunc is a hub method which is called without reflection. It unmarshals its arguments itself.
+// The result is sent to the client as result of the invocation, an error as for reflected hub methods.
+// A HubMethodFunc can not receive client streams, and it can not return a chan to stream its results
+type HubMethodFunc func(ctx ConnectionContext, args HubArguments) (interface{}, error)
+
+// HubArguments are the arguments of an invocation of a HubMethodFunc
+type HubArguments struct {
+	invocation InvocationMessage
+	protocol   HubProtocol
+}
+
+// Len returns the number of arguments sent by the client
+func (a HubArguments) Len() int {
+	return len(a.invocation.Arguments)
+}
+
+// Get unmarshals argument i into value, which must be a pointer
+func (a HubArguments) Get(i int, value interface{}) error {
+	if i < 0 || i >= len(a.invocation.Arguments) {
+		return fmt.Errorf("method %s has no argument %v, the client sent %v arguments", a.invocation.Target, i, len(a.invocation.Arguments))
+	}
+	return a.protocol.UnmarshalArgument(a.invocation.Arguments[i], value)
+}
+
+// NewMethodTable creates an empty MethodTable
+func NewMethodTable() *MethodTable {
+	return &MethodTable{
+		methods: make(map[string]*hubMethod),
+		funcs:   make(map[string]HubMethodFunc),
+	}
+}
+
+// Register registers method as the hub method name. Method names are case insensitive.
+// A HubMethodFunc is called directly, any other func follows the rules of the methods of a hub, see ValidateHub.
+// Register panics if method is no func or does not follow the rules, or if name has already been registered
+func (t *MethodTable) Register(name string, method interface{}) *MethodTable {
+	key := strings.ToLower(name)
+	if _, ok := t.methods[key]; ok {
+		panic(fmt.Sprintf("signalr: hub method %v registered twice", name))
+	}
+	if _, ok := t.funcs[key]; ok {
+		panic(fmt.Sprintf("signalr: hub method %v registered twice", name))
+	}
+	switch method := method.(type) {
+	case HubMethodFunc:
+		t.funcs[key] = method
+	case func(ctx ConnectionContext, args HubArguments) (interface{}, error):
+		t.funcs[key] = method
+	default:
+		value := reflect.ValueOf(method)
+		if value.Kind() != reflect.Func {
+			panic(fmt.Sprintf("signalr: hub method %v is no func but %T", name, method))
+		}
+		if err := validateHubMethod(value.Type(), 0); err != nil {
+			panic(fmt.Sprintf("signalr: hub method %v: %v", name, err))
+		}
+		t.methods[key] = newHubMethod(value)
+	}
+	return t
+}
+
+// methodTable returns the table. It is promoted to hubs embedding MethodTable
+func (t *MethodTable) methodTable() *MethodTable {
+	return t
+}
+
+// invokeFunc calls a HubMethodFunc and sends its result to the client
+func (s *Server) invokeFunc(hubInfo *hubInfo, conn hubConnection, invocation InvocationMessage, fn HubMethodFunc,
+	protocol HubProtocol, connectionContext ConnectionContext) {
+	if len(invocation.StreamIds) > 0 {
+		conn.Completion(invocation.InvocationID, nil, fmt.Sprintf("method %s can not receive client streams", invocation.Target))
+		return
+	}
+	counters, started := s.methodCounters(hubInfo, invocation.Target), s.clock.Now()
+	result, err := func() (result interface{}, err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("%v", r)
+			}
+			counters.record(s.clock.Now().Sub(started), err != nil)
+		}()
+		if key, ok := hubInfo.ordering[strings.ToLower(invocation.Target)]; ok {
+			// The key gets the arguments as sent by the client, they have not been unmarshaled
+			unlock := s.orderingLocks.lock(key(connectionContext, invocation.Arguments))
+			defer unlock()
+		}
+		return fn(connectionContext, HubArguments{invocation: invocation, protocol: protocol})
+	}()
+	if err != nil {
+		hubErr := toHubError(err)
+		if hubErr.Internal != nil {
+			_ = s.logger.Log("connection", conn.GetConnectionID(), "event", "invocation failed", "invocation", invocation.InvocationID,
+				"target", invocation.Target, "error", hubErr.Internal)
+		}
+		conn.Completion(invocation.InvocationID, nil, hubErr.clientError())
+		return
+	}
+	switch invocation.Type {
+	// Simple invocation
+	case 1:
+		conn.Completion(invocation.InvocationID, result, "")
+	// Stream invocation, the result is the single stream item
+	case 4:
+		conn.StreamItem(invocation.InvocationID, result)
+		conn.Completion(invocation.InvocationID, nil, "")
+	}
+}
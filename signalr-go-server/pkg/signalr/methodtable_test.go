@@ -0,0 +1,89 @@
+package signalr
+
+import (
+	"errors"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type tableHub struct {
+	*MethodTable
+}
+
+func newTableHub() *tableHub {
+	hub := &tableHub{NewMethodTable()}
+	hub.Register("Concat", func(ctx ConnectionContext, a string, b string) (string, error) {
+		if a == "" {
+			return "", errors.New("a is missing")
+		}
+		return a + b + " for " + ctx.ConnectionID(), nil
+	})
+	hub.Register("Repeat", HubMethodFunc(func(ctx ConnectionContext, args HubArguments) (interface{}, error) {
+		var s string
+		var n int
+		if err := args.Get(0, &s); err != nil {
+			return nil, err
+		}
+		if err := args.Get(1, &n); err != nil {
+			return nil, err
+		}
+		return strings.Repeat(s, n), nil
+	}))
+	return hub
+}
+
+var _ = Describe("MethodTable", func() {
+
+	Describe("Invocation of registered methods", func() {
+		conn := connect(newTableHub())
+		Context("When a registered func is invoked", func() {
+			It("should bind the arguments and return the result", func() {
+				_, err := conn.clientSend(`{"type":1,"invocationId": "concat","target":"concat","arguments":["a","b"]}`)
+				Expect(err).To(BeNil())
+				Expect((<-conn.received).(CompletionMessage).Result).To(Equal("ab for " + conn.ConnectionID()))
+			})
+			It("should send the error it returns", func() {
+				_, err := conn.clientSend(`{"type":1,"invocationId": "missing","target":"concat","arguments":["","b"]}`)
+				Expect(err).To(BeNil())
+				Expect((<-conn.received).(CompletionMessage).Error).To(Equal("a is missing"))
+			})
+		})
+		Context("When a HubMethodFunc is invoked", func() {
+			It("should get the arguments and return the result", func() {
+				_, err := conn.clientSend(`{"type":1,"invocationId": "repeat","target":"repeat","arguments":["ab",2]}`)
+				Expect(err).To(BeNil())
+				Expect((<-conn.received).(CompletionMessage).Result).To(Equal("abab"))
+			})
+			It("should send the error of a missing argument", func() {
+				_, err := conn.clientSend(`{"type":1,"invocationId": "short","target":"repeat","arguments":["ab"]}`)
+				Expect(err).To(BeNil())
+				Expect((<-conn.received).(CompletionMessage).Error).To(ContainSubstring("has no argument 1"))
+			})
+		})
+		Context("When a method of the hub type which has not been registered is invoked", func() {
+			It("should send an unknown method error", func() {
+				_, err := conn.clientSend(`{"type":1,"invocationId": "register","target":"register","arguments":[]}`)
+				Expect(err).To(BeNil())
+				Expect((<-conn.received).(CompletionMessage).Error).To(Equal("Method does not exist"))
+			})
+		})
+	})
+
+	Describe("Registration of methods", func() {
+		Context("When a method can not be bound", func() {
+			It("should panic", func() {
+				table := NewMethodTable()
+				Expect(func() { table.Register("notafunc", 42) }).To(Panic())
+				Expect(func() { table.Register("wrong", func(value string, ctx ConnectionContext) {}) }).To(Panic())
+			})
+		})
+		Context("When a method name is registered twice", func() {
+			It("should panic", func() {
+				table := NewMethodTable().Register("send", func() {})
+				Expect(func() { table.Register("Send", func() {}) }).To(Panic())
+			})
+		})
+	})
+})
@@ -0,0 +1,284 @@
+package signalr
+
+import (
+	"net/http"
+	"runtime"
+	"time"
+)
+
+// Option is a function which configures a Server
+type Option func(s *Server)
+
+// WriteTimeout sets the time in which a message has to be written to the transport connection.
+// When the time is exceeded, the connection is closed. Only transports supporting
+// write deadlines, e.g. WebSockets, apply the timeout. Default is 30 seconds, 0 disables the timeout
+func WriteTimeout(timeout time.Duration) Option {
+	return func(s *Server) {
+		s.writeTimeout = timeout
+	}
+}
+
+// NegotiateTimeout sets the time after which a connection ID issued by negotiate expires
+// when no transport connection claims it. Default is 30 seconds
+func NegotiateTimeout(timeout time.Duration) Option {
+	return func(s *Server) {
+		s.connections.negotiateTimeout = timeout
+	}
+}
+
+// AllowConnectionTakeover configures what happens when a transport connection presents a connection ID
+// which is already bound to a live transport connection. By default the new connection is rejected.
+// With takeover allowed, the old transport connection is closed and the new one replaces it.
+// Takeover is available for WebSockets connections
+func AllowConnectionTakeover(allow bool) Option {
+	return func(s *Server) {
+		s.connectionTakeover = allow
+	}
+}
+
+// OfferWebSocketsOverHTTP2 sets if negotiate requests made over HTTP/2 are offered the WebSockets transport.
+// WebSockets can only be upgraded from HTTP/1.1 requests. Browsers open a separate HTTP/1.1 connection for them,
+// but clients of a server which is only reachable over HTTP/2, e.g. h2c behind a proxy, can not upgrade.
+// Without the offer, they use Server-Sent Events or long polling, which work over HTTP/2.
+// By default, WebSockets are offered to HTTP/2 requests
+func OfferWebSocketsOverHTTP2(offer bool) Option {
+	return func(s *Server) {
+		s.webSocketsOverHTTP2 = offer
+	}
+}
+
+// ListAvailableMethods sets if the error sent to a client which invoked a method the hub does not have lists the
+// methods of the hub, which helps during development. By default, the error is "Method does not exist"
+func ListAvailableMethods(list bool) Option {
+	return func(s *Server) {
+		s.listAvailableMethods = list
+	}
+}
+
+// NegotiateRedirector decides if a negotiate request should be redirected to another endpoint,
+// e.g. a regional node or Azure SignalR. If redirect is true, the client is sent to url,
+// and accessToken, if not empty, is used by the client as bearer token for the new endpoint
+type NegotiateRedirector func(req *http.Request) (url string, accessToken string, redirect bool)
+
+// NegotiateRedirect sets a NegotiateRedirector which is called for each negotiate request
+func NegotiateRedirect(redirector NegotiateRedirector) Option {
+	return func(s *Server) {
+		s.negotiateRedirector = redirector
+	}
+}
+
+// NegotiateError rejects a negotiate request with StatusCode. Message is sent to the client as error of the negotiate response
+type NegotiateError struct {
+	StatusCode int
+	Message    string
+}
+
+func (n *NegotiateError) Error() string {
+	return n.Message
+}
+
+// NegotiateFilter is called for each negotiate request before a connection ID is issued.
+// When it returns an error, the request is rejected, e.g. because of capacity limits, maintenance or banned IPs.
+// A *NegotiateError selects the status code of the response, other errors are answered with 403.
+// WebSocket connections started without negotiate are filtered before the upgrade
+type NegotiateFilter func(req *http.Request) error
+
+// OnNegotiate sets a NegotiateFilter
+func OnNegotiate(filter NegotiateFilter) Option {
+	return func(s *Server) {
+		s.negotiateFilter = filter
+	}
+}
+
+// MaxConnections limits the number of concurrent connections of the server. Negotiate requests exceeding the limit
+// are answered with 429, transport connections exceeding it are closed before the handshake. Default is no limit
+func MaxConnections(max int) Option {
+	return func(s *Server) {
+		s.connections.maxConnections = max
+	}
+}
+
+// MaxConnectionsPerUser limits the number of concurrent connections of a user, as identified by IdentifyUser.
+// A connection exceeding the limit is closed after the handshake with an error. Default is no limit
+func MaxConnectionsPerUser(max int) Option {
+	return func(s *Server) {
+		s.connections.maxUserConnections = max
+	}
+}
+
+// MessageTTL sets the time in which an invocation without result must be written to a connection.
+// Invocations waiting longer, e.g. behind a stalled client or in the queue of a long polling connection,
+// are dropped instead of delivering stale updates late. Default is 0, invocations never expire
+func MessageTTL(ttl time.Duration) Option {
+	return func(s *Server) {
+		s.messageTTL = ttl
+	}
+}
+
+// MaximumReceiveMessageSize sets the maximum number of bytes of a message received from a client. A client sending
+// a larger message is disconnected with a protocol error, so it can not make the server buffer unbounded data.
+// Default is 1MB, 0 means no limit
+func MaximumReceiveMessageSize(size int) Option {
+	return func(s *Server) {
+		s.maxMessageSize = size
+	}
+}
+
+// StreamBufferSize sets the number of bytes queued for a connection at which streams to it pause, until the client
+// has received enough of them. WebSocket and Server-Sent Events connections write to the network, a slow client
+// blocks the writes and with them the stream. Long polling connections queue the messages until the client polls.
+// While a stream is paused, the hub method producing it blocks in sending to the returned chan, so a fast producer
+// can not fill the memory of the server with the items for a slow client. Default is 1MB, 0 means no limit
+func StreamBufferSize(size int) Option {
+	return func(s *Server) {
+		s.streamBufferSize = size
+	}
+}
+
+// LongPollingMaxResponseSize sets the maximum number of bytes of the messages sent in one long polling response.
+// A single message larger than this is sent alone, in chunks which are flushed separately. Default is 64K
+func LongPollingMaxResponseSize(size int) Option {
+	return func(s *Server) {
+		s.longPollingMaxResponseSize = size
+	}
+}
+
+// ConnectionHeaders sets the names of the request headers which are kept as metadata of a connection,
+// available to hub methods by ConnectionContext.Header(). The headers are taken from the negotiate request
+// and the transport request of the connection. By default, no headers are kept
+func ConnectionHeaders(names ...string) Option {
+	return func(s *Server) {
+		s.connectionHeaders = names
+	}
+}
+
+// HubProtocols registers additional hub protocols with the server. Clients select one of them by
+// its name in the handshake. A protocol with the name of an already registered protocol replaces it
+func HubProtocols(protocols ...HubProtocol) Option {
+	return func(s *Server) {
+		for _, protocol := range protocols {
+			s.protocols[protocol.Name()] = protocol
+		}
+	}
+}
+
+// DrainWaves sets the number of waves in which Drain closes the connections of the server.
+// The waves are spread evenly over the drain window. Default is 10
+func DrainWaves(waves int) Option {
+	return func(s *Server) {
+		s.drainWaves = waves
+	}
+}
+
+// AddHealthCheck adds a named HealthCheck which is run by Healthy and the health and readiness handlers
+func AddHealthCheck(name string, check HealthCheck) Option {
+	return func(s *Server) {
+		s.healthChecks = append(s.healthChecks, namedHealthCheck{name: name, check: check})
+	}
+}
+
+// MaxGoroutines sets the number of goroutines of the process above which the server is reported unhealthy.
+// This detects goroutine leaks which wedge the server. Default is 0, which disables the check
+func MaxGoroutines(max int) Option {
+	return func(s *Server) {
+		s.maxGoroutines = max
+	}
+}
+
+// Ordering is the ordering guarantee of broadcasts to the connections of a server
+type Ordering int
+
+const (
+	// FIFOOrdering guarantees that the messages sent by one publisher, e.g. one goroutine using the HubContext,
+	// arrive at each connection in the order they were sent. A broadcast returns after it has been written to all
+	// connections, so a slow connection holds back the publisher
+	FIFOOrdering Ordering = iota
+	// RelaxedOrdering writes a broadcast to each connection from its own goroutine and returns immediately.
+	// This gives the maximum throughput, but messages can arrive at a connection in any order
+	RelaxedOrdering
+)
+
+// BroadcastOrdering sets the ordering guarantee of broadcasts. Default is FIFOOrdering
+func BroadcastOrdering(ordering Ordering) Option {
+	return func(s *Server) {
+		s.ordering = ordering
+	}
+}
+
+// GroupFanOut configures the parallel fan-out of invocations of large groups, see FanOutLargeGroups.
+// A zero Threshold is 1000 members, a zero Parallelism is the number of CPUs
+type GroupFanOut struct {
+	// Threshold is the number of members above which the members of a group are written to in parallel
+	Threshold int
+	// Parallelism is the number of goroutines writing to the members of a large group
+	Parallelism int
+}
+
+// FanOutLargeGroups writes invocations of groups with more than Threshold members from Parallelism goroutines, each
+// writing to one shard of the members, e.g. for an "all users" group of 200k members. With FIFOOrdering, the
+// members of a group are otherwise written to one after the other by the publisher. The invocation returns when all
+// shards have been written, so the invocations of one publisher still arrive in order. RelaxedOrdering writes to
+// each member from its own goroutine anyway. By default, groups are written to by the publisher
+func FanOutLargeGroups(fanOut GroupFanOut) Option {
+	return func(s *Server) {
+		if fanOut.Threshold <= 0 {
+			fanOut.Threshold = defaultFanOutThreshold
+		}
+		if fanOut.Parallelism <= 0 {
+			fanOut.Parallelism = runtime.NumCPU()
+		}
+		s.groupFanOut = &fanOut
+	}
+}
+
+const defaultFanOutThreshold = 1000
+
+// UserIDProvider returns the ID of the user of a connection. The UserID() of ctx is not set yet when it is called.
+// Connections with the same user ID receive the messages sent to this user. An empty ID means the connection has no user
+type UserIDProvider func(ctx ConnectionContext) string
+
+// NotifyGroupMembers sends the GroupNotifications to the members of a group when a connection joins or leaves it
+func NotifyGroupMembers(notifications GroupNotifications) Option {
+	return func(s *Server) {
+		s.groupNotifications = &notifications
+	}
+}
+
+// IdentifyUser sets the UserIDProvider which is called for each connection after the handshake.
+// By default, connections have no user
+func IdentifyUser(provider UserIDProvider) Option {
+	return func(s *Server) {
+		s.userIDProvider = provider
+	}
+}
+
+// ClientResultTimeout sets the time InvokeClientWithAck waits for the client before it returns ErrTimeout.
+// The context passed to InvokeClientWithAck can end the wait earlier. Default is 30 seconds, 0 means no limit
+func ClientResultTimeout(timeout time.Duration) Option {
+	return func(s *Server) {
+		s.clientResultTimeout = timeout
+	}
+}
+
+// SlowConsumer sets the SlowConsumerPolicy of the server. By default, slow connections are neither detected nor evicted
+func SlowConsumer(policy SlowConsumerPolicy) Option {
+	return func(s *Server) {
+		s.slowConsumer = &policy
+	}
+}
+
+// UseClock sets the Clock of the server, which times the keep alive pings, the expiry of negotiated connection IDs,
+// the long polling timeouts and the drain waves. Write deadlines of transports always use the system time.
+// Tests can use signalrtest.FakeClock to control time. Default is the system time
+func UseClock(clock Clock) Option {
+	return func(s *Server) {
+		s.clock = clock
+	}
+}
+
+// ConnectionReadModel sets the ReadModel of the server. Default is GoroutinePerConnection
+func ConnectionReadModel(model ReadModel) Option {
+	return func(s *Server) {
+		s.readModel = model
+	}
+}
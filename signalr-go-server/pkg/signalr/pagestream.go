@@ -0,0 +1,73 @@
+package signalr
+
+import (
+	"context"
+	"sync"
+)
+
+// PageFunc fetches the page of a paged data source which starts at cursor, the first page starts at the empty cursor.
+// It returns the items of the page and the cursor of the next page. An empty next cursor means the page is the last one
+type PageFunc func(ctx context.Context, cursor string) (items []interface{}, next string, err error)
+
+// PageStream streams the items of a paged data source to the client, e.g. the rows of a large query result.
+// A hub method returns Stream(). The next page is fetched when the client has received the items of the current one,
+// so only one page is held in memory. When the stream has ended, Err returns why
+type PageStream struct {
+	items chan interface{}
+	mx    sync.Mutex
+	err   error
+}
+
+// NewPageStream creates a PageStream which fetches the pages with fetch. Fetching stops when ctx is done,
+// e.g. with the context.Context parameter of the hub method when the connection has ended
+func NewPageStream(ctx context.Context, fetch PageFunc) *PageStream {
+	p := &PageStream{items: make(chan interface{})}
+	go p.run(ctx, fetch)
+	return p
+}
+
+// Stream returns the items of the stream, to be returned by the hub method
+func (p *PageStream) Stream() <-chan interface{} {
+	return p.items
+}
+
+// Err returns the error of fetch or ctx which ended the stream, or nil if the stream is not ended or ended with the last page
+func (p *PageStream) Err() error {
+	p.mx.Lock()
+	defer p.mx.Unlock()
+	return p.err
+}
+
+func (p *PageStream) run(ctx context.Context, fetch PageFunc) {
+	defer close(p.items)
+	cursor := ""
+	for {
+		items, next, err := fetch(ctx, cursor)
+		if err != nil {
+			p.fail(err)
+			return
+		}
+		for _, item := range items {
+			select {
+			case p.items <- item:
+			case <-ctx.Done():
+				p.fail(ctx.Err())
+				return
+			}
+		}
+		if next == "" {
+			return
+		}
+		if err = ctx.Err(); err != nil {
+			p.fail(err)
+			return
+		}
+		cursor = next
+	}
+}
+
+func (p *PageStream) fail(err error) {
+	p.mx.Lock()
+	defer p.mx.Unlock()
+	p.err = err
+}
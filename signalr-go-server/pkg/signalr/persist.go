@@ -0,0 +1,57 @@
+package signalr
+
+import (
+	"sort"
+	"time"
+)
+
+// ConnectionSnapshot is the state of a connection when it ended, as passed to a PersistFunc
+type ConnectionSnapshot struct {
+	ConnectionID string
+	UserID       string
+	Tenant       string
+	// Groups and Tags are the groups and tags the connection had, sorted
+	Groups []string
+	Tags   []string
+	// Features are the features of the connection, published by its transport or attached by middleware or the hub
+	Features  map[string]interface{}
+	Connected time.Time
+	Duration  time.Duration
+	Reason    DisconnectReason
+	// Stats are the messages and bytes the connection received and sent
+	Stats ConnectionStats
+}
+
+// PersistFunc receives the snapshot of each connection which ended, e.g. to write a session record to a database
+type PersistFunc func(ctx ConnectionContext, snapshot ConnectionSnapshot)
+
+// OnDisconnectPersist sets the PersistFunc of the server. It is called for each connection which ended, after
+// OnDisconnected of the hub and before the connection leaves its groups, so the application needs no state of its
+// own keyed by connection ID. It runs on the goroutine of the connection, slow writes delay its cleanup
+func OnDisconnectPersist(persist PersistFunc) Option {
+	return func(s *Server) {
+		s.persist = persist
+	}
+}
+
+// snapshot returns the snapshot of the ended connection hubConn
+func (s *Server) snapshot(live *liveConnection, ctx ConnectionContext, lifetimeManager *defaultHubLifetimeManager, hubConn hubConnection, reason DisconnectReason) ConnectionSnapshot {
+	features := make(map[string]interface{})
+	for _, name := range hubConn.Features().Names() {
+		features[name], _ = hubConn.Features().Get(name)
+	}
+	tags := lifetimeManager.ConnectionTags(hubConn.GetConnectionID())
+	sort.Strings(tags)
+	return ConnectionSnapshot{
+		ConnectionID: hubConn.GetConnectionID(),
+		UserID:       hubConn.GetUserID(),
+		Tenant:       ctx.Tenant(),
+		Groups:       lifetimeManager.groups.groupsOf(hubConn.GetConnectionID()),
+		Tags:         tags,
+		Features:     features,
+		Connected:    live.connected,
+		Duration:     s.clock.Now().Sub(live.connected),
+		Reason:       reason,
+		Stats:        hubConn.Stats(),
+	}
+}
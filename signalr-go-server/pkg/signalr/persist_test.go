@@ -0,0 +1,44 @@
+package signalr
+
+import (
+	"io"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type persistHub struct {
+	Hub
+}
+
+func (p *persistHub) Ready() {}
+
+var _ = Describe("OnDisconnectPersist", func() {
+
+	Describe("Server with a PersistFunc", func() {
+		snapshots := make(chan ConnectionSnapshot, 1)
+		server := NewServer(&persistHub{}, IdentifyUser(func(ctx ConnectionContext) string {
+			return ctx.Query().Get("user")
+		}), OnDisconnectPersist(func(ctx ConnectionContext, snapshot ConnectionSnapshot) {
+			snapshots <- snapshot
+		}))
+		Context("When a connection ends", func() {
+			It("should pass the snapshot of the connection with its groups, tags and features", func() {
+				conn := connectUser(server, "p", "paula")
+				Expect(server.HubContext().Groups().AddToGroup("team", "p")).To(Succeed())
+				Expect(server.HubContext().Groups().AddToGroup("admins", "p")).To(Succeed())
+				Expect(server.HubContext().Tags().TagConnection("p", "beta")).To(Succeed())
+				Expect(conn.cliWriter.(io.Closer).Close()).To(Succeed())
+				snapshot := <-snapshots
+				Expect(snapshot.ConnectionID).To(Equal("p"))
+				Expect(snapshot.UserID).To(Equal("paula"))
+				Expect(snapshot.Groups).To(Equal([]string{"admins", "team"}))
+				Expect(snapshot.Tags).To(Equal([]string{"beta"}))
+				Expect(snapshot.Features).To(HaveKey(FeatureClientIP))
+				Expect(snapshot.Stats.MessagesIn).To(Equal(int64(1)))
+				Expect(snapshot.Duration).To(BeNumerically(">", 0))
+				Expect(snapshot.Reason).To(Equal(DisconnectTransportError))
+			})
+		})
+	})
+})
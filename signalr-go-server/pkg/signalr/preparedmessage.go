@@ -0,0 +1,71 @@
+package signalr
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// preparedMessage is a message which is sent to many connections.
+// It is serialized only once for each protocol used by the connections
+type preparedMessage struct {
+	message interface{}
+	mx      sync.Mutex
+	encoded map[string]encodedMessage
+}
+
+type encodedMessage struct {
+	data []byte
+	err  error
+}
+
+func newPreparedInvocation(target string, args []interface{}) *preparedMessage {
+	return &preparedMessage{
+		message: InvocationMessage{
+			Type:      1,
+			Target:    target,
+			Arguments: args,
+		},
+		encoded: make(map[string]encodedMessage),
+	}
+}
+
+// encode returns the message serialized by protocol. When it can not be serialized,
+// the error is kept, so the connections using protocol are skipped without trying again
+func (p *preparedMessage) encode(protocol HubProtocol) ([]byte, error) {
+	p.mx.Lock()
+	defer p.mx.Unlock()
+	if encoded, ok := p.encoded[protocol.Name()]; ok {
+		return encoded.data, encoded.err
+	}
+	var buf bytes.Buffer
+	err := encodeMessage(protocol, p.message, &buf)
+	p.encoded[protocol.Name()] = encodedMessage{data: buf.Bytes(), err: err}
+	return buf.Bytes(), err
+}
+
+// encodeMessage writes message serialized by protocol to w. A panic of the protocol, e.g. in the MarshalJSON method
+// of an argument, is returned as error, so it does not abort a broadcast to the other connections
+func encodeMessage(protocol HubProtocol, message interface{}, w io.Writer) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v protocol panicked: %v", protocol.Name(), r)
+		}
+	}()
+	return protocol.WriteMessage(message, w)
+}
+
+// serializationError is returned by writeMessage when the protocol of the connection could not serialize the message.
+// Nothing has been written then, the connection can go on with the next message
+type serializationError struct {
+	err error
+}
+
+func (s *serializationError) Error() string {
+	return fmt.Sprintf("cannot serialize message: %v", s.err)
+}
+
+func (s *serializationError) Unwrap() error {
+	return s.err
+}
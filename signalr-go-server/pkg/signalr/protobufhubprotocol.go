@@ -0,0 +1,99 @@
+package signalr
+
+import (
+	"io"
+	"reflect"
+
+	"github.com/fxamacker/cbor/v2"
+	"google.golang.org/protobuf/proto"
+)
+
+// ProtobufHubProtocol is the CborHubProtocol for teams which define their payloads as protobuf schemas.
+// Arguments, stream items and results of the registered message types are carried as CBOR byte strings
+// holding their protobuf binary encoding, all other values as CBOR. Hub methods take registered types
+// as parameters directly, e.g.
+//
+//	func (h *OrderHub) Update(update *pb.OrderUpdate)
+//
+// Register it with HubProtocols(NewProtobufHubProtocol(&pb.OrderUpdate{}, &pb.Order{}))
+type ProtobufHubProtocol struct {
+	CborHubProtocol
+	// types are the registered message types, e.g. *pb.OrderUpdate
+	types map[reflect.Type]bool
+}
+
+// NewProtobufHubProtocol returns a ProtobufHubProtocol for the generated types of messages
+func NewProtobufHubProtocol(messages ...proto.Message) *ProtobufHubProtocol {
+	p := &ProtobufHubProtocol{types: make(map[reflect.Type]bool)}
+	for _, message := range messages {
+		p.types[reflect.TypeOf(message)] = true
+	}
+	return p
+}
+
+// Name returns "protobuf"
+func (p *ProtobufHubProtocol) Name() string {
+	return "protobuf"
+}
+
+// UnmarshalArgument decodes arguments into values of the registered types, i.e. a pointer to a registered type or
+// the message itself, with proto.Unmarshal and all others like the CborHubProtocol
+func (p *ProtobufHubProtocol) UnmarshalArgument(argument interface{}, value interface{}) error {
+	target := reflect.ValueOf(value)
+	switch {
+	case target.Kind() == reflect.Ptr && p.types[target.Type().Elem()]:
+		message := reflect.New(target.Type().Elem().Elem())
+		if err := p.unmarshalMessage(argument, message.Interface().(proto.Message)); err != nil {
+			return err
+		}
+		target.Elem().Set(message)
+		return nil
+	case p.types[target.Type()]:
+		return p.unmarshalMessage(argument, value.(proto.Message))
+	default:
+		return p.CborHubProtocol.UnmarshalArgument(argument, value)
+	}
+}
+
+func (p *ProtobufHubProtocol) unmarshalMessage(argument interface{}, message proto.Message) error {
+	var data []byte
+	if err := cbor.Unmarshal(argument.(cbor.RawMessage), &data); err != nil {
+		return err
+	}
+	return proto.Unmarshal(data, message)
+}
+
+func (p *ProtobufHubProtocol) WriteMessage(message interface{}, writer io.Writer) error {
+	var err error
+	switch m := message.(type) {
+	case InvocationMessage:
+		arguments := make([]interface{}, len(m.Arguments))
+		for i, argument := range m.Arguments {
+			if arguments[i], err = p.marshalMessage(argument); err != nil {
+				return err
+			}
+		}
+		m.Arguments = arguments
+		message = m
+	case StreamItemMessage:
+		if m.Item, err = p.marshalMessage(m.Item); err != nil {
+			return err
+		}
+		message = m
+	case CompletionMessage:
+		if m.Result, err = p.marshalMessage(m.Result); err != nil {
+			return err
+		}
+		message = m
+	}
+	return p.CborHubProtocol.WriteMessage(message, writer)
+}
+
+// marshalMessage returns the protobuf encoding of values of the registered types, and all others unchanged
+func (p *ProtobufHubProtocol) marshalMessage(value interface{}) (interface{}, error) {
+	if message, ok := value.(proto.Message); ok && p.types[reflect.TypeOf(value)] {
+		data, err := proto.Marshal(message)
+		return data, err
+	}
+	return value, nil
+}
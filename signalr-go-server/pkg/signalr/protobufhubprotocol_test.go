@@ -0,0 +1,54 @@
+package signalr
+
+import (
+	"bytes"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ProtobufHubProtocol", func() {
+	protocol := NewProtobufHubProtocol(&wrapperspb.StringValue{})
+
+	roundtrip := func(message interface{}) interface{} {
+		var buf bytes.Buffer
+		Expect(protocol.WriteMessage(message, &buf)).To(Succeed())
+		read, complete, err := protocol.ReadMessage(&buf)
+		Expect(err).To(BeNil())
+		Expect(complete).To(BeTrue())
+		return read
+	}
+
+	Describe("Invocation", func() {
+		Context("When it has arguments of registered and other types", func() {
+			It("should decode the registered ones as protobuf messages and the others as CBOR", func() {
+				invocation := roundtrip(InvocationMessage{Type: 1, Target: "update", Arguments: []interface{}{wrapperspb.String("shipped"), 5}}).(InvocationMessage)
+				Expect(invocation.Arguments).To(HaveLen(2))
+				// Hub method parameters are unmarshaled into a pointer to the parameter type
+				var update *wrapperspb.StringValue
+				var i int
+				Expect(protocol.UnmarshalArgument(invocation.Arguments[0], &update)).To(Succeed())
+				Expect(protocol.UnmarshalArgument(invocation.Arguments[1], &i)).To(Succeed())
+				Expect(proto.Equal(update, wrapperspb.String("shipped"))).To(BeTrue())
+				Expect(i).To(Equal(5))
+				message := &wrapperspb.StringValue{}
+				Expect(protocol.UnmarshalArgument(invocation.Arguments[0], message)).To(Succeed())
+				Expect(message.GetValue()).To(Equal("shipped"))
+			})
+		})
+	})
+
+	Describe("Completion", func() {
+		Context("When its result is a registered type", func() {
+			It("should carry it as protobuf", func() {
+				completion := roundtrip(CompletionMessage{Type: 3, InvocationID: "1", Result: wrapperspb.String("done")}).(CompletionMessage)
+				data, err := proto.Marshal(wrapperspb.String("done"))
+				Expect(err).To(BeNil())
+				Expect(completion.Result).To(Equal(data))
+			})
+		})
+	})
+})
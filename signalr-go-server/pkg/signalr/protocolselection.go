@@ -0,0 +1,138 @@
+package signalr
+
+import (
+	"fmt"
+	"strings"
+)
+
+// How the hub protocol of a ProtocolSelection was selected
+const (
+	// SelectedBySubprotocol means the client offered the protocol as WebSocket subprotocol and sent no handshake
+	SelectedBySubprotocol = "subprotocol"
+	// SelectedByHandshake means the client requested the protocol with its handshake request
+	SelectedByHandshake = "handshake"
+)
+
+// The encodings of a ProtocolSelection, how the messages of the protocol are sent over the transport
+const (
+	EncodingText   = "Text"
+	EncodingBinary = "Binary"
+	// EncodingBase64 are binary messages sent as base64 text, by the Server-Sent Events transport
+	EncodingBase64 = "Base64"
+)
+
+// Reasons of a RejectedProtocol
+const (
+	// RejectedUnsupported means the server has no hub protocol with the name
+	RejectedUnsupported = "not supported"
+	// RejectedVersion means the client requested a newer version of the protocol than the server has
+	RejectedVersion = "version not supported"
+	// RejectedNotPreferred means the client offered another supported WebSocket subprotocol before this one
+	RejectedNotPreferred = "not preferred"
+	// RejectedByHandshakeHandler means the HandshakeFunc refused the connection
+	RejectedByHandshakeHandler = "refused by handshake handler"
+)
+
+// RejectedProtocol is a hub protocol a client asked for which was not selected, and why
+type RejectedProtocol struct {
+	Name string
+	// Version is the version the client requested, 0 for WebSocket subprotocols which have none
+	Version int
+	// Reason is RejectedUnsupported, RejectedVersion, RejectedNotPreferred or RejectedByHandshakeHandler
+	Reason string
+}
+
+// ProtocolSelection tells which hub protocol the negotiation of a connection selected, what the client asked for
+// and why the other protocols it asked for were rejected, e.g. to find out why a client is on JSON
+type ProtocolSelection struct {
+	ConnectionID string
+	// Transport is the transport of the connection, empty for connections without transport request
+	Transport string
+	// By is SelectedBySubprotocol or SelectedByHandshake
+	By string
+	// Requested are the names of the protocols the client asked for, the WebSocket subprotocols it offered
+	// and the protocol of its handshake request
+	Requested []string
+	// Protocol and Version are the selected hub protocol, Protocol is empty if the selection failed
+	Protocol string
+	Version  int
+	// Encoding is EncodingText, EncodingBinary or EncodingBase64, empty if the selection failed
+	Encoding string
+	Rejected []RejectedProtocol
+	// Error is why the selection failed, e.g. the handshake error sent to the client
+	Error string
+}
+
+// OnProtocolSelected sets a handler called for each connection when its hub protocol has been selected or the
+// selection failed. With or without a handler, selections are logged as debug events and failed selections as events,
+// with the requested and rejected protocols
+func OnProtocolSelected(handler func(selection ProtocolSelection)) Option {
+	return func(s *Server) {
+		s.protocolSelectionHandler = handler
+	}
+}
+
+// newProtocolSelection starts the ProtocolSelection of conn with the WebSocket subprotocols its client offered.
+// The first hub protocol among them is selected, see selectSubprotocol
+func (s *Server) newProtocolSelection(conn Connection) ProtocolSelection {
+	selection := ProtocolSelection{ConnectionID: conn.ConnectionID(), Transport: connectionTransport(conn), By: SelectedByHandshake}
+	if ws, ok := conn.(*webSocketConnection); ok {
+		selected := false
+		for _, name := range ws.offeredSubprotocols {
+			selection.Requested = append(selection.Requested, name)
+			if _, ok := s.protocols[name]; !ok {
+				selection.Rejected = append(selection.Rejected, RejectedProtocol{Name: name, Reason: RejectedUnsupported})
+			} else if selected {
+				selection.Rejected = append(selection.Rejected, RejectedProtocol{Name: name, Reason: RejectedNotPreferred})
+			} else {
+				selected = true
+			}
+		}
+	}
+	return selection
+}
+
+// connectionTransport returns the transport published by the transport request of conn, or "" if it has none
+func connectionTransport(conn Connection) string {
+	if metadata, ok := conn.(interface{ requestFeatures() map[string]interface{} }); ok {
+		if transport, ok := metadata.requestFeatures()[FeatureTransport].(string); ok {
+			return transport
+		}
+	}
+	return ""
+}
+
+// reportProtocolSelection completes selection with the protocol selected or the error of the selection, logs it
+// and calls the handler of OnProtocolSelected
+func (s *Server) reportProtocolSelection(selection ProtocolSelection, protocol HubProtocol, err error) {
+	if err == nil {
+		selection.Protocol, selection.Version = protocol.Name(), protocol.Version()
+		selection.Encoding = protocol.TransferFormat()
+		if selection.Encoding == EncodingBinary && selection.Transport == TransportServerSentEvents {
+			selection.Encoding = EncodingBase64
+		}
+		_ = s.debugLogger.Log("connection", selection.ConnectionID, "event", "protocol selected", "protocol", selection.Protocol,
+			"version", selection.Version, "encoding", selection.Encoding, "by", selection.By,
+			"requested", strings.Join(selection.Requested, ","), "rejected", formatRejected(selection.Rejected))
+	} else {
+		selection.Error = err.Error()
+		_ = s.logger.Log("connection", selection.ConnectionID, "event", "no protocol selected", "by", selection.By,
+			"requested", strings.Join(selection.Requested, ","), "rejected", formatRejected(selection.Rejected), "error", selection.Error)
+	}
+	if s.protocolSelectionHandler != nil {
+		s.protocolSelectionHandler(selection)
+	}
+}
+
+// formatRejected formats the rejected protocols for the log, like "messagepack 2: version not supported"
+func formatRejected(rejected []RejectedProtocol) string {
+	parts := make([]string, len(rejected))
+	for i, protocol := range rejected {
+		if protocol.Version > 0 {
+			parts[i] = fmt.Sprintf("%v %v: %v", protocol.Name, protocol.Version, protocol.Reason)
+		} else {
+			parts[i] = fmt.Sprintf("%v: %v", protocol.Name, protocol.Reason)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
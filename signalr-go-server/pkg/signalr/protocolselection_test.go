@@ -0,0 +1,67 @@
+package signalr
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"golang.org/x/net/websocket"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ProtocolSelection", func() {
+
+	Describe("WebSocket client offering several subprotocols", func() {
+		selections := make(chan ProtocolSelection, 1)
+		mux := http.NewServeMux()
+		MapHub(mux, "/hub", &contextHub{}, HubProtocols(&CborHubProtocol{}), OnProtocolSelected(func(selection ProtocolSelection) {
+			selections <- selection
+		}))
+		Context("When the client connects", func() {
+			It("should report the selected protocol and why the others were rejected", func() {
+				httpServer := httptest.NewServer(mux)
+				defer httpServer.Close()
+				config, err := websocket.NewConfig("ws"+strings.TrimPrefix(httpServer.URL, "http")+"/hub", httpServer.URL)
+				Expect(err).To(BeNil())
+				config.Protocol = []string{"mqtt", "cbor", "json"}
+				ws, err := websocket.DialConfig(config)
+				Expect(err).To(BeNil())
+				defer ws.Close()
+				selection := <-selections
+				Expect(selection.Transport).To(Equal(TransportWebSockets))
+				Expect(selection.By).To(Equal(SelectedBySubprotocol))
+				Expect(selection.Requested).To(Equal([]string{"mqtt", "cbor", "json"}))
+				Expect(selection.Protocol).To(Equal("cbor"))
+				Expect(selection.Version).To(Equal(1))
+				Expect(selection.Encoding).To(Equal(EncodingBinary))
+				Expect(selection.Rejected).To(Equal([]RejectedProtocol{
+					{Name: "mqtt", Reason: RejectedUnsupported},
+					{Name: "json", Reason: RejectedNotPreferred},
+				}))
+				Expect(selection.Error).To(BeEmpty())
+			})
+		})
+	})
+
+	Describe("Client requesting an unsupported version in its handshake", func() {
+		selections := make(chan ProtocolSelection, 1)
+		server := NewServer(&contextHub{}, OnProtocolSelected(func(selection ProtocolSelection) {
+			selections <- selection
+		}))
+		Context("When the handshake fails", func() {
+			It("should report the rejected protocol and the handshake error", func() {
+				conn := newTestingConnectionWithHandshake(`{"protocol":"json","version":2}`)
+				go server.Run(conn)
+				selection := <-selections
+				Expect(selection.By).To(Equal(SelectedByHandshake))
+				Expect(selection.Requested).To(Equal([]string{"json"}))
+				Expect(selection.Protocol).To(BeEmpty())
+				Expect(selection.Encoding).To(BeEmpty())
+				Expect(selection.Rejected).To(Equal([]RejectedProtocol{{Name: "json", Version: 2, Reason: RejectedVersion}}))
+				Expect(selection.Error).To(ContainSubstring("does not support version 2"))
+			})
+		})
+	})
+})
@@ -0,0 +1,85 @@
+package signalr
+
+import (
+	"encoding/json"
+	"reflect"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+// RawMessage is a value which has been serialized already, e.g. taken from a cache or received from another service.
+// It can be passed as argument of an invocation, returned as result of a hub method or sent as stream item.
+// Connections using the protocol it has been serialized with get its bytes as they are, without decoding and
+// encoding them again. Connections using another protocol get it converted.
+// A hub method parameter of type RawMessage gets the argument sent by the client without decoding it
+type RawMessage struct {
+	protocol string
+	data     []byte
+}
+
+// RawJSON returns a RawMessage with a value serialized as JSON
+func RawJSON(data []byte) RawMessage {
+	return RawMessage{protocol: "json", data: data}
+}
+
+// RawCBOR returns a RawMessage with a value serialized as CBOR
+func RawCBOR(data []byte) RawMessage {
+	return RawMessage{protocol: "cbor", data: data}
+}
+
+// Protocol returns the name of the hub protocol the value has been serialized with
+func (r RawMessage) Protocol() string {
+	return r.protocol
+}
+
+// Bytes returns the serialized value
+func (r RawMessage) Bytes() []byte {
+	return r.data
+}
+
+// cborDecMode decodes maps with string keys, so they can be encoded as JSON
+var cborDecMode, _ = cbor.DecOptions{DefaultMapType: reflect.TypeOf(map[string]interface{}(nil))}.DecMode()
+
+// MarshalJSON returns the bytes of a JSON RawMessage, other ones are converted
+func (r RawMessage) MarshalJSON() ([]byte, error) {
+	switch r.protocol {
+	case "json":
+		return r.data, nil
+	case "cbor":
+		var value interface{}
+		if err := cborDecMode.Unmarshal(r.data, &value); err != nil {
+			return nil, err
+		}
+		return json.Marshal(value)
+	default:
+		return []byte("null"), nil
+	}
+}
+
+// MarshalCBOR returns the bytes of a CBOR RawMessage, other ones are converted
+func (r RawMessage) MarshalCBOR() ([]byte, error) {
+	switch r.protocol {
+	case "cbor":
+		return r.data, nil
+	case "json":
+		var value interface{}
+		if err := json.Unmarshal(r.data, &value); err != nil {
+			return nil, err
+		}
+		return cbor.Marshal(value)
+	default:
+		return cbor.Marshal(nil)
+	}
+}
+
+// UnmarshalJSON keeps a copy of the JSON value
+func (r *RawMessage) UnmarshalJSON(data []byte) error {
+	*r = RawJSON(append([]byte(nil), data...))
+	return nil
+}
+
+// UnmarshalCBOR keeps a copy of the CBOR value
+func (r *RawMessage) UnmarshalCBOR(data []byte) error {
+	*r = RawCBOR(append([]byte(nil), data...))
+	return nil
+}
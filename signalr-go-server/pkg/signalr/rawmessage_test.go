@@ -0,0 +1,67 @@
+package signalr
+
+import (
+	"bytes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type rawHub struct {
+	Hub
+}
+
+func (r *rawHub) Cached() RawMessage {
+	return RawJSON([]byte(`{"name":"cached","items":[1,2]}`))
+}
+
+func (r *rawHub) Forward(raw RawMessage) []interface{} {
+	return []interface{}{raw.Protocol(), string(raw.Bytes())}
+}
+
+var _ = Describe("RawMessage", func() {
+
+	Describe("Hub with raw results and arguments", func() {
+		conn := connect(&rawHub{})
+		Context("When a hub method returns a RawMessage", func() {
+			It("should send the serialized value as result", func() {
+				_, err := conn.clientSend(`{"type":1,"invocationId": "cached","target":"cached"}`)
+				Expect(err).To(BeNil())
+				Expect((<-conn.received).(CompletionMessage).Result).To(Equal(map[string]interface{}{"name": "cached", "items": []interface{}{1.0, 2.0}}))
+			})
+		})
+		Context("When a hub method has a RawMessage parameter", func() {
+			It("should get the argument as sent by the client", func() {
+				_, err := conn.clientSend(`{"type":1,"invocationId": "forward","target":"forward","arguments":[{"b": [true]}]}`)
+				Expect(err).To(BeNil())
+				Expect((<-conn.received).(CompletionMessage).Result).To(Equal([]interface{}{"json", `{"b": [true]}`}))
+			})
+		})
+	})
+
+	Describe("Conversion between protocols", func() {
+		Context("When a JSON RawMessage is written with the CBOR protocol", func() {
+			It("should be converted to CBOR", func() {
+				protocol := &CborHubProtocol{}
+				var buf bytes.Buffer
+				Expect(protocol.WriteMessage(InvocationMessage{Type: 1, Target: "t", Arguments: []interface{}{RawJSON([]byte(`{"a":[1,"x"]}`))}}, &buf)).To(Succeed())
+				read, _, err := protocol.ReadMessage(&buf)
+				Expect(err).To(BeNil())
+				var value struct {
+					A []interface{} `cbor:"a"`
+				}
+				Expect(protocol.UnmarshalArgument(read.(InvocationMessage).Arguments[0], &value)).To(Succeed())
+				Expect(value.A).To(Equal([]interface{}{1.0, "x"}))
+			})
+		})
+		Context("When a CBOR RawMessage is written with the JSON protocol", func() {
+			It("should be converted to JSON", func() {
+				var buf bytes.Buffer
+				raw, err := RawJSON([]byte(`{"a":"b"}`)).MarshalCBOR()
+				Expect(err).To(BeNil())
+				Expect((&JsonHubProtocol{}).WriteMessage(CompletionMessage{Type: 3, InvocationID: "1", Result: RawCBOR(raw)}, &buf)).To(Succeed())
+				Expect(buf.String()).To(ContainSubstring(`"result":{"a":"b"}`))
+			})
+		})
+	})
+})
@@ -0,0 +1,99 @@
+package signalr
+
+import (
+	"fmt"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// userConnection is a testingConnection with its own connection ID and the user ID as query parameter
+type userConnection struct {
+	requestMetadata
+	*testingConnection
+	connectionID string
+}
+
+func (u *userConnection) ConnectionID() string {
+	return u.connectionID
+}
+
+func connectUser(server *Server, connectionID string, userID string) *testingConnection {
+	conn := newTestingConnection()
+	req := httptest.NewRequest("GET", "/hub?user="+userID, nil)
+	go server.Run(&userConnection{server.newRequestMetadata(req, nil), conn, connectionID})
+	_, err := conn.clientSend(fmt.Sprintf(`{"type":1,"invocationId": "%v","target":"ready"}`, connectionID))
+	Expect(err).To(BeNil())
+	Expect((<-conn.received).(CompletionMessage).InvocationID).To(Equal(connectionID))
+	return conn
+}
+
+func expectTargets(conn *testingConnection, targets ...string) {
+	for _, target := range targets {
+		Expect((<-conn.received).(InvocationMessage).Target).To(Equal(target))
+	}
+}
+
+var _ = Describe("Recipients", func() {
+
+	Describe("Send to computed sets of recipients", func() {
+		server := NewServer(&contextHub{}, IdentifyUser(func(ctx ConnectionContext) string {
+			return ctx.Query().Get("user")
+		}))
+		Context("When sending to connections, users and groups", func() {
+			It("should send each invocation once to each recipient", func() {
+				a := connectUser(server, "a", "alice")
+				b := connectUser(server, "b", "bob")
+				c := connectUser(server, "c", "alice")
+				server.HubContext().Groups().AddToGroup("g1", "a")
+				server.HubContext().Groups().AddToGroup("g1", "b")
+				server.HubContext().Groups().AddToGroup("g2", "b")
+				go func() {
+					clients := server.HubContext().Clients()
+					clients.Clients([]string{"a", "b", "a", "unknown"}).Send("clients")
+					clients.Users([]string{"alice"}).Send("users")
+					clients.Groups([]string{"g1", "g2"}).Send("groups")
+					clients.User("bob").Send("user")
+					clients.All().Send("end")
+				}()
+				done := make(chan bool)
+				go func() {
+					defer GinkgoRecover()
+					expectTargets(b, "clients", "groups", "user", "end")
+					done <- true
+				}()
+				go func() {
+					defer GinkgoRecover()
+					expectTargets(c, "users", "end")
+					done <- true
+				}()
+				expectTargets(a, "clients", "users", "groups", "end")
+				Eventually(done).Should(Receive())
+				Eventually(done).Should(Receive())
+			})
+		})
+	})
+	Describe("Clients subscribing to targets", func() {
+		server := NewServer(&contextHub{})
+		Context("When a broadcast has targets a client did not subscribe to", func() {
+			It("should send only the subscribed targets to the client", func() {
+				all := connectUser(server, "all", "")
+				subscriber := newTestingConnectionWithHandshake(`{"protocol":"json","version":1,"capabilities":{"targets":["scores"]}}`)
+				go server.Run(&userConnection{server.newRequestMetadata(httptest.NewRequest("GET", "/hub", nil), nil), subscriber, "subscriber"})
+				_, err := subscriber.clientSend(`{"type":1,"invocationId": "subscriber","target":"ready"}`)
+				Expect(err).To(BeNil())
+				Expect((<-subscriber.received).(CompletionMessage).InvocationID).To(Equal("subscriber"))
+				go func() {
+					clients := server.HubContext().Clients()
+					clients.All().Send("news")
+					clients.All().Send("scores")
+					clients.Client("subscriber").Send("weather")
+					clients.Client("subscriber").Send("scores")
+				}()
+				expectTargets(all, "news", "scores")
+				expectTargets(subscriber, "scores", "scores")
+			})
+		})
+	})
+})
@@ -0,0 +1,189 @@
+package signalr
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RecordedOperation is an operation of the lifetime manager of a server as recorded by RecordOperations
+type RecordedOperation struct {
+	Time time.Time `json:"time"`
+	// Operation is the name of the HubLifetimeManager method, e.g. "InvokeGroup"
+	Operation string `json:"operation"`
+	Tenant    string `json:"tenant"`
+	// Recipients are the connection IDs, user IDs, group names or tag expression the operation addressed
+	Recipients string `json:"recipients"`
+	Target     string `json:"target,omitempty"`
+	// Size is the size of the arguments of an invocation as JSON
+	Size     int           `json:"size,omitempty"`
+	Duration time.Duration `json:"duration"`
+	Error    string        `json:"error,omitempty"`
+}
+
+// RecordOperations records the last size invocations and group and tag changes of the server, to find out
+// in production what the server sent to whom and how long it took, see RecordedOperations.
+// Recording does not change what the operations do. By default, no operations are recorded
+func RecordOperations(size int) Option {
+	return func(s *Server) {
+		if size > 0 {
+			s.operations = &operationLog{entries: make([]RecordedOperation, size)}
+		}
+	}
+}
+
+// RecordedOperations returns the operations recorded by RecordOperations, oldest first,
+// or nil if the server does not record operations
+func (s *Server) RecordedOperations() []RecordedOperation {
+	if s.operations == nil {
+		return nil
+	}
+	return s.operations.list()
+}
+
+// operationLog is a ring buffer of the last recorded operations
+type operationLog struct {
+	mx      sync.Mutex
+	entries []RecordedOperation
+	next    int
+	full    bool
+}
+
+func (o *operationLog) add(operation RecordedOperation) {
+	o.mx.Lock()
+	defer o.mx.Unlock()
+	o.entries[o.next] = operation
+	if o.next = (o.next + 1) % len(o.entries); o.next == 0 {
+		o.full = true
+	}
+}
+
+func (o *operationLog) list() []RecordedOperation {
+	o.mx.Lock()
+	defer o.mx.Unlock()
+	if !o.full {
+		return append([]RecordedOperation(nil), o.entries[:o.next]...)
+	}
+	return append(append([]RecordedOperation(nil), o.entries[o.next:]...), o.entries[:o.next]...)
+}
+
+// recordingLifetimeManager is a HubLifetimeManager which records the operations of the HubLifetimeManager it wraps
+type recordingLifetimeManager struct {
+	HubLifetimeManager
+	log    *operationLog
+	tenant string
+	clock  Clock
+}
+
+// record runs an operation and records it. args are the arguments of an invocation, nil for other operations
+func (r *recordingLifetimeManager) record(operation string, recipients string, target string, args []interface{}, run func() error) error {
+	recorded := RecordedOperation{Time: r.clock.Now(), Operation: operation, Tenant: r.tenant, Recipients: recipients, Target: target}
+	if args != nil {
+		if data, err := json.Marshal(args); err == nil {
+			recorded.Size = len(data)
+		}
+	}
+	err := run()
+	recorded.Duration = r.clock.Now().Sub(recorded.Time)
+	if err != nil {
+		recorded.Error = err.Error()
+	}
+	r.log.add(recorded)
+	return err
+}
+
+func (r *recordingLifetimeManager) InvokeAll(target string, args []interface{}) {
+	_ = r.record("InvokeAll", "", target, args, func() error {
+		r.HubLifetimeManager.InvokeAll(target, args)
+		return nil
+	})
+}
+
+func (r *recordingLifetimeManager) InvokeClient(connectionID string, target string, args []interface{}) {
+	_ = r.record("InvokeClient", connectionID, target, args, func() error {
+		r.HubLifetimeManager.InvokeClient(connectionID, target, args)
+		return nil
+	})
+}
+
+func (r *recordingLifetimeManager) InvokeGroup(groupName string, target string, args []interface{}) {
+	_ = r.record("InvokeGroup", groupName, target, args, func() error {
+		r.HubLifetimeManager.InvokeGroup(groupName, target, args)
+		return nil
+	})
+}
+
+func (r *recordingLifetimeManager) InvokeClients(connectionIDs []string, target string, args []interface{}) {
+	_ = r.record("InvokeClients", strings.Join(connectionIDs, ","), target, args, func() error {
+		r.HubLifetimeManager.InvokeClients(connectionIDs, target, args)
+		return nil
+	})
+}
+
+func (r *recordingLifetimeManager) InvokeUsers(userIDs []string, target string, args []interface{}) {
+	_ = r.record("InvokeUsers", strings.Join(userIDs, ","), target, args, func() error {
+		r.HubLifetimeManager.InvokeUsers(userIDs, target, args)
+		return nil
+	})
+}
+
+func (r *recordingLifetimeManager) InvokeGroups(groupNames []string, target string, args []interface{}) {
+	_ = r.record("InvokeGroups", strings.Join(groupNames, ","), target, args, func() error {
+		r.HubLifetimeManager.InvokeGroups(groupNames, target, args)
+		return nil
+	})
+}
+
+func (r *recordingLifetimeManager) InvokeTagged(expression *TagExpression, target string, args []interface{}) {
+	_ = r.record("InvokeTagged", expression.String(), target, args, func() error {
+		r.HubLifetimeManager.InvokeTagged(expression, target, args)
+		return nil
+	})
+}
+
+func (r *recordingLifetimeManager) InvokeClientWithAck(ctx context.Context, connectionID string, target string, args []interface{}) error {
+	return r.record("InvokeClientWithAck", connectionID, target, args, func() error {
+		return r.HubLifetimeManager.InvokeClientWithAck(ctx, connectionID, target, args)
+	})
+}
+
+func (r *recordingLifetimeManager) CreateGroup(groupName string, owner string, maxSize int) error {
+	return r.record("CreateGroup", groupName, "", nil, func() error {
+		return r.HubLifetimeManager.CreateGroup(groupName, owner, maxSize)
+	})
+}
+
+func (r *recordingLifetimeManager) RetainMessages(groupName string, retention Retention) {
+	_ = r.record("RetainMessages", groupName, "", nil, func() error {
+		r.HubLifetimeManager.RetainMessages(groupName, retention)
+		return nil
+	})
+}
+
+func (r *recordingLifetimeManager) AddToGroup(groupName, connectionID string) error {
+	return r.record("AddToGroup", groupName+":"+connectionID, "", nil, func() error {
+		return r.HubLifetimeManager.AddToGroup(groupName, connectionID)
+	})
+}
+
+func (r *recordingLifetimeManager) RemoveFromGroup(groupName, connectionID string) {
+	_ = r.record("RemoveFromGroup", groupName+":"+connectionID, "", nil, func() error {
+		r.HubLifetimeManager.RemoveFromGroup(groupName, connectionID)
+		return nil
+	})
+}
+
+func (r *recordingLifetimeManager) TagConnection(connectionID string, tags []string) error {
+	return r.record("TagConnection", connectionID+":"+strings.Join(tags, ","), "", nil, func() error {
+		return r.HubLifetimeManager.TagConnection(connectionID, tags)
+	})
+}
+
+func (r *recordingLifetimeManager) UntagConnection(connectionID string, tags []string) {
+	_ = r.record("UntagConnection", connectionID+":"+strings.Join(tags, ","), "", nil, func() error {
+		r.HubLifetimeManager.UntagConnection(connectionID, tags)
+		return nil
+	})
+}
@@ -0,0 +1,46 @@
+package signalr
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type recordingHub struct {
+	Hub
+}
+
+func (r *recordingHub) Ready() {}
+
+var _ = Describe("Recording", func() {
+
+	Describe("Server recording operations", func() {
+		server := NewServer(&recordingHub{}, RecordOperations(3))
+		Context("When more operations than recorded are run", func() {
+			It("should keep the last ones, oldest first", func() {
+				conn := connectUser(server, "r", "rita")
+				Expect(server.HubContext().Groups().AddToGroup("team", "r")).To(Succeed())
+				server.HubContext().Clients().Group("team").Send("news", "one")
+				expectTargets(conn, "news")
+				server.HubContext().Clients().Group("team").Send("news", "two")
+				expectTargets(conn, "news")
+				server.HubContext().Clients().Client("r").Send("private", 1, 2)
+				expectTargets(conn, "private")
+				operations := server.RecordedOperations()
+				Expect(operations).To(HaveLen(3))
+				Expect(operations[0].Operation).To(Equal("InvokeGroup"))
+				Expect(operations[0].Recipients).To(Equal("team"))
+				Expect(operations[0].Size).To(Equal(len(`["one"]`)))
+				Expect(operations[1].Target).To(Equal("news"))
+				Expect(operations[2].Operation).To(Equal("InvokeClient"))
+				Expect(operations[2].Recipients).To(Equal("r"))
+				Expect(operations[2].Size).To(Equal(len(`[1,2]`)))
+			})
+		})
+	})
+
+	Describe("Server not recording operations", func() {
+		It("should have no recorded operations", func() {
+			Expect(NewServer(&recordingHub{}).RecordedOperations()).To(BeNil())
+		})
+	})
+})
@@ -0,0 +1,45 @@
+package signalr
+
+import (
+	"time"
+)
+
+// MeasureRoundTrip measures the round trip time of each connection. Every interval, the server invokes target on
+// the client and waits for its completion, the time until the completion is the round trip time.
+// Pings can not be used for this, as the client does not answer them. The invocation carries the last round trip time
+// in milliseconds, 0 before the first measurement, so the client can display it. The client must return from the
+// handler of target, e.g. with the JavaScript client
+//
+//	connection.on("roundTrip", (ms) => { showLatency(ms); return true; });
+//
+// The round trip time is available by ConnectionContext.RoundTrip, ConnectionStats and ServerStats.
+// A client which does not complete the invocation within interval keeps its last measurement
+func MeasureRoundTrip(target string, interval time.Duration) Option {
+	return func(s *Server) {
+		s.roundTripTarget = target
+		s.roundTripInterval = interval
+	}
+}
+
+// measureRoundTrip invokes the round trip target on the client every interval while the connection is connected
+func (s *Server) measureRoundTrip(conn hubConnection) {
+	for conn.IsConnected() {
+		sent := s.clock.Now()
+		next := s.clock.After(s.roundTripInterval)
+		id, completed, err := conn.InvokeWithResult(s.roundTripTarget, []interface{}{conn.Stats().RoundTrip.Milliseconds()})
+		if err != nil {
+			<-next
+			continue
+		}
+		select {
+		case completion, ok := <-completed:
+			if ok && completion.Error == "" {
+				conn.SetRoundTrip(s.clock.Now().Sub(sent))
+			}
+			<-next
+		case <-next:
+			// The client did not answer in time
+			conn.CancelInvocation(id)
+		}
+	}
+}
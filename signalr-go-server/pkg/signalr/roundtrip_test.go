@@ -0,0 +1,56 @@
+package signalr
+
+import (
+	"fmt"
+	"time"
+
+	"./signalrtest"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type roundTripHub struct {
+	Hub
+}
+
+func (r *roundTripHub) Latency(connectionContext ConnectionContext) int64 {
+	return connectionContext.RoundTrip().Milliseconds()
+}
+
+var _ = Describe("Round trip", func() {
+
+	Describe("Server measuring the round trip time", func() {
+		clock := signalrtest.NewFakeClock(time.Now())
+		server := NewServer(&roundTripHub{}, UseClock(clock), MeasureRoundTrip("roundTrip", time.Second))
+		conn := newTestingConnection()
+		go server.Run(conn)
+		Context("When the client completes the invocations of the round trip target", func() {
+			It("should measure the time until the completion and send it with the next invocation", func() {
+				probe := (<-conn.received).(InvocationMessage)
+				Expect(probe.Target).To(Equal("roundTrip"))
+				Expect(probe.Arguments).To(Equal([]interface{}{float64(0)}))
+				clock.Advance(30 * time.Millisecond)
+				_, err := conn.clientSend(fmt.Sprintf(`{"type":3,"invocationId":"%v","result":true}`, probe.InvocationID))
+				Expect(err).To(BeNil())
+				Eventually(func() time.Duration { return server.Stats().RoundTrip }).Should(Equal(30 * time.Millisecond))
+
+				_, err = conn.clientSend(`{"type":1,"invocationId": "latency","target":"latency"}`)
+				Expect(err).To(BeNil())
+				Expect((<-conn.received).(CompletionMessage).Result).To(Equal(float64(30)))
+
+				clock.Advance(time.Second)
+				probe = (<-conn.received).(InvocationMessage)
+				Expect(probe.Target).To(Equal("roundTrip"))
+				Expect(probe.Arguments).To(Equal([]interface{}{float64(30)}))
+			})
+		})
+		Context("When the client does not complete an invocation within the interval", func() {
+			It("should keep the last round trip time and invoke the target again", func() {
+				clock.Advance(time.Second)
+				probe := (<-conn.received).(InvocationMessage)
+				Expect(probe.Arguments).To(Equal([]interface{}{float64(30)}))
+				Expect(server.Stats().RoundTrip).To(Equal(30 * time.Millisecond))
+			})
+		})
+	})
+})
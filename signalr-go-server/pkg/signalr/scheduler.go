@@ -0,0 +1,146 @@
+package signalr
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// ScheduledSend is an invocation of the clients of a group which is sent at a later time
+type ScheduledSend struct {
+	ID        string
+	Group     string
+	Target    string
+	Arguments []interface{}
+	At        time.Time
+}
+
+// ScheduleStore keeps the pending sends scheduled with ScheduleInvokeGroup. A store which persists them, e.g. in a
+// database, lets them survive a restart of the server. Stores must be safe for concurrent use.
+// Add() stores a send
+// Remove() removes the send with the ID and returns if it was pending
+// Next() returns the time of the earliest pending send, false if there is none
+// Due() removes the sends whose time is not after now and returns them
+type ScheduleStore interface {
+	Add(send ScheduledSend) error
+	Remove(id string) (bool, error)
+	Next() (time.Time, bool, error)
+	Due(now time.Time) ([]ScheduledSend, error)
+}
+
+// UseScheduleStore sets the ScheduleStore of the server. The sends pending in the store are sent when they are due.
+// By default, scheduled sends are kept in memory
+func UseScheduleStore(store ScheduleStore) Option {
+	return func(s *Server) {
+		s.scheduler.store = store
+	}
+}
+
+// ScheduleInvokeGroup invokes target with args on the clients of group in the default tenant at the time at,
+// e.g. for reminders. A time in the past sends right away. It returns the ID of the scheduled send, which
+// cancels it with CancelScheduled
+func (s *Server) ScheduleInvokeGroup(group string, target string, args []interface{}, at time.Time) (string, error) {
+	send := ScheduledSend{ID: s.getConnectionID(), Group: group, Target: target, Arguments: args, At: at}
+	if err := s.scheduler.store.Add(send); err != nil {
+		return "", err
+	}
+	s.scheduler.arm()
+	return send.ID, nil
+}
+
+// CancelScheduled cancels the scheduled send with id. It returns false if the send is not pending anymore
+func (s *Server) CancelScheduled(id string) (bool, error) {
+	return s.scheduler.store.Remove(id)
+}
+
+// scheduler sends the sends of its store when they are due. Its timer is set for the earliest pending send
+type scheduler struct {
+	store  ScheduleStore
+	server *Server
+	mx     sync.Mutex
+	// next is the time the timer fires, stop stops it. stop is nil if no timer is set
+	next time.Time
+	stop func() bool
+}
+
+// arm sets the timer for the earliest pending send, if it is not set for it already
+func (s *scheduler) arm() {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	next, ok, err := s.store.Next()
+	if err != nil {
+		_ = s.server.logger.Log("event", "schedule failed", "error", err)
+		return
+	}
+	if !ok || (s.stop != nil && !next.Before(s.next)) {
+		return
+	}
+	if s.stop != nil {
+		s.stop()
+	}
+	s.next = next
+	s.stop = s.server.clock.AfterFunc(next.Sub(s.server.clock.Now()), s.fire)
+}
+
+// fire sends the due sends and sets the timer for the next one
+func (s *scheduler) fire() {
+	s.mx.Lock()
+	s.stop = nil
+	s.mx.Unlock()
+	due, err := s.store.Due(s.server.clock.Now())
+	if err != nil {
+		_ = s.server.logger.Log("event", "scheduled send failed", "error", err)
+	}
+	for _, send := range due {
+		s.server.hubContext.Clients().Group(send.Group).Send(send.Target, send.Arguments...)
+	}
+	s.arm()
+}
+
+// memoryScheduleStore is the default ScheduleStore
+type memoryScheduleStore struct {
+	mx    sync.Mutex
+	sends map[string]ScheduledSend
+}
+
+func (m *memoryScheduleStore) Add(send ScheduledSend) error {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	m.sends[send.ID] = send
+	return nil
+}
+
+func (m *memoryScheduleStore) Remove(id string) (bool, error) {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	_, ok := m.sends[id]
+	delete(m.sends, id)
+	return ok, nil
+}
+
+func (m *memoryScheduleStore) Next() (time.Time, bool, error) {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	var next time.Time
+	pending := false
+	for _, send := range m.sends {
+		if !pending || send.At.Before(next) {
+			next, pending = send.At, true
+		}
+	}
+	return next, pending, nil
+}
+
+func (m *memoryScheduleStore) Due(now time.Time) ([]ScheduledSend, error) {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	var due []ScheduledSend
+	for id, send := range m.sends {
+		if !send.At.After(now) {
+			due = append(due, send)
+			delete(m.sends, id)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool { return due[i].At.Before(due[j].At) })
+	return due, nil
+}
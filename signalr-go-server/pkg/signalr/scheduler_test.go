@@ -0,0 +1,48 @@
+package signalr
+
+import (
+	"time"
+
+	"./signalrtest"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type scheduleHub struct {
+	Hub
+}
+
+func (s *scheduleHub) Ready() {}
+
+var _ = Describe("Scheduler", func() {
+
+	Describe("Server with scheduled sends", func() {
+		clock := signalrtest.NewFakeClock(time.Now())
+		server := NewServer(&scheduleHub{}, UseClock(clock))
+		Context("When sends to a group are scheduled", func() {
+			It("should send them when they are due, unless they have been cancelled", func() {
+				conn := connectUser(server, "a", "alice")
+				Expect(server.HubContext().Groups().AddToGroup("reminders", "a")).To(Succeed())
+				_, err := server.ScheduleInvokeGroup("reminders", "later", []interface{}{2}, clock.Now().Add(2*time.Minute))
+				Expect(err).To(BeNil())
+				cancelled, err := server.ScheduleInvokeGroup("reminders", "cancelled", nil, clock.Now().Add(90*time.Second))
+				Expect(err).To(BeNil())
+				_, err = server.ScheduleInvokeGroup("reminders", "sooner", []interface{}{1}, clock.Now().Add(time.Minute))
+				Expect(err).To(BeNil())
+				Expect(server.CancelScheduled(cancelled)).To(BeTrue())
+				Consistently(conn.received, 50*time.Millisecond).ShouldNot(Receive())
+				clock.Advance(time.Minute)
+				Expect((<-conn.received).(InvocationMessage).Arguments).To(Equal([]interface{}{float64(1)}))
+				// The timer for the next send is set after the due sends have been sent
+				Eventually(func() bool {
+					server.scheduler.mx.Lock()
+					defer server.scheduler.mx.Unlock()
+					return server.scheduler.stop != nil
+				}).Should(BeTrue())
+				clock.Advance(time.Minute)
+				expectTargets(conn, "later")
+				Expect(server.CancelScheduled(cancelled)).To(BeFalse())
+			})
+		})
+	})
+})
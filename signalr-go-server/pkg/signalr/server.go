@@ -2,154 +2,532 @@ package signalr
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"net"
 	"reflect"
 	"runtime/debug"
+	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
 // Server is a SignalR server for one type of hub
 type Server struct {
-	hub               HubInterface
-	lifetimeManager   HubLifetimeManager
-	defaultHubClients HubClients
-	groupManager      GroupManager
+	hub                        HubInterface
+	lifetimeManager            HubLifetimeManager
+	defaultHubClients          HubClients
+	groupManager               GroupManager
+	hubContext                 HubContext
+	initialize                 sync.Once
+	hubInfo                    *hubInfo
+	connections                *connectionRegistry
+	connectionTakeover         bool
+	negotiateRedirector        NegotiateRedirector
+	longPollingConnections     sync.Map
+	longPollingMaxResponseSize int
+	connectionHeaders          []string
+	writeTimeout               time.Duration
+	protocols                  map[string]HubProtocol
+	drainWaves                 int
+	healthChecks               []namedHealthCheck
+	maxGoroutines              int
+	runningLoops               int32
+	ordering                   Ordering
+	userIDProvider             UserIDProvider
+	slowConsumer               *SlowConsumerPolicy
+	orderingLocks              keyedMutex
+	clock                      Clock
+	broadcaster                bool
+	interceptors               []OutboundInterceptor
+	readModel                  ReadModel
+	keepAlive                  *keepAlive
+	trustedProxies             []*net.IPNet
+	negotiateFilter            NegotiateFilter
+	messageTTL                 time.Duration
+	started                    time.Time
+	groupNotifications         *GroupNotifications
+	affinityCookie             string
+	affinityNode               string
+	roundTripTarget            string
+	roundTripInterval          time.Duration
+	handshakeHandler           HandshakeFunc
+	welcome                    WelcomeFunc
+	handshakeTimeout           time.Duration
+	correlationHeader          string
+	correlationIDs             sync.Map
+	methodStats                sync.Map
+	camelCaseJSON              bool
+	argumentLimits             *ArgumentLimits
+	loopRestarts               int
+	webSocketsOverHTTP2        bool
+	persist                    PersistFunc
+	groupFanOut                *GroupFanOut
+	wildcardGroups             bool
+	fallbackHandler            func(fallback TransportFallback)
+	protocolSelectionHandler   func(selection ProtocolSelection)
+	maxMessageSize             int
+	streamBufferSize           int
+	transportOptions           TransportOptions
+	tenantProvider             TenantProvider
+	bandwidthQuota             *BandwidthQuota
+	clientResultTimeout        time.Duration
+	listAvailableMethods       bool
+	logger                     StructuredLogger
+	debugLogger                StructuredLogger
+	transports                 map[string]bool
+	sessions                   *sessionRegistry
+	graces                     *graceRegistry
+	inbox                      *offlineInbox
+	connectionTokens           *connectionTokens
+	authentication             *requestAuthentication
+	scheduler                  *scheduler
+	tokens                     *tokenRefresher
+	idleTimeout                time.Duration
+	operations                 *operationLog
+	// tenants are the lifetime managers of the tenants by key, the default tenant has the empty key
+	tenantsMx sync.Mutex
+	tenants   map[string]*tenant
+	// serverSentEventsConnections are the live connections of the Server-Sent Events transport by connection ID
+	serverSentEventsConnections sync.Map
+	// messagesIn and messagesOut count the messages of the connections which have ended, bytesIn and bytesOut their bytes
+	messagesIn  int64
+	messagesOut int64
+	bytesIn     int64
+	bytesOut    int64
+	// userBytes are the bytes of the ended connections of each user
+	userBytesMx sync.Mutex
+	userBytes   map[string]UserStats
+	// fallbacks count the connections which started on another transport than WebSockets by reason
+	fallbacksMx sync.Mutex
+	fallbacks   map[string]int64
+	// protocolErrors counts the connections ended by malformed messages
+	protocolErrors int64
+	// unknownMethods counts the invocations of targets the hub does not have
+	unknownMethods int64
 }
 
-// NewServer creates a new server for one type of hub
-func NewServer(hub HubInterface) *Server {
-	lifetimeManager := defaultHubLifetimeManager{}
-	return &Server{
-		hub:             hub,
-		lifetimeManager: &lifetimeManager,
-		defaultHubClients: &defaultHubClients{
-			lifetimeManager: &lifetimeManager,
-			allCache:        allClientProxy{lifetimeManager: &lifetimeManager},
-		},
-		groupManager: &defaultGroupManager{
-			lifetimeManager: &lifetimeManager,
-		},
+// NewServer creates a new server for one type of hub. It panics when a method of the hub can not be bound, see ValidateHub
+func NewServer(hub HubInterface, options ...Option) *Server {
+	if err := ValidateHub(hub); err != nil {
+		panic(fmt.Sprintf("signalr: %v", err))
 	}
+	server := &Server{
+		hub:                        hub,
+		connections:                newConnectionRegistry(),
+		longPollingMaxResponseSize: defaultLongPollingMaxResponseSize,
+		writeTimeout:               defaultWriteTimeout,
+		protocols:                  make(map[string]HubProtocol),
+		drainWaves:                 defaultDrainWaves,
+		maxMessageSize:             defaultMaxMessageSize,
+		streamBufferSize:           defaultStreamBufferSize,
+		clientResultTimeout:        defaultClientResultTimeout,
+		logger:                     stdoutLogger,
+		debugLogger:                nopLogger{},
+		clock:                      realClock{},
+		webSocketsOverHTTP2:        true,
+		handshakeTimeout:           defaultHandshakeTimeout,
+		correlationHeader:          defaultCorrelationHeader,
+		tenants:                    make(map[string]*tenant),
+		userBytes:                  make(map[string]UserStats),
+		fallbacks:                  make(map[string]int64),
+		scheduler:                  &scheduler{store: &memoryScheduleStore{sends: make(map[string]ScheduledSend)}},
+	}
+	server.scheduler.server = server
+	jsonProtocol := &JsonHubProtocol{}
+	server.protocols[jsonProtocol.Name()] = jsonProtocol
+	for _, option := range options {
+		option(server)
+	}
+	server.logger = &correlatingLogger{logger: server.logger, server: server}
+	server.debugLogger = &correlatingLogger{logger: server.debugLogger, server: server}
+	for _, protocol := range server.protocols {
+		if debugging, ok := protocol.(interface{ setDebugLogger(logger StructuredLogger) }); ok {
+			debugging.setDebugLogger(server.debugLogger)
+		}
+		if naming, ok := protocol.(interface{ setCamelCase(camelCase bool) }); ok {
+			naming.setCamelCase(server.camelCaseJSON)
+		}
+		if limited, ok := protocol.(interface{ setArgumentLimits(limits *ArgumentLimits) }); ok {
+			limited.setArgumentLimits(server.argumentLimits)
+		}
+	}
+	server.connections.clock = server.clock
+	server.keepAlive = newKeepAlive(server.clock)
+	server.started = server.clock.Now()
+	if server.sessions != nil {
+		server.sessions.clock = server.clock
+	}
+	if server.graces != nil {
+		server.graces.clock = server.clock
+	}
+	if server.authentication != nil {
+		server.authentication.clock = server.clock
+		if cache, ok := server.authentication.options.Cache.(*memoryClaimsCache); ok {
+			cache.clock = server.clock
+		}
+	}
+	if server.inbox != nil {
+		server.inbox.clock = server.clock
+		server.inbox.logger = server.logger
+	}
+	// The lifetime manager is configured by the options, so the default tenant is created after them
+	defaultTenant := server.tenant("")
+	server.lifetimeManager = defaultTenant.lifetimeManager
+	server.defaultHubClients = defaultTenant.hubContext.clients
+	server.groupManager = defaultTenant.hubContext.groups
+	server.hubContext = defaultTenant.hubContext
+	// Sends might be pending in the store already
+	server.scheduler.arm()
+	return server
+}
+
+// HubContext returns the HubContext of the server. It can be held by code outside the hub,
+// e.g. http handlers or background workers, to send messages to the clients connected to the hub
+func (s *Server) HubContext() HubContext {
+	return s.hubContext
 }
 
 // Run runs the server on one connection. The same server might be run on different connections in parallel
 func (s *Server) Run(conn Connection) {
-	if protocol, err := processHandshake(conn); err != nil {
-		fmt.Println(err)
+	live, ok := s.connections.bind(conn, s.connectionTakeover)
+	if !ok {
+		// The connection ID is already in use
+		if closer, ok := conn.(io.Closer); ok {
+			_ = closer.Close()
+		}
+		return
+	}
+	correlationID := s.connectionCorrelationID(conn)
+	s.correlationIDs.Store(conn.ConnectionID(), correlationID)
+	defer s.correlationIDs.Delete(conn.ConnectionID())
+	session := s.sessions.newSession()
+	if protocol, capabilities, err := s.handshake(conn, session); err != nil {
+		_ = s.logger.Log("connection", conn.ConnectionID(), "event", "handshake failed", "error", err)
+		session.discard()
+		s.connections.release(live)
 	} else {
-		hubConn := newHubConnection(conn, protocol)
+		if formatter, ok := conn.(interface{ setTransferFormat(format string) }); ok {
+			formatter.setTransferFormat(protocol.TransferFormat())
+		}
+		connectionContext := newConnectionContext(conn)
+		connectionContext.capabilities = capabilities
+		connectionContext.features.Set(FeatureCorrelationID, correlationID)
+		if locale := capabilities.String(CapabilityLocale); locale != "" {
+			connectionContext.features.Set(FeatureLocale, locale)
+		}
+		var identity Identity
+		var tokenErr error
+		if s.tokens != nil {
+			if identity, tokenErr = s.tokens.validate(accessToken(conn)); tokenErr == nil {
+				connectionContext.features.Set(FeatureClaims, identity.Claims)
+				connectionContext.userID = identity.UserID
+			}
+		} else if authenticated, ok := connectionContext.features.Get(FeatureIdentity); ok {
+			connectionContext.userID = authenticated.(Identity).UserID
+		}
+		if s.userIDProvider != nil {
+			connectionContext.userID = s.userIDProvider(connectionContext)
+		}
+		if s.tenantProvider != nil {
+			connectionContext.tenant = s.tenantProvider(connectionContext)
+			connectionContext.features.Set(FeatureTenant, connectionContext.tenant)
+		}
+		lifetimeManager := s.tenant(connectionContext.tenant).lifetimeManager
+		hubConn := newHubConnection(conn, protocol, hubConnectionOptions{
+			writeTimeout:     s.writeTimeout,
+			userID:           connectionContext.userID,
+			features:         connectionContext.features,
+			slowConsumer:     s.slowConsumer,
+			intercept:        s.outboundInterceptor(connectionContext),
+			readModel:        s.readModel,
+			messageTTL:       s.messageTTL,
+			maxMessageSize:   s.maxMessageSize,
+			streamBufferSize: s.streamBufferSize,
+			readBufferSize:   s.readBufferSize(conn),
+			logger:           s.logger,
+			bandwidthQuota:   s.bandwidthQuota,
+			clock:            s.clock,
+			targets:          capabilities.targets(),
+		})
+		connectionContext.hubConn = hubConn
+		refusal := ""
+		if tokenErr != nil {
+			_ = s.logger.Log("connection", conn.ConnectionID(), "event", "invalid access token", "error", tokenErr)
+			refusal = "Invalid access token"
+		} else if !s.connections.attach(live, hubConn) {
+			refusal = "Too many connections of the user"
+		}
+		if refusal != "" {
+			hubConn.Start()
+			hubConn.Close(refusal)
+			session.discard()
+			connectionContext.cancel()
+			s.connections.release(live)
+			if closer, ok := conn.(io.Closer); ok {
+				_ = closer.Close()
+			}
+			return
+		}
 		// start sending pings to the client
-		pings := startPingClientLoop(hubConn)
-		hubConn.Start()
+		supervisor := s.newLoopSupervisor(conn, hubConn)
+		if s.readModel == GoroutinePerConnection {
+			supervisor.start("keep-alive", func() { pingClientLoop(hubConn, s.clock) })
+			hubConn.Start()
+		} else {
+			hubConn.Start()
+			s.keepAlive.add(hubConn)
+		}
+		if s.roundTripTarget != "" {
+			supervisor.start("round trip", func() { s.measureRoundTrip(hubConn) })
+		}
+		token := s.tokens.track(conn, hubConn, identity, s.clock)
+		idle := s.watchIdle(conn, hubConn)
 		// Process messages
 		streamer := newStreamer(hubConn)
-		streamClient := newStreamClient()
+		streamClient := newStreamClient(protocol, s.logger)
 		hubInfo := s.newHubInfo()
-		hubInfo.lifetimeManager.OnConnected(hubConn)
-		hubInfo.hub.OnConnected(hubConn.GetConnectionID())
-
-		for hubConn.IsConnected() {
-			if message, err := hubConn.Receive(); err != nil {
-				fmt.Println(err)
-				break
-			} else {
-				fmt.Printf("Message received %v\n", message)
-				switch message.(type) {
-				case invocationMessage:
-					invocation := message.(invocationMessage)
-					// Dispatch invocation here
-					if method, ok := hubInfo.methods[strings.ToLower(invocation.Target)]; !ok {
-						// Unable to find the method
-						hubConn.Completion(invocation.InvocationID, nil, fmt.Sprintf("Unknown method %s", invocation.Target))
-					} else if in, clientStreaming, err := buildMethodArguments(method, invocation, streamClient, protocol); err != nil {
-						// argument build failed
-						hubConn.Completion(invocation.InvocationID, nil, err.Error())
-					} else if clientStreaming {
-						// let the receiving method run independently
-						go func() {
-							defer func() {
-								if err := recover(); err != nil {
-									hubConn.Completion(invocation.InvocationID, nil, fmt.Sprintf("%v\n%v", err, string(debug.Stack())))
-								}
+		atomic.AddInt32(&s.runningLoops, 1)
+		defer atomic.AddInt32(&s.runningLoops, -1)
+		if s.welcome != nil {
+			if target, args := s.welcome(connectionContext); target != "" {
+				hubConn.SendInvocation(target, args)
+			}
+		}
+		var resumed *detachedSession
+		if claimed := session.claimed(); session.resume(lifetimeManager, hubConn) {
+			resumed = claimed
+		} else {
+			lifetimeManager.OnConnected(hubConn)
+		}
+		if previousID := s.graces.reconnect(lifetimeManager, hubConn, resumed); previousID == "" {
+			hubInfo.hub.OnConnected(hubConn.GetConnectionID())
+		} else if reconnectHub, ok := hubInfo.hub.(ReconnectHub); ok {
+			reconnectHub.OnReconnected(previousID, hubConn.GetConnectionID())
+		}
+		s.inbox.deliver(connectionContext.tenant, hubConn)
+
+		clientClosed := false
+		supervisor.receive(func() {
+			for hubConn.IsConnected() && !clientClosed {
+				if message, err := hubConn.Receive(); err != nil {
+					var protocolErr *protocolError
+					if errors.As(err, &protocolErr) {
+						atomic.AddInt64(&s.protocolErrors, 1)
+						_ = s.logger.Log("connection", hubConn.GetConnectionID(), "event", "protocol error", "error", protocolErr, "message", fmt.Sprintf("%q", protocolErr.data))
+						hubConn.Close(fmt.Sprintf("Protocol error: %v", protocolErr))
+						break
+					}
+					_ = s.logger.Log("connection", hubConn.GetConnectionID(), "event", "receive failed", "error", err)
+					if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+						hubConn.SetDisconnectReason(DisconnectTimeout)
+					}
+					break
+				} else {
+					_ = s.debugLogger.Log("connection", hubConn.GetConnectionID(), "event", "message received", "message", fmt.Sprintf("%v", message))
+					switch message.(type) {
+					case InvocationMessage:
+						invocation := message.(InvocationMessage)
+						idle.active()
+						s.methodCounters(hubInfo, invocation.Target).received(receivedSize(hubConn))
+						// Dispatch invocation here
+						if token != nil && invocation.Target == refreshTokenTarget {
+							token.refresh(invocation, protocol)
+						} else if fn, ok := hubInfo.funcs[strings.ToLower(invocation.Target)]; ok {
+							s.invokeFunc(hubInfo, hubConn, invocation, fn, protocol, connectionContext)
+						} else if method, ok := hubInfo.methods[strings.ToLower(invocation.Target)]; !ok {
+							s.unknownMethod(hubConn, invocation, hubInfo)
+						} else if in, clientStreaming, err := buildMethodArguments(method, invocation, streamClient, protocol, connectionContext); err != nil {
+							// argument build failed
+							hubConn.Completion(invocation.InvocationID, nil, err.Error())
+						} else if clientStreaming {
+							// let the receiving method run independently
+							go func() {
+								defer func() {
+									if err := recover(); err != nil {
+										hubConn.Completion(invocation.InvocationID, nil, fmt.Sprintf("%v\n%v", err, string(debug.Stack())))
+									}
+								}()
+								s.returnInvocationResult(hubConn, invocation, streamer, s.callMethod(hubInfo, invocation, method, in, connectionContext))
 							}()
-							method.Call(in)
-						}()
-					} else {
-						result := func() []reflect.Value {
-							defer func() {
-								if err := recover(); err != nil {
-									hubConn.Completion(invocation.InvocationID, nil, fmt.Sprintf("%v\n%v", err, string(debug.Stack())))
-								}
+						} else {
+							result := func() []reflect.Value {
+								defer func() {
+									if err := recover(); err != nil {
+										hubConn.Completion(invocation.InvocationID, nil, fmt.Sprintf("%v\n%v", err, string(debug.Stack())))
+									}
+								}()
+								return s.callMethod(hubInfo, invocation, method, in, connectionContext)
 							}()
-							return method.Call(in)
-						}()
-						returnInvocationResult(hubConn, invocation, streamer, result)
+							s.returnInvocationResult(hubConn, invocation, streamer, result)
+						}
+					case CancelInvocationMessage:
+						streamer.Stop(message.(CancelInvocationMessage).InvocationID)
+					case StreamItemMessage:
+						streamClient.receiveStreamItem(message.(StreamItemMessage))
+					case CompletionMessage:
+						// Either the completion of an invocation sent to the client or of a client stream
+						if !hubConn.CompleteInvocation(message.(CompletionMessage)) {
+							streamClient.receiveCompletionItem(message.(CompletionMessage))
+						}
+					case HubMessage:
+						if message.(HubMessage).Type == 7 {
+							// The client closes the connection
+							hubConn.SetDisconnectReason(DisconnectClientClose)
+							clientClosed = true
+						}
+						// Ping
 					}
-				case cancelInvocationMessage:
-					streamer.Stop(message.(cancelInvocationMessage).InvocationID)
-				case streamItemMessage:
-					streamClient.receiveStreamItem(message.(streamItemMessage))
-				case completionMessage:
-					streamClient.receiveCompletionItem(message.(completionMessage))
-				case hubMessage:
-					// Ping
 				}
 			}
+		})
+		if stopped, ok := conn.(interface{ closedByClient() bool }); ok && stopped.closedByClient() {
+			// The client stopped the connection by its transport, e.g. with the DELETE request of long polling
+			hubConn.SetDisconnectReason(DisconnectClientClose)
+			clientClosed = true
 		}
-		hubInfo.hub.OnDisconnected(hubConn.GetConnectionID())
-		hubInfo.lifetimeManager.OnDisconnected(hubConn)
+		reason := hubConn.DisconnectReason()
+		_ = s.logger.Log("connection", hubConn.GetConnectionID(), "event", "disconnected", "reason", reason)
+		disconnected := func() {
+			hubInfo.hub.OnDisconnected(hubConn.GetConnectionID())
+			if reasonHub, ok := hubInfo.hub.(DisconnectReasonHub); ok {
+				reasonHub.OnDisconnectedReason(hubConn.GetConnectionID(), reason)
+			}
+		}
+		// A connection which dropped without the client closing it waits for its client to resume its session,
+		// or during the DisconnectGrace, to connect again
+		resumable := !clientClosed && (reason == DisconnectTransportError || reason == DisconnectTimeout)
+		graced := resumable && s.graces.graced(hubConn.GetUserID(), session.issued())
+		if !graced {
+			disconnected()
+		}
+		if s.persist != nil {
+			s.persist(connectionContext, s.snapshot(live, connectionContext, lifetimeManager, hubConn, reason))
+		}
+		var detached *detachedSession
+		if resumable {
+			detached = session.detach(lifetimeManager, hubConn)
+		}
+		if graced {
+			s.graces.park(lifetimeManager, hubConn, detached, disconnected)
+		} else if detached == nil {
+			lifetimeManager.OnDisconnected(hubConn)
+		}
+		s.connections.release(live)
+		connectionStats := hubConn.Stats()
+		atomic.AddInt64(&s.messagesIn, connectionStats.MessagesIn)
+		atomic.AddInt64(&s.messagesOut, connectionStats.MessagesOut)
+		atomic.AddInt64(&s.bytesIn, connectionStats.BytesIn)
+		atomic.AddInt64(&s.bytesOut, connectionStats.BytesOut)
+		s.addUserBytes(hubConn.GetUserID(), connectionStats)
 		hubConn.Close("")
-		// Wait for pings to complete
-		pings.Wait()
+		token.end()
+		idle.end()
+		// The connection is gone, goroutines tied to it by its context can end now
+		connectionContext.cancel()
+		if s.readModel != GoroutinePerConnection {
+			s.keepAlive.remove(hubConn)
+		}
+		// Wait for the pings and round trips to complete
+		supervisor.wait()
 	}
 }
 
-func startPingClientLoop(conn hubConnection) *sync.WaitGroup {
-	var waitgroup sync.WaitGroup
-	waitgroup.Add(1)
-	go func(waitGroup *sync.WaitGroup, conn hubConnection) {
-		defer waitGroup.Done()
-
-		for conn.IsConnected() {
-			conn.Ping()
-			time.Sleep(5 * time.Second)
-		}
-	}(&waitgroup, conn)
-	return &waitgroup
+// pingClientLoop sends pings to the client while the connection is connected
+func pingClientLoop(conn hubConnection, clock Clock) {
+	for conn.IsConnected() {
+		conn.Ping()
+		<-clock.After(keepAliveInterval)
+	}
 }
 
 type hubInfo struct {
-	hub             HubInterface
-	lifetimeManager HubLifetimeManager
-	methods         map[string]reflect.Value
+	hub      HubInterface
+	methods  map[string]*hubMethod
+	funcs    map[string]HubMethodFunc
+	ordering map[string]OrderingKey
 }
 
+// newHubInfo returns the hubInfo shared by all connections. It is built when the first connection starts
 func (s *Server) newHubInfo() *hubInfo {
 
-	s.hub.Initialize(&defaultHubContext{
-		clients: s.defaultHubClients,
-		groups:  s.groupManager,
+	// The hub is shared by all connections, initializing it once keeps connections starting in parallel from racing.
+	// The methods are looked up once, too
+	s.initialize.Do(func() {
+		s.hub.Initialize(s.hubContext)
+		s.hubInfo = s.buildHubInfo()
 	})
+	return s.hubInfo
+}
+
+func (s *Server) buildHubInfo() *hubInfo {
+	if s.broadcaster {
+		return &hubInfo{hub: s.hub}
+	}
 
 	hubInfo := &hubInfo{
-		hub:             s.hub,
-		lifetimeManager: s.lifetimeManager,
-		methods:         make(map[string]reflect.Value),
+		hub:      s.hub,
+		ordering: make(map[string]OrderingKey),
+	}
+	if orderedHub, ok := s.hub.(OrderedHub); ok {
+		for name, key := range orderedHub.OrderedMethods() {
+			hubInfo.ordering[strings.ToLower(name)] = key
+		}
 	}
 
-	hubType := reflect.TypeOf(s.hub)
-	hubValue := reflect.ValueOf(s.hub)
-	for i := 0; i < hubType.NumMethod(); i++ {
-		m := hubType.Method(i)
-		hubInfo.methods[strings.ToLower(m.Name)] = hubValue.Method(i)
+	if table, ok := s.hub.(interface{ methodTable() *MethodTable }); ok {
+		// Only the registered methods can be invoked
+		hubInfo.methods = table.methodTable().methods
+		hubInfo.funcs = table.methodTable().funcs
+		return hubInfo
 	}
+	hubInfo.methods = newHubMethods(s.hub)
 	return hubInfo
 }
 
-func returnInvocationResult(conn hubConnection, invocation invocationMessage, streamer *streamer, result []reflect.Value) {
+// unknownMethod answers the invocation of a target the hub does not have. The connection stays open
+func (s *Server) unknownMethod(conn hubConnection, invocation InvocationMessage, hubInfo *hubInfo) {
+	atomic.AddInt64(&s.unknownMethods, 1)
+	_ = s.logger.Log("connection", conn.GetConnectionID(), "event", "unknown method", "target", invocation.Target)
+	if invocation.InvocationID == "" {
+		// The client does not wait for a completion
+		return
+	}
+	message := "Method does not exist"
+	if s.listAvailableMethods {
+		message = fmt.Sprintf("Method %v does not exist. Available methods: %v", invocation.Target, strings.Join(hubInfo.methodNames(), ", "))
+	}
+	conn.Completion(invocation.InvocationID, nil, message)
+}
+
+// methodNames returns the names of the methods clients can invoke, in lower case and sorted
+func (h *hubInfo) methodNames() []string {
+	names := make([]string, 0, len(h.methods)+len(h.funcs))
+	for name := range h.methods {
+		names = append(names, name)
+	}
+	for name := range h.funcs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (s *Server) returnInvocationResult(conn hubConnection, invocation InvocationMessage, streamer *streamer, result []reflect.Value) {
+	result, hubErr := splitHubError(result)
+	if hubErr != nil {
+		if hubErr.Internal != nil {
+			_ = s.logger.Log("connection", conn.GetConnectionID(), "event", "invocation failed", "invocation", invocation.InvocationID,
+				"target", invocation.Target, "error", hubErr.Internal)
+		}
+		conn.Completion(invocation.InvocationID, nil, hubErr.clientError())
+		return
+	}
 	// if the hub method returns a chan, it should be considered asynchronous or source for a stream
 	if len(result) == 1 && result[0].Kind() == reflect.Chan {
 		switch invocation.Type {
@@ -181,43 +559,86 @@ func returnInvocationResult(conn hubConnection, invocation invocationMessage, st
 	}
 }
 
-func buildMethodArguments(method reflect.Value, invocation invocationMessage,
-	streamClient *streamClient, protocol HubProtocol) (arguments []reflect.Value, clientStreaming bool, err error) {
-	arguments = make([]reflect.Value, method.Type().NumIn())
-	chanCount := 0
-	for i := 0; i < method.Type().NumIn(); i++ {
-		t := method.Type().In(i)
-		// Is it a channel for client streaming?
-		if arg, clientStreaming, err := streamClient.buildChannelArgument(invocation, t, chanCount); err != nil {
-			// it is, but channel count in invocation and method mismatch
-			return nil, false, err
-		} else if clientStreaming {
-			// it is
-			chanCount++
+func buildMethodArguments(method *hubMethod, invocation InvocationMessage,
+	streamClient *streamClient, protocol HubProtocol, connectionContext ConnectionContext) (arguments []reflect.Value, clientStreaming bool, err error) {
+	arguments = make([]reflect.Value, len(method.params))
+	var channels []reflect.Value
+	// argIndex is the index of the next argument sent by the client
+	argIndex := 0
+	for i, param := range method.params {
+		switch param.kind {
+		case connectionContextParameter:
+			arguments[i] = reflect.ValueOf(connectionContext)
+		case contextParameter:
+			arguments[i] = reflect.ValueOf(context.WithValue(connectionContext.Context(), invocationIDKey{}, invocation.InvocationID))
+		case streamParameter:
+			if len(invocation.StreamIds) <= len(channels) {
+				// To many channel parameters arguments this method. The client will not send streamItems for these
+				return nil, false, fmt.Errorf("method %s has more chan parameters than the client will stream", invocation.Target)
+			}
+			arg := reflect.MakeChan(param.typ, 0)
+			channels = append(channels, arg)
 			arguments[i] = arg
-		} else {
-			// it is not, so do the normal thing
-			arg := reflect.New(t)
-			if err := protocol.UnmarshalArgument(invocation.Arguments[i-chanCount], arg.Interface()); err != nil {
-				return arguments, chanCount > 0, err
+		default:
+			if method.variadic && i == len(method.params)-1 {
+				// The remaining arguments are the elements of the variadic parameter
+				rest := reflect.MakeSlice(param.typ, 0, len(invocation.Arguments)-argIndex)
+				for ; argIndex < len(invocation.Arguments); argIndex++ {
+					arg := reflect.New(param.typ.Elem())
+					if err := protocol.UnmarshalArgument(invocation.Arguments[argIndex], arg.Interface()); err != nil {
+						return nil, false, err
+					}
+					rest = reflect.Append(rest, arg.Elem())
+				}
+				arguments[i] = rest
+				continue
+			}
+			if argIndex >= len(invocation.Arguments) {
+				if param.optional {
+					arguments[i] = reflect.Zero(param.typ)
+					continue
+				}
+				return nil, false, fmt.Errorf("method %s expects more arguments than the client sent", invocation.Target)
+			}
+			arg := reflect.New(param.typ)
+			if err := protocol.UnmarshalArgument(invocation.Arguments[argIndex], arg.Interface()); err != nil {
+				return nil, false, err
 			}
 			arguments[i] = arg.Elem()
+			argIndex++
 		}
 	}
-	return arguments, chanCount > 0, nil
+	if len(channels) != len(invocation.StreamIds) {
+		return nil, false, fmt.Errorf("method %s has %v chan parameters but the client sent %v streams", invocation.Target, len(channels), len(invocation.StreamIds))
+	}
+	if !method.variadic && method.arguments < len(invocation.Arguments) {
+		return nil, false, fmt.Errorf("method %s expects less arguments than the client sent", invocation.Target)
+	}
+	streamClient.registerChannels(invocation, channels)
+	return arguments, len(channels) > 0, nil
+}
+
+// invocationIDKey is the context key of the invocation ID in the context passed to hub methods
+type invocationIDKey struct{}
+
+// InvocationID returns the ID of the invocation from the context passed to a hub method.
+// The ID is empty for invocations which do not expect a result
+func InvocationID(ctx context.Context) string {
+	id, _ := ctx.Value(invocationIDKey{}).(string)
+	return id
 }
 
-type connFunc func(conn hubConnection, invocation invocationMessage, value interface{})
+type connFunc func(conn hubConnection, invocation InvocationMessage, value interface{})
 
-func completion(conn hubConnection, invocation invocationMessage, value interface{}) {
+func completion(conn hubConnection, invocation InvocationMessage, value interface{}) {
 	conn.Completion(invocation.InvocationID, value, "")
 }
 
-func streamItem(conn hubConnection, invocation invocationMessage, value interface{}) {
+func streamItem(conn hubConnection, invocation InvocationMessage, value interface{}) {
 	conn.StreamItem(invocation.InvocationID, value)
 }
 
-func invokeConnection(conn hubConnection, invocation invocationMessage, connFunc connFunc, result []reflect.Value) {
+func invokeConnection(conn hubConnection, invocation InvocationMessage, connFunc connFunc, result []reflect.Value) {
 	values := make([]interface{}, len(result))
 	for i, rv := range result {
 		values[i] = rv.Interface()
@@ -232,64 +653,132 @@ func invokeConnection(conn hubConnection, invocation invocationMessage, connFunc
 	}
 }
 
-func processHandshake(conn Connection) (HubProtocol, error) {
+// handshake selects the hub protocol of conn and reports the ProtocolSelection
+func (s *Server) handshake(conn Connection, session *connectionSession) (HubProtocol, Capabilities, error) {
+	selection := s.newProtocolSelection(conn)
+	protocol, capabilities, err := s.selectProtocol(conn, session, &selection)
+	s.reportProtocolSelection(selection, protocol, err)
+	return protocol, capabilities, err
+}
+
+// selectProtocol selects the hub protocol of conn. Clients which selected the protocol already with their transport,
+// e.g. by a WebSocket subprotocol, skip the handshake: they send no handshake request and get no response.
+// The HandshakeFunc is called for them without fields and can refuse them, too
+func (s *Server) selectProtocol(conn Connection, session *connectionSession, selection *ProtocolSelection) (HubProtocol, Capabilities, error) {
+	selected, ok := conn.(interface{ selectedProtocol() HubProtocol })
+	if !ok || selected.selectedProtocol() == nil {
+		handshakeConn := &handshakeConnection{Connection: conn}
+		if s.handshakeTimeout > 0 {
+			stop := s.clock.AfterFunc(s.handshakeTimeout, handshakeConn.cancel)
+			defer stop()
+		}
+		protocol, capabilities, err := processHandshake(handshakeConn, s.protocols, session.handshakeFunc(s.handshakeHandler, s.getConnectionID), s.debugLogger, selection)
+		if err != nil && handshakeConn.canceled() {
+			err = errHandshakeCanceled
+		}
+		return protocol, capabilities, err
+	}
+	protocol := selected.selectedProtocol()
+	selection.By = SelectedBySubprotocol
+	if s.handshakeHandler != nil {
+		if _, err := s.handshakeHandler(HandshakeRequest{
+			ConnectionID: conn.ConnectionID(),
+			Protocol:     protocol.Name(),
+			Version:      protocol.Version(),
+			Capabilities: Capabilities{},
+			Fields:       map[string]json.RawMessage{},
+		}); err != nil {
+			selection.Rejected = append(selection.Rejected, RejectedProtocol{Name: protocol.Name(), Reason: RejectedByHandshakeHandler})
+			return nil, nil, err
+		}
+	}
+	return protocol, Capabilities{}, nil
+}
+
+// processHandshake reads the handshake request from conn and answers it. The requested protocol is added to selection,
+// and to its rejected protocols if the handshake fails
+func processHandshake(conn Connection, protocols map[string]HubProtocol, handler HandshakeFunc, debugLogger StructuredLogger, selection *ProtocolSelection) (HubProtocol, Capabilities, error) {
 	var err error
 	var protocol HubProtocol
+	var capabilities Capabilities
 	var ok bool
-	const handshakeResponse = "{}\u001e"
-	const errorHandshakeResponse = "{\"error\":\"%s\"}\u001e"
-
-	// TODO 5 seconds to process the handshake
-	// ws.SetReadDeadline(time.Now().Add(5 * time.Second))
+	// The error is encoded as JSON string, errors of a HandshakeFunc might contain quotes.
+	// The errors are those of ASP.NET, the clients show them to the user
+	const errorHandshakeResponse = "{\"error\":%s}\u001e"
 
 	var buf bytes.Buffer
+	var n int
+	var rawHandshake []byte
 	data := make([]byte, 1<<12)
 	for {
-		n, err := conn.Read(data)
-		if err != nil {
+		if n, err = conn.Read(data); err != nil {
 			break
 		}
 
 		buf.Write(data[:n])
 
-		rawHandshake, err := parseTextMessageFormat(&buf)
+		rawHandshake, err = parseTextMessageFormat(&buf)
 
 		if err != nil {
 			// Partial message, read more data
 			continue
 		}
 
-		fmt.Println("Handshake received")
+		_ = debugLogger.Log("connection", conn.ConnectionID(), "event", "handshake received")
 
 		request := handshakeRequest{}
 		err = json.Unmarshal(rawHandshake, &request)
 
 		if err != nil {
 			// Malformed handshake
+			handshakeError := fmt.Sprintf("An unexpected error occurred during connection handshake. %v", err)
+			encodedError, _ := json.Marshal(handshakeError)
+			if _, err = conn.Write([]byte(fmt.Sprintf(errorHandshakeResponse, encodedError))); err == nil {
+				err = errors.New(handshakeError)
+			}
 			break
 		}
 
-		protocol, ok = protocolMap[request.Protocol]
+		protocol, ok = protocols[request.Protocol]
+		selection.Requested = append(selection.Requested, request.Protocol)
 
-		if ok {
+		var response []byte
+		var handshakeErr error
+		if ok && request.Version <= protocol.Version() {
+			capabilities = request.Capabilities
+			if capabilities == nil {
+				capabilities = Capabilities{}
+			}
+			request.Capabilities = capabilities
+			response, handshakeErr = handshakeResponse(handler, conn.ConnectionID(), request, rawHandshake)
+		}
+		if response != nil {
 			// Send the handshake response
-			_, err = conn.Write([]byte(handshakeResponse))
+			_, err = conn.Write(response)
 		} else {
-			// Protocol not supported
-			fmt.Printf("\"%s\" is the only supported Protocol\n", request.Protocol)
-			_, err = conn.Write([]byte(fmt.Sprintf(errorHandshakeResponse, fmt.Sprintf("Protocol \"%s\" not supported", request.Protocol))))
+			var handshakeError string
+			rejected := RejectedProtocol{Name: request.Protocol, Version: request.Version}
+			if handshakeErr != nil {
+				handshakeError = handshakeErr.Error()
+				rejected.Reason = RejectedByHandshakeHandler
+			} else if ok {
+				handshakeError = fmt.Sprintf("The server does not support version %v of the '%s' protocol.", request.Version, request.Protocol)
+				rejected.Reason = RejectedVersion
+			} else {
+				handshakeError = fmt.Sprintf("The protocol '%s' is not supported.", request.Protocol)
+				rejected.Reason = RejectedUnsupported
+			}
+			selection.Rejected = append(selection.Rejected, rejected)
+			protocol = nil
+			encodedError, _ := json.Marshal(handshakeError)
+			if _, err = conn.Write([]byte(fmt.Sprintf(errorHandshakeResponse, encodedError))); err == nil {
+				err = errors.New(handshakeError)
+			}
 		}
 		break
 	}
 
-	// TODO Disable the timeout (either we already timeout out or)
-	//ws.SetReadDeadline(time.Time{})
-
-	return protocol, err
-}
-
-var protocolMap = map[string]HubProtocol{
-	"json": &JsonHubProtocol{},
+	return protocol, capabilities, err
 }
 
 type availableTransport struct {
@@ -301,3 +790,12 @@ type negotiateResponse struct {
 	ConnectionID        string               `json:"connectionId"`
 	AvailableTransports []availableTransport `json:"availableTransports"`
 }
+
+type negotiateErrorResponse struct {
+	Error string `json:"error"`
+}
+
+type negotiateRedirectResponse struct {
+	URL         string `json:"url"`
+	AccessToken string `json:"accessToken,omitempty"`
+}
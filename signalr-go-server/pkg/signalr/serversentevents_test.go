@@ -0,0 +1,130 @@
+package signalr
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// readEvents sends the data of each event of an event stream to events
+func readEvents(resp *http.Response, events chan<- string) {
+	defer close(events)
+	reader := bufio.NewReader(resp.Body)
+	var data []string
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			events <- strings.Join(data, "\n")
+			data = nil
+		} else {
+			data = append(data, strings.TrimPrefix(line, "data: "))
+		}
+	}
+}
+
+// nextEvent returns the data of the next event which is not a ping. isPing tells the pings of the hub protocol
+func nextEvent(events <-chan string, isPing func(data string) bool) string {
+	for data := range events {
+		if !isPing(data) {
+			return data
+		}
+	}
+	Fail("event stream ended")
+	return ""
+}
+
+func isJSONPing(data string) bool {
+	return strings.Contains(data, `"type":6`)
+}
+
+func openEventStream(streamURL string) chan string {
+	req, _ := http.NewRequest("GET", streamURL, nil)
+	req.Header.Set("Accept", "text/event-stream")
+	resp, err := http.DefaultClient.Do(req)
+	Expect(err).To(BeNil())
+	Expect(resp.StatusCode).To(Equal(200))
+	Expect(resp.Header.Get("Content-Type")).To(Equal("text/event-stream"))
+	events := make(chan string, 10)
+	go readEvents(resp, events)
+	return events
+}
+
+// closeEventStream closes the connection and waits until the event stream has ended
+func closeEventStream(streamURL string, events <-chan string) {
+	req, _ := http.NewRequest("DELETE", streamURL, nil)
+	resp, err := http.DefaultClient.Do(req)
+	Expect(err).To(BeNil())
+	Expect(resp.StatusCode).To(Equal(202))
+	// The remaining events, e.g. pings, are skipped
+	Eventually(func() bool {
+		_, ok := <-events
+		return ok
+	}).Should(BeFalse())
+}
+
+var _ = Describe("Server-Sent Events", func() {
+
+	Describe("Server-Sent Events connection with a text protocol", func() {
+		mux := http.NewServeMux()
+		MapHub(mux, "/hub", &longPollingHub{})
+		httpServer := httptest.NewServer(mux)
+		Context("When a client connects and invokes a method", func() {
+			It("should receive the handshake response and the result as events", func() {
+				defer httpServer.Close()
+				streamURL := httpServer.URL + "/hub?id=" + url.QueryEscape(negotiate(mux, "/hub")["connectionId"].(string))
+				events := openEventStream(streamURL)
+				longPollSend(streamURL, `{"protocol": "json","version": 1}`)
+				Expect(<-events).To(Equal("{}\u001e"))
+				longPollSend(streamURL, `{"type":1,"invocationId": "large","target":"large","arguments":[3]}`)
+				Expect(nextEvent(events, isJSONPing)).To(ContainSubstring(`"result":"xxx"`))
+				closeEventStream(streamURL, events)
+			})
+		})
+	})
+
+	Describe("Server-Sent Events connection with a binary protocol", func() {
+		mux := http.NewServeMux()
+		MapHub(mux, "/hub", &longPollingHub{}, HubProtocols(&CborHubProtocol{}))
+		httpServer := httptest.NewServer(mux)
+		Context("When a client connects and invokes a method", func() {
+			It("should receive the binary messages base64 encoded", func() {
+				defer httpServer.Close()
+				streamURL := httpServer.URL + "/hub?id=" + url.QueryEscape(negotiate(mux, "/hub")["connectionId"].(string))
+				events := openEventStream(streamURL)
+				longPollSend(streamURL, `{"protocol": "cbor","version": 1}`)
+				Expect(<-events).To(Equal("{}\u001e"))
+				protocol := &CborHubProtocol{}
+				var invocation bytes.Buffer
+				Expect(protocol.WriteMessage(InvocationMessage{Type: 1, InvocationID: "large", Target: "large", Arguments: []interface{}{3}}, &invocation)).To(Succeed())
+				resp, err := http.Post(streamURL, "application/octet-stream", &invocation)
+				Expect(err).To(BeNil())
+				Expect(resp.StatusCode).To(Equal(200))
+				resp.Body.Close()
+				decode := func(data string) interface{} {
+					decoded, err := base64.StdEncoding.DecodeString(data)
+					Expect(err).To(BeNil())
+					message, complete, err := protocol.ReadMessage(bytes.NewBuffer(decoded))
+					Expect(err).To(BeNil())
+					Expect(complete).To(BeTrue())
+					return message
+				}
+				isPing := func(data string) bool {
+					return decode(data) == HubMessage{Type: 6}
+				}
+				Expect(decode(nextEvent(events, isPing))).To(Equal(CompletionMessage{Type: 3, InvocationID: "large", Result: "xxx"}))
+				closeEventStream(streamURL, events)
+			})
+		})
+	})
+})
@@ -0,0 +1,153 @@
+package signalr
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// serverSentEventsConnection sends the messages of the server as events of a text/event-stream response
+// and receives the messages of the client by send requests, like long polling.
+// Event streams can only carry text, so binary hub protocols are sent base64 encoded, one message per event
+type serverSentEventsConnection struct {
+	requestMetadata
+	connectionID string
+	reader       *io.PipeReader
+	writer       *io.PipeWriter
+	mx           sync.Mutex
+	w            http.ResponseWriter
+	flusher      http.Flusher
+	binary       bool
+	closed       bool
+	// stopped tells that the client closed the connection with a DELETE request
+	stopped bool
+	// done is closed when the connection is closed, no events are written after that
+	done chan struct{}
+	// flushInterval is the interval events are flushed at, 0 flushes each event. flushPending tells that
+	// a flush of written events is scheduled with clock
+	flushInterval time.Duration
+	flushPending  bool
+	clock         Clock
+}
+
+// newServerSentEventsConnection creates a Server-Sent Events connection writing its events to w.
+// A flushInterval which is not 0 flushes the events written within the interval together
+func newServerSentEventsConnection(connectionID string, metadata requestMetadata, w http.ResponseWriter, clock Clock, flushInterval time.Duration) *serverSentEventsConnection {
+	reader, writer := io.Pipe()
+	flusher, _ := w.(http.Flusher)
+	return &serverSentEventsConnection{
+		requestMetadata: metadata,
+		connectionID:    connectionID,
+		reader:          reader,
+		writer:          writer,
+		w:               w,
+		flusher:         flusher,
+		done:            make(chan struct{}),
+		flushInterval:   flushInterval,
+		clock:           clock,
+	}
+}
+
+func (s *serverSentEventsConnection) ConnectionID() string {
+	return s.connectionID
+}
+
+// requestFeatures returns the features of the request which started the connection and of the transport
+func (s *serverSentEventsConnection) requestFeatures() map[string]interface{} {
+	features := map[string]interface{}{FeatureTransport: TransportServerSentEvents, FeatureBinary: true}
+	for name, value := range s.features {
+		features[name] = value
+	}
+	return features
+}
+
+func (s *serverSentEventsConnection) Read(p []byte) (n int, err error) {
+	return s.reader.Read(p)
+}
+
+// Write sends one complete message as one event
+func (s *serverSentEventsConnection) Write(p []byte) (n int, err error) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	if s.closed {
+		return 0, io.ErrClosedPipe
+	}
+	data := p
+	if s.binary {
+		data = make([]byte, base64.StdEncoding.EncodedLen(len(p)))
+		base64.StdEncoding.Encode(data, p)
+	}
+	var event bytes.Buffer
+	// Each line of the message is a data field of the event, an empty line ends the event
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		event.WriteString("data: ")
+		event.Write(line)
+		event.WriteString("\r\n")
+	}
+	event.WriteString("\r\n")
+	if _, err = s.w.Write(event.Bytes()); err != nil {
+		return 0, err
+	}
+	if s.flusher != nil {
+		if s.flushInterval <= 0 {
+			s.flusher.Flush()
+		} else if !s.flushPending {
+			s.flushPending = true
+			s.clock.AfterFunc(s.flushInterval, s.flushEvents)
+		}
+	}
+	return len(p), nil
+}
+
+// flushEvents flushes the events written since the last flush, unless the event stream has ended
+func (s *serverSentEventsConnection) flushEvents() {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.flushPending = false
+	if !s.closed {
+		s.flusher.Flush()
+	}
+}
+
+// Close ends the event stream. The server side reader gets io.EOF
+func (s *serverSentEventsConnection) Close() error {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	if !s.closed {
+		s.closed = true
+		_ = s.writer.Close()
+		close(s.done)
+	}
+	return nil
+}
+
+// closeByClient closes the connection for the DELETE request of the client
+func (s *serverSentEventsConnection) closeByClient() {
+	s.mx.Lock()
+	s.stopped = true
+	s.mx.Unlock()
+	_ = s.Close()
+}
+
+// closedByClient returns if the client closed the connection with a DELETE request
+func (s *serverSentEventsConnection) closedByClient() bool {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	return s.stopped
+}
+
+// setTransferFormat switches the connection to base64 encoded events for binary hub protocols
+func (s *serverSentEventsConnection) setTransferFormat(format string) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.binary = format == "Binary"
+}
+
+// send copies the body of a send request to the server side reader
+func (s *serverSentEventsConnection) send(body io.Reader) error {
+	_, err := io.Copy(s.writer, body)
+	return err
+}
@@ -0,0 +1,72 @@
+package signalr
+
+import (
+	"net/http"
+	"strings"
+)
+
+// isServerSentEvents returns if req belongs to the Server-Sent Events transport:
+// it is an event stream request or it is sent to a live Server-Sent Events connection
+func (s *Server) isServerSentEvents(req *http.Request) bool {
+	if req.Method == "GET" {
+		return strings.Contains(req.Header.Get("Accept"), "text/event-stream")
+	}
+	_, ok := s.serverSentEventsConnections.Load(req.URL.Query().Get("id"))
+	return ok
+}
+
+func (s *Server) serverSentEventsHandler(w http.ResponseWriter, req *http.Request) {
+	connectionID := req.URL.Query().Get("id")
+	if len(connectionID) == 0 {
+		http.Error(w, errConnectionIDRequired, 400)
+		return
+	}
+	switch req.Method {
+	case "GET":
+		fallback := s.connections.fallback(connectionID)
+		negotiateHeader, ok := s.connections.claimNegotiated(connectionID)
+		if !ok {
+			http.Error(w, errUnknownConnectionID, 404)
+			return
+		}
+		s.reportFallback(connectionID, TransportServerSentEvents, fallback)
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.WriteHeader(200)
+		if flusher, ok := w.(http.Flusher); ok {
+			flusher.Flush()
+		}
+		conn := newServerSentEventsConnection(connectionID, s.newRequestMetadata(req, negotiateHeader), w, s.clock, s.transportOptions.ServerSentEventsFlushInterval)
+		s.serverSentEventsConnections.Store(connectionID, conn)
+		defer s.serverSentEventsConnections.Delete(connectionID)
+		go func() {
+			s.Run(conn)
+			_ = conn.Close()
+		}()
+		// The event stream ends when the connection is closed, the remaining cleanup of Run does not delay it
+		select {
+		case <-conn.done:
+		case <-req.Context().Done():
+			// The client closed the event stream
+			_ = conn.Close()
+		}
+	case "POST":
+		conn, ok := s.serverSentEventsConnections.Load(connectionID)
+		if !ok {
+			http.Error(w, errUnknownConnectionID, 404)
+			return
+		}
+		if err := conn.(*serverSentEventsConnection).send(req.Body); err != nil {
+			http.Error(w, errUnknownConnectionID, 404)
+			return
+		}
+		w.WriteHeader(200)
+	case "DELETE":
+		if conn, ok := s.serverSentEventsConnections.Load(connectionID); ok {
+			conn.(*serverSentEventsConnection).closeByClient()
+		}
+		w.WriteHeader(202)
+	default:
+		w.WriteHeader(405)
+	}
+}
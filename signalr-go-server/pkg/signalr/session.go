@@ -0,0 +1,273 @@
+package signalr
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+const defaultSessionTimeout = 2 * time.Minute
+
+const defaultMaxPendingMessages = 100
+
+// sessionTokenField is the field of the handshake response which carries the session token,
+// and of the handshake request which resumes a session
+const sessionTokenField = "sessionToken"
+
+// SessionOptions configures the resumable sessions of a server, see ResumableSessions.
+// A zero Timeout is 2 minutes, a zero MaxPendingMessages is 100
+type SessionOptions struct {
+	// Timeout is the time a session is kept for its client after the connection of the session dropped
+	Timeout time.Duration
+	// MaxPendingMessages is the number of messages kept for a dropped session. When it is exceeded, the oldest are dropped
+	MaxPendingMessages int
+}
+
+// ResumableSessions lets a client whose connection dropped, e.g. a mobile client switching networks, resume
+// its session on a new connection. The handshake response of each connection carries a "sessionToken" field.
+// When a connection drops without the client closing it, its session is kept for the Timeout: its groups, its tags,
+// the features attached to it by the hub or middleware and the messages sent to it, up to MaxPendingMessages.
+// A client sending the token as "sessionToken" field of the handshake request of a new connection gets the
+// session transferred to the new connection, and the pending messages are sent to it first. The new connection
+// must have the same user and tenant, and each token resumes a session only once.
+// Sessions live above the transports: The new connection has its own connection ID, and OnConnected and
+// OnDisconnected are called for each connection, unless the server has a DisconnectGrace. Members of the groups of a session are notified that it left
+// only when the session expires. Connections which select their protocol by WebSocket subprotocol get no token.
+// By default, sessions end with their connection
+func ResumableSessions(options SessionOptions) Option {
+	return func(s *Server) {
+		if options.Timeout <= 0 {
+			options.Timeout = defaultSessionTimeout
+		}
+		if options.MaxPendingMessages <= 0 {
+			options.MaxPendingMessages = defaultMaxPendingMessages
+		}
+		s.sessions = &sessionRegistry{options: options, detached: make(map[string]*detachedSession)}
+	}
+}
+
+// sessionRegistry keeps the sessions whose connection dropped by the token issued to their client
+type sessionRegistry struct {
+	options  SessionOptions
+	clock    Clock
+	mx       sync.Mutex
+	detached map[string]*detachedSession
+}
+
+// detachedSession is a session waiting for its client, represented in its lifetime manager by a parkedConnection
+type detachedSession struct {
+	lifetimeManager *defaultHubLifetimeManager
+	parked          *parkedConnection
+	features        map[string]interface{}
+}
+
+// expire ends the session like a connection which dropped
+func (d *detachedSession) expire() {
+	d.lifetimeManager.OnDisconnected(d.parked)
+}
+
+// connectionSession is the session of one connection, nil if the server has no resumable sessions
+type connectionSession struct {
+	registry *sessionRegistry
+	// token is the token issued to the client in the handshake response, empty until then
+	token string
+	// resumed is the session claimed with the token of the handshake request, nil if none was claimed
+	resumed *detachedSession
+}
+
+// newSession returns the session of a new connection
+func (r *sessionRegistry) newSession() *connectionSession {
+	if r == nil {
+		return nil
+	}
+	return &connectionSession{registry: r}
+}
+
+// claim removes the detached session of token
+func (r *sessionRegistry) claim(token string) *detachedSession {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	detached := r.detached[token]
+	delete(r.detached, token)
+	return detached
+}
+
+// handshakeFunc returns a HandshakeFunc which claims the session resumed by the handshake request and adds a new token
+// from newToken to the fields returned by handler
+func (c *connectionSession) handshakeFunc(handler HandshakeFunc, newToken func() string) HandshakeFunc {
+	if c == nil {
+		return handler
+	}
+	return func(request HandshakeRequest) (map[string]interface{}, error) {
+		fields := map[string]interface{}{}
+		if handler != nil {
+			var err error
+			if fields, err = handler(request); err != nil {
+				return nil, err
+			}
+			if fields == nil {
+				fields = map[string]interface{}{}
+			}
+		}
+		var token string
+		if raw, ok := request.Fields[sessionTokenField]; ok && json.Unmarshal(raw, &token) == nil && token != "" {
+			c.resumed = c.registry.claim(token)
+		}
+		c.token = newToken()
+		fields[sessionTokenField] = c.token
+		return fields, nil
+	}
+}
+
+// resume transfers the claimed session to hubConn. It returns false if there is none, or if it belongs to
+// another tenant or user, which ends it
+func (c *connectionSession) resume(lifetimeManager *defaultHubLifetimeManager, hubConn hubConnection) bool {
+	if c == nil || c.resumed == nil {
+		return false
+	}
+	resumed := c.resumed
+	c.resumed = nil
+	if resumed.lifetimeManager != lifetimeManager || resumed.parked.userID != hubConn.GetUserID() {
+		resumed.expire()
+		return false
+	}
+	for name, value := range resumed.features {
+		if _, ok := hubConn.Features().Get(name); !ok {
+			hubConn.Features().Set(name, value)
+		}
+	}
+	resumed.parked.forward(hubConn)
+	lifetimeManager.transfer(resumed.parked.connectionID, hubConn)
+	return true
+}
+
+// discard ends the claimed session if it has not been resumed, e.g. when the connection could not be started
+func (c *connectionSession) discard() {
+	if c != nil && c.resumed != nil {
+		c.resumed.expire()
+		c.resumed = nil
+	}
+}
+
+// claimed returns the session claimed with the token of the handshake request, nil if none was claimed
+func (c *connectionSession) claimed() *detachedSession {
+	if c == nil {
+		return nil
+	}
+	return c.resumed
+}
+
+// issued returns if the client got a token
+func (c *connectionSession) issued() bool {
+	return c != nil && c.token != ""
+}
+
+// detach keeps the session of hubConn for its client. It returns nil if the client got no token
+func (c *connectionSession) detach(lifetimeManager *defaultHubLifetimeManager, hubConn hubConnection) *detachedSession {
+	if !c.issued() {
+		return nil
+	}
+	detached := &detachedSession{
+		lifetimeManager: lifetimeManager,
+		parked: &parkedConnection{
+			connectionID: hubConn.GetConnectionID(),
+			userID:       hubConn.GetUserID(),
+			features:     hubConn.Features(),
+			max:          c.registry.options.MaxPendingMessages,
+		},
+		features: make(map[string]interface{}),
+	}
+	for _, name := range hubConn.Features().Names() {
+		if !transportFeatures[name] {
+			detached.features[name], _ = hubConn.Features().Get(name)
+		}
+	}
+	lifetimeManager.transfer(hubConn.GetConnectionID(), detached.parked)
+	r, token := c.registry, c.token
+	r.mx.Lock()
+	r.detached[token] = detached
+	r.mx.Unlock()
+	go func() {
+		<-r.clock.After(r.options.Timeout)
+		// Tokens are issued once, so the session is still detached if it can be claimed
+		if r.claim(token) == detached {
+			detached.expire()
+		}
+	}()
+	return detached
+}
+
+// transportFeatures are the features published by the transports, which the connection resuming a session has its own of
+var transportFeatures = map[string]bool{
+	FeatureTransport:  true,
+	FeatureBinary:     true,
+	FeatureRemoteAddr: true,
+	FeatureClientIP:   true,
+	FeatureTLS:        true,
+	FeatureHost:       true,
+	FeatureTenant:     true,
+	FeatureClaims:     true,
+	FeatureLocale:     true,
+}
+
+// parkedConnection stands in for the connection of a detached session in its lifetime manager, so the session
+// stays in its groups and keeps the messages sent to it. Once the session is resumed, it forwards them
+type parkedConnection struct {
+	connectionID string
+	userID       string
+	features     *Features
+	max          int
+	mx           sync.Mutex
+	pending      []*preparedMessage
+	resumed      hubConnection
+}
+
+// forward sends the pending messages to conn, and all messages sent to the parkedConnection from now on
+func (p *parkedConnection) forward(conn hubConnection) {
+	p.mx.Lock()
+	defer p.mx.Unlock()
+	for _, message := range p.pending {
+		conn.SendPrepared(message)
+	}
+	p.pending = nil
+	p.resumed = conn
+}
+
+func (p *parkedConnection) SendPrepared(message *preparedMessage) {
+	p.mx.Lock()
+	defer p.mx.Unlock()
+	if p.resumed != nil {
+		p.resumed.SendPrepared(message)
+		return
+	}
+	if p.pending = append(p.pending, message); len(p.pending) > p.max {
+		p.pending = p.pending[len(p.pending)-p.max:]
+	}
+}
+
+func (p *parkedConnection) SendInvocation(target string, args []interface{}) {
+	p.SendPrepared(newPreparedInvocation(target, args))
+}
+
+func (p *parkedConnection) InvokeWithResult(string, []interface{}) (string, <-chan CompletionMessage, error) {
+	return "", nil, ErrConnectionClosed
+}
+
+func (p *parkedConnection) Start()                                    {}
+func (p *parkedConnection) IsConnected() bool                         { return false }
+func (p *parkedConnection) Close(string)                              {}
+func (p *parkedConnection) SetDisconnectReason(DisconnectReason)      {}
+func (p *parkedConnection) SetRoundTrip(time.Duration)                {}
+func (p *parkedConnection) DisconnectReason() DisconnectReason        { return DisconnectTransportError }
+func (p *parkedConnection) GetConnectionID() string                   { return p.connectionID }
+func (p *parkedConnection) GetUserID() string                         { return p.userID }
+func (p *parkedConnection) GetProtocolName() string                   { return "" }
+func (p *parkedConnection) Features() *Features                       { return p.features }
+func (p *parkedConnection) Receive() (interface{}, error)             { return nil, io.EOF }
+func (p *parkedConnection) Stats() ConnectionStats                    { return ConnectionStats{} }
+func (p *parkedConnection) CompleteInvocation(CompletionMessage) bool { return false }
+func (p *parkedConnection) CancelInvocation(string)                   {}
+func (p *parkedConnection) StreamItem(string, interface{})            {}
+func (p *parkedConnection) Completion(string, interface{}, string)    {}
+func (p *parkedConnection) Ping()                                     {}
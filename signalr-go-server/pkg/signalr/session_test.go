@@ -0,0 +1,110 @@
+package signalr
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"./signalrtest"
+	"golang.org/x/net/websocket"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type sessionHub struct {
+	Hub
+}
+
+func (s *sessionHub) Join(ctx ConnectionContext, groupName string) {
+	ctx.Features().Set("note", "joined "+groupName)
+	_ = s.Groups().AddToGroup(groupName, ctx.ConnectionID())
+}
+
+func (s *sessionHub) Note(ctx ConnectionContext) interface{} {
+	note, _ := ctx.Features().Get("note")
+	return note
+}
+
+var _ = Describe("Sessions", func() {
+
+	Describe("Server with resumable sessions", func() {
+		clock := signalrtest.NewFakeClock(time.Now())
+		mux := http.NewServeMux()
+		server := MapHub(mux, "/hub", &sessionHub{}, UseClock(clock), ResumableSessions(SessionOptions{Timeout: time.Minute, MaxPendingMessages: 2}))
+		var httpServer *httptest.Server
+		// connect dials the hub and returns the connection and the session token of the handshake response
+		connect := func(handshake string) (*websocket.Conn, string) {
+			ws, err := websocket.Dial("ws"+strings.TrimPrefix(httpServer.URL, "http")+"/hub", "", httpServer.URL)
+			Expect(err).To(BeNil())
+			Expect(websocket.Message.Send(ws, handshake+"\u001e")).To(Succeed())
+			var response string
+			Expect(websocket.Message.Receive(ws, &response)).To(Succeed())
+			var fields map[string]string
+			Expect(json.Unmarshal([]byte(strings.TrimSuffix(response, "\u001e")), &fields)).To(Succeed())
+			return ws, fields["sessionToken"]
+		}
+		receive := func(ws *websocket.Conn) map[string]interface{} {
+			for {
+				var message string
+				Expect(websocket.Message.Receive(ws, &message)).To(Succeed())
+				var fields map[string]interface{}
+				Expect(json.Unmarshal([]byte(strings.TrimSuffix(message, "\u001e")), &fields)).To(Succeed())
+				if fields["type"] != float64(6) {
+					return fields
+				}
+			}
+		}
+		var token string
+		Context("When the connection of a session drops", func() {
+			It("should keep the session in its groups", func() {
+				httpServer = httptest.NewServer(mux)
+				var ws *websocket.Conn
+				ws, token = connect(`{"protocol":"json","version":1}`)
+				Expect(token).NotTo(BeEmpty())
+				Expect(websocket.Message.Send(ws, `{"type":1,"invocationId":"join","target":"join","arguments":["team"]}`+"\u001e")).To(Succeed())
+				Expect(receive(ws)["invocationId"]).To(Equal("join"))
+				Expect(ws.Close()).To(Succeed())
+				Eventually(func() []DebugConnection { return server.Debug().Connections }).Should(BeEmpty())
+				Expect(server.Debug().Groups).To(HaveLen(1))
+				for _, text := range []string{"one", "two", "three"} {
+					server.HubContext().Clients().Group("team").Send("news", text)
+				}
+			})
+		})
+		Context("When the client resumes the session", func() {
+			It("should transfer its groups, features and the last pending messages to the new connection", func() {
+				defer httpServer.Close()
+				ws, newToken := connect(`{"protocol":"json","version":1,"sessionToken":"` + token + `"}`)
+				defer ws.Close()
+				Expect(newToken).NotTo(BeEmpty())
+				Expect(newToken).NotTo(Equal(token))
+				Expect(receive(ws)["arguments"]).To(Equal([]interface{}{"two"}))
+				Expect(receive(ws)["arguments"]).To(Equal([]interface{}{"three"}))
+				server.HubContext().Clients().Group("team").Send("news", "four")
+				Expect(receive(ws)["arguments"]).To(Equal([]interface{}{"four"}))
+				Expect(websocket.Message.Send(ws, `{"type":1,"invocationId":"note","target":"note"}`+"\u001e")).To(Succeed())
+				Expect(receive(ws)["result"]).To(Equal("joined team"))
+				Expect(server.Debug().Groups[0].Members).To(Equal([]string{server.Debug().Connections[0].ConnectionID}))
+			})
+		})
+		Context("When a session is not resumed in time", func() {
+			It("should remove it from its groups", func() {
+				httpServer = httptest.NewServer(mux)
+				defer httpServer.Close()
+				ws, _ := connect(`{"protocol":"json","version":1}`)
+				Expect(websocket.Message.Send(ws, `{"type":1,"invocationId":"join","target":"join","arguments":["solo"]}`+"\u001e")).To(Succeed())
+				Expect(receive(ws)["invocationId"]).To(Equal("join"))
+				Expect(ws.Close()).To(Succeed())
+				Eventually(func() []DebugConnection { return server.Debug().Connections }).Should(BeEmpty())
+				Expect(server.Debug().Groups).To(ContainElement(HaveField("Name", "solo")))
+				Eventually(func() []DebugGroup {
+					clock.Advance(time.Minute)
+					return server.Debug().Groups
+				}).ShouldNot(ContainElement(HaveField("Name", "solo")))
+			})
+		})
+	})
+})
@@ -0,0 +1,63 @@
+package signalrtest
+
+import (
+	"strings"
+	"time"
+)
+
+// Timeout is the time the Assert functions wait for their condition. Default is one second
+var Timeout = time.Second
+
+// TestingT is the part of testing.TB used by the Assert functions, e.g. a *testing.T or GinkgoT()
+type TestingT interface {
+	Helper()
+	Fatalf(format string, args ...interface{})
+}
+
+// GroupMembers is the part of signalr.GroupManager used by AssertInGroup, e.g. the Groups() of a hub or HubContext
+type GroupMembers interface {
+	Members(groupName string) []string
+}
+
+// AssertInvoked waits until client has received an invocation of target whose arguments match, and fails t if
+// it does not within Timeout. Targets are compared case-insensitively. A nil match accepts all arguments.
+// Arguments are decoded from json, numbers are float64 and objects map[string]interface{}
+func AssertInvoked(t TestingT, client *Client, target string, match func(args []interface{}) bool) {
+	t.Helper()
+	deadline := time.After(Timeout)
+	for {
+		invocations, changed := client.receivedInvocations()
+		for _, invocation := range invocations {
+			if strings.EqualFold(invocation.Target, target) && (match == nil || match(invocation.Arguments)) {
+				return
+			}
+		}
+		select {
+		case <-changed:
+		case <-deadline:
+			t.Fatalf("signalrtest: %v did not receive a matching invocation of %v within %v, received %v",
+				client.ConnectionID(), target, Timeout, invocations)
+			return
+		}
+	}
+}
+
+// AssertInGroup waits until the connection with connectionID is a member of the group, and fails t if it is not
+// within Timeout
+func AssertInGroup(t TestingT, groups GroupMembers, groupName string, connectionID string) {
+	t.Helper()
+	deadline := time.Now().Add(Timeout)
+	for {
+		members := groups.Members(groupName)
+		for _, member := range members {
+			if member == connectionID {
+				return
+			}
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("signalrtest: %v is not in group %v within %v, members are %v", connectionID, groupName, Timeout, members)
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
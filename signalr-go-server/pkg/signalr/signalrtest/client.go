@@ -0,0 +1,107 @@
+package signalrtest
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"strings"
+	"sync"
+)
+
+// Invocation is an invocation a Client received from the server
+type Invocation struct {
+	Target    string
+	Arguments []interface{}
+}
+
+// Client is a signalr.Connection for hub tests, which talks the json hub protocol. Start it with Server.Run,
+// invoke hub methods with Invoke and check what the server sent to it with AssertInvoked
+type Client struct {
+	id     string
+	reader io.Reader
+	writer *io.PipeWriter
+	mx     sync.Mutex
+	// received keeps the data of a partially received message
+	received    []byte
+	invocations []Invocation
+	// changed is closed and replaced when an invocation is received
+	changed chan struct{}
+}
+
+// NewClient creates a Client with connectionID. Its handshake selects the json hub protocol
+func NewClient(connectionID string) *Client {
+	reader, writer := io.Pipe()
+	return &Client{
+		id:      connectionID,
+		reader:  io.MultiReader(strings.NewReader(`{"protocol":"json","version":1}`+"\u001e"), reader),
+		writer:  writer,
+		changed: make(chan struct{}),
+	}
+}
+
+// ConnectionID returns the connection ID of the client
+func (c *Client) ConnectionID() string {
+	return c.id
+}
+
+// Read returns the data the client sends to the server
+func (c *Client) Read(p []byte) (int, error) {
+	return c.reader.Read(p)
+}
+
+// Write receives data from the server and records the invocations in it
+func (c *Client) Write(p []byte) (int, error) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	c.received = append(c.received, p...)
+	for {
+		end := bytes.IndexByte(c.received, 30)
+		if end == -1 {
+			return len(p), nil
+		}
+		var message struct {
+			Type      int           `json:"type"`
+			Target    string        `json:"target"`
+			Arguments []interface{} `json:"arguments"`
+		}
+		if err := json.Unmarshal(c.received[:end], &message); err == nil && message.Type == 1 {
+			c.invocations = append(c.invocations, Invocation{Target: message.Target, Arguments: message.Arguments})
+			close(c.changed)
+			c.changed = make(chan struct{})
+		}
+		c.received = c.received[end+1:]
+	}
+}
+
+// Invoke invokes the hub method target with args, without waiting for a result.
+// It returns when the server has read the invocation
+func (c *Client) Invoke(target string, args ...interface{}) error {
+	if args == nil {
+		args = []interface{}{}
+	}
+	data, err := json.Marshal(map[string]interface{}{"type": 1, "target": target, "arguments": args})
+	if err != nil {
+		return err
+	}
+	_, err = c.writer.Write(append(data, 30))
+	return err
+}
+
+// Close ends the connection, as if the client had gone away
+func (c *Client) Close() error {
+	return c.writer.Close()
+}
+
+// Invocations returns the invocations the client has received, in the order they arrived
+func (c *Client) Invocations() []Invocation {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	return append([]Invocation(nil), c.invocations...)
+}
+
+// receivedInvocations returns the received invocations and a channel which is closed when the next one arrives
+func (c *Client) receivedInvocations() ([]Invocation, <-chan struct{}) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	return append([]Invocation(nil), c.invocations...), c.changed
+}
@@ -0,0 +1,92 @@
+// Package signalrtest provides utilities for testing code using package signalr
+package signalrtest
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// FakeClock is a signalr.Clock whose time only advances by Advance
+type FakeClock struct {
+	mx     sync.Mutex
+	now    time.Time
+	timers []*fakeTimer
+}
+
+type fakeTimer struct {
+	when time.Time
+	fire func()
+}
+
+// NewFakeClock returns a FakeClock starting at now
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+// Now returns the current time of the clock
+func (c *FakeClock) Now() time.Time {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	return c.now
+}
+
+// After returns a channel which receives the time of the clock when it has been advanced by d
+func (c *FakeClock) After(d time.Duration) <-chan time.Time {
+	ch := make(chan time.Time, 1)
+	c.add(d, func() { ch <- c.Now() })
+	return ch
+}
+
+// AfterFunc calls f in its own goroutine when the clock has been advanced by d
+func (c *FakeClock) AfterFunc(d time.Duration, f func()) func() bool {
+	timer := c.add(d, func() { go f() })
+	return func() bool {
+		c.mx.Lock()
+		defer c.mx.Unlock()
+		for i, t := range c.timers {
+			if t == timer {
+				c.timers = append(c.timers[:i], c.timers[i+1:]...)
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Advance advances the clock by d and fires all timers which are due, in the order of their due time
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mx.Lock()
+	c.now = c.now.Add(d)
+	var due []*fakeTimer
+	var pending []*fakeTimer
+	for _, t := range c.timers {
+		if t.when.After(c.now) {
+			pending = append(pending, t)
+		} else {
+			due = append(due, t)
+		}
+	}
+	c.timers = pending
+	c.mx.Unlock()
+	sort.SliceStable(due, func(i, j int) bool { return due[i].when.Before(due[j].when) })
+	for _, t := range due {
+		t.fire()
+	}
+}
+
+// Timers returns the number of timers which have not fired yet. Tests can wait for code
+// under test to start a timer before advancing the clock
+func (c *FakeClock) Timers() int {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	return len(c.timers)
+}
+
+func (c *FakeClock) add(d time.Duration, fire func()) *fakeTimer {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	timer := &fakeTimer{when: c.now.Add(d), fire: fire}
+	c.timers = append(c.timers, timer)
+	return timer
+}
@@ -0,0 +1,59 @@
+package signalr
+
+import "time"
+
+// ConnectionStats are the statistics of a connection
+type ConnectionStats struct {
+	ConnectionID string
+	// QueueDepth is the number of messages waiting to be written to the connection, including the one being written
+	QueueDepth int
+	// Latency is the time from sending a message until it has been written to the connection.
+	// While a message is being written, it is at least the time this message is waiting
+	Latency time.Duration
+	// MessagesIn and MessagesOut are the messages received from and sent to the client
+	MessagesIn  int64
+	MessagesOut int64
+	// MessagesExpired are the invocations dropped because they were not written within the MessageTTL
+	MessagesExpired int64
+	// BytesIn and BytesOut are the bytes received from and sent to the client
+	BytesIn  int64
+	BytesOut int64
+	// RoundTrip is the last round trip time measured with MeasureRoundTrip, 0 if none has been measured
+	RoundTrip time.Duration
+}
+
+// SlowConsumerAction is what happens to a connection which lags behind
+type SlowConsumerAction int
+
+const (
+	// DropMessages drops the invocations sent to the connection while it lags behind
+	DropMessages SlowConsumerAction = iota
+	// Disconnect closes the connection
+	Disconnect
+)
+
+// SlowConsumerPolicy decides when a connection lags behind and what happens to it.
+// A connection lags behind when its QueueDepth exceeds MaxQueueDepth or its Latency exceeds MaxLatency.
+// A zero MaxQueueDepth or MaxLatency is not checked. The policy is applied when an invocation is sent to the connection.
+// OnSlowConsumer, if set, is called with the stats of the connection each time the Action is taken
+type SlowConsumerPolicy struct {
+	MaxQueueDepth  int
+	MaxLatency     time.Duration
+	Action         SlowConsumerAction
+	OnSlowConsumer func(stats ConnectionStats, action SlowConsumerAction)
+}
+
+func (p *SlowConsumerPolicy) lagging(stats ConnectionStats) bool {
+	return (p.MaxQueueDepth > 0 && stats.QueueDepth > p.MaxQueueDepth) ||
+		(p.MaxLatency > 0 && stats.Latency > p.MaxLatency)
+}
+
+// ConnectionStats returns the outbound statistics of all connections of the server
+func (s *Server) ConnectionStats() []ConnectionStats {
+	hubConns := s.connections.hubConnections()
+	stats := make([]ConnectionStats, 0, len(hubConns))
+	for _, hubConn := range hubConns {
+		stats = append(stats, hubConn.Stats())
+	}
+	return stats
+}
@@ -0,0 +1,87 @@
+package signalr
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+)
+
+// SpooledUpload is an upload stream received by SpoolUpload. Read reads the uploaded bytes, Close must be
+// called when the upload has been processed, to remove the temporary file of large uploads
+type SpooledUpload struct {
+	size   int64
+	memory *bytes.Reader
+	file   *os.File
+}
+
+// SpoolUpload receives all chunks of an upload stream, e.g. the `<-chan []byte` parameter of a hub method for
+// file uploads. Chunks are kept in memory until they exceed memoryLimit bytes, then the upload is written to
+// a temporary file in dir, or in the default directory for temporary files if dir is empty.
+// The stream is received completely also when writing the file fails, so the connection is not blocked
+func SpoolUpload(chunks <-chan []byte, memoryLimit int64, dir string) (*SpooledUpload, error) {
+	var buf bytes.Buffer
+	var file *os.File
+	var size int64
+	var err error
+	for chunk := range chunks {
+		if err != nil {
+			continue
+		}
+		size += int64(len(chunk))
+		if file == nil && size <= memoryLimit {
+			buf.Write(chunk)
+			continue
+		}
+		if file == nil {
+			if file, err = ioutil.TempFile(dir, "signalr-upload-"); err != nil {
+				continue
+			}
+			_, err = buf.WriteTo(file)
+		}
+		if err == nil {
+			_, err = file.Write(chunk)
+		}
+	}
+	if file != nil && err == nil {
+		_, err = file.Seek(0, io.SeekStart)
+	}
+	upload := &SpooledUpload{size: size, file: file}
+	if file == nil {
+		upload.memory = bytes.NewReader(buf.Bytes())
+	}
+	if err != nil {
+		_ = upload.Close()
+		return nil, err
+	}
+	return upload, nil
+}
+
+// Size returns the number of bytes uploaded
+func (s *SpooledUpload) Size() int64 {
+	return s.size
+}
+
+// Spilled returns if the upload has been written to a temporary file
+func (s *SpooledUpload) Spilled() bool {
+	return s.file != nil
+}
+
+func (s *SpooledUpload) Read(p []byte) (int, error) {
+	if s.file != nil {
+		return s.file.Read(p)
+	}
+	return s.memory.Read(p)
+}
+
+// Close removes the temporary file of the upload
+func (s *SpooledUpload) Close() error {
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	if removeErr := os.Remove(s.file.Name()); err == nil {
+		err = removeErr
+	}
+	return err
+}
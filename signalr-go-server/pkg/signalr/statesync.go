@@ -0,0 +1,165 @@
+package signalr
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// PatchOperation is an operation of a RFC 6902 JSON Patch
+type PatchOperation struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	Value interface{} `json:"value"`
+}
+
+// MarshalJSON encodes the operation without value if it is a remove, the value of add and replace may be null
+func (p PatchOperation) MarshalJSON() ([]byte, error) {
+	if p.Op == "remove" {
+		return json.Marshal(struct {
+			Op   string `json:"op"`
+			Path string `json:"path"`
+		}{p.Op, p.Path})
+	}
+	type operation PatchOperation
+	return json.Marshal(operation(p))
+}
+
+// StateUpdate is the argument of the client method of a StateSync. The first update of a key and each Resync carry
+// the complete State, the following ones the Patch which turns the state of the previous update into the new one.
+// Version counts the updates of the key, a client which receives a patch whose Version is not the version of its
+// state + 1 has missed an update and should ask the hub for a Resync
+type StateUpdate struct {
+	Version int64            `json:"version"`
+	State   interface{}      `json:"state,omitempty"`
+	Patch   []PatchOperation `json:"patch,omitempty"`
+}
+
+// StateSync sends state to clients, e.g. the state of a dashboard, as JSON Patch diffs against the state it sent
+// before, instead of the complete state with each change. The state is kept by key, e.g. a connection ID or a group
+// name, and its diffs are computed on the encoding/json encoding of the state. Arrays whose length changes are
+// replaced completely.
+//
+// The client method target gets one StateUpdate. A client keeps the state of the last update: it takes State if the
+// update has one, or applies Patch to its state as described by RFC 6902, e.g. with the fast-json-patch package.
+// Clients joining a group whose state has been sent before get the complete state with Resync, e.g. in the hub method
+// which adds them to the group. Keys which are no longer used are removed with Forget, e.g. in OnDisconnected
+type StateSync struct {
+	target string
+	mx     sync.Mutex
+	states map[string]*syncedState
+}
+
+// syncedState is the state last sent for a key
+type syncedState struct {
+	version int64
+	value   interface{}
+}
+
+// NewStateSync creates a StateSync which sends the updates to the client method target
+func NewStateSync(target string) *StateSync {
+	return &StateSync{target: target, states: make(map[string]*syncedState)}
+}
+
+// Send sends state as the state of key to clients. Nothing is sent if it has not changed since the last Send for key
+func (s *StateSync) Send(clients ClientProxy, key string, state interface{}) error {
+	value, err := jsonValue(state)
+	if err != nil {
+		return err
+	}
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	last, ok := s.states[key]
+	if !ok {
+		s.states[key] = &syncedState{version: 1, value: value}
+		clients.Send(s.target, StateUpdate{Version: 1, State: value})
+		return nil
+	}
+	patch := diffJSON("", last.value, value, nil)
+	if len(patch) == 0 {
+		return nil
+	}
+	last.version++
+	last.value = value
+	clients.Send(s.target, StateUpdate{Version: last.version, Patch: patch})
+	return nil
+}
+
+// Resync sends the complete state of key to clients, if a state has been sent for key
+func (s *StateSync) Resync(clients ClientProxy, key string) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	if last, ok := s.states[key]; ok {
+		clients.Send(s.target, StateUpdate{Version: last.version, State: last.value})
+	}
+}
+
+// Forget removes the state of key. The next Send for key sends the complete state
+func (s *StateSync) Forget(key string) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	delete(s.states, key)
+}
+
+// jsonValue returns the generic json value of v, as parsed by encoding/json
+func jsonValue(v interface{}) (interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var value interface{}
+	err = json.Unmarshal(data, &value)
+	return value, err
+}
+
+// diffJSON appends the operations which turn the json value from into to at path
+func diffJSON(path string, from, to interface{}, patch []PatchOperation) []PatchOperation {
+	switch from := from.(type) {
+	case map[string]interface{}:
+		if to, ok := to.(map[string]interface{}); ok {
+			keys := make([]string, 0, len(from)+len(to))
+			for key := range from {
+				keys = append(keys, key)
+			}
+			for key := range to {
+				if _, ok := from[key]; !ok {
+					keys = append(keys, key)
+				}
+			}
+			sort.Strings(keys)
+			for _, key := range keys {
+				memberPath := path + "/" + escapePointer(key)
+				fromValue, inFrom := from[key]
+				toValue, inTo := to[key]
+				switch {
+				case !inTo:
+					patch = append(patch, PatchOperation{Op: "remove", Path: memberPath})
+				case !inFrom:
+					patch = append(patch, PatchOperation{Op: "add", Path: memberPath, Value: toValue})
+				default:
+					patch = diffJSON(memberPath, fromValue, toValue, patch)
+				}
+			}
+			return patch
+		}
+	case []interface{}:
+		if to, ok := to.([]interface{}); ok && len(to) == len(from) {
+			for i := range from {
+				patch = diffJSON(path+"/"+strconv.Itoa(i), from[i], to[i], patch)
+			}
+			return patch
+		}
+	}
+	if reflect.DeepEqual(from, to) {
+		return patch
+	}
+	return append(patch, PatchOperation{Op: "replace", Path: path, Value: to})
+}
+
+// escapePointer escapes key as a reference token of a JSON Pointer, RFC 6901
+func escapePointer(key string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(key, "~", "~0"), "/", "~1")
+}
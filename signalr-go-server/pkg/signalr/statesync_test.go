@@ -0,0 +1,82 @@
+package signalr
+
+import (
+	"encoding/json"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// updateProxy is a ClientProxy which keeps the StateUpdates sent to it
+type updateProxy struct {
+	updates []StateUpdate
+}
+
+func (u *updateProxy) Send(target string, args ...interface{}) {
+	Expect(target).To(Equal("state"))
+	u.updates = append(u.updates, args[0].(StateUpdate))
+}
+
+type dashboard struct {
+	Title   string            `json:"title"`
+	Load    float64           `json:"load"`
+	Nodes   []string          `json:"nodes"`
+	Alerts  map[string]string `json:"alerts"`
+	Comment *string           `json:"comment"`
+}
+
+var _ = Describe("StateSync", func() {
+
+	Describe("StateSync sending changed state", func() {
+		states := NewStateSync("state")
+		proxy := &updateProxy{}
+		comment := "maintenance"
+		Context("When the state of a key is sent again", func() {
+			It("should send the complete state first, then patches", func() {
+				Expect(states.Send(proxy, "board", dashboard{Title: "a/b", Load: 1, Nodes: []string{"x", "y"}, Alerts: map[string]string{"cpu": "high"}, Comment: &comment})).To(Succeed())
+				Expect(proxy.updates).To(HaveLen(1))
+				Expect(proxy.updates[0].Version).To(Equal(int64(1)))
+				Expect(proxy.updates[0].State).To(HaveKeyWithValue("title", "a/b"))
+				Expect(states.Send(proxy, "board", dashboard{Title: "a/b", Load: 2, Nodes: []string{"x", "z"}, Alerts: map[string]string{"disk~/": "full"}})).To(Succeed())
+				Expect(proxy.updates).To(HaveLen(2))
+				Expect(proxy.updates[1].Version).To(Equal(int64(2)))
+				Expect(proxy.updates[1].State).To(BeNil())
+				Expect(proxy.updates[1].Patch).To(Equal([]PatchOperation{
+					{Op: "remove", Path: "/alerts/cpu"},
+					{Op: "add", Path: "/alerts/disk~0~1", Value: "full"},
+					{Op: "replace", Path: "/comment", Value: nil},
+					{Op: "replace", Path: "/load", Value: float64(2)},
+					{Op: "replace", Path: "/nodes/1", Value: "z"},
+				}))
+				data, err := json.Marshal(proxy.updates[1].Patch[:3])
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(data)).To(Equal(`[{"op":"remove","path":"/alerts/cpu"},{"op":"add","path":"/alerts/disk~0~1","value":"full"},{"op":"replace","path":"/comment","value":null}]`))
+				Expect(states.Send(proxy, "board", dashboard{Title: "a/b", Load: 2, Nodes: []string{"x"}, Alerts: map[string]string{"disk~/": "full"}})).To(Succeed())
+				Expect(proxy.updates[2].Patch).To(Equal([]PatchOperation{{Op: "replace", Path: "/nodes", Value: []interface{}{"x"}}}))
+			})
+		})
+		Context("When the state has not changed", func() {
+			It("should send nothing", func() {
+				Expect(states.Send(proxy, "board", dashboard{Title: "a/b", Load: 2, Nodes: []string{"x"}, Alerts: map[string]string{"disk~/": "full"}})).To(Succeed())
+				Expect(proxy.updates).To(HaveLen(3))
+			})
+		})
+		Context("When a client is resynced", func() {
+			It("should send it the complete state with the current version", func() {
+				joined := &updateProxy{}
+				states.Resync(joined, "board")
+				Expect(joined.updates).To(HaveLen(1))
+				Expect(joined.updates[0].Version).To(Equal(int64(3)))
+				Expect(joined.updates[0].State).To(HaveKeyWithValue("nodes", []interface{}{"x"}))
+			})
+		})
+		Context("When the key is forgotten", func() {
+			It("should send the complete state again", func() {
+				states.Forget("board")
+				Expect(states.Send(proxy, "board", dashboard{Title: "c"})).To(Succeed())
+				Expect(proxy.updates[3].Version).To(Equal(int64(1)))
+				Expect(proxy.updates[3].State).To(HaveKeyWithValue("title", "c"))
+			})
+		})
+	})
+})
@@ -0,0 +1,140 @@
+package signalr
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// ServerStats is a snapshot of the statistics of a server, e.g. for an admin endpoint or a metrics exporter.
+// The counters are read one after the other, so they are not exactly consistent while connections come and go
+type ServerStats struct {
+	// Connections is the number of connected clients
+	Connections int `json:"connections"`
+	// Transports is the number of connections by transport, "WebSockets" or "LongPolling"
+	Transports map[string]int `json:"transports"`
+	// Protocols is the number of connections by hub protocol
+	Protocols map[string]int `json:"protocols"`
+	// MessagesIn and MessagesOut are the messages received from and sent to clients since the server was created
+	MessagesIn  int64 `json:"messagesIn"`
+	MessagesOut int64 `json:"messagesOut"`
+	// BytesIn and BytesOut are the bytes received from and sent to clients since the server was created
+	BytesIn  int64 `json:"bytesIn"`
+	BytesOut int64 `json:"bytesOut"`
+	// ProtocolErrors is the number of connections closed because the client sent a malformed message
+	ProtocolErrors int64 `json:"protocolErrors"`
+	// UnknownMethods is the number of invocations of methods the hub does not have
+	UnknownMethods int64 `json:"unknownMethods"`
+	// Groups is the number of groups of all tenants
+	Groups int `json:"groups"`
+	// Uptime is the time since the server was created
+	Uptime time.Duration `json:"uptime"`
+	// TransportFallbacks is the number of connections which started on another transport than WebSockets by reason,
+	// see OnTransportFallback
+	TransportFallbacks map[string]int64 `json:"transportFallbacks"`
+	// RoundTrip is the average round trip time of the connections which have been measured, see MeasureRoundTrip
+	RoundTrip time.Duration `json:"roundTrip"`
+	// Methods are the execution statistics of the hub methods, see MethodStats
+	Methods map[string]MethodStats `json:"methods"`
+}
+
+// Stats returns the statistics of the server
+func (s *Server) Stats() ServerStats {
+	stats := ServerStats{
+		Transports:     make(map[string]int),
+		Protocols:      make(map[string]int),
+		MessagesIn:     atomic.LoadInt64(&s.messagesIn),
+		MessagesOut:    atomic.LoadInt64(&s.messagesOut),
+		BytesIn:        atomic.LoadInt64(&s.bytesIn),
+		BytesOut:       atomic.LoadInt64(&s.bytesOut),
+		ProtocolErrors: atomic.LoadInt64(&s.protocolErrors),
+		UnknownMethods: atomic.LoadInt64(&s.unknownMethods),
+		Uptime:         s.clock.Now().Sub(s.started),
+		Methods:        s.MethodStats(),
+	}
+	s.fallbacksMx.Lock()
+	stats.TransportFallbacks = make(map[string]int64, len(s.fallbacks))
+	for reason, count := range s.fallbacks {
+		stats.TransportFallbacks[reason] = count
+	}
+	s.fallbacksMx.Unlock()
+	for _, key := range s.tenantKeys() {
+		stats.Groups += s.tenant(key).lifetimeManager.GroupCount()
+	}
+	measured, roundTrips := 0, time.Duration(0)
+	for _, hubConn := range s.connections.hubConnections() {
+		stats.Connections++
+		if transport, ok := hubConn.Features().Get(FeatureTransport); ok {
+			stats.Transports[transport.(string)]++
+		}
+		stats.Protocols[hubConn.GetProtocolName()]++
+		connectionStats := hubConn.Stats()
+		stats.MessagesIn += connectionStats.MessagesIn
+		stats.MessagesOut += connectionStats.MessagesOut
+		stats.BytesIn += connectionStats.BytesIn
+		stats.BytesOut += connectionStats.BytesOut
+		if connectionStats.RoundTrip > 0 {
+			measured++
+			roundTrips += connectionStats.RoundTrip
+		}
+	}
+	if measured > 0 {
+		stats.RoundTrip = roundTrips / time.Duration(measured)
+	}
+	return stats
+}
+
+// UserStats are the statistics of the connections of one user, e.g. for usage based billing
+type UserStats struct {
+	// Connections is the number of connected clients of the user
+	Connections int `json:"connections"`
+	// BytesIn and BytesOut are the bytes received from and sent to the clients of the user since the server was created
+	BytesIn  int64 `json:"bytesIn"`
+	BytesOut int64 `json:"bytesOut"`
+}
+
+// UserStats returns the statistics of the users which have been connected since the server was created, by user ID.
+// Connections without user are not counted
+func (s *Server) UserStats() map[string]UserStats {
+	s.userBytesMx.Lock()
+	users := make(map[string]UserStats, len(s.userBytes))
+	for userID, stats := range s.userBytes {
+		users[userID] = stats
+	}
+	s.userBytesMx.Unlock()
+	for _, hubConn := range s.connections.hubConnections() {
+		if hubConn.GetUserID() == "" {
+			continue
+		}
+		connectionStats := hubConn.Stats()
+		stats := users[hubConn.GetUserID()]
+		stats.Connections++
+		stats.BytesIn += connectionStats.BytesIn
+		stats.BytesOut += connectionStats.BytesOut
+		users[hubConn.GetUserID()] = stats
+	}
+	return users
+}
+
+// addUserBytes adds the bytes of an ended connection to the stats of its user
+func (s *Server) addUserBytes(userID string, connectionStats ConnectionStats) {
+	if userID == "" {
+		return
+	}
+	s.userBytesMx.Lock()
+	defer s.userBytesMx.Unlock()
+	stats := s.userBytes[userID]
+	stats.BytesIn += connectionStats.BytesIn
+	stats.BytesOut += connectionStats.BytesOut
+	s.userBytes[userID] = stats
+}
+
+// StatsHandler returns a handler which answers with the Stats of the server as JSON, e.g. for an admin endpoint.
+// The uptime is given in nanoseconds
+func (s *Server) StatsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(s.Stats())
+	})
+}
@@ -0,0 +1,90 @@
+package signalr
+
+import (
+	"errors"
+	"net/http/httptest"
+	"time"
+
+	"./signalrtest"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type methodStatsHub struct {
+	Hub
+	clock *signalrtest.FakeClock
+}
+
+func (m *methodStatsHub) Ready() {}
+
+// Sleep takes milliseconds on the clock of the server
+func (m *methodStatsHub) Sleep(milliseconds int) {
+	m.clock.Advance(time.Duration(milliseconds) * time.Millisecond)
+}
+
+func (m *methodStatsHub) Fail() error {
+	return errors.New("failed")
+}
+
+func (m *methodStatsHub) Panic() {
+	panic("panicked")
+}
+
+var _ = Describe("Stats", func() {
+
+	Describe("Stats of a server with connected clients", func() {
+		clock := signalrtest.NewFakeClock(time.Now())
+		server := NewServer(&contextHub{}, UseClock(clock))
+		Context("When the stats are taken", func() {
+			It("should count the connections, messages and groups", func() {
+				connectUser(server, "a", "")
+				connectUser(server, "b", "")
+				Expect(server.HubContext().Groups().AddToGroup("g", "a")).To(Succeed())
+				clock.Advance(time.Minute)
+				stats := server.Stats()
+				Expect(stats.Connections).To(Equal(2))
+				Expect(stats.Protocols).To(Equal(map[string]int{"json": 2}))
+				Expect(stats.MessagesIn).To(BeNumerically(">=", 2))
+				Expect(stats.MessagesOut).To(BeNumerically(">=", 2))
+				Expect(stats.Groups).To(Equal(1))
+				Expect(stats.Uptime).To(Equal(time.Minute))
+				recorder := httptest.NewRecorder()
+				server.StatsHandler().ServeHTTP(recorder, httptest.NewRequest("GET", "/stats", nil))
+				Expect(recorder.Body.String()).To(ContainSubstring(`"connections":2`))
+			})
+		})
+	})
+
+	Describe("Method stats of a server", func() {
+		clock := signalrtest.NewFakeClock(time.Now())
+		server := NewServer(&methodStatsHub{clock: clock}, UseClock(clock))
+		Context("When methods have been invoked", func() {
+			It("should count the calls, errors, latencies and bytes of each method", func() {
+				conn := connectUser(server, "a", "")
+				invoke := func(message string) {
+					_, err := conn.clientSend(message)
+					Expect(err).To(BeNil())
+					<-conn.received
+				}
+				for i := 0; i < 10; i++ {
+					invoke(`{"type":1,"invocationId":"s","target":"sleep","arguments":[1]}`)
+				}
+				invoke(`{"type":1,"invocationId":"s","target":"sleep","arguments":[50]}`)
+				invoke(`{"type":1,"invocationId":"f","target":"fail"}`)
+				invoke(`{"type":1,"invocationId":"p","target":"panic"}`)
+				methods := server.MethodStats()
+				Expect(methods).To(HaveLen(4))
+				sleep := methods["sleep"]
+				Expect(sleep.Calls).To(Equal(int64(11)))
+				Expect(sleep.Errors).To(BeZero())
+				Expect(sleep.P50).To(BeNumerically(">=", time.Millisecond))
+				Expect(sleep.P50).To(BeNumerically("<", 50*time.Millisecond))
+				Expect(sleep.P99).To(BeNumerically(">=", 50*time.Millisecond))
+				Expect(sleep.BytesIn).To(Equal(int64(11 * len(`{"type":1,"invocationId":"s","target":"sleep","arguments":[1]}`+"\u001e") + 1)))
+				Expect(methods["fail"]).To(HaveField("Errors", int64(1)))
+				Expect(methods["panic"]).To(HaveField("Errors", int64(1)))
+				Expect(server.Stats().Methods).To(HaveKey("sleep"))
+			})
+		})
+	})
+})
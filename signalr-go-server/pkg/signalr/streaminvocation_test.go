@@ -1,6 +1,17 @@
 package signalr
 
 import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"time"
+
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 )
@@ -28,6 +39,75 @@ func (s *streamHub) SimpleInt() int {
 	return -1
 }
 
+// Table streams a csv table with rows rows in chunks of 8 bytes
+func (s *streamHub) Table(ctx context.Context, rows int) <-chan []byte {
+	writer := NewStreamWriter(ctx, 8)
+	go func() {
+		defer writer.Close()
+		table := csv.NewWriter(writer)
+		for i := 1; i <= rows; i++ {
+			_ = table.Write([]string{fmt.Sprint(i), strings.Repeat("x", i)})
+		}
+		table.Flush()
+	}()
+	return writer.Stream()
+}
+
+// Pages streams pages pages of two items, numbered from 1
+func (s *streamHub) Pages(ctx context.Context, pages int) <-chan interface{} {
+	return NewPageStream(ctx, countingPages(pages, nil)).Stream()
+}
+
+// countingPages returns a PageFunc for pages pages of two items, which sends each fetched cursor to fetched if not nil
+func countingPages(pages int, fetched chan<- string) PageFunc {
+	return func(ctx context.Context, cursor string) ([]interface{}, string, error) {
+		if fetched != nil {
+			fetched <- cursor
+		}
+		page, _ := strconv.Atoi(cursor)
+		next := ""
+		if page+1 < pages {
+			next = strconv.Itoa(page + 1)
+		}
+		return []interface{}{2*page + 1, 2*page + 2}, next, nil
+	}
+}
+
+type uploadHub struct {
+	Hub
+}
+
+func (u *uploadHub) Upload(upload <-chan int, factor int) int {
+	sum := 0
+	for value := range upload {
+		sum += value
+	}
+	return sum * factor
+}
+
+// UploadFile spools the uploaded file and returns its content, and if it has been spilled to disk
+func (u *uploadHub) UploadFile(chunks <-chan []byte, memoryLimit int64) ([]interface{}, error) {
+	upload, err := SpoolUpload(chunks, memoryLimit, "")
+	if err != nil {
+		return nil, err
+	}
+	defer upload.Close()
+	content, err := ioutil.ReadAll(upload)
+	return []interface{}{string(content), upload.Spilled()}, err
+}
+
+// CountLines reads the uploaded text and returns its number of lines
+func (u *uploadHub) CountLines(chunks <-chan []byte) (int, error) {
+	reader := NewStreamReader(chunks)
+	defer reader.Close()
+	lines := 0
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		lines++
+	}
+	return lines, scanner.Err()
+}
+
 var _ = Describe("Streaminvocation", func() {
 
 	Describe("Simple stream invocation", func() {
@@ -38,12 +118,12 @@ var _ = Describe("Streaminvocation", func() {
 				Expect(err).To(BeNil())
 				Expect(<-streamInvocationQueue).To(Equal("SimpleStream()"))
 				for i := 1; i < 4; i++ {
-					recv := (<-conn.received).(streamItemMessage)
+					recv := (<-conn.received).(StreamItemMessage)
 					Expect(recv).NotTo(BeNil())
 					Expect(recv.InvocationID).To(Equal("zzz"))
 					Expect(recv.Item).To(Equal(float64(i)))
 				}
-				recv := (<-conn.received).(completionMessage)
+				recv := (<-conn.received).(CompletionMessage)
 				Expect(recv).NotTo(BeNil())
 				Expect(recv.InvocationID).To(Equal("zzz"))
 				Expect(recv.Result).To(BeNil())
@@ -52,6 +132,66 @@ var _ = Describe("Streaminvocation", func() {
 		})
 	})
 
+	Describe("Paged stream invocation", func() {
+		conn := connect(&streamHub{})
+		Context("When invoked by the client", func() {
+			It("should return the items of all pages and a final completion", func() {
+				_, err := conn.clientSend(`{"type":4,"invocationId": "pages","target":"pages","arguments":[3]}`)
+				Expect(err).To(BeNil())
+				for i := 1; i <= 6; i++ {
+					recv := (<-conn.received).(StreamItemMessage)
+					Expect(recv.InvocationID).To(Equal("pages"))
+					Expect(recv.Item).To(Equal(float64(i)))
+				}
+				recv := (<-conn.received).(CompletionMessage)
+				Expect(recv.InvocationID).To(Equal("pages"))
+				Expect(recv.Error).To(Equal(""))
+			})
+		})
+	})
+
+	Describe("PageStream", func() {
+		Context("When the items of a page are consumed", func() {
+			It("should fetch the next page only after the last item of the page has been taken", func() {
+				fetched := make(chan string, 3)
+				pages := NewPageStream(context.Background(), countingPages(3, fetched))
+				Expect(<-fetched).To(Equal(""))
+				Expect(<-pages.Stream()).To(Equal(1))
+				Consistently(fetched, 50*time.Millisecond).ShouldNot(Receive())
+				Expect(<-pages.Stream()).To(Equal(2))
+				Expect(<-fetched).To(Equal("1"))
+				var rest []interface{}
+				for item := range pages.Stream() {
+					rest = append(rest, item)
+				}
+				Expect(rest).To(Equal([]interface{}{3, 4, 5, 6}))
+				Expect(pages.Err()).To(BeNil())
+			})
+		})
+		Context("When fetching a page fails", func() {
+			It("should end the stream with the error", func() {
+				pages := NewPageStream(context.Background(), func(ctx context.Context, cursor string) ([]interface{}, string, error) {
+					if cursor == "" {
+						return []interface{}{"first"}, "next", nil
+					}
+					return nil, "", errors.New("query failed")
+				})
+				Expect(<-pages.Stream()).To(Equal("first"))
+				Eventually(pages.Stream()).Should(BeClosed())
+				Expect(pages.Err()).To(MatchError("query failed"))
+			})
+		})
+		Context("When the context ends while a page is consumed", func() {
+			It("should end the stream with the error of the context", func() {
+				ctx, cancel := context.WithCancel(context.Background())
+				pages := NewPageStream(ctx, countingPages(3, nil))
+				Expect(<-pages.Stream()).To(Equal(1))
+				cancel()
+				Eventually(pages.Err).Should(Equal(context.Canceled))
+			})
+		})
+	})
+
 	Describe("Stop simple stream invocation", func() {
 		conn := connect(&streamHub{})
 		Context("When invoked by the client and stop after one result", func() {
@@ -59,7 +199,7 @@ var _ = Describe("Streaminvocation", func() {
 				_, err := conn.clientSend(`{"type":4,"invocationId": "xxx","target":"simplestream"}`)
 				Expect(err).To(BeNil())
 				Expect(<-streamInvocationQueue).To(Equal("SimpleStream()"))
-				recv := (<-conn.received).(streamItemMessage)
+				recv := (<-conn.received).(StreamItemMessage)
 				Expect(recv).NotTo(BeNil())
 				Expect(recv.InvocationID).To(Equal("xxx"))
 				Expect(recv.Item).To(Equal(float64(1)))
@@ -70,11 +210,11 @@ var _ = Describe("Streaminvocation", func() {
 					recv := <-conn.received
 					Expect(recv).NotTo(BeNil())
 					switch recv.(type) {
-					case streamItemMessage:
-						srecv := recv.(streamItemMessage)
+					case StreamItemMessage:
+						srecv := recv.(StreamItemMessage)
 						Expect(srecv.InvocationID).To(Equal("xxx"))
-					case completionMessage:
-						crecv := recv.(completionMessage)
+					case CompletionMessage:
+						crecv := recv.(CompletionMessage)
 						Expect(crecv.InvocationID).To(Equal("xxx"))
 						Expect(crecv.Result).To(BeNil())
 						Expect(crecv.Error).To(Equal(""))
@@ -92,11 +232,11 @@ var _ = Describe("Streaminvocation", func() {
 				_, err := conn.clientSend(`{"type":4,"invocationId": "yyy","target":"simpleint"}`)
 				Expect(err).To(BeNil())
 				Expect(<-streamInvocationQueue).To(Equal("SimpleInt()"))
-				sRecv := (<-conn.received).(streamItemMessage)
+				sRecv := (<-conn.received).(StreamItemMessage)
 				Expect(sRecv).NotTo(BeNil())
 				Expect(sRecv.InvocationID).To(Equal("yyy"))
 				Expect(sRecv.Item).To(Equal(float64(-1)))
-				cRecv := (<-conn.received).(completionMessage)
+				cRecv := (<-conn.received).(CompletionMessage)
 				Expect(cRecv).NotTo(BeNil())
 				Expect(cRecv.InvocationID).To(Equal("yyy"))
 				Expect(cRecv.Result).To(BeNil())
@@ -104,4 +244,118 @@ var _ = Describe("Streaminvocation", func() {
 			})
 		})
 	})
+
+	Describe("Invocation with client stream argument", func() {
+		conn := connect(&uploadHub{})
+		Context("When invoked by the client with a stream id and a regular argument", func() {
+			It("should bind the stream to the chan parameter and return the result after the stream completed", func() {
+				_, err := conn.clientSend(`{"type":1,"invocationId": "up","target":"upload","arguments":[10],"streamIds":["s1"]}`)
+				Expect(err).To(BeNil())
+				for i := 1; i <= 3; i++ {
+					_, err = conn.clientSend(fmt.Sprintf(`{"type":2,"invocationId": "s1","item":%v}`, i))
+					Expect(err).To(BeNil())
+				}
+				_, err = conn.clientSend(`{"type":3,"invocationId": "s1"}`)
+				Expect(err).To(BeNil())
+				recv := (<-conn.received).(CompletionMessage)
+				Expect(recv.InvocationID).To(Equal("up"))
+				Expect(recv.Error).To(Equal(""))
+				Expect(recv.Result).To(Equal(float64(60)))
+			})
+		})
+	})
+
+	Describe("Invocation with a file upload stream", func() {
+		conn := connect(&uploadHub{})
+		upload := func(invocationID string, memoryLimit int) []interface{} {
+			_, err := conn.clientSend(fmt.Sprintf(`{"type":1,"invocationId": "%v","target":"uploadfile","arguments":[%v],"streamIds":["%v"]}`, invocationID, memoryLimit, invocationID+"s"))
+			Expect(err).To(BeNil())
+			for _, chunk := range []string{"aGVs", "bG8g", "d29ybGQ="} {
+				_, err = conn.clientSend(fmt.Sprintf(`{"type":2,"invocationId": "%v","item":"%v"}`, invocationID+"s", chunk))
+				Expect(err).To(BeNil())
+			}
+			_, err = conn.clientSend(fmt.Sprintf(`{"type":3,"invocationId": "%v"}`, invocationID+"s"))
+			Expect(err).To(BeNil())
+			recv := (<-conn.received).(CompletionMessage)
+			Expect(recv.Error).To(Equal(""))
+			return recv.Result.([]interface{})
+		}
+		Context("When the upload fits into the memory limit", func() {
+			It("should keep the upload in memory", func() {
+				Expect(upload("small", 1024)).To(Equal([]interface{}{"hello world", false}))
+			})
+		})
+		Context("When the upload exceeds the memory limit", func() {
+			It("should spill the upload to a temporary file", func() {
+				Expect(upload("large", 4)).To(Equal([]interface{}{"hello world", true}))
+			})
+		})
+	})
+
+	Describe("Invocation with mismatching stream ids", func() {
+		conn := connect(&uploadHub{})
+		Context("When invoked by the client with more streams than chan parameters", func() {
+			It("should return an error", func() {
+				_, err := conn.clientSend(`{"type":1,"invocationId": "up2","target":"upload","arguments":[10],"streamIds":["s1","s2"]}`)
+				Expect(err).To(BeNil())
+				recv := (<-conn.received).(CompletionMessage)
+				Expect(recv.InvocationID).To(Equal("up2"))
+				Expect(recv.Error).NotTo(Equal(""))
+			})
+		})
+		Context("When the client completes an unknown stream", func() {
+			It("should ignore it and keep the connection", func() {
+				_, err := conn.clientSend(`{"type":3,"invocationId": "unknown"}`)
+				Expect(err).To(BeNil())
+				_, err = conn.clientSend(`{"type":1,"invocationId": "up3","target":"upload","arguments":[10]}`)
+				Expect(err).To(BeNil())
+				recv := (<-conn.received).(CompletionMessage)
+				Expect(recv.InvocationID).To(Equal("up3"))
+				Expect(recv.Error).NotTo(Equal(""))
+			})
+		})
+	})
+
+	Describe("Stream invocation of a method writing to a StreamWriter", func() {
+		conn := connect(&streamHub{})
+		Context("When invoked by the client", func() {
+			It("should stream the written bytes in chunks", func() {
+				_, err := conn.clientSend(`{"type":4,"invocationId": "table","target":"table","arguments":[3]}`)
+				Expect(err).To(BeNil())
+				var table strings.Builder
+				for {
+					recv := <-conn.received
+					if completion, ok := recv.(CompletionMessage); ok {
+						Expect(completion.Error).To(Equal(""))
+						break
+					}
+					chunk, err := base64.StdEncoding.DecodeString(recv.(StreamItemMessage).Item.(string))
+					Expect(err).To(BeNil())
+					Expect(len(chunk)).To(BeNumerically("<=", 8))
+					table.Write(chunk)
+				}
+				Expect(table.String()).To(Equal("1,x\n2,xx\n3,xxx\n"))
+			})
+		})
+	})
+
+	Describe("Invocation of a method reading a StreamReader", func() {
+		conn := connect(&uploadHub{})
+		Context("When the client uploads text", func() {
+			It("should read the items as one byte stream", func() {
+				_, err := conn.clientSend(`{"type":1,"invocationId": "lines","target":"countlines","streamIds":["text"]}`)
+				Expect(err).To(BeNil())
+				for _, chunk := range []string{"a\nb", "c\n", "\nd"} {
+					item := base64.StdEncoding.EncodeToString([]byte(chunk))
+					_, err = conn.clientSend(fmt.Sprintf(`{"type":2,"invocationId": "text","item":"%v"}`, item))
+					Expect(err).To(BeNil())
+				}
+				_, err = conn.clientSend(`{"type":3,"invocationId": "text"}`)
+				Expect(err).To(BeNil())
+				recv := (<-conn.received).(CompletionMessage)
+				Expect(recv.Error).To(Equal(""))
+				Expect(recv.Result).To(Equal(float64(4)))
+			})
+		})
+	})
 })
@@ -0,0 +1,141 @@
+package signalr
+
+import (
+	"context"
+	"io"
+	"sync"
+)
+
+// StreamWriter is an io.WriteCloser which sends the bytes written to it as items of a stream to the client.
+// A hub method returns Stream() and writes to the StreamWriter from another goroutine, e.g. with an encoder,
+// a csv.Writer or io.Copy. Close ends the stream. Writes fail with the error of ctx when ctx is done,
+// e.g. with the context.Context parameter of the hub method when the connection has ended
+type StreamWriter struct {
+	ctx       context.Context
+	items     chan []byte
+	chunkSize int
+	buf       []byte
+	mx        sync.Mutex
+	closed    bool
+}
+
+// NewStreamWriter creates a StreamWriter. If chunkSize is larger than 0, written bytes are collected and sent
+// in items of chunkSize bytes, the rest is sent by Flush or Close. Otherwise each Write is sent as one item
+func NewStreamWriter(ctx context.Context, chunkSize int) *StreamWriter {
+	return &StreamWriter{ctx: ctx, items: make(chan []byte), chunkSize: chunkSize}
+}
+
+// Stream returns the items of the stream, to be returned by the hub method
+func (s *StreamWriter) Stream() <-chan []byte {
+	return s.items
+}
+
+// Write sends p, it blocks until the stream has taken it
+func (s *StreamWriter) Write(p []byte) (n int, err error) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	if s.closed {
+		return 0, io.ErrClosedPipe
+	}
+	if s.chunkSize <= 0 {
+		if err = s.send(append([]byte(nil), p...)); err != nil {
+			return 0, err
+		}
+		return len(p), nil
+	}
+	for len(p) > 0 {
+		free := s.chunkSize - len(s.buf)
+		if free > len(p) {
+			free = len(p)
+		}
+		s.buf = append(s.buf, p[:free]...)
+		p = p[free:]
+		n += free
+		if len(s.buf) == s.chunkSize {
+			if err = s.flush(); err != nil {
+				return n, err
+			}
+		}
+	}
+	return n, nil
+}
+
+// Flush sends the bytes collected for the next item
+func (s *StreamWriter) Flush() error {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	if s.closed {
+		return io.ErrClosedPipe
+	}
+	return s.flush()
+}
+
+// Close sends the collected bytes and ends the stream
+func (s *StreamWriter) Close() error {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	err := s.flush()
+	close(s.items)
+	return err
+}
+
+func (s *StreamWriter) flush() error {
+	if len(s.buf) == 0 {
+		return nil
+	}
+	item := s.buf
+	s.buf = nil
+	return s.send(item)
+}
+
+func (s *StreamWriter) send(item []byte) error {
+	select {
+	case s.items <- item:
+		return nil
+	case <-s.ctx.Done():
+		return s.ctx.Err()
+	}
+}
+
+// StreamReader is an io.ReadCloser reading the items of a client stream, e.g. the `<-chan []byte` parameter
+// of a hub method for uploads, so it can be read by a decoder, a csv.Reader or io.Copy.
+// Read returns io.EOF when the client has completed the stream
+type StreamReader struct {
+	items <-chan []byte
+	item  []byte
+	once  sync.Once
+}
+
+// NewStreamReader creates a StreamReader for the items of a client stream
+func NewStreamReader(items <-chan []byte) *StreamReader {
+	return &StreamReader{items: items}
+}
+
+func (s *StreamReader) Read(p []byte) (n int, err error) {
+	for len(s.item) == 0 {
+		item, ok := <-s.items
+		if !ok {
+			return 0, io.EOF
+		}
+		s.item = item
+	}
+	n = copy(p, s.item)
+	s.item = s.item[n:]
+	return n, nil
+}
+
+// Close receives the rest of the stream without reading it, so the client stream does not block the connection
+// when the hub method stops reading early
+func (s *StreamReader) Close() error {
+	s.once.Do(func() {
+		go func() {
+			for range s.items {
+			}
+		}()
+	})
+	return nil
+}
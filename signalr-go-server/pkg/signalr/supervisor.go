@@ -0,0 +1,86 @@
+package signalr
+
+import (
+	"fmt"
+	"io"
+	"runtime/debug"
+	"sync"
+)
+
+// LoopRestarts sets how often a crashed helper loop of a connection, i.e. its keep-alive pings or its round trip
+// measurement, is restarted before the connection is closed. The receive loop of a connection is never restarted,
+// as a crash loses the messages it has read partly. By default, a crashed loop closes its connection
+func LoopRestarts(restarts int) Option {
+	return func(s *Server) {
+		s.loopRestarts = restarts
+	}
+}
+
+// loopSupervisor runs the loops of one connection. A loop which panics is restarted or closes the connection,
+// which ends the sibling loops, so the connection is cleaned up once by Run and no loop outlives it
+type loopSupervisor struct {
+	conn     Connection
+	hubConn  hubConnection
+	logger   StructuredLogger
+	restarts int
+	loops    sync.WaitGroup
+	failed   sync.Once
+}
+
+func (s *Server) newLoopSupervisor(conn Connection, hubConn hubConnection) *loopSupervisor {
+	return &loopSupervisor{conn: conn, hubConn: hubConn, logger: s.logger, restarts: s.loopRestarts}
+}
+
+// receive runs the receive loop in the calling goroutine. It returns when the loop ended or crashed
+func (l *loopSupervisor) receive(loop func()) {
+	if err := runLoop(loop); err != nil {
+		l.fail("receive", err)
+	}
+}
+
+// start runs a helper loop in its own goroutine, which is restarted when it crashes while the connection is connected
+func (l *loopSupervisor) start(name string, loop func()) {
+	l.loops.Add(1)
+	go func() {
+		defer l.loops.Done()
+		for restarts := 0; ; restarts++ {
+			err := runLoop(loop)
+			if err == nil || !l.hubConn.IsConnected() {
+				return
+			}
+			if restarts >= l.restarts {
+				l.fail(name, err)
+				return
+			}
+			_ = l.logger.Log("connection", l.hubConn.GetConnectionID(), "event", "loop restarted", "loop", name, "error", err)
+		}
+	}()
+}
+
+// wait waits for the helper loops to end
+func (l *loopSupervisor) wait() {
+	l.loops.Wait()
+}
+
+// runLoop runs loop and returns the panic of a crashed loop as error
+func runLoop(loop func()) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v\n%v", r, string(debug.Stack()))
+		}
+	}()
+	loop()
+	return nil
+}
+
+// fail reports the crashed loop and closes the connection for the first one
+func (l *loopSupervisor) fail(name string, err error) {
+	_ = l.logger.Log("connection", l.hubConn.GetConnectionID(), "event", "loop crashed", "loop", name, "error", err)
+	l.failed.Do(func() {
+		l.hubConn.SetDisconnectReason(DisconnectLoopFailure)
+		l.hubConn.Close("Internal server error")
+		if closer, ok := l.conn.(io.Closer); ok {
+			_ = closer.Close()
+		}
+	})
+}
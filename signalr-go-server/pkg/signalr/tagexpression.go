@@ -0,0 +1,148 @@
+package signalr
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// TagExpression selects connections by their tags, e.g. "region:eu && (tier:pro || tier:trial) && !muted".
+// A tag in the expression is true if the connection has the tag. The operators are ! (not), && (and) and || (or),
+// in the order of their precedence. Parentheses group sub expressions
+type TagExpression struct {
+	expression string
+	match      func(tags map[string]bool) bool
+}
+
+// ParseTagExpression parses a TagExpression
+func ParseTagExpression(expression string) (*TagExpression, error) {
+	p := &tagParser{tokens: tokenizeTags(expression)}
+	match, err := p.or()
+	if err == nil && p.pos < len(p.tokens) {
+		err = fmt.Errorf("unexpected %q", p.tokens[p.pos])
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid tag expression %q: %v", expression, err)
+	}
+	return &TagExpression{expression: expression, match: match}, nil
+}
+
+// MustParseTagExpression is like ParseTagExpression but panics if the expression can not be parsed
+func MustParseTagExpression(expression string) *TagExpression {
+	t, err := ParseTagExpression(expression)
+	if err != nil {
+		panic(fmt.Sprintf("signalr: %v", err))
+	}
+	return t
+}
+
+func (t *TagExpression) String() string {
+	return t.expression
+}
+
+// Match returns if tags satisfy the expression
+func (t *TagExpression) Match(tags []string) bool {
+	set := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		set[tag] = true
+	}
+	return t.match(set)
+}
+
+func tokenizeTags(expression string) []string {
+	var tokens []string
+	for i := 0; i < len(expression); {
+		switch c := expression[i]; {
+		case unicode.IsSpace(rune(c)):
+			i++
+		case c == '(' || c == ')' || c == '!':
+			tokens = append(tokens, string(c))
+			i++
+		case strings.HasPrefix(expression[i:], "&&") || strings.HasPrefix(expression[i:], "||"):
+			tokens = append(tokens, expression[i:i+2])
+			i += 2
+		default:
+			end := i
+			for end < len(expression) && !strings.ContainsRune(" \t\r\n()!&|", rune(expression[end])) {
+				end++
+			}
+			if end == i {
+				// A single & or |
+				end++
+			}
+			tokens = append(tokens, expression[i:end])
+			i = end
+		}
+	}
+	return tokens
+}
+
+// tagParser is a recursive descent parser of tag expressions
+type tagParser struct {
+	tokens []string
+	pos    int
+}
+
+func (p *tagParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *tagParser) or() (func(map[string]bool) bool, error) {
+	left, err := p.and()
+	for err == nil && p.peek() == "||" {
+		p.pos++
+		var right func(map[string]bool) bool
+		if right, err = p.and(); err == nil {
+			l := left
+			left = func(tags map[string]bool) bool { return l(tags) || right(tags) }
+		}
+	}
+	return left, err
+}
+
+func (p *tagParser) and() (func(map[string]bool) bool, error) {
+	left, err := p.not()
+	for err == nil && p.peek() == "&&" {
+		p.pos++
+		var right func(map[string]bool) bool
+		if right, err = p.not(); err == nil {
+			l := left
+			left = func(tags map[string]bool) bool { return l(tags) && right(tags) }
+		}
+	}
+	return left, err
+}
+
+func (p *tagParser) not() (func(map[string]bool) bool, error) {
+	switch token := p.peek(); token {
+	case "!":
+		p.pos++
+		operand, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+		return func(tags map[string]bool) bool { return !operand(tags) }, nil
+	case "(":
+		p.pos++
+		inner, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ")" {
+			return nil, fmt.Errorf("missing )")
+		}
+		p.pos++
+		return inner, nil
+	case "", ")", "&&", "||", "&", "|":
+		if token == "" {
+			return nil, fmt.Errorf("unexpected end")
+		}
+		return nil, fmt.Errorf("unexpected %q", token)
+	default:
+		p.pos++
+		return func(tags map[string]bool) bool { return tags[token] }, nil
+	}
+}
@@ -0,0 +1,104 @@
+package signalr
+
+import "sync"
+
+// TagManager manages the tags of the connections of the hub, e.g. "region:eu" or "tier:pro".
+// Tags select connections by TagExpressions with HubClients.Tagged(). They are removed when the connection ends
+// TagConnection() adds tags to a connection. It returns ErrUnknownConnection when the connection does not exist
+// UntagConnection() removes tags from a connection
+// ConnectionTags() returns the tags of a connection
+type TagManager interface {
+	TagConnection(connectionID string, tags ...string) error
+	UntagConnection(connectionID string, tags ...string)
+	ConnectionTags(connectionID string) []string
+}
+
+type defaultTagManager struct {
+	lifetimeManager HubLifetimeManager
+}
+
+func (d *defaultTagManager) TagConnection(connectionID string, tags ...string) error {
+	return d.lifetimeManager.TagConnection(connectionID, tags)
+}
+
+func (d *defaultTagManager) UntagConnection(connectionID string, tags ...string) {
+	d.lifetimeManager.UntagConnection(connectionID, tags)
+}
+
+func (d *defaultTagManager) ConnectionTags(connectionID string) []string {
+	return d.lifetimeManager.ConnectionTags(connectionID)
+}
+
+// tagRegistry keeps the tags of the connections of a lifetime manager
+type tagRegistry struct {
+	mx   sync.Mutex
+	tags map[string]map[string]bool
+}
+
+func (r *tagRegistry) add(connectionID string, tags []string) {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	if r.tags == nil {
+		r.tags = make(map[string]map[string]bool)
+	}
+	set, ok := r.tags[connectionID]
+	if !ok {
+		set = make(map[string]bool)
+		r.tags[connectionID] = set
+	}
+	for _, tag := range tags {
+		set[tag] = true
+	}
+}
+
+func (r *tagRegistry) remove(connectionID string, tags []string) {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	if set, ok := r.tags[connectionID]; ok {
+		for _, tag := range tags {
+			delete(set, tag)
+		}
+		if len(set) == 0 {
+			delete(r.tags, connectionID)
+		}
+	}
+}
+
+func (r *tagRegistry) removeAll(connectionID string) {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	delete(r.tags, connectionID)
+}
+
+// transfer moves the tags of the connection with fromID to the connection with toID
+func (r *tagRegistry) transfer(fromID string, toID string) {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	if set, ok := r.tags[fromID]; ok {
+		delete(r.tags, fromID)
+		r.tags[toID] = set
+	}
+}
+
+func (r *tagRegistry) get(connectionID string) []string {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	tags := make([]string, 0, len(r.tags[connectionID]))
+	for tag := range r.tags[connectionID] {
+		tags = append(tags, tag)
+	}
+	return tags
+}
+
+// matching returns the IDs of the connections whose tags match expression
+func (r *tagRegistry) matching(expression *TagExpression) map[string]bool {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	matching := make(map[string]bool)
+	for connectionID, set := range r.tags {
+		if expression.match(set) {
+			matching[connectionID] = true
+		}
+	}
+	return matching
+}
@@ -0,0 +1,54 @@
+package signalr
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Tags", func() {
+
+	Describe("Tag expressions", func() {
+		Context("When an expression is parsed", func() {
+			It("should match tags with the precedence of the operators", func() {
+				expression := MustParseTagExpression("region:eu && (tier:pro || tier:trial) && !muted")
+				Expect(expression.Match([]string{"region:eu", "tier:pro"})).To(BeTrue())
+				Expect(expression.Match([]string{"region:eu", "tier:trial"})).To(BeTrue())
+				Expect(expression.Match([]string{"region:eu", "tier:pro", "muted"})).To(BeFalse())
+				Expect(expression.Match([]string{"region:us", "tier:pro"})).To(BeFalse())
+				Expect(MustParseTagExpression("a || b && c").Match([]string{"a"})).To(BeTrue())
+				Expect(MustParseTagExpression("!!a").Match([]string{"a"})).To(BeTrue())
+			})
+			It("should refuse invalid expressions", func() {
+				for _, invalid := range []string{"", "a &&", "(a || b", "a b", "a & b", "|| a", "a)"} {
+					_, err := ParseTagExpression(invalid)
+					Expect(err).NotTo(BeNil(), invalid)
+				}
+			})
+		})
+	})
+
+	Describe("Invocation by tags", func() {
+		server := NewServer(&contextHub{})
+		Context("When connections are tagged", func() {
+			It("should send the invocation only to the connections matching the expression", func() {
+				eu := connectUser(server, "eu", "")
+				pro := connectUser(server, "pro", "")
+				connectUser(server, "untagged", "")
+				tags := server.HubContext().Tags()
+				Expect(tags.TagConnection("eu", "region:eu")).To(Succeed())
+				Expect(tags.TagConnection("pro", "region:eu", "tier:pro")).To(Succeed())
+				Expect(tags.TagConnection("unknown", "region:eu")).To(Equal(ErrUnknownConnection))
+				Expect(tags.ConnectionTags("pro")).To(ConsistOf("region:eu", "tier:pro"))
+				go func() {
+					clients := server.HubContext().Clients()
+					clients.Tagged(MustParseTagExpression("region:eu && tier:pro")).Send("pro")
+					clients.Tagged(MustParseTagExpression("region:eu && !tier:pro")).Send("basic")
+				}()
+				expectTargets(pro, "pro")
+				expectTargets(eu, "basic")
+				tags.UntagConnection("pro", "tier:pro")
+				Expect(tags.ConnectionTags("pro")).To(ConsistOf("region:eu"))
+			})
+		})
+	})
+})
@@ -0,0 +1,90 @@
+package signalr
+
+import "sort"
+
+// TenantProvider returns the key of the tenant of a connection, e.g. from the FeatureHost of the connection,
+// a query parameter or a claim in its Context(). It is called after the UserIDProvider.
+// Connections with the empty key belong to the default tenant
+type TenantProvider func(ctx ConnectionContext) string
+
+// IsolateTenants scopes connections, groups and tags to the tenant returned by provider for each connection.
+// Each tenant has its own lifetime manager, so the clients, groups and tags of one tenant can not be reached
+// from another: All() sends to the connections of its tenant only, and a connection can not be added to
+// or tagged in another tenant than its own.
+// The HubContext of the server and the Clients(), Groups() and Tags() of the Hub base class address the default
+// tenant, HubContext.Tenant() addresses another one. A hub method reaches the tenant of its caller with
+//
+//	h.Tenant(ctx.Tenant()).Clients().All().Send("message", text)
+//
+// By default, all connections belong to the default tenant
+func IsolateTenants(provider TenantProvider) Option {
+	return func(s *Server) {
+		s.tenantProvider = provider
+	}
+}
+
+// tenant is the lifetime manager of the connections of one tenant and the HubContext addressing them
+type tenant struct {
+	lifetimeManager *defaultHubLifetimeManager
+	hubContext      *defaultHubContext
+}
+
+// newTenant creates the tenant with key with a lifetime manager configured by the options of the server
+func (s *Server) newTenant(key string) *tenant {
+	lifetimeManager := &defaultHubLifetimeManager{
+		ordering:      s.ordering,
+		notifications: s.groupNotifications,
+		resultTimeout: s.clientResultTimeout,
+		clock:         s.clock,
+		fanOut:        s.groupFanOut,
+		inbox:         s.inbox,
+		tenant:        key,
+	}
+	lifetimeManager.groups.others = s.groupNotifications != nil
+	lifetimeManager.groups.clock = s.clock
+	if s.wildcardGroups {
+		lifetimeManager.groups.tree = &groupNode{}
+	}
+	// The hub context sends through the recording lifetime manager, connections are managed by the wrapped one
+	var operations HubLifetimeManager = lifetimeManager
+	if s.operations != nil {
+		operations = &recordingLifetimeManager{HubLifetimeManager: lifetimeManager, log: s.operations, tenant: key, clock: s.clock}
+	}
+	return &tenant{
+		lifetimeManager: lifetimeManager,
+		hubContext: &defaultHubContext{
+			clients: &defaultHubClients{
+				lifetimeManager: operations,
+				allCache:        allClientProxy{lifetimeManager: operations},
+			},
+			groups: &defaultGroupManager{lifetimeManager: operations},
+			tags:   &defaultTagManager{lifetimeManager: operations},
+			server: s,
+		},
+	}
+}
+
+// tenant returns the tenant with key, which is created if it does not exist. Tenants are kept when their
+// last connection ends, so groups created for them with CreateGroup or RetainMessages are not lost
+func (s *Server) tenant(key string) *tenant {
+	s.tenantsMx.Lock()
+	defer s.tenantsMx.Unlock()
+	t, ok := s.tenants[key]
+	if !ok {
+		t = s.newTenant(key)
+		s.tenants[key] = t
+	}
+	return t
+}
+
+// tenantKeys returns the keys of all tenants, sorted
+func (s *Server) tenantKeys() []string {
+	s.tenantsMx.Lock()
+	defer s.tenantsMx.Unlock()
+	keys := make([]string, 0, len(s.tenants))
+	for key := range s.tenants {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,70 @@
+package signalr
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type tenantHub struct {
+	Hub
+}
+
+func (t *tenantHub) Ready() {}
+
+func (t *tenantHub) Announce(ctx ConnectionContext, text string) {
+	t.Tenant(ctx.Tenant()).Clients().All().Send("announcement", text)
+}
+
+func (t *tenantHub) Join(ctx ConnectionContext, groupName string) {
+	_ = t.Tenant(ctx.Tenant()).Groups().AddToGroup(groupName, ctx.ConnectionID())
+}
+
+var _ = Describe("Tenants", func() {
+
+	Describe("Server isolating tenants", func() {
+		server := NewServer(&tenantHub{}, IsolateTenants(func(ctx ConnectionContext) string {
+			return ctx.Query().Get("user")
+		}))
+		var a, b, c *testingConnection
+		Context("When a hub method sends to all clients of its tenant", func() {
+			It("should reach only the connections of the tenant", func() {
+				a = connectUser(server, "a", "acme")
+				b = connectUser(server, "b", "acme")
+				c = connectUser(server, "c", "globex")
+				_, err := a.clientSend(`{"type":1,"invocationId": "announce","target":"announce","arguments":["hello"]}`)
+				Expect(err).To(BeNil())
+				Expect((<-a.received).(InvocationMessage).Arguments).To(Equal([]interface{}{"hello"}))
+				Expect((<-a.received).(CompletionMessage).InvocationID).To(Equal("announce"))
+				Expect((<-b.received).(InvocationMessage).Target).To(Equal("announcement"))
+				server.HubContext().Tenant("globex").Clients().All().Send("notice")
+				expectTargets(c, "notice")
+			})
+		})
+		Context("When the default tenant sends to all clients", func() {
+			It("should not reach the connections of other tenants", func() {
+				server.HubContext().Clients().All().Send("everybody")
+				server.HubContext().Tenant("acme").Clients().All().Send("acme")
+				expectTargets(a, "acme")
+				expectTargets(b, "acme")
+			})
+		})
+		Context("When groups with the same name exist in several tenants", func() {
+			It("should keep them apart", func() {
+				for _, conn := range []*testingConnection{a, c} {
+					_, err := conn.clientSend(`{"type":1,"invocationId": "join","target":"join","arguments":["team"]}`)
+					Expect(err).To(BeNil())
+					Expect((<-conn.received).(CompletionMessage).InvocationID).To(Equal("join"))
+				}
+				Expect(server.HubContext().Tenant("acme").Groups().AddToGroup("team", "c")).To(Equal(ErrUnknownConnection))
+				server.HubContext().Tenant("globex").Clients().Group("team").Send("globex team")
+				server.HubContext().Tenant("acme").Clients().Group("team").Send("acme team")
+				expectTargets(c, "globex team")
+				expectTargets(a, "acme team")
+				Expect(server.Stats().Groups).To(Equal(2))
+				Expect(server.Debug().Groups).To(Equal([]DebugGroup{
+					{Name: "team", Tenant: "acme", Members: []string{"a"}},
+					{Name: "team", Tenant: "globex", Members: []string{"c"}}}))
+			})
+		})
+	})
+})
@@ -0,0 +1,71 @@
+package signalr
+
+import (
+	"fmt"
+	"time"
+
+	"./signalrtest"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type roomHub struct {
+	Hub
+}
+
+func (r *roomHub) Join(connectionContext ConnectionContext, room string) {
+	_ = r.Groups().AddToGroup(room, connectionContext.ConnectionID())
+}
+
+func (r *roomHub) Say(room string, message string) {
+	r.Clients().Group(room).Send("receiveMessage", room, message)
+}
+
+// failingT records the failure of an Assert function
+type failingT struct {
+	failure string
+}
+
+func (f *failingT) Helper() {}
+
+func (f *failingT) Fatalf(format string, args ...interface{}) {
+	f.failure = fmt.Sprintf(format, args...)
+}
+
+var _ = Describe("signalrtest", func() {
+
+	Describe("Clients of a hub joining a room", func() {
+		server := NewServer(&roomHub{})
+		alice, bob := signalrtest.NewClient("alice"), signalrtest.NewClient("bob")
+		go server.Run(alice)
+		go server.Run(bob)
+		Context("When a client says something in the room", func() {
+			It("should be asserted that the members are in the room and received the message", func() {
+				Expect(alice.Invoke("join", "room1")).To(Succeed())
+				Expect(bob.Invoke("join", "room1")).To(Succeed())
+				signalrtest.AssertInGroup(GinkgoT(), server.HubContext().Groups(), "room1", "alice")
+				signalrtest.AssertInGroup(GinkgoT(), server.HubContext().Groups(), "room1", "bob")
+				Expect(alice.Invoke("say", "room1", "hello")).To(Succeed())
+				signalrtest.AssertInvoked(GinkgoT(), bob, "ReceiveMessage", func(args []interface{}) bool {
+					return len(args) == 2 && args[1] == "hello"
+				})
+				Expect(alice.Invocations()).To(ContainElement(signalrtest.Invocation{Target: "receiveMessage", Arguments: []interface{}{"room1", "hello"}}))
+				Expect(alice.Close()).To(Succeed())
+				Expect(bob.Close()).To(Succeed())
+			})
+		})
+		Context("When an assertion is not met within the timeout", func() {
+			It("should fail the test", func() {
+				timeout := signalrtest.Timeout
+				signalrtest.Timeout = 50 * time.Millisecond
+				defer func() { signalrtest.Timeout = timeout }()
+				t := &failingT{}
+				signalrtest.AssertInvoked(t, bob, "leave", nil)
+				Expect(t.failure).To(ContainSubstring("bob did not receive a matching invocation of leave"))
+				t = &failingT{}
+				signalrtest.AssertInGroup(t, server.HubContext().Groups(), "room2", "bob")
+				Expect(t.failure).To(ContainSubstring("bob is not in group room2"))
+			})
+		})
+	})
+})
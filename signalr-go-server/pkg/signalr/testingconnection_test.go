@@ -14,6 +14,9 @@ type testingConnection struct {
 	cliWriter io.Writer
 	cliReader io.Reader
 	received  chan interface{}
+	closed    chan CloseMessage
+	// handshakeSent is closed when the server has read the handshake, so later messages can not overtake it
+	handshakeSent chan struct{}
 }
 
 func (t *testingConnection) ConnectionID() string {
@@ -29,37 +32,57 @@ func (t *testingConnection) Write(b []byte) (n int, err error) {
 }
 
 func newTestingConnection() *testingConnection {
+	return newTestingConnectionWithHandshake(`{"protocol": "json","version": 1}`)
+}
+
+func newTestingConnectionWithHandshake(handshake string) *testingConnection {
 	cliReader, srvWriter := io.Pipe()
 	srvReader, cliWriter := io.Pipe()
 	conn := testingConnection{
-		srvWriter: srvWriter,
-		srvReader: srvReader,
-		cliWriter: cliWriter,
-		cliReader: cliReader,
+		srvWriter:     srvWriter,
+		srvReader:     srvReader,
+		cliWriter:     cliWriter,
+		cliReader:     cliReader,
+		handshakeSent: make(chan struct{}),
 	}
 	// Send initial Handshake
 	go func() {
-		if _, err := conn.clientSend(`{"protocol": "json","version": 1}`); err != nil {
+		defer close(conn.handshakeSent)
+		if _, err := conn.cliWriter.Write(append([]byte(handshake), 30)); err != nil {
 			ginkgo.Fail(fmt.Sprint(err))
 		}
 	}()
 	conn.received = make(chan interface{}, 0)
+	conn.closed = make(chan CloseMessage, 1)
 	go func() {
 		for {
 			if message, err := conn.clientReceive(); err == nil {
-				var hubMessage hubMessage
+				var hubMessage HubMessage
 				if err = json.Unmarshal([]byte(message), &hubMessage); err == nil {
 					switch hubMessage.Type {
+					case 1:
+						var invocationMessage InvocationMessage
+						if err = json.Unmarshal([]byte(message), &invocationMessage); err == nil {
+							conn.received <- invocationMessage
+						}
 					case 2:
-						var streamItemMessage streamItemMessage
+						var streamItemMessage StreamItemMessage
 						if err = json.Unmarshal([]byte(message), &streamItemMessage); err == nil {
 							conn.received <- streamItemMessage
 						}
 					case 3:
-						var completionMessage completionMessage
+						var completionMessage CompletionMessage
 						if err = json.Unmarshal([]byte(message), &completionMessage); err == nil {
 							conn.received <- completionMessage
 						}
+					case 7:
+						var closeMessage CloseMessage
+						if err = json.Unmarshal([]byte(message), &closeMessage); err == nil {
+							select {
+							case conn.closed <- closeMessage:
+							default:
+							}
+						}
 					}
 				}
 			}
@@ -69,6 +92,7 @@ func newTestingConnection() *testingConnection {
 }
 
 func (t *testingConnection) clientSend(message string) (int, error) {
+	<-t.handshakeSent
 	return t.cliWriter.Write(append([]byte(message), 30))
 }
 
@@ -0,0 +1,158 @@
+package signalr
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// refreshTokenTarget is the reserved target clients invoke to refresh their access token.
+// Hub methods can not have it as name, so it does not hide a method of the hub
+const refreshTokenTarget = "$refreshToken"
+
+// Identity is what an access token grants a connection
+type Identity struct {
+	UserID string
+	Claims map[string]interface{}
+	// Expires is the time the token expires, zero if it does not expire
+	Expires time.Time
+}
+
+// TokenValidator validates an access token and returns the Identity it grants.
+// It returns an error if the token is invalid or expired
+type TokenValidator func(token string) (Identity, error)
+
+// RefreshTokens validates the access tokens of the connections with validator and lets clients refresh their
+// token over the live connection. The first token of a connection is the access_token query parameter or
+// the bearer token in the Authorization header of the request which started it, the empty string if there is none.
+// Connections whose first token the validator refuses are closed right after the handshake. The UserID of the
+// Identity is the user of the connection, unless the server has a UserIDProvider, and its Claims are the
+// FeatureClaims of the connection.
+// To refresh its token, the client invokes the reserved target "$refreshToken" with the new token, e.g.
+//
+//	{"type":1,"invocationId":"7","target":"$refreshToken","arguments":["<token>"]}
+//
+// The invocation completes with an error if the new token is invalid or grants another user, the connection
+// keeps its identity then. Otherwise the connection gets the claims and the expiry of the new token.
+// With disconnectExpired, connections are closed when their token expires before it has been refreshed
+func RefreshTokens(validator TokenValidator, disconnectExpired bool) Option {
+	return func(s *Server) {
+		s.tokens = &tokenRefresher{validate: validator, disconnectExpired: disconnectExpired}
+	}
+}
+
+// errOtherUser is the error of a refreshed token which grants another user than the connection has
+var errOtherUser = errors.New("token is for another user")
+
+type tokenRefresher struct {
+	validate          TokenValidator
+	disconnectExpired bool
+}
+
+// accessToken returns the access token of the request which started conn
+func accessToken(conn Connection) string {
+	if metadata, ok := conn.(interface{ requestAccessToken() string }); ok {
+		return metadata.requestAccessToken()
+	}
+	return ""
+}
+
+// bearerToken returns the access token sent with req
+func bearerToken(req *http.Request) string {
+	if token := req.URL.Query().Get("access_token"); token != "" {
+		return token
+	}
+	if authorization := req.Header.Get("Authorization"); strings.HasPrefix(authorization, "Bearer ") {
+		return strings.TrimPrefix(authorization, "Bearer ")
+	}
+	return ""
+}
+
+// connectionToken keeps the identity of a connection and closes the connection when its token expires
+type connectionToken struct {
+	refresher *tokenRefresher
+	conn      Connection
+	hubConn   hubConnection
+	clock     Clock
+	mx        sync.Mutex
+	identity  Identity
+	// stop stops the expiry timer, nil if none is running
+	stop func() bool
+}
+
+// track starts tracking the identity of hubConn, nil if the server does not validate tokens
+func (t *tokenRefresher) track(conn Connection, hubConn hubConnection, identity Identity, clock Clock) *connectionToken {
+	if t == nil {
+		return nil
+	}
+	token := &connectionToken{refresher: t, conn: conn, hubConn: hubConn, clock: clock}
+	token.apply(identity)
+	return token
+}
+
+// apply sets the claims of identity and restarts the expiry timer. It must be called with mx held or before the token is shared
+func (c *connectionToken) apply(identity Identity) {
+	c.identity = identity
+	c.hubConn.Features().Set(FeatureClaims, identity.Claims)
+	if c.stop != nil {
+		c.stop()
+		c.stop = nil
+	}
+	if c.refresher.disconnectExpired && !identity.Expires.IsZero() {
+		c.stop = c.clock.AfterFunc(identity.Expires.Sub(c.clock.Now()), c.expire)
+	}
+}
+
+// expire closes the connection whose token expired
+func (c *connectionToken) expire() {
+	c.hubConn.SetDisconnectReason(DisconnectKicked)
+	c.hubConn.Close("Access token expired")
+	if closer, ok := c.conn.(io.Closer); ok {
+		_ = closer.Close()
+	}
+}
+
+// refresh validates the token sent with the reserved invocation and completes the invocation
+func (c *connectionToken) refresh(invocation InvocationMessage, protocol HubProtocol) {
+	var errorText string
+	if err := c.refreshWith(invocation, protocol); err != nil {
+		errorText = err.Error()
+	}
+	if invocation.InvocationID != "" {
+		c.hubConn.Completion(invocation.InvocationID, nil, errorText)
+	}
+}
+
+func (c *connectionToken) refreshWith(invocation InvocationMessage, protocol HubProtocol) error {
+	var token string
+	if len(invocation.Arguments) != 1 || protocol.UnmarshalArgument(invocation.Arguments[0], &token) != nil {
+		return errors.New("refresh needs the token as only argument")
+	}
+	identity, err := c.refresher.validate(token)
+	if err != nil {
+		return err
+	}
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	if identity.UserID != c.identity.UserID {
+		return errOtherUser
+	}
+	c.apply(identity)
+	return nil
+}
+
+// end stops the expiry timer of the ended connection
+func (c *connectionToken) end() {
+	if c == nil {
+		return
+	}
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	if c.stop != nil {
+		c.stop()
+		c.stop = nil
+	}
+}
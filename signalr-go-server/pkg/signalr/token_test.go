@@ -0,0 +1,82 @@
+package signalr
+
+import (
+	"errors"
+	"net/http/httptest"
+	"time"
+
+	"./signalrtest"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type tokenHub struct {
+	Hub
+}
+
+func (t *tokenHub) Ready() {}
+
+func (t *tokenHub) Scope(ctx ConnectionContext) string {
+	claims, _ := ctx.Features().Get(FeatureClaims)
+	return claims.(map[string]interface{})["scope"].(string)
+}
+
+var _ = Describe("Tokens", func() {
+	clock := signalrtest.NewFakeClock(time.Now())
+	tokens := map[string]Identity{
+		"alice-read":  {UserID: "alice", Claims: map[string]interface{}{"scope": "read"}, Expires: clock.Now().Add(time.Minute)},
+		"alice-write": {UserID: "alice", Claims: map[string]interface{}{"scope": "write"}, Expires: clock.Now().Add(2 * time.Minute)},
+		"bob-read":    {UserID: "bob", Claims: map[string]interface{}{"scope": "read"}},
+	}
+	validator := func(token string) (Identity, error) {
+		if identity, ok := tokens[token]; ok {
+			return identity, nil
+		}
+		return Identity{}, errors.New("invalid token")
+	}
+	connectWithToken := func(server *Server, connectionID string, token string) *testingConnection {
+		conn := newTestingConnection()
+		req := httptest.NewRequest("GET", "/hub?access_token="+token, nil)
+		go server.Run(&userConnection{server.newRequestMetadata(req, nil), conn, connectionID})
+		return conn
+	}
+	invoke := func(conn *testingConnection, target string, args string) CompletionMessage {
+		_, err := conn.clientSend(`{"type":1,"invocationId": "` + target + `","target":"` + target + `","arguments":[` + args + `]}`)
+		Expect(err).To(BeNil())
+		return (<-conn.received).(CompletionMessage)
+	}
+
+	Describe("Server refreshing tokens", func() {
+		server := NewServer(&tokenHub{}, UseClock(clock), RefreshTokens(validator, false))
+		Context("When the client refreshes its token", func() {
+			It("should update the claims, but not switch to another user", func() {
+				conn := connectWithToken(server, "a", "alice-read")
+				Expect(invoke(conn, "scope", "").Result).To(Equal("read"))
+				Expect(server.UserStats()).To(HaveKey("alice"))
+				Expect(invoke(conn, "$refreshToken", `"alice-write"`).Error).To(BeEmpty())
+				Expect(invoke(conn, "scope", "").Result).To(Equal("write"))
+				Expect(invoke(conn, "$refreshToken", `"bob-read"`).Error).To(Equal("token is for another user"))
+				Expect(invoke(conn, "$refreshToken", `"forged"`).Error).To(Equal("invalid token"))
+				Expect(invoke(conn, "scope", "").Result).To(Equal("write"))
+			})
+		})
+		Context("When a client connects without valid token", func() {
+			It("should close the connection", func() {
+				conn := connectWithToken(server, "b", "")
+				Expect((<-conn.closed).Error).To(Equal("Invalid access token"))
+			})
+		})
+	})
+
+	Describe("Server disconnecting expired tokens", func() {
+		server := NewServer(&tokenHub{}, UseClock(clock), RefreshTokens(validator, true))
+		Context("When the token expires without refresh", func() {
+			It("should close the connection", func() {
+				conn := connectWithToken(server, "c", "alice-read")
+				Expect(invoke(conn, "ready", "").Error).To(BeEmpty())
+				clock.Advance(time.Minute)
+				Expect((<-conn.closed).Error).To(Equal("Access token expired"))
+			})
+		})
+	})
+})
@@ -0,0 +1,53 @@
+package signalr
+
+import "time"
+
+// TransportOptions tunes the buffers of the transports, see TuneTransports. Zero fields keep the defaults
+type TransportOptions struct {
+	// WebSocketReadBufferSize is the size of the buffer each WebSocket connection reads the messages of its client into.
+	// Small buffers save memory with many connections sending tiny messages, large ones save reads with large payloads.
+	// Default is 4K
+	WebSocketReadBufferSize int
+	// WebSocketWriteBufferSize is the number of bytes of messages which are collected and sent as one WebSocket frame
+	// while further messages wait to be written, e.g. during a broadcast. A message is never split, the frame is sent when
+	// the next message does not fit or no message is waiting anymore, so collecting adds no latency.
+	// By default, each message is sent as its own frame
+	WebSocketWriteBufferSize int
+	// ServerSentEventsFlushInterval is the interval at which the events written to a Server-Sent Events connection
+	// are flushed to the client. Flushing less often sends many small events in fewer packets, at the cost of delaying
+	// them up to the interval. By default, each event is flushed when it is written
+	ServerSentEventsFlushInterval time.Duration
+	// LongPollingBufferSize is the maximum number of bytes of the messages queued for a long polling client. A message
+	// which does not fit waits until the client has polled, like a write to a WebSocket connection whose network buffers
+	// are full. A single larger message is queued when nothing else is. By default, the queue is not limited
+	LongPollingBufferSize int
+}
+
+// TuneTransports sets the TransportOptions of the server. The hard-coded defaults suit most apps,
+// tiny-message workloads with many clients and large-payload dashboards can do better with their own values
+func TuneTransports(options TransportOptions) Option {
+	return func(s *Server) {
+		s.transportOptions = options
+	}
+}
+
+// readBufferSize returns the size of the read buffer of conn
+func (s *Server) readBufferSize(conn Connection) int {
+	if _, ok := conn.(*webSocketConnection); ok && s.transportOptions.WebSocketReadBufferSize > 0 {
+		return s.transportOptions.WebSocketReadBufferSize
+	}
+	return readSize
+}
+
+// bufferedConnection is a Connection which holds back written data until it is flushed
+type bufferedConnection interface {
+	flush() error
+}
+
+// flushConnection flushes the data conn holds back, if it is a bufferedConnection
+func flushConnection(conn Connection) error {
+	if buffered, ok := conn.(bufferedConnection); ok {
+		return buffered.flush()
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+package signalr
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/net/websocket"
+
+	"./signalrtest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type burstHub struct {
+	Hub
+}
+
+// Burst sends n invocations to all clients at once
+func (b *burstHub) Burst(n int) {
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			b.Clients().All().Send("item", i)
+		}(i)
+	}
+	wg.Wait()
+}
+
+// flushCountingRecorder is a ResponseRecorder which counts its flushes
+type flushCountingRecorder struct {
+	*httptest.ResponseRecorder
+	flushes int32
+}
+
+func (f *flushCountingRecorder) Flush() {
+	atomic.AddInt32(&f.flushes, 1)
+}
+
+var _ = Describe("TransportOptions", func() {
+
+	Describe("WebSocket connection with a WebSocketWriteBufferSize", func() {
+		mux := http.NewServeMux()
+		MapHub(mux, "/hub", &burstHub{}, TuneTransports(TransportOptions{WebSocketReadBufferSize: 16, WebSocketWriteBufferSize: 1 << 12}))
+		Context("When many messages are sent at once", func() {
+			It("should send frames of complete messages without holding any back", func() {
+				httpServer := httptest.NewServer(mux)
+				defer httpServer.Close()
+				ws, err := websocket.Dial("ws"+strings.TrimPrefix(httpServer.URL, "http")+"/hub", "", httpServer.URL)
+				Expect(err).To(BeNil())
+				defer ws.Close()
+				Expect(websocket.Message.Send(ws, `{"protocol":"json","version":1}`+"\u001e")).To(Succeed())
+				var frame string
+				Expect(websocket.Message.Receive(ws, &frame)).To(Succeed())
+				Expect(frame).To(Equal("{}\u001e"))
+				// The invocation is longer than the read buffer
+				Expect(websocket.Message.Send(ws, `{"type":1,"invocationId":"burst","target":"burst","arguments":[50]}`+"\u001e")).To(Succeed())
+				items := 0
+				for !strings.Contains(frame, `"invocationId":"burst"`) {
+					Expect(websocket.Message.Receive(ws, &frame)).To(Succeed())
+					Expect(frame).To(HaveSuffix("\u001e"))
+					items += strings.Count(frame, `"target":"item"`)
+				}
+				Expect(items).To(Equal(50))
+			})
+		})
+	})
+
+	Describe("Server-Sent Events connection with a ServerSentEventsFlushInterval", func() {
+		clock := signalrtest.NewFakeClock(time.Now())
+		recorder := &flushCountingRecorder{ResponseRecorder: httptest.NewRecorder()}
+		conn := newServerSentEventsConnection("sse", requestMetadata{}, recorder, clock, 100*time.Millisecond)
+		Context("When events are written within the interval", func() {
+			It("should flush them together when the interval has passed", func() {
+				_, _ = conn.Write([]byte("first"))
+				_, _ = conn.Write([]byte("second"))
+				Expect(atomic.LoadInt32(&recorder.flushes)).To(Equal(int32(0)))
+				clock.Advance(100 * time.Millisecond)
+				Eventually(func() int32 { return atomic.LoadInt32(&recorder.flushes) }).Should(Equal(int32(1)))
+				_, _ = conn.Write([]byte("third"))
+				clock.Advance(100 * time.Millisecond)
+				Eventually(func() int32 { return atomic.LoadInt32(&recorder.flushes) }).Should(Equal(int32(2)))
+			})
+		})
+	})
+
+	Describe("Long polling connection with a LongPollingBufferSize", func() {
+		clock := signalrtest.NewFakeClock(time.Now())
+		conn := newLongPollingConnection("capped", requestMetadata{}, clock, longPollingDisconnectTimeout, 10, func() {})
+		Context("When a message does not fit in the queue", func() {
+			It("should wait until the client has polled", func() {
+				_, err := conn.Write([]byte("12345678"))
+				Expect(err).To(BeNil())
+				written := make(chan error, 1)
+				go func() {
+					_, err := conn.Write([]byte("abcde"))
+					written <- err
+				}()
+				Consistently(written, 100*time.Millisecond).ShouldNot(Receive())
+				recorder := httptest.NewRecorder()
+				conn.poll(recorder, httptest.NewRequest("GET", "/hub?id=capped", nil), longPollingPollTimeout, longPollingDisconnectTimeout, 1000)
+				Expect(recorder.Body.String()).To(Equal("12345678"))
+				Eventually(written).Should(Receive(BeNil()))
+			})
+		})
+		Context("When a single message is larger than the queue", func() {
+			It("should queue it while nothing else is", func() {
+				recorder := httptest.NewRecorder()
+				conn.poll(recorder, httptest.NewRequest("GET", "/hub?id=capped", nil), longPollingPollTimeout, longPollingDisconnectTimeout, 1000)
+				Expect(recorder.Body.String()).To(Equal("abcde"))
+				_, err := conn.Write([]byte("0123456789abcdef"))
+				Expect(err).To(BeNil())
+				recorder = httptest.NewRecorder()
+				conn.poll(recorder, httptest.NewRequest("GET", "/hub?id=capped", nil), longPollingPollTimeout, longPollingDisconnectTimeout, 1000)
+				Expect(recorder.Body.String()).To(Equal("0123456789abcdef"))
+			})
+		})
+	})
+})
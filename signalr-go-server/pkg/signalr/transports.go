@@ -0,0 +1,73 @@
+package signalr
+
+// The names of the transports of the server, as sent by negotiate and published as FeatureTransport
+const (
+	TransportWebSockets       = "WebSockets"
+	TransportServerSentEvents = "ServerSentEvents"
+	TransportLongPolling      = "LongPolling"
+)
+
+// The errors of transport requests, as sent by ASP.NET
+const (
+	errConnectionIDRequired = "Connection ID required"
+	errUnknownConnectionID  = "No Connection with that ID"
+)
+
+// AllowTransports sets the transports clients can connect with. Negotiate offers only these transports, and
+// requests of other transports are answered with 404. By default, all transports are allowed
+func AllowTransports(transports ...string) Option {
+	return func(s *Server) {
+		s.transports = make(map[string]bool, len(transports))
+		for _, transport := range transports {
+			s.transports[transport] = true
+		}
+	}
+}
+
+// allowsTransport returns if clients can connect with transport
+func (s *Server) allowsTransport(transport string) bool {
+	return s.transports == nil || s.transports[transport]
+}
+
+// Reasons of a TransportFallback
+const (
+	// FallbackNotOffered means negotiate did not offer WebSockets, see AllowTransports and OfferWebSocketsOverHTTP2
+	FallbackNotOffered = "not offered"
+	// FallbackUpgradeFailed means the client tried WebSockets, but the server could not upgrade the request
+	FallbackUpgradeFailed = "upgrade failed"
+	// FallbackNoAttempt means no WebSocket request of the client reached the server, e.g. because the client
+	// does not support WebSockets or a proxy blocked the request
+	FallbackNoAttempt = "no attempt"
+)
+
+// TransportFallback tells that a negotiated connection started on another transport than WebSockets, and why
+type TransportFallback struct {
+	ConnectionID string
+	// Transport is the transport the connection started on
+	Transport string
+	// Reason is FallbackNotOffered, FallbackUpgradeFailed or FallbackNoAttempt
+	Reason string
+	// Error is why the upgrade failed, empty for other reasons
+	Error string
+}
+
+// OnTransportFallback sets a handler called for each negotiated connection which starts on Server-Sent Events or
+// long polling, e.g. to count the clients which degrade from WebSockets and why. Fallbacks are logged and counted
+// by reason in the TransportFallbacks of ServerStats with or without a handler
+func OnTransportFallback(handler func(fallback TransportFallback)) Option {
+	return func(s *Server) {
+		s.fallbackHandler = handler
+	}
+}
+
+// reportFallback reports the connection with connectionID which started on transport
+func (s *Server) reportFallback(connectionID string, transport string, fallback TransportFallback) {
+	fallback.ConnectionID, fallback.Transport = connectionID, transport
+	_ = s.logger.Log("connection", connectionID, "event", "transport fallback", "transport", transport, "reason", fallback.Reason, "error", fallback.Error)
+	s.fallbacksMx.Lock()
+	s.fallbacks[fallback.Reason]++
+	s.fallbacksMx.Unlock()
+	if s.fallbackHandler != nil {
+		s.fallbackHandler(fallback)
+	}
+}
@@ -0,0 +1,329 @@
+package signalr
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// GenerateTypeScript writes TypeScript declarations and stubs for the JavaScript client (@microsoft/signalr)
+// to w, so the signatures used by the frontend follow the hub. It writes
+//   - the interface <name>Hub with the methods of hub and create<name>Hub, which binds them to a HubConnection,
+//   - the interface <name>Client with the methods of client and register<name>Client, which registers them
+//     as handlers of a HubConnection,
+//   - an interface for each struct used by the methods, with the fields as marshaled to JSON.
+//
+// client is a pointer to an interface type with the methods the hub invokes on its clients, e.g. (*ChatClient)(nil),
+// or nil. Methods returning a chan are declared as streams. Parameters are named by position, as Go keeps no names.
+// GenerateTypeScript is meant to be called by a go:generate program
+func GenerateTypeScript(w io.Writer, name string, hub HubInterface, client interface{}) error {
+	if err := ValidateHub(hub); err != nil {
+		return err
+	}
+	g := &typeScriptGenerator{declared: make(map[reflect.Type]string)}
+	methods := g.hubMethods(hub)
+	var clientMethods []reflect.Method
+	if client != nil {
+		clientType := reflect.TypeOf(client)
+		if clientType.Kind() != reflect.Ptr || clientType.Elem().Kind() != reflect.Interface {
+			return fmt.Errorf("client must be a pointer to an interface type, not %v", clientType)
+		}
+		for i := 0; i < clientType.Elem().NumMethod(); i++ {
+			clientMethods = append(clientMethods, clientType.Elem().Method(i))
+		}
+	}
+
+	var body bytes.Buffer
+	fmt.Fprintf(&body, "export interface %vHub {\n", name)
+	for _, m := range methods {
+		params := g.parameters(m.params, optionalFrom(m.params, m.variadicParam), m.variadicParam)
+		if m.variadic {
+			params = "...args: any[]"
+		}
+		fmt.Fprintf(&body, "    %v(%v): %v;\n", lowerFirst(m.name), params, g.result(m.results))
+	}
+	fmt.Fprintf(&body, "}\n\n")
+	fmt.Fprintf(&body, "export function create%vHub(connection: HubConnection): %vHub {\n    return {\n", name, name)
+	for _, m := range methods {
+		call := "invoke"
+		if isStream(m.results) {
+			call = "stream"
+		} else if len(m.results) == 0 {
+			call = "send"
+		}
+		params, args := argumentNames(len(m.params)), argumentNames(len(m.params))
+		if m.variadicParam {
+			params[len(params)-1] = "..." + params[len(params)-1]
+			args[len(args)-1] = "..." + args[len(args)-1]
+		}
+		if m.variadic {
+			params, args = []string{"...args"}, []string{"...args"}
+		}
+		fmt.Fprintf(&body, "        %v: (%v) => connection.%v(%v),\n", lowerFirst(m.name), strings.Join(params, ", "),
+			call, strings.Join(append([]string{fmt.Sprintf("%q", m.name)}, args...), ", "))
+	}
+	fmt.Fprintf(&body, "    };\n}\n")
+	if client != nil {
+		fmt.Fprintf(&body, "\nexport interface %vClient {\n", name)
+		for _, m := range clientMethods {
+			fmt.Fprintf(&body, "    %v(%v): void;\n", lowerFirst(m.Name), g.parameters(inTypes(m.Type, 0), m.Type.NumIn(), false))
+		}
+		fmt.Fprintf(&body, "}\n\n")
+		fmt.Fprintf(&body, "export function register%vClient(connection: HubConnection, client: %vClient): void {\n", name, name)
+		for _, m := range clientMethods {
+			args := strings.Join(argumentNames(m.Type.NumIn()), ", ")
+			fmt.Fprintf(&body, "    connection.on(%q, (%v) => client.%v(%v));\n", m.Name, args, lowerFirst(m.Name), args)
+		}
+		fmt.Fprintf(&body, "}\n")
+	}
+
+	if _, err := fmt.Fprintf(w, "// Code generated by signalr.GenerateTypeScript. DO NOT EDIT.\n\n"+
+		"import { HubConnection, IStreamResult, Subject } from \"@microsoft/signalr\";\n\n"); err != nil {
+		return err
+	}
+	if _, err := w.Write(g.interfaces.Bytes()); err != nil {
+		return err
+	}
+	_, err := w.Write(body.Bytes())
+	return err
+}
+
+type typeScriptGenerator struct {
+	// declared are the names of the struct types which have been declared as interfaces
+	declared   map[reflect.Type]string
+	interfaces bytes.Buffer
+}
+
+type typeScriptMethod struct {
+	name    string
+	params  []reflect.Type
+	results []reflect.Type
+	// variadic methods take any arguments
+	variadic bool
+	// variadicParam is set if the last parameter is variadic
+	variadicParam bool
+}
+
+// hubMethods returns the methods clients can invoke, the same the server dispatches invocations to
+func (g *typeScriptGenerator) hubMethods(hub HubInterface) []typeScriptMethod {
+	var methods []typeScriptMethod
+	if table, ok := hub.(interface{ methodTable() *MethodTable }); ok {
+		for name, method := range table.methodTable().methods {
+			methods = append(methods, typeScriptMethod{name: name, params: inTypes(method.value.Type(), 0), results: outTypes(method.value.Type()),
+				variadicParam: method.variadic})
+		}
+		for name := range table.methodTable().funcs {
+			// The arguments of a HubMethodFunc are not known
+			methods = append(methods, typeScriptMethod{name: name, variadic: true, results: []reflect.Type{emptyInterfaceType}})
+		}
+		sort.Slice(methods, func(i, j int) bool { return methods[i].name < methods[j].name })
+		return methods
+	}
+	hubType := reflect.TypeOf(hub)
+	for i := 0; i < hubType.NumMethod(); i++ {
+		m := hubType.Method(i)
+		// The server does not dispatch invocations to the methods it calls itself
+		if isHookMethod(m.Name) {
+			continue
+		}
+		// Parameter 0 is the receiver
+		methods = append(methods, typeScriptMethod{name: m.Name, params: inTypes(m.Type, 1), results: outTypes(m.Type),
+			variadicParam: m.Type.IsVariadic()})
+	}
+	return methods
+}
+
+var emptyInterfaceType = reflect.TypeOf((*interface{})(nil)).Elem()
+var timeType = reflect.TypeOf(time.Time{})
+var rawMessageType = reflect.TypeOf(RawMessage{})
+
+// inTypes returns the parameter types from parameter first on, without the injected ones
+func inTypes(methodType reflect.Type, first int) []reflect.Type {
+	var params []reflect.Type
+	for i := first; i < methodType.NumIn(); i++ {
+		if t := methodType.In(i); t != connectionContextType && t != contextType {
+			params = append(params, t)
+		}
+	}
+	return params
+}
+
+// outTypes returns the result types, without the error
+func outTypes(methodType reflect.Type) []reflect.Type {
+	var results []reflect.Type
+	for i := 0; i < methodType.NumOut(); i++ {
+		if t := methodType.Out(i); t != errorType && t != hubErrorType {
+			results = append(results, t)
+		}
+	}
+	return results
+}
+
+func isStream(results []reflect.Type) bool {
+	return len(results) == 1 && results[0].Kind() == reflect.Chan
+}
+
+func argumentNames(n int) []string {
+	names := make([]string, n)
+	for i := range names {
+		names[i] = fmt.Sprintf("arg%v", i)
+	}
+	return names
+}
+
+// optionalFrom returns the index of the first of the trailing pointer parameters, which clients can omit
+func optionalFrom(params []reflect.Type, variadic bool) int {
+	i := len(params)
+	if variadic {
+		i--
+	}
+	for i > 0 && params[i-1].Kind() == reflect.Ptr {
+		i--
+	}
+	return i
+}
+
+// parameters declares params. The parameters from optional on are optional, the last one is variadic if variadic is set
+func (g *typeScriptGenerator) parameters(params []reflect.Type, optional int, variadic bool) string {
+	declarations := make([]string, len(params))
+	for i, t := range params {
+		if variadic && i == len(params)-1 {
+			declarations[i] = fmt.Sprintf("...arg%v: Array<%v>", i, g.typeOf(t.Elem()))
+		} else if i >= optional {
+			declarations[i] = fmt.Sprintf("arg%v?: %v", i, g.typeOf(t))
+		} else if t.Kind() == reflect.Chan {
+			// Client streams are sent by a Subject
+			declarations[i] = fmt.Sprintf("arg%v: Subject<%v>", i, g.typeOf(t.Elem()))
+		} else {
+			declarations[i] = fmt.Sprintf("arg%v: %v", i, g.typeOf(t))
+		}
+	}
+	return strings.Join(declarations, ", ")
+}
+
+func (g *typeScriptGenerator) result(results []reflect.Type) string {
+	switch {
+	case isStream(results):
+		return fmt.Sprintf("IStreamResult<%v>", g.typeOf(results[0].Elem()))
+	case len(results) == 0:
+		return "Promise<void>"
+	case len(results) == 1:
+		return fmt.Sprintf("Promise<%v>", g.typeOf(results[0]))
+	default:
+		// Multiple results are sent as array
+		types := make([]string, len(results))
+		for i, t := range results {
+			types[i] = g.typeOf(t)
+		}
+		return fmt.Sprintf("Promise<[%v]>", strings.Join(types, ", "))
+	}
+}
+
+// typeOf returns the TypeScript type of the JSON encoding of t. Struct types are declared as interfaces
+func (g *typeScriptGenerator) typeOf(t reflect.Type) string {
+	if t == timeType {
+		return "string"
+	}
+	if t == rawMessageType {
+		return "any"
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.String:
+		return "string"
+	case reflect.Ptr:
+		return g.typeOf(t.Elem()) + " | null"
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			// encoding/json sends []byte base64 encoded
+			return "string"
+		}
+		element := g.typeOf(t.Elem())
+		if strings.Contains(element, " ") {
+			element = "(" + element + ")"
+		}
+		return element + "[]"
+	case reflect.Map:
+		return fmt.Sprintf("{ [key: string]: %v }", g.typeOf(t.Elem()))
+	case reflect.Struct:
+		return g.declare(t)
+	default:
+		return "any"
+	}
+}
+
+// declare declares struct type t as interface and returns the name of the interface
+func (g *typeScriptGenerator) declare(t reflect.Type) string {
+	if name, ok := g.declared[t]; ok {
+		return name
+	}
+	name := t.Name()
+	if name == "" {
+		name = fmt.Sprintf("Anonymous%v", len(g.declared)+1)
+	}
+	name = upperFirst(name)
+	g.declared[t] = name
+	var fields bytes.Buffer
+	g.fields(&fields, t)
+	fmt.Fprintf(&g.interfaces, "export interface %v {\n%v}\n\n", name, fields.String())
+	return name
+}
+
+// fields writes the fields of struct type t, with the fields of embedded structs, as encoding/json marshals them
+func (g *typeScriptGenerator) fields(w io.Writer, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, options := tag, ""
+		if comma := strings.Index(tag, ","); comma >= 0 {
+			name, options = tag[:comma], tag[comma:]
+		}
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			g.fields(w, field.Type)
+			continue
+		}
+		if field.PkgPath != "" {
+			// Not exported
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		optional := ""
+		if strings.Contains(options, "omitempty") {
+			optional = "?"
+		}
+		fmt.Fprintf(w, "    %v%v: %v;\n", name, optional, g.typeOf(field.Type))
+	}
+}
+
+func lowerFirst(s string) string {
+	if s == "" {
+		return s
+	}
+	r := []rune(s)
+	r[0] = unicode.ToLower(r[0])
+	return string(r)
+}
+
+func upperFirst(s string) string {
+	if s == "" {
+		return s
+	}
+	r := []rune(s)
+	r[0] = unicode.ToUpper(r[0])
+	return string(r)
+}
@@ -0,0 +1,109 @@
+package signalr
+
+import (
+	"bytes"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type chatMessage struct {
+	Author  string    `json:"author"`
+	Text    string    `json:"text"`
+	Sent    time.Time `json:"sent"`
+	Replies []*chatMessage
+	Tags    map[string]int `json:"tags,omitempty"`
+	secret  string
+}
+
+type typeScriptHub struct {
+	Hub
+}
+
+func (t *typeScriptHub) Post(ctx ConnectionContext, room string, message chatMessage) error {
+	return nil
+}
+
+func (t *typeScriptHub) History(room string, count int) ([]chatMessage, error) {
+	return nil, nil
+}
+
+func (t *typeScriptHub) Watch(room string) <-chan chatMessage {
+	return nil
+}
+
+func (t *typeScriptHub) Join(room string) {}
+
+func (t *typeScriptHub) OnReconnected(previousID string, connectionID string) {}
+
+func (t *typeScriptHub) OnDisconnectedReason(connectionID string, reason DisconnectReason) {}
+
+func (t *typeScriptHub) OrderedMethods() map[string]OrderingKey {
+	return nil
+}
+
+type typeScriptClient interface {
+	Receive(room string, message chatMessage)
+}
+
+var _ = Describe("GenerateTypeScript", func() {
+
+	Describe("TypeScript of a hub", func() {
+		Context("When it is generated", func() {
+			var buf bytes.Buffer
+			Expect(GenerateTypeScript(&buf, "Chat", &typeScriptHub{}, (*typeScriptClient)(nil))).To(Succeed())
+			ts := buf.String()
+			It("should declare the hub methods without the hook methods and the injected parameters", func() {
+				Expect(ts).To(ContainSubstring("export interface ChatHub {\n" +
+					"    history(arg0: string, arg1: number): Promise<ChatMessage[]>;\n" +
+					"    join(arg0: string): Promise<void>;\n" +
+					"    post(arg0: string, arg1: ChatMessage): Promise<void>;\n" +
+					"    watch(arg0: string): IStreamResult<ChatMessage>;\n" +
+					"}\n"))
+			})
+			It("should bind the hub methods to the connection", func() {
+				Expect(ts).To(ContainSubstring(`history: (arg0, arg1) => connection.invoke("History", arg0, arg1),`))
+				Expect(ts).To(ContainSubstring(`join: (arg0) => connection.send("Join", arg0),`))
+				Expect(ts).To(ContainSubstring(`watch: (arg0) => connection.stream("Watch", arg0),`))
+			})
+			It("should declare the structs as marshaled to JSON", func() {
+				Expect(ts).To(ContainSubstring("export interface ChatMessage {\n" +
+					"    author: string;\n" +
+					"    text: string;\n" +
+					"    sent: string;\n" +
+					"    Replies: (ChatMessage | null)[];\n" +
+					"    tags?: { [key: string]: number };\n" +
+					"}\n"))
+			})
+			It("should declare and register the client methods", func() {
+				Expect(ts).To(ContainSubstring("export interface ChatClient {\n    receive(arg0: string, arg1: ChatMessage): void;\n}\n"))
+				Expect(ts).To(ContainSubstring(`connection.on("Receive", (arg0, arg1) => client.receive(arg0, arg1));`))
+			})
+		})
+	})
+
+	Describe("TypeScript of a MethodTable hub", func() {
+		Context("When it is generated", func() {
+			It("should declare the registered methods", func() {
+				var buf bytes.Buffer
+				Expect(GenerateTypeScript(&buf, "Table", newTableHub(), nil)).To(Succeed())
+				Expect(buf.String()).To(ContainSubstring("    concat(arg0: string, arg1: string): Promise<string>;\n    repeat(...args: any[]): Promise<any>;\n"))
+				Expect(buf.String()).NotTo(ContainSubstring("register"))
+			})
+		})
+	})
+
+	Describe("TypeScript of a hub with optional and variadic parameters", func() {
+		Context("When it is generated", func() {
+			It("should declare trailing pointer parameters optional and variadic parameters as rest parameters", func() {
+				var buf bytes.Buffer
+				Expect(GenerateTypeScript(&buf, "Optional", &optionalHub{}, nil)).To(Succeed())
+				ts := buf.String()
+				Expect(ts).To(ContainSubstring("    greet(arg0: string, arg1?: string | null): Promise<string>;\n"))
+				Expect(ts).To(ContainSubstring("    sum(arg0: string, ...arg1: Array<number>): Promise<string>;\n"))
+				Expect(ts).To(ContainSubstring(`sum: (arg0, ...arg1) => connection.invoke("Sum", arg0, ...arg1),`))
+			})
+		})
+	})
+})
@@ -4,52 +4,246 @@ import (
 	"crypto/rand"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"golang.org/x/net/websocket"
 	"net/http"
+	"strings"
 )
 
-// MapHub used to register a SignalR Hub with the specified ServeMux
-func MapHub(mux *http.ServeMux, path string, hub HubInterface) {
-	mux.HandleFunc(fmt.Sprintf("%s/negotiate", path), negotiateHandler)
-	server := NewServer(hub)
-	mux.Handle(path, websocket.Handler(func(ws *websocket.Conn) {
-		connectionID := ws.Request().URL.Query().Get("id")
-		if len(connectionID) == 0 {
-			// Support websocket connection without negotiate
-			connectionID = getConnectionID()
-		}
-		server.Run(&webSocketConnection{ws, nil, connectionID})
-	}))
+// MapHub used to register a SignalR Hub with the specified ServeMux.
+// The returned Server can be used to access the HubContext from outside the hub
+func MapHub(mux *http.ServeMux, path string, hub HubInterface, options ...Option) *Server {
+	server := NewServer(hub, options...)
+	mapServer(mux, path, server)
+	return server
 }
 
-func negotiateHandler(w http.ResponseWriter, req *http.Request) {
+// MapBroadcaster registers a broadcaster with the specified ServeMux. See NewBroadcaster
+func MapBroadcaster(mux *http.ServeMux, path string, options ...Option) *Server {
+	server := NewBroadcaster(options...)
+	mapServer(mux, path, server)
+	return server
+}
+
+func mapServer(mux *http.ServeMux, path string, server *Server) {
+	mux.HandleFunc(fmt.Sprintf("%s/negotiate", path), server.negotiateHandler)
+	webSocketServer := websocket.Server{
+		Handshake: func(config *websocket.Config, req *http.Request) (err error) {
+			if config.Origin, err = websocket.Origin(config, req); err == nil && config.Origin == nil {
+				err = fmt.Errorf("null origin")
+			}
+			if err != nil {
+				server.connections.noteFallback(req.URL.Query().Get("id"), FallbackUpgradeFailed, err.Error())
+				return err
+			}
+			// Clients can select the hub protocol by a subprotocol named like it. Other subprotocols are not
+			// accepted, the client falls back to selecting the protocol by the handshake then
+			config.Protocol = server.selectSubprotocol(config.Protocol)
+			return err
+		},
+		Handler: func(ws *websocket.Conn) {
+			connectionID := ws.Request().URL.Query().Get("id")
+			var negotiateHeader http.Header
+			if len(connectionID) == 0 {
+				// Support websocket connection without negotiate
+				connectionID = server.getConnectionID()
+			} else if header, ok := server.connections.claimNegotiated(connectionID); ok {
+				negotiateHeader = header
+			} else if !(server.connectionTakeover && server.connections.isLive(connectionID)) {
+				// The ID has been claimed by another transport connection meanwhile
+				_ = ws.Close()
+				return
+			}
+			conn := &webSocketConnection{
+				requestMetadata: server.newRequestMetadata(ws.Request(), negotiateHeader),
+				ws:              ws,
+				connectionID:    connectionID,
+				writeBufferSize: server.transportOptions.WebSocketWriteBufferSize,
+			}
+			for _, name := range strings.Split(ws.Request().Header.Get("Sec-WebSocket-Protocol"), ",") {
+				if name = strings.TrimSpace(name); name != "" {
+					conn.offeredSubprotocols = append(conn.offeredSubprotocols, name)
+				}
+			}
+			if len(ws.Config().Protocol) == 1 {
+				conn.subprotocol = server.protocols[ws.Config().Protocol[0]]
+			}
+			server.Run(conn)
+		},
+	}
+	mux.HandleFunc(path, func(w http.ResponseWriter, req *http.Request) {
+		if !server.checkAffinity(w, req) {
+			return
+		}
+		transport := TransportLongPolling
+		if strings.EqualFold(req.Header.Get("Upgrade"), "websocket") {
+			transport = TransportWebSockets
+		} else if server.isServerSentEvents(req) {
+			transport = TransportServerSentEvents
+		}
+		if !server.allowsTransport(transport) {
+			http.Error(w, fmt.Sprintf("%v transport not supported by this end point type", transport), 404)
+			return
+		}
+		req, identity, ok := server.authenticateRequest(w, req, req.URL.Query().Get("id"))
+		if !ok {
+			return
+		}
+		if connectionID := req.URL.Query().Get("id"); len(connectionID) > 0 && !server.verifyConnectionID(req, connectionID, identity) {
+			http.Error(w, errUnknownConnectionID, 404)
+			return
+		}
+		if transport == TransportWebSockets {
+			if _, ok := w.(http.Hijacker); !ok || req.ProtoMajor != 1 {
+				// The WebSocket server panics on requests whose connection can not be taken over, e.g. HTTP/2 requests
+				_ = server.logger.Log("event", "websocket upgrade failed", "proto", req.Proto)
+				upgradeErr := fmt.Sprintf("WebSockets need an HTTP/1.1 request, got %v", req.Proto)
+				server.connections.noteFallback(req.URL.Query().Get("id"), FallbackUpgradeFailed, upgradeErr)
+				http.Error(w, upgradeErr, http.StatusHTTPVersionNotSupported)
+				return
+			}
+			// Only connection IDs issued by negotiate are accepted, and each of them only once.
+			// With takeover, the ID of a live connection is accepted, too.
+			// The ID is claimed after the upgrade succeeded, so a client whose upgrade fails
+			// can fall back to long polling with the same ID
+			if connectionID := req.URL.Query().Get("id"); len(connectionID) > 0 {
+				if !server.connections.isNegotiated(connectionID) && !(server.connectionTakeover && server.connections.isLive(connectionID)) {
+					http.Error(w, errUnknownConnectionID, 404)
+					return
+				}
+			} else if !server.filterNegotiate(w, req) {
+				// Connections without negotiate pass the NegotiateFilter when they are started
+				return
+			}
+			if connectionID := req.URL.Query().Get("id"); server.connections.isNegotiated(connectionID) {
+				defer func() {
+					// The Handler claims the ID of an upgraded connection
+					if server.connections.isNegotiated(connectionID) {
+						server.connections.noteFallback(connectionID, FallbackUpgradeFailed, "WebSocket handshake failed")
+					}
+				}()
+			}
+			webSocketServer.ServeHTTP(w, req)
+		} else if transport == TransportServerSentEvents {
+			server.serverSentEventsHandler(w, req)
+		} else {
+			server.longPollingHandler(w, req)
+		}
+	})
+}
+
+// selectSubprotocol returns the first of the subprotocols requested by a WebSocket client which is the name of a
+// hub protocol of the server, or nil if there is none
+func (s *Server) selectSubprotocol(requested []string) []string {
+	for _, name := range requested {
+		if _, ok := s.protocols[name]; ok {
+			return []string{name}
+		}
+	}
+	return nil
+}
+
+func (s *Server) negotiateHandler(w http.ResponseWriter, req *http.Request) {
 	if req.Method != "POST" {
-		w.WriteHeader(400)
+		w.Header().Set("Allow", "POST")
+		w.WriteHeader(405)
+		return
+	}
+
+	if s.connections.isDraining() {
+		w.WriteHeader(503)
+		return
+	}
+
+	req, identity, ok := s.authenticateRequest(w, req, "")
+	if !ok {
+		return
+	}
+
+	if s.connections.atCapacity() {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(429)
+		if err := json.NewEncoder(w).Encode(negotiateErrorResponse{Error: "Too many connections"}); err != nil {
+			_ = s.logger.Log("event", "cannot write negotiate response", "error", err)
+		}
 		return
 	}
 
-	connectionID := getConnectionID()
+	if !s.filterNegotiate(w, req) {
+		return
+	}
+
+	if s.negotiateRedirector != nil {
+		if url, accessToken, redirect := s.negotiateRedirector(req); redirect {
+			if err := json.NewEncoder(w).Encode(negotiateRedirectResponse{URL: url, AccessToken: accessToken}); err != nil {
+				_ = s.logger.Log("event", "cannot write negotiate response", "error", err)
+			}
+			return
+		}
+	}
+
+	connectionID := s.issueConnectionID(req, identity)
+	if s.authentication != nil {
+		s.authentication.remember(req, connectionID, identity)
+	}
+	// The correlation ID is kept with the negotiate headers, to be found by the transport request
+	header := s.selectHeaders(req)
+	correlationID := s.requestCorrelationID(req, nil)
+	header.Set(s.correlationHeader, correlationID)
+	w.Header().Set(s.correlationHeader, correlationID)
+	s.connections.addNegotiated(connectionID, header)
+	s.setAffinity(w, req)
 
 	response := negotiateResponse{
-		ConnectionID: connectionID,
-		AvailableTransports: []availableTransport{
-			{
-				Transport:       "WebSockets",
-				TransferFormats: []string{"Text", "Binary"},
-			},
-		},
+		ConnectionID:        connectionID,
+		AvailableTransports: []availableTransport{},
+	}
+	for _, transport := range []string{TransportWebSockets, TransportServerSentEvents, TransportLongPolling} {
+		if transport == TransportWebSockets && req.ProtoMajor > 1 && !s.webSocketsOverHTTP2 {
+			s.connections.noteFallback(connectionID, FallbackNotOffered, "")
+			continue
+		}
+		if s.allowsTransport(transport) {
+			response.AvailableTransports = append(response.AvailableTransports,
+				availableTransport{Transport: transport, TransferFormats: []string{"Text", "Binary"}})
+		} else if transport == TransportWebSockets {
+			s.connections.noteFallback(connectionID, FallbackNotOffered, "")
+		}
 	}
 
 	if err := json.NewEncoder(w).Encode(response); err != nil {
-		fmt.Println(err)
+		_ = s.logger.Log("event", "cannot write negotiate response", "error", err)
+	}
+}
+
+// filterNegotiate applies the NegotiateFilter to req. It returns false if req has been rejected
+func (s *Server) filterNegotiate(w http.ResponseWriter, req *http.Request) bool {
+	if s.negotiateFilter == nil {
+		return true
+	}
+	err := s.negotiateFilter(req)
+	if err == nil {
+		return true
+	}
+	status := 403
+	var negotiateErr *NegotiateError
+	if errors.As(err, &negotiateErr) {
+		status = negotiateErr.StatusCode
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(negotiateErrorResponse{Error: err.Error()}); err != nil {
+		_ = s.logger.Log("event", "cannot write negotiate response", "error", err)
 	}
+	return false
 }
 
-func getConnectionID() string {
+// getConnectionID returns a new random ID, for connections, correlations, session tokens and scheduled sends
+func (s *Server) getConnectionID() string {
 	bytes := make([]byte, 16)
 	if _, err := rand.Read(bytes); err != nil {
-		fmt.Println(err)
+		_ = s.logger.Log("event", "cannot read random bytes", "error", err)
 	}
 	return base64.StdEncoding.EncodeToString(bytes)
 }
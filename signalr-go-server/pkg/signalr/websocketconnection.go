@@ -3,20 +3,66 @@ package signalr
 import (
 	"bytes"
 	"golang.org/x/net/websocket"
+	"time"
 )
 
 type webSocketConnection struct {
+	requestMetadata
 	ws           *websocket.Conn
 	r            *bytes.Reader
 	connectionID string
+	// subprotocol is the hub protocol selected by the WebSocket subprotocol, nil if the handshake selects it
+	subprotocol HubProtocol
+	// offeredSubprotocols are the WebSocket subprotocols the client offered, in its order
+	offeredSubprotocols []string
+	// writeBuf collects written messages until they are flushed as one frame, if writeBufferSize is not 0.
+	// The writes of a connection are serialized by its hubConnection, so writeBuf needs no lock
+	writeBuf        []byte
+	writeBufferSize int
 }
 
 func (w *webSocketConnection) ConnectionID() string {
 	return w.connectionID
 }
 
+func (w *webSocketConnection) selectedProtocol() HubProtocol {
+	return w.subprotocol
+}
+
+// requestFeatures returns the features of the request which started the connection and of the transport
+func (w *webSocketConnection) requestFeatures() map[string]interface{} {
+	features := map[string]interface{}{FeatureTransport: TransportWebSockets, FeatureBinary: true}
+	for name, value := range w.features {
+		features[name] = value
+	}
+	return features
+}
+
+// Write sends p as one frame, or collects it for the next frame if the connection has a writeBufferSize
 func (w *webSocketConnection) Write(p []byte) (n int, err error) {
-	return w.ws.Write(p)
+	if w.writeBufferSize <= 0 {
+		return w.ws.Write(p)
+	}
+	if len(w.writeBuf)+len(p) > w.writeBufferSize {
+		if err = w.flush(); err != nil {
+			return 0, err
+		}
+		if len(p) > w.writeBufferSize {
+			return w.ws.Write(p)
+		}
+	}
+	w.writeBuf = append(w.writeBuf, p...)
+	return len(p), nil
+}
+
+// flush sends the collected messages as one frame
+func (w *webSocketConnection) flush() error {
+	if len(w.writeBuf) == 0 {
+		return nil
+	}
+	_, err := w.ws.Write(w.writeBuf)
+	w.writeBuf = w.writeBuf[:0]
+	return err
 }
 
 func (w *webSocketConnection) Read(p []byte) (n int, err error) {
@@ -30,3 +76,18 @@ func (w *webSocketConnection) Read(p []byte) (n int, err error) {
 	}
 	return w.r.Read(p)
 }
+
+func (w *webSocketConnection) Close() error {
+	return w.ws.Close()
+}
+
+func (w *webSocketConnection) SetWriteDeadline(t time.Time) error {
+	return w.ws.SetWriteDeadline(t)
+}
+
+// setTransferFormat switches the connection to binary frames for binary hub protocols
+func (w *webSocketConnection) setTransferFormat(format string) {
+	if format == "Binary" {
+		w.ws.PayloadType = websocket.BinaryFrame
+	}
+}
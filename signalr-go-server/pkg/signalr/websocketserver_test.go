@@ -0,0 +1,160 @@
+package signalr
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func negotiate(mux *http.ServeMux, path string) map[string]interface{} {
+	recorder := httptest.NewRecorder()
+	mux.ServeHTTP(recorder, httptest.NewRequest("POST", path+"/negotiate", nil))
+	Expect(recorder.Code).To(Equal(200))
+	var response map[string]interface{}
+	Expect(json.Unmarshal(recorder.Body.Bytes(), &response)).To(Succeed())
+	return response
+}
+
+var _ = Describe("Negotiate", func() {
+
+	Describe("Default negotiate", func() {
+		mux := http.NewServeMux()
+		MapHub(mux, "/hub", &contextHub{})
+		Context("When the client negotiates", func() {
+			It("should return a connection ID and the available transports", func() {
+				response := negotiate(mux, "/hub")
+				Expect(response["connectionId"]).NotTo(BeEmpty())
+				Expect(response["availableTransports"]).NotTo(BeEmpty())
+				Expect(response).NotTo(HaveKey("url"))
+			})
+		})
+	})
+
+	Describe("Negotiate over HTTP/2", func() {
+		mux := http.NewServeMux()
+		MapHub(mux, "/hub", &contextHub{}, OfferWebSocketsOverHTTP2(false))
+		transports := func(protoMajor int) []interface{} {
+			recorder := httptest.NewRecorder()
+			req := httptest.NewRequest("POST", "/hub/negotiate", nil)
+			req.ProtoMajor = protoMajor
+			mux.ServeHTTP(recorder, req)
+			var response map[string]interface{}
+			Expect(json.Unmarshal(recorder.Body.Bytes(), &response)).To(Succeed())
+			var names []interface{}
+			for _, transport := range response["availableTransports"].([]interface{}) {
+				names = append(names, transport.(map[string]interface{})["transport"])
+			}
+			return names
+		}
+		Context("When WebSockets are not offered over HTTP/2", func() {
+			It("should offer them to HTTP/1.1 requests only", func() {
+				Expect(transports(1)).To(Equal([]interface{}{"WebSockets", "ServerSentEvents", "LongPolling"}))
+				Expect(transports(2)).To(Equal([]interface{}{"ServerSentEvents", "LongPolling"}))
+			})
+		})
+		Context("When a WebSocket upgrade is requested over a connection which can not be taken over", func() {
+			It("should answer with an error", func() {
+				recorder := httptest.NewRecorder()
+				req := httptest.NewRequest("GET", "/hub", nil)
+				req.Header.Set("Upgrade", "websocket")
+				req.Header.Set("Connection", "Upgrade")
+				mux.ServeHTTP(recorder, req)
+				Expect(recorder.Code).To(Equal(http.StatusHTTPVersionNotSupported))
+				Expect(recorder.Body.String()).To(ContainSubstring("WebSockets need an HTTP/1.1 request"))
+			})
+		})
+	})
+
+	Describe("Redirected negotiate", func() {
+		mux := http.NewServeMux()
+		MapHub(mux, "/hub", &contextHub{}, NegotiateRedirect(func(req *http.Request) (string, string, bool) {
+			return "https://other.example.com/hub", "token", true
+		}))
+		Context("When the client negotiates", func() {
+			It("should return the redirect url and access token", func() {
+				response := negotiate(mux, "/hub")
+				Expect(response["url"]).To(Equal("https://other.example.com/hub"))
+				Expect(response["accessToken"]).To(Equal("token"))
+				Expect(response).NotTo(HaveKey("connectionId"))
+			})
+		})
+	})
+
+	Describe("Filtered negotiate", func() {
+		mux := http.NewServeMux()
+		MapHub(mux, "/hub", &contextHub{}, OnNegotiate(func(req *http.Request) error {
+			switch req.URL.Query().Get("client") {
+			case "banned":
+				return errors.New("banned")
+			case "busy":
+				return &NegotiateError{StatusCode: 503, Message: "capacity exceeded"}
+			}
+			return nil
+		}))
+		reject := func(client string) (int, string) {
+			recorder := httptest.NewRecorder()
+			mux.ServeHTTP(recorder, httptest.NewRequest("POST", "/hub/negotiate?client="+client, nil))
+			return recorder.Code, recorder.Body.String()
+		}
+		Context("When the filter rejects the request with an error", func() {
+			It("should answer with 403 and the error", func() {
+				code, body := reject("banned")
+				Expect(code).To(Equal(403))
+				Expect(body).To(MatchJSON(`{"error":"banned"}`))
+			})
+		})
+		Context("When the filter rejects the request with a NegotiateError", func() {
+			It("should answer with its status code and message", func() {
+				code, body := reject("busy")
+				Expect(code).To(Equal(503))
+				Expect(body).To(MatchJSON(`{"error":"capacity exceeded"}`))
+			})
+		})
+		Context("When the filter accepts the request", func() {
+			It("should issue a connection ID", func() {
+				Expect(negotiate(mux, "/hub")["connectionId"]).NotTo(BeEmpty())
+			})
+		})
+	})
+
+	Describe("Negotiate with an affinity cookie", func() {
+		mux := http.NewServeMux()
+		MapHub(mux, "/hub", &contextHub{}, AffinityCookie("node", "node-a"))
+		Context("When the client negotiates", func() {
+			It("should set the cookie with the name of the node", func() {
+				recorder := httptest.NewRecorder()
+				mux.ServeHTTP(recorder, httptest.NewRequest("POST", "/hub/negotiate", nil))
+				cookies := recorder.Result().Cookies()
+				Expect(cookies).To(HaveLen(1))
+				Expect(cookies[0].Name).To(Equal("node"))
+				Expect(cookies[0].Value).To(Equal("node-a"))
+				Expect(cookies[0].Path).To(Equal("/hub"))
+			})
+		})
+		Context("When a transport request with the cookie of another node arrives", func() {
+			It("should answer with 409 and describe the misrouting", func() {
+				req := httptest.NewRequest("GET", "/hub?id=elsewhere", nil)
+				req.AddCookie(&http.Cookie{Name: "node", Value: "node-b"})
+				recorder := httptest.NewRecorder()
+				mux.ServeHTTP(recorder, req)
+				Expect(recorder.Code).To(Equal(409))
+				Expect(recorder.Body.String()).To(ContainSubstring(`negotiated by node "node-b" but its request reached node "node-a"`))
+			})
+		})
+		Context("When a transport request with the cookie of this node arrives", func() {
+			It("should be handled by the transport", func() {
+				connectionID := negotiate(mux, "/hub")["connectionId"].(string)
+				req := httptest.NewRequest("GET", "/hub?id="+url.QueryEscape(connectionID), nil)
+				req.AddCookie(&http.Cookie{Name: "node", Value: "node-a"})
+				recorder := httptest.NewRecorder()
+				mux.ServeHTTP(recorder, req)
+				Expect(recorder.Code).To(Equal(200))
+			})
+		})
+	})
+})
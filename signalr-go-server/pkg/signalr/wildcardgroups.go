@@ -0,0 +1,134 @@
+package signalr
+
+import (
+	"sort"
+	"strings"
+)
+
+// WildcardGroups makes group names hierarchical, with segments separated by dots like "building.3.floor.2".
+// Invocations of groups, e.g. with Clients().Group(), can then address many groups with a pattern:
+// a segment "*" matches any one segment, so "building.3.floor.*" are all floors of building 3, and a last segment
+// "**" matches one or more segments, so "building.**" are all groups below all buildings. Each connection
+// gets the invocation once, even if it is a member of several matching groups. The app does not need to track the
+// concrete groups, they are kept in a tree by their segments. By default, group names are not interpreted
+func WildcardGroups() Option {
+	return func(s *Server) {
+		s.wildcardGroups = true
+	}
+}
+
+// groupNode is a node of the tree of group names by their segments
+type groupNode struct {
+	children map[string]*groupNode
+	// group tells that there is a group named by the segments of the path to the node
+	group bool
+}
+
+// insert adds the group named by segments to the tree below n
+func (n *groupNode) insert(segments []string) {
+	for _, segment := range segments {
+		child, ok := n.children[segment]
+		if !ok {
+			if n.children == nil {
+				n.children = make(map[string]*groupNode)
+			}
+			child = &groupNode{}
+			n.children[segment] = child
+		}
+		n = child
+	}
+	n.group = true
+}
+
+// remove removes the group named by segments from the tree below n, with the nodes which lead to no other group
+func (n *groupNode) remove(segments []string) {
+	if len(segments) == 0 {
+		n.group = false
+		return
+	}
+	child, ok := n.children[segments[0]]
+	if !ok {
+		return
+	}
+	child.remove(segments[1:])
+	if !child.group && len(child.children) == 0 {
+		delete(n.children, segments[0])
+	}
+}
+
+// match appends the names of the groups below n which match pattern to names. prefix is the group name of n
+func (n *groupNode) match(pattern []string, prefix string, names []string) []string {
+	if len(pattern) == 0 {
+		if n.group {
+			names = append(names, prefix)
+		}
+		return names
+	}
+	switch {
+	case pattern[0] == "**" && len(pattern) == 1:
+		for segment, child := range n.children {
+			names = child.all(childName(prefix, segment), names)
+		}
+	case pattern[0] == "*":
+		for segment, child := range n.children {
+			names = child.match(pattern[1:], childName(prefix, segment), names)
+		}
+	default:
+		if child, ok := n.children[pattern[0]]; ok {
+			names = child.match(pattern[1:], childName(prefix, pattern[0]), names)
+		}
+	}
+	return names
+}
+
+// all appends the names of n and all groups below it to names. prefix is the group name of n
+func (n *groupNode) all(prefix string, names []string) []string {
+	if n.group {
+		names = append(names, prefix)
+	}
+	for segment, child := range n.children {
+		names = child.all(childName(prefix, segment), names)
+	}
+	return names
+}
+
+func childName(prefix string, segment string) string {
+	if prefix == "" {
+		return segment
+	}
+	return prefix + "." + segment
+}
+
+// isGroupPattern returns if groupName has a wildcard segment
+func isGroupPattern(groupName string) bool {
+	for _, segment := range strings.Split(groupName, ".") {
+		if segment == "*" || segment == "**" {
+			return true
+		}
+	}
+	return false
+}
+
+// expand replaces the patterns in groupNames by the names of the groups matching them, sorted.
+// Each group is returned once. Without a tree, the names are returned as they are
+func (r *groupRegistry) expand(groupNames []string) []string {
+	if r.tree == nil {
+		return groupNames
+	}
+	var names []string
+	seen := make(map[string]bool)
+	for _, groupName := range groupNames {
+		matched := []string{groupName}
+		if isGroupPattern(groupName) {
+			matched = r.tree.match(strings.Split(groupName, "."), "", nil)
+			sort.Strings(matched)
+		}
+		for _, name := range matched {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
@@ -0,0 +1,76 @@
+package signalr
+
+import "sync"
+
+// writeLane is the priority of a message waiting to be written to a connection, lower lanes are written first
+type writeLane int
+
+const (
+	// laneControl are pings and close messages, which tell the client that the connection is alive or ends
+	laneControl writeLane = iota
+	// laneInteractive are completions, stream items and invocations which wait for a client result
+	laneInteractive
+	// laneBulk are the invocations sent by hubs, e.g. broadcasts
+	laneBulk
+	writeLanes
+)
+
+// messageLane returns the lane of message
+func messageLane(message interface{}) writeLane {
+	if isControlMessage(message) {
+		return laneControl
+	}
+	if expires(message) {
+		return laneBulk
+	}
+	return laneInteractive
+}
+
+// laneLock serializes the writes to a connection. When the write in progress ends, the next write is taken
+// from the highest lane with waiting writes, so a backlog of broadcasts does not delay pings and completions
+// until the client times out. Writes of the same lane are written in the order they arrived
+type laneLock struct {
+	mx      sync.Mutex
+	busy    bool
+	waiting [writeLanes][]chan struct{}
+}
+
+func (l *laneLock) lock(lane writeLane) {
+	l.mx.Lock()
+	if !l.busy {
+		l.busy = true
+		l.mx.Unlock()
+		return
+	}
+	turn := make(chan struct{})
+	l.waiting[lane] = append(l.waiting[lane], turn)
+	l.mx.Unlock()
+	<-turn
+}
+
+func (l *laneLock) unlock() {
+	l.mx.Lock()
+	defer l.mx.Unlock()
+	for lane := range l.waiting {
+		if waiting := l.waiting[lane]; len(waiting) > 0 {
+			// The lock passes to the next write without being released
+			close(waiting[0])
+			waiting[0] = nil
+			l.waiting[lane] = waiting[1:]
+			return
+		}
+	}
+	l.busy = false
+}
+
+// contended returns if writes are waiting for the lock
+func (l *laneLock) contended() bool {
+	l.mx.Lock()
+	defer l.mx.Unlock()
+	for _, waiting := range l.waiting {
+		if len(waiting) > 0 {
+			return true
+		}
+	}
+	return false
+}